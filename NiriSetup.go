@@ -1,17 +1,33 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	runtimedebug "runtime/debug"
+	"slices"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"NiriSetup/internal/niri"
+	"NiriSetup/nirisetup"
 )
 
 type appState int
@@ -20,17 +36,327 @@ const (
 	menuView appState = iota
 	installView
 	actionView
+	confirmView
+	choiceView
+	editView
+	textView
+	inputView
+	pickView
+	summaryView
 )
 
 type model struct {
-	state        appState
-	choices      []string
-	cursor       int
-	selected     string
-	logs         []string
-	isProcessing bool
-	progress     string
-	actionMsg    string
+	state          appState
+	choices        []string
+	cursor         int
+	filter         string // the menu shows only the entries containing it
+	filtering      bool   // keys type into filter
+	selected       string
+	logs           []string
+	logTimes       []time.Time // when each of logs was logged
+	logKinds       []logKind   // what each of logs reports
+	logLimit       int         // --log-lines: most log lines kept in memory, 0 for the default
+	spilled        int         // log lines moved out of memory to the log file
+	spillErr       error
+	isProcessing   bool
+	install        installProgress // how far the install in installView has got
+	bar            progress.Model  // draws installView's progress bars
+	currentPackage string          // what pkg is installing right now, "" between runs
+	installStart   time.Time       // when the install in installView started
+	outcomes       map[string]int  // how many of its packages ended ok, cached, skipped or failed
+	actionMsg      string
+	actionErr      string        // actionMsg, when it reports a failure
+	actionOutput   []string      // the newest lines the running action printed
+	spinner        spinner.Model // turns in actionView so a slow action does not look hung
+	confirm        confirmation
+	autoYes        bool            // --yes: answer every confirmation with yes
+	force          bool            // --force: let --yes accept destructive confirmations too
+	noConfirmQuit  bool            // --no-confirm-quit: quit or cancel at once while an action runs
+	confirmingQuit bool            // asking whether to quit while an action runs
+	dryRun         bool            // --dry-run: show commands and config changes instead of making them
+	ctx            context.Context // what the commands of the running action hand niri
+	preview        *changeLog      // what the running action would change, under --dry-run
+	choice         choice
+	editor         textarea.Model
+	editErr        string            // why the last save from editView was refused
+	pause          *pauser           // holds the running install between two pkg runs
+	pausing        bool              // a pause was asked for with p
+	cancelling     bool              // ctrl+c asked the install to stop
+	remaining      []string          // packages left to install while paused
+	failed         []string          // packages the last install did not install, for a retry
+	installFiles   map[string]string // local package files of the last install
+	textTitle      string
+	textBody       string         // what textView shows, before it is wrapped to the terminal
+	text           viewport.Model // long output shown in textView
+	textConfirm    *confirmation  // what y in textView runs, if it asks a question
+	logView        viewport.Model // the logs in installView, scrollable
+	prompt         prompt
+	pick           packagePick
+	profile        string            // active config profile, "" for none
+	versionWarn    string            // why the installed niri is too old, if it is
+	memoryWarn     string            // why the machine is short of RAM, if it is
+	help           string            // the menu entry whose help is shown, if any
+	notice         string            // what changed since the last NiriSetup, until dismissed
+	insideNiri     bool              // running inside a live niri session
+	unchecked      int               // config writes since the last automatic validation
+	undo           string            // backup from before the first of those writes
+	saved          map[string]string // the settings file, as Settings changes it
+	termWidth      int               // of the terminal, 0 until it says
+	termHeight     int
+	cmds           commands
+}
+
+// choice is a question answered by picking one of several options in
+// choiceView.
+type choice struct {
+	question string
+	options  []option
+	cursor   int
+}
+
+// prompt is a question answered by typing a line in inputView.
+type prompt struct {
+	question string
+	field    textinput.Model
+	submit   func(answer string) tea.Msg // the next step, given the answer
+}
+
+// ask for a line of text in inputView, handing it to submit.
+func (m model) askText(question, placeholder string, submit func(string) tea.Msg) (model, tea.Cmd) {
+	m.state = inputView
+	m.prompt = prompt{question: question, field: textinput.New(), submit: submit}
+	m.prompt.field.Placeholder = placeholder
+	m.prompt.field.Width = m.fitWidth(editorWidth) - 4
+	return m, m.prompt.field.Focus()
+}
+
+// option is one answer to a choice. Picking an option without a command
+// cancels back to the menu.
+type option struct {
+	label   string
+	working string // action message shown while run is in progress
+	run     tea.Cmd
+}
+
+// confirmation is a yes/no question shown in confirmView, along with the
+// action to run if the user answers yes.
+type confirmation struct {
+	question string
+	working  string // action message shown while run is in progress
+	declined string // shown in the menu on no, "Cancelled." if empty
+	run      tea.Cmd
+
+	// destructive confirmations are only auto-accepted with --force on top
+	// of --yes.
+	destructive bool
+}
+
+// bugReportMsg is the path of a freshly written bug report.
+type bugReportMsg struct {
+	path string
+	err  error
+}
+
+// textMsg carries long output to show in textView.
+type textMsg struct {
+	title string
+	text  string
+	err   error
+}
+
+// commands holds the constructors for every menu action. The TUI only calls
+// them through the model so tests can swap in stubs that never touch the
+// system.
+type commands struct {
+	choices       func() []string
+	check         func() tea.Cmd
+	install       func(pkgs []string, files map[string]string, dryRun bool, pause *pauser) tea.Cmd
+	scanCache     func(dir string) tea.Cmd
+	script        func(ctx context.Context) tea.Cmd
+	deps          func() tea.Cmd
+	toolsets      func() tea.Cmd
+	configure     func(ctx context.Context, ts niri.Toolset, tools []string) tea.Cmd
+	planConfigure func(ts niri.Toolset, tools []string) tea.Cmd
+	swayFiles     func() tea.Cmd
+	importSway    func(ctx context.Context, path string) tea.Cmd
+	clipboard     func(ctx context.Context, key string) tea.Cmd
+	xwayland      func(ctx context.Context) tea.Cmd
+	xwaylandState func() tea.Cmd
+	noXwayland    func(ctx context.Context) tea.Cmd
+	outputs       func() tea.Cmd
+	scale         func(ctx context.Context, output string, scale float64) tea.Cmd
+	cursors       func() tea.Cmd
+	cursorPkg     func(ctx context.Context) tea.Cmd
+	cursor        func(ctx context.Context, theme string, size int) tea.Cmd
+	nightLight    func(ctx context.Context, day, night, transition int) tea.Cmd
+	screenshots   func(ctx context.Context) tea.Cmd
+	brightness    func(ctx context.Context) tea.Cmd
+	mediaKeys     func(ctx context.Context) tea.Cmd
+	idle          func(ctx context.Context) tea.Cmd
+	polkits       func() tea.Cmd
+	polkit        func(ctx context.Context, agent niri.PolkitAgent) tea.Cmd
+	portal        func(ctx context.Context) tea.Cmd
+	allDefaults   func(ctx context.Context) tea.Cmd
+	audioChecks   func() tea.Cmd
+	audio         func(ctx context.Context) tea.Cmd
+	inputMethod   func(ctx context.Context, im niri.InputMethod) tea.Cmd
+	rules         func() tea.Cmd
+	addRule       func(ctx context.Context, field, pattern, property string) tea.Cmd
+	appearance    func(ctx context.Context, look niri.Appearance) tea.Cmd
+	removeRule    func(ctx context.Context, rule niri.WindowRule) tea.Cmd
+	workspaceApps func() tea.Cmd
+	behavior      func() tea.Cmd
+	setBehavior   func(ctx context.Context, b niri.InputBehavior) tea.Cmd
+	setWorkspace  func(ctx context.Context, app niri.WorkspaceApp) tea.Cmd
+	dropWorkspace func(ctx context.Context, app niri.WorkspaceApp) tea.Cmd
+	validate      func() tea.Cmd
+	repair        func() tea.Cmd
+	repairSetup   func(ctx context.Context) tea.Cmd
+	audit         func() tea.Cmd
+	restore       func(ctx context.Context, backup string) tea.Cmd
+	autoValidate  func(undo string) tea.Cmd
+	profiles      func() tea.Cmd
+	useProfile    func(ctx context.Context, name string) tea.Cmd
+	saveProfile   func(ctx context.Context, name string) tea.Cmd
+	defaults      func(ctx context.Context) tea.Cmd
+	mirrors       func() tea.Cmd
+	useMirror     func(ctx context.Context, url string) tea.Cmd
+	doctor        func() tea.Cmd
+	systemCheck   func() tea.Cmd
+	launchChecks  func() tea.Cmd
+	launch        func(ctx context.Context) tea.Cmd
+	session       func(ctx context.Context) tea.Cmd
+	launchLog     func() tea.Cmd
+	start         func(ctx context.Context) tea.Cmd
+	autostart     func(ctx context.Context) tea.Cmd
+	loginDMs      func() tea.Cmd
+	login         func(ctx context.Context, dm niri.DisplayManager) tea.Cmd
+	packages      func() tea.Cmd
+	remove        func(ctx context.Context, pkg string) tea.Cmd
+	setupPkgs     func() tea.Cmd
+	uninstall     func(ctx context.Context, pkgs []string) tea.Cmd
+	processes     func() tea.Cmd
+	kill          func(ctx context.Context, p niri.Process) tea.Cmd
+	restart       func(ctx context.Context, p niri.Process) tea.Cmd
+	verify        func() tea.Cmd
+	upgrade       func(ctx context.Context) tea.Cmd
+	reload        func(ctx context.Context) tea.Cmd
+	keybinds      func() tea.Cmd
+	manPage       func(name string) tea.Cmd
+	configPaths   func() tea.Cmd
+	lock          func() error
+	acknowledge   func() error
+	saveSettings  func(settings map[string]string) error
+	reinstall     func(ctx context.Context, pkgs []string) tea.Cmd
+	backups       func() tea.Cmd
+	compare       func(a, b string) tea.Cmd
+	edit          func() tea.Cmd
+	editorBackup  func(ctx context.Context) tea.Cmd
+	openEditor    func(ctx context.Context) tea.Cmd
+	saveEdit      func(ctx context.Context, text string) tea.Cmd
+	saveLogs      func(model) tea.Cmd
+	logFile       logStore
+	bugReport     func(model) tea.Cmd
+	showFile      func(path string) tea.Cmd
+	now           func() time.Time
+	spin          func(s spinner.Model) tea.Cmd // starts the spinner of actionView
+	cancel        func()
+}
+
+var defaultCommands = commands{
+	choices:       menuChoices,
+	check:         checkPackages,
+	install:       installNiri,
+	scanCache:     scanPackageCache,
+	script:        writeInstallScript,
+	deps:          showDependencies,
+	toolsets:      checkToolset,
+	configure:     configureNiri,
+	planConfigure: planConfigure,
+	swayFiles:     findSwayConfigs,
+	importSway:    importSwayConfig,
+	clipboard:     configureClipboard,
+	xwayland:      configureXWayland,
+	xwaylandState: checkXWayland,
+	noXwayland:    disableXWayland,
+	outputs:       detectOutputs,
+	scale:         setOutputScale,
+	cursors:       listCursorThemes,
+	cursorPkg:     installCursorTheme,
+	cursor:        configureCursor,
+	nightLight:    configureNightLight,
+	screenshots:   configureScreenshots,
+	brightness:    configureBrightness,
+	mediaKeys:     configureMediaKeys,
+	idle:          configureIdle,
+	polkits:       detectPolkitAgents,
+	polkit:        configurePolkitAgent,
+	portal:        configurePortal,
+	allDefaults:   generateDefaults,
+	audioChecks:   checkAudio,
+	audio:         configureAudio,
+	inputMethod:   configureInputMethod,
+	rules:         listWindowRules,
+	addRule:       addWindowRule,
+	appearance:    configureAppearance,
+	removeRule:    removeWindowRule,
+	workspaceApps: listWorkspaceApps,
+	behavior:      readInputBehavior,
+	setBehavior:   configureInputBehavior,
+	setWorkspace:  setWorkspaceApp,
+	dropWorkspace: removeWorkspaceApp,
+	validate:      validateNiriConfig,
+	repair:        checkConfigForRepair,
+	repairSetup:   repairSetup,
+	audit:         auditConfig,
+	restore:       restoreConfigBackup,
+	autoValidate:  validateWrittenConfig,
+	profiles:      listProfiles,
+	useProfile:    switchProfile,
+	saveProfile:   saveProfile,
+	defaults:      restoreDefaultConfig,
+	mirrors:       benchmarkMirrors,
+	useMirror:     useMirror,
+	doctor:        runDoctor,
+	systemCheck:   systemCheck,
+	launchChecks:  checkLaunch,
+	launch:        launchNiri,
+	session:       runSession,
+	launchLog:     showLaunchLog,
+	start:         writeStartScript,
+	autostart:     setUpAutostart,
+	loginDMs:      detectDisplayManagers,
+	login:         setUpDisplayManager,
+	packages:      listPackages,
+	remove:        removePackage,
+	setupPkgs:     listSetupPackages,
+	uninstall:     uninstallNiri,
+	processes:     listProcesses,
+	kill:          killProcess,
+	restart:       restartProcess,
+	verify:        verifyPackages,
+	upgrade:       upgradePackages,
+	reload:        reloadConfig,
+	keybinds:      showKeybinds,
+	manPage:       showManPage,
+	configPaths:   showConfigPaths,
+	lock:          niri.Lock,
+	acknowledge:   niri.AcknowledgeChanges,
+	saveSettings:  niri.SaveSettings,
+	reinstall:     reinstallPackages,
+	backups:       listBackups,
+	compare:       compareBackups,
+	edit:          loadConfigForEditing,
+	editorBackup:  backUpForEditor,
+	openEditor:    openEditor,
+	saveEdit:      saveEditedConfig,
+	saveLogs:      saveLogsToFile,
+	logFile:       fileLog{},
+	bugReport:     writeBugReport,
+	showFile:      showFile,
+	now:           time.Now,
+	spin:          func(s spinner.Model) tea.Cmd { return s.Tick },
+	cancel:        niri.CancelCommands,
 }
 
 // Set consistent height and width for all views
@@ -38,113 +364,760 @@ const viewHeight = 12
 const viewWidth = 50
 const menuItemWidth = 25 // Adjusted width for better alignment
 
-// Styles
-var (
+// The config editor needs more room than the other views
+const editorWidth = 80
+const editorHeight = 20
+
+// installLogHeight is how many log lines installView shows at a time.
+const installLogHeight = 12
+
+// minViewWidth is as narrow as the views get on a small terminal, which
+// wraps them below that.
+const minViewWidth = 30
+
+// installChrome and textChrome are how many lines installView and textView
+// take besides the log and the text, which get the rest of a terminal.
+const installChrome = 19
+const textChrome = 4
+
+// actionOutputLines is how many of the newest lines a running action
+// printed actionView shows under it.
+const actionOutputLines = 6
+const editorMaxLines = 9999 // textarea refuses new lines past this
+
+// defaultLogLimit is how many log lines are kept in memory unless
+// --log-lines says otherwise.
+const defaultLogLimit = 2000
+
+// defaultTheme is the palette used for the colors niri.Theme leaves
+// out, a darker variant of each on light terminals.
+var defaultTheme = struct {
+	accent, text, dim, success, warning, failure, border lipgloss.TerminalColor
+}{
+	accent:  lipgloss.AdaptiveColor{Light: "#008700", Dark: "#00ff00"},
+	text:    lipgloss.AdaptiveColor{Light: "56", Dark: "63"},
+	dim:     lipgloss.AdaptiveColor{Light: "245", Dark: "240"},
+	success: lipgloss.AdaptiveColor{Light: "#008700", Dark: "#00ff00"},
+	warning: lipgloss.AdaptiveColor{Light: "136", Dark: "220"},
+	failure: lipgloss.AdaptiveColor{Light: "160", Dark: "203"},
+	border:  lipgloss.NoColor{},
+}
+
+// themeColor is color, or fallback when the theme leaves it out.
+func themeColor(color string, fallback lipgloss.TerminalColor) lipgloss.TerminalColor {
+	if color == "" {
+		return fallback
+	}
+	return lipgloss.Color(color)
+}
+
+// applyTheme sets the styles of every view from t.
+func applyTheme(t niri.Theme) {
 	// Title style
 	titleStyle = lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("#00ff00")). // Green color
+		Foreground(themeColor(t.Accent, defaultTheme.accent)).
 		Padding(1, 2).
 		Align(lipgloss.Center).
 		Width(viewWidth). // Set consistent width
 		Height(2)         // Reduced height for title area
 
-	// Menu style with consistent padding for all menu items
-	menuStyle = lipgloss.NewStyle().
-		Align(lipgloss.Left).
-		Width(viewWidth)
-
 	// Cursor style
-	cursorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#00ff00")).Bold(true)
+	cursorStyle = lipgloss.NewStyle().Foreground(themeColor(t.Accent, defaultTheme.accent)).Bold(true)
 
 	// Dimmed style for non-selected options
-	disabledStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	disabledStyle = lipgloss.NewStyle().Foreground(themeColor(t.Dim, defaultTheme.dim))
 
 	// Log and action message styles
-	logStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("63")).Padding(1, 2).Width(viewWidth)
-	actionStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#00ff00")).Padding(1, 2).Align(lipgloss.Center).Width(viewWidth)
+	logStyle = lipgloss.NewStyle().Foreground(themeColor(t.Text, defaultTheme.text)).Padding(1, 2).Width(viewWidth)
+	actionStyle = lipgloss.NewStyle().Bold(true).Foreground(themeColor(t.Success, defaultTheme.success)).Padding(1, 2).Align(lipgloss.Center).Width(viewWidth)
+	errorStyle = actionStyle.Foreground(themeColor(t.Error, defaultTheme.failure))
+
+	// Log lines by kind; plain ones keep the color of logStyle
+	logKindStyles = map[logKind]lipgloss.Style{
+		kindSuccess: lipgloss.NewStyle().Foreground(themeColor(t.Success, defaultTheme.success)),
+		kindWarning: lipgloss.NewStyle().Foreground(themeColor(t.Warning, defaultTheme.warning)),
+		kindError:   lipgloss.NewStyle().Foreground(themeColor(t.Error, defaultTheme.failure)),
+	}
+
+	// Frame of the help the ? key shows
+	helpStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(themeColor(t.Border, defaultTheme.border)).Padding(0, 1).Width(viewWidth)
+}
+
+// Styles, set by applyTheme
+var (
+	titleStyle, cursorStyle, disabledStyle, logStyle, actionStyle, errorStyle, helpStyle lipgloss.Style
+	logKindStyles                                                                        map[logKind]lipgloss.Style
+
+	// Menu style with consistent padding for all menu items
+	menuStyle = lipgloss.NewStyle().
+			Align(lipgloss.Left).
+			Width(viewWidth)
 )
 
+func init() { applyTheme(niri.Theme{}) }
+
 type statusMsg struct {
 	status string
 	err    error
+	failed []string // packages an install did not get installed
+}
+
+// version and commit say which build this is. Release builds set them with
+// -ldflags "-X main.version=... -X main.commit=..."; otherwise commit comes
+// from the VCS information Go records.
+var (
+	version = niri.Version
+	commit  string
+)
+
+// versionLine is what --version prints and bug reports start with.
+func versionLine() string {
+	rev := commit
+	if info, ok := runtimedebug.ReadBuildInfo(); ok && rev == "" {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				rev = s.Value[:min(len(s.Value), 12)]
+			}
+		}
+	}
+	return fmt.Sprintf("NiriSetup %s (commit %s, %s)", version, cmp.Or(rev, "unknown"), runtime.Version())
+}
+
+// rootWarning is what NiriSetup warns at startup when it runs as root,
+// by sudo or doas for user if that is who ran it: it then needs neither
+// itself, but sets the desktop up for root instead of anyone's own login.
+func rootWarning(user string) string {
+	if user != "" {
+		return trf("Warning: NiriSetup is running as root, so the niri config and session are root's, not %s's. Run it as %s instead; it uses sudo or doas itself where it needs root.", user, user)
+	}
+	return tr("Warning: NiriSetup is running as root, so the niri config and session are root's. Run it as the user who will log in to niri instead; it uses sudo or doas itself where it needs root.")
+}
+
+// traceMsg is a command line about to be run, reported in debug mode.
+type traceMsg string
+
+// debug makes every external command get logged before it runs. It is set
+// by --log-level debug, --debug or a non-empty DEBUG environment variable.
+var debug bool
+
+// logLevel ranks log entries, from the ones that always matter down to the
+// command trace.
+type logLevel int
+
+const (
+	levelError logLevel = iota
+	levelWarn
+	levelInfo
+	levelDebug
+)
+
+func (l logLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("logLevel(%d)", int(l))
+}
+
+// levelNames are the names of the log levels, as --log-level takes them.
+var levelNames = map[logLevel]string{levelError: "error", levelWarn: "warn", levelInfo: "info", levelDebug: "debug"}
+
+// parseLogLevel returns the level called name in levelNames.
+func parseLogLevel(name string) (logLevel, bool) {
+	for level, n := range levelNames {
+		if n == name {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// logKind is what a log line reports, which the install view shows in
+// its own color.
+type logKind int
+
+const (
+	kindPlain logKind = iota
+	kindSuccess
+	kindWarning
+	kindError
+)
+
+// kindOf is the kind of a line logged at level about a package that ended
+// with status, if any.
+func kindOf(level logLevel, status string) logKind {
+	switch {
+	case level == levelError || status == "failed":
+		return kindError
+	case level == levelWarn:
+		return kindWarning
+	case status == "ok" || status == "cached":
+		return kindSuccess
+	}
+	return kindPlain
+}
+
+// jsonLogs writes every log line to stdout as a JSON logEvent, one per
+// line, when --json-logs is given.
+var jsonLogs *json.Encoder
+
+// logEvent is a log line as --json-logs writes it. Step is the action the
+// line comes from; Package and Status are set for the line the install
+// logs when it is done with a package.
+type logEvent struct {
+	Time    string  `json:"time"`
+	Level   string  `json:"level"`
+	Step    string  `json:"step,omitempty"`
+	Package string  `json:"package,omitempty"`
+	Status  string  `json:"status,omitempty"`  // ok, cached, skipped or failed
+	Seconds float64 `json:"seconds,omitempty"` // how long the pkg run or the install took
+	Message string  `json:"message"`
+
+	kind logKind // kindOf the line unless set, e.g. by logDone
+}
+
+// stepName is the step of logEvent for the menu entry name: "install" for
+// Install Niri, else the name in lower case with dashes, e.g.
+// "configure-niri".
+func stepName(name string) string {
+	switch name {
+	case "":
+		return ""
+	case "Install Niri", "Install from Cache", "Retry Failed Packages":
+		return "install"
+	}
+	return strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+}
+
+// verbosity is the --log-level: entries below it are neither kept nor
+// shown, in the TUI and on the command line alike.
+var verbosity = levelInfo
+
+// traces carries command lines from running actions back to the TUI.
+var traces = make(chan traceMsg, 64)
+
+// waitForTrace delivers the next traced command line to Update.
+func waitForTrace() tea.Cmd {
+	return func() tea.Msg {
+		return <-traces
+	}
 }
 
 func initialModel() model {
-	// Clear the terminal screen
-	clearScreen()
+	m := model{
+		state:       menuView,
+		profile:     niri.ActiveProfile(),
+		versionWarn: versionWarning(),
+		memoryWarn:  memoryWarning(),
+		insideNiri:  niri.InsideNiri(),
+		cmds:        defaultCommands,
+		ctx:         context.Background(),
+		spinner:     spinner.New(spinner.WithSpinner(spinner.Line)), // plain ASCII for the console
+		bar:         newProgressBar(),
+	}
+	m.choices = m.menu()
+	return m
+}
+
+// runSafe starts a safe action, staying in the menu with working shown
+// until its result replaces it.
+func (m model) runSafe(working string, cmd tea.Cmd) (model, tea.Cmd) {
+	m.actionMsg = working
+	return m, cmd
+}
+
+// ask puts c to the user in confirmView, or runs it straight away when
+// --yes answers for them.
+func (m model) ask(c confirmation) (model, tea.Cmd) {
+	if m.autoYes && (!c.destructive || m.force) {
+		m.state = actionView
+		m.actionMsg = c.working
+		m.log(trf("Auto-accepted: %s", strings.SplitN(c.question, "\n", 2)[0]))
+		return m, c.run
+	}
+	m.state = confirmView
+	m.confirm = c
+	return m, nil
+}
+
+// quit ends NiriSetup, asking first while an action still runs in the
+// background, unless --no-confirm-quit is given.
+func (m model) quit() (model, tea.Cmd) {
+	if m.isProcessing && !m.noConfirmQuit {
+		m.confirmingQuit = true
+		return m, nil
+	}
+	return m, tea.Quit
+}
+
+// cancelAction stops what runs in actionView and goes back to the menu.
+// What the stopped commands report still arrives and is logged.
+func (m model) cancelAction() model {
+	m.cmds.cancel()
+	m.log(trf("Cancelled: %s", m.actionMsg))
+	m.state, m.isProcessing, m.actionOutput = menuView, false, nil
+	m.actionMsg = tr("Cancelled.")
+	return m
+}
+
+// askAbout is ask for a question about text too long for confirmView,
+// such as a diff: it is shown in textView under the question, scrollable,
+// and y or n answer.
+func (m model) askAbout(text string, c confirmation) (model, tea.Cmd) {
+	if m.autoYes && (!c.destructive || m.force) {
+		m.log(text)
+		return m.ask(c)
+	}
+	next, _ := m.Update(textMsg{title: c.question, text: text})
+	m = next.(model)
+	m.textConfirm = &c
+	return m, nil
+}
+
+// changeLog collects what niri reports it would change instead of
+// changing it while --dry-run previews an action. The action's commands
+// report from their own goroutines, so it locks.
+type changeLog struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+// previewing returns ctx with niri in dry-run mode, collecting what the
+// actions called with it would change.
+func previewing(ctx context.Context) (context.Context, *changeLog) {
+	l := &changeLog{}
+	return niri.WithOptions(ctx, niri.Options{Preview: l.add}), l
+}
+
+// add records change, as niri's Preview.
+func (l *changeLog) add(change string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, strings.TrimSuffix(change, "\n"))
+}
+
+// report says what the dry run would have changed. The status of the
+// action tells of its changes as made, so it is only kept for an error or
+// when nothing would change.
+func (l *changeLog) report(status string, err error) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lines := []string{tr("Dry run, nothing was changed.")}
+	if status != "" && (err != nil || len(l.changes) == 0) {
+		lines = append(lines, status)
+	}
+	if err == nil && len(l.changes) == 0 {
+		lines = append(lines, tr("It would change nothing."))
+	}
+	return strings.Join(append(lines, l.changes...), "\n")
+}
+
+// screen is where the TUI draws: stdout, or with --json-logs, which writes
+// to stdout, the terminal itself.
+var screen = os.Stdout
+
+// plainOutput reports whether to render without colors or other escape
+// codes: when NO_COLOR is set, TERM is dumb or the screen is not a terminal.
+func plainOutput() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return true
+	}
+	info, err := screen.Stat()
+	return err != nil || info.Mode()&os.ModeCharDevice == 0
+}
+
+// log adds lines to the log. Only the newest logLimit lines stay in memory;
+// older ones are moved to the log file, a tenth of the limit at a time so a
+// busy log doesn't write on every line, and Save Logs adds the rest after
+// them, so the file still gets everything.
+func (m *model) log(lines ...string) {
+	m.logAt(levelInfo, lines...)
+}
+
+// logAt logs lines at level, unless --log-level leaves that level out.
+func (m *model) logAt(level logLevel, lines ...string) {
+	m.logEvent(level, logEvent{}, lines...)
+}
+
+// logDone logs lines that report something worked.
+func (m *model) logDone(lines ...string) {
+	m.logEvent(levelInfo, logEvent{kind: kindSuccess}, lines...)
+}
 
-	return model{
-		state:   menuView,
-		choices: []string{"Install Niri", "Configure Niri", "Validate Config", "Save Logs", "Exit"},
+// logEvent logs lines at level like logAt, with --json-logs writing each
+// as event.
+func (m *model) logEvent(level logLevel, event logEvent, lines ...string) {
+	if level > verbosity {
+		return
 	}
+	m.logs = append(m.logs, lines...)
+	at := m.cmds.now()
+	for len(m.logTimes) < len(m.logs) {
+		m.logTimes = append(m.logTimes, at)
+	}
+	kind := cmp.Or(event.kind, kindOf(level, event.Status))
+	for len(m.logKinds) < len(m.logs) {
+		m.logKinds = append(m.logKinds, kind)
+	}
+	if jsonLogs != nil {
+		event.Time, event.Level, event.Step = at.Format(time.RFC3339), level.String(), cmp.Or(event.Step, stepName(m.selected))
+		for _, line := range lines {
+			event.Message = line
+			jsonLogs.Encode(event) // a closed pipe must not stop the TUI
+		}
+	}
+	if m.state == installView {
+		defer m.followLogs()
+	}
+	limit := cmp.Or(m.logLimit, defaultLogLimit)
+	if len(m.logs) <= limit+limit/10 {
+		return
+	}
+	spill := len(m.logs) - limit
+	if err := m.cmds.logFile.Append(m.stampedLogs()[:spill]); err != nil {
+		m.spillErr = err
+	}
+	m.spilled += spill
+	m.logs = slices.Clone(m.logs[spill:])
+	m.logTimes = slices.Clone(m.logTimes[spill:])
+	m.logKinds = slices.Clone(m.logKinds[spill:])
+}
+
+// stampedLogs returns the logs with the time each was logged in front, as
+// they go into the log file and bug reports.
+func (m model) stampedLogs() []string {
+	stamped := make([]string, len(m.logs))
+	for i, line := range m.logs {
+		stamped[i] = m.logTimes[i].Format(time.RFC3339) + " " + line
+	}
+	return stamped
+}
+
+// fitWidth is w, the width a view is laid out for, narrowed to the
+// terminal when that is narrower.
+func (m model) fitWidth(w int) int {
+	if m.termWidth == 0 || m.termWidth >= w {
+		return w
+	}
+	return max(m.termWidth, minViewWidth)
+}
+
+// fit is style with its width, border included, narrowed by fitWidth.
+func (m model) fit(style lipgloss.Style) lipgloss.Style {
+	border := style.GetHorizontalBorderSize()
+	return style.Width(m.fitWidth(style.GetWidth()+border) - border)
+}
+
+// hint renders text dimmed, like the key hints under each view, and
+// wrapped to the terminal when that is narrower.
+func (m model) hint(text string) string {
+	return disabledStyle.Width(m.fitWidth(lipgloss.Width(text))).Render(text)
+}
+
+// logHeight is how many log lines installView has room for.
+func (m model) logHeight() int {
+	if m.termHeight == 0 {
+		return installLogHeight
+	}
+	return max(m.termHeight-installChrome, 3)
+}
+
+// textHeight is how many lines of text textView and editView have room
+// for.
+func (m model) textHeight() int {
+	if m.termHeight == 0 {
+		return editorHeight
+	}
+	return max(m.termHeight-textChrome, 3)
+}
+
+// resize lays the views out again for the terminal's new size.
+func (m model) resize(width, height int) model {
+	m.termWidth, m.termHeight = width, height
+	m.logView.Width = m.fitWidth(viewWidth) - 4 // inside logStyle's padding
+	m.followLogs()
+	m.text.Width, m.text.Height = m.fitWidth(editorWidth), m.textHeight()
+	m.text.SetContent(m.fit(lipgloss.NewStyle().Width(editorWidth)).Render(m.textBody))
+	if m.state == editView { // the editor only exists while it is open
+		m.editor.SetWidth(m.fitWidth(editorWidth))
+		m.editor.SetHeight(m.textHeight())
+	}
+	m.prompt.field.Width = m.fitWidth(editorWidth) - 4
+	return m
+}
+
+// followLogs shows the logs in logView, staying at the newest line unless
+// the user has scrolled up to read older ones.
+func (m *model) followLogs() {
+	bottom := m.logView.AtBottom()
+	// wrapped here so the viewport counts the lines it really shows
+	lines := slices.Clone(m.logs)
+	for i, kind := range m.logKinds {
+		if style, ok := logKindStyles[kind]; ok && i < len(lines) {
+			lines[i] = style.Render(lines[i])
+		}
+	}
+	content := lipgloss.NewStyle().Width(m.logView.Width).Render(strings.Join(lines, "\n"))
+	m.logView.Height = min(lipgloss.Height(content), m.logHeight()) // no blank lines under a short log
+	m.logView.SetContent(content)
+	if bottom {
+		m.logView.GotoBottom()
+	}
+}
+
+// spillNote says how many earlier log lines are no longer shown, or "".
+func (m model) spillNote() string {
+	switch {
+	case m.spilled == 0:
+		return ""
+	case m.spillErr != nil:
+		return trf("(%d earlier log lines dropped: %s)", m.spilled, m.spillErr)
+	}
+	return trf("(%d earlier log lines are in %s)", m.spilled, m.cmds.logFile.Path())
 }
 
 func clearScreen() {
 	cmd := exec.Command("clear")
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = screen
 	cmd.Run()
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	return tea.Batch(waitForTrace(), waitForWrite())
 }
 
+// Update handles msg, and starts the spinner whenever that puts the model
+// in actionView.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	working := m.state == actionView
+	next, cmd := m.update(msg)
+	if n := next.(model); n.state == actionView && !working {
+		return n, tea.Batch(cmd, n.cmds.spin(n.spinner))
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		return m.resize(msg.Width, msg.Height), nil
+	case spinner.TickMsg:
+		if m.state != actionView {
+			return m, nil // stops turning until the next action
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
 	case tea.KeyMsg:
 		switch m.state {
 		case menuView:
+			return m.menuKey(msg)
+		case confirmView:
+			switch msg.String() {
+			case "y", "Y":
+				m.state = actionView
+				m.actionMsg = m.confirm.working
+				return m, m.confirm.run
+			case "n", "N", "esc":
+				m.state = menuView
+				m.isProcessing = false
+				m.actionMsg = tr("Cancelled.")
+				if m.confirm.declined != "" {
+					m.actionMsg = m.confirm.declined
+				}
+			}
+			return m, nil
+		case choiceView:
 			switch msg.String() {
-			case "ctrl+c", "q":
-				return m, tea.Quit
 			case "up":
-				if m.cursor > 0 {
-					m.cursor--
+				if m.choice.cursor > 0 {
+					m.choice.cursor--
 				}
 			case "down":
-				if m.cursor < len(m.choices)-1 {
-					m.cursor++
+				if m.choice.cursor < len(m.choice.options)-1 {
+					m.choice.cursor++
 				}
 			case "enter":
-				m.selected = m.choices[m.cursor]
-				m.isProcessing = true
-				switch m.selected {
-				case "Install Niri":
-					m.state = installView
-					return m, installNiri()
-				case "Configure Niri":
-					m.state = actionView
-					m.actionMsg = "Configuring Niri..."
-					return m, configureNiri()
-				case "Validate Config":
-					m.state = actionView
-					m.actionMsg = "Validating Niri config..."
-					return m, validateNiriConfig()
-				case "Save Logs":
-					m.state = actionView
-					m.actionMsg = "Saving logs..."
-					return m, saveLogsToFile(m)
-				case "Exit":
-					return m, tea.Quit
+				opt := m.choice.options[m.choice.cursor]
+				if opt.run == nil {
+					m.state = menuView
+					m.isProcessing = false
+					m.actionMsg = tr("Cancelled.")
+					return m, nil
 				}
+				m.state = actionView
+				m.actionMsg = opt.working
+				return m, opt.run
+			case "esc":
+				m.state = menuView
+				m.isProcessing = false
+				m.actionMsg = tr("Cancelled.")
 			}
-		case installView, actionView:
-			// Disable input during processing
 			return m, nil
+		case editView:
+			return m.editKey(msg)
+		case summaryView:
+			m.state = menuView
+			m.choices = m.menu() // the install may unlock new entries
+			m.cursor = min(m.cursor, len(m.choices)-1)
+			return m, nil
+		case pickView:
+			return m.pickKey(msg)
+		case inputView:
+			switch msg.String() {
+			case "enter":
+				answer := strings.TrimSpace(m.prompt.field.Value())
+				if answer == "" {
+					return m, nil
+				}
+				return m.Update(m.prompt.submit(answer))
+			case "esc":
+				m.state = menuView
+				m.isProcessing = false
+				m.actionMsg = tr("Cancelled.")
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.prompt.field, cmd = m.prompt.field.Update(msg)
+			return m, cmd
+		case textView:
+			if c := m.textConfirm; c != nil {
+				switch msg.String() {
+				case "y", "Y":
+					m.state, m.actionMsg, m.textConfirm = actionView, c.working, nil
+					return m, c.run
+				case "n", "N", "esc", "q":
+					m.state, m.isProcessing, m.textConfirm = menuView, false, nil
+					m.actionMsg = cmp.Or(c.declined, tr("Cancelled."))
+					return m, nil
+				}
+			}
+			switch msg.String() {
+			case "esc", "q":
+				m.state = menuView
+				m.isProcessing = false
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.text, cmd = m.text.Update(msg)
+			return m, cmd
+		case installView:
+			return m.installKey(msg)
+		case actionView:
+			if m.confirmingQuit {
+				m.confirmingQuit = false
+				if msg.String() == "y" || msg.String() == "Y" {
+					return m.cancelAction(), nil
+				}
+				return m, nil // any other key keeps it running
+			}
+			if msg.String() == "ctrl+c" {
+				if m.isProcessing && !m.noConfirmQuit {
+					m.confirmingQuit = true
+					return m, nil
+				}
+				return m.cancelAction(), nil
+			}
+			// Disable other input during processing
+			return m, nil
+		}
+	// each feature handles its messages next to its commands
+	case packagesCheckedMsg, pickPackagesMsg, startInstallMsg, cacheDirMsg, cacheScannedMsg, progressMsg, packageProgressMsg, installingMsg, pausedMsg:
+		return m.updateInstall(msg)
+	case mirrorsMsg, packagesMsg, removePackageMsg, setupPackagesMsg, processesMsg, processPickedMsg, integrityMsg, upgradeMsg:
+		return m.updatePackages(msg)
+	case bindConflictMsg, xwaylandMsg, audioMsg, outputsMsg, outputPickedMsg, cursorThemesMsg, cursorThemePickedMsg, toolsetMsg, configureToolsMsg, configurePlanMsg, inputBehaviorMsg, appearanceStepMsg, swayConfigsMsg, nightLightPickedMsg, polkitAgentsMsg:
+		return m.updateConfigure(msg)
+	case windowRulesMsg, workspaceAppsMsg, workspaceAppCommandMsg, workspaceAppIDMsg, workspaceAppTargetMsg, workspaceAppPickedMsg, workspaceAppMsg, removeWindowRuleMsg, windowRuleFieldMsg, windowRuleMatchMsg:
+		return m.updateRules(msg)
+	case profilesMsg, newProfileMsg, saveProfileMsg, profileSwitchedMsg:
+		return m.updateProfiles(msg)
+	case repairMsg, backupsMsg, backupPickedMsg, editorBackupMsg, editorExitedMsg, editorMsg, editSavedMsg, configWrittenMsg, validateDueMsg, autoValidatedMsg:
+		return m.updateConfig(msg)
+	case displayManagersMsg, launchReadyMsg, sessionEndedMsg, launchMsg:
+		return m.updateLaunch(msg)
+	case settingPickedMsg:
+		return m.stepSetting(int(msg))
+	case textMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
 		}
+		m.state = textView
+		m.textTitle, m.textBody, m.textConfirm = msg.title, msg.text, nil
+		m.text = viewport.New(m.fitWidth(editorWidth), m.textHeight())
+		m.text.SetContent(m.fit(lipgloss.NewStyle().Width(editorWidth)).Render(msg.text))
+		return m, nil
+	case bugReportMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if msg.path == "" {
+			return m.Update(statusMsg{}) // a dry run, which says where it would go
+		}
+		written := trf("Bug report written to %s", msg.path)
+		m.log(written)
+		m.state = choiceView
+		m.choice = choice{
+			question: trf("%s\n\nIt has your config and recent logs, with your home directory, user and host names and secret-looking environment values redacted. Have a look before sharing it.", written),
+			options: []option{
+				{label: tr("Open it"), working: tr("Reading the bug report..."), run: m.cmds.showFile(msg.path)},
+				{label: tr("Back to the menu")},
+			},
+		}
+		return m, nil
+	case traceMsg:
+		m.logAt(levelDebug, string(msg))
+		return m, waitForTrace()
 	case statusMsg:
+		if m.preview != nil {
+			if m.state != installView { // which shows its own dry run
+				msg.status = m.preview.report(msg.status, msg.err)
+			}
+			m.preview = nil
+		}
 		// Append logs and handle state transitions
-		m.logs = append(m.logs, msg.status)
-		m.isProcessing = false
+		if msg.err != nil {
+			m.logAt(levelError, msg.status)
+		} else {
+			m.logDone(msg.status)
+		}
+		// nothing is left running to ask about stopping
+		m.isProcessing, m.confirmingQuit = false, false
+		if m.state == installView && msg.err == nil {
+			m.actionMsg = msg.status
+			if m.failed != nil {
+				m.actionMsg = trf("Retried the failed packages: %s", msg.status)
+			}
+		}
+		if m.state == installView {
+			m.failed = msg.failed
+			if m.cancelling {
+				m.cancelling = false
+				m.state = menuView
+			}
+		}
 		if msg.err == nil && m.state == installView {
-			// Automatically return to the menu after installation
-			m.state = menuView
-			m.logs = nil // Clear logs before returning to menu
+			// Sum up the install until a key returns to the menu; the
+			// logs stay for View Last Logs and Save Logs
+			m.state = summaryView
+		} else if m.state == menuView {
+			// a safe action's result shows under the menu, failed or not
+			m.actionMsg = msg.status
+			if msg.err != nil {
+				m.actionErr = msg.status
+			}
 		} else if msg.err == nil && m.state == actionView {
-			// Automatically return to the menu after actions
+			// Automatically return to the menu after actions; a failed one
+			// stays on the action view
 			m.state = menuView
-			m.actionMsg = msg.status // Display success or error message
+			m.actionMsg = msg.status // Display success message
+		}
+		if m.state == menuView {
+			// Earlier steps may have unlocked new menu entries
+			m.choices = m.menu()
+			if m.cursor >= len(m.choices) {
+				m.cursor = len(m.choices) - 1
+			}
 		}
 		return m, nil
 	}
@@ -160,150 +1133,604 @@ func (m model) View() string {
 		return m.renderInstallView()
 	case actionView:
 		return m.renderActionView()
+	case confirmView:
+		return m.renderConfirmView()
+	case choiceView:
+		return m.renderChoiceView()
+	case editView:
+		return m.renderEditView()
+	case textView:
+		return m.renderTextView()
+	case inputView:
+		return m.renderInputView()
+	case pickView:
+		return m.renderPickView()
+	case summaryView:
+		return m.renderSummaryView()
 	default:
 		return "Unknown state!"
 	}
 }
 
 func (m model) renderMenuView() string {
-    // Title section, centered and fixed width
-    title := titleStyle.Render("Niri Setup Assistant for GhostBSD")
+	// Title section, centered and fixed width
+	title := lipgloss.JoinVertical(lipgloss.Left, m.fit(titleStyle).Render(tr("Niri Setup Assistant for GhostBSD")), m.hint(trf("Version %s", version)))
+	if m.profile != "" {
+		title = lipgloss.JoinVertical(lipgloss.Left, title, m.hint(trf("Profile: %s", m.profile)))
+	}
+	if m.versionWarn != "" {
+		title = lipgloss.JoinVertical(lipgloss.Left, title, m.fit(logStyle).Render(m.versionWarn))
+	}
+	if m.memoryWarn != "" {
+		title = lipgloss.JoinVertical(lipgloss.Left, title, m.fit(logStyle).Render(m.memoryWarn))
+	}
+
+	if m.help != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, title, renderHelp(m.help, m.fit(helpStyle)))
+	}
+	if m.confirmingQuit {
+		return lipgloss.JoinVertical(lipgloss.Left, title, m.fit(helpStyle).Render(tr("Really quit? An action is still running, and quitting stops it.")+"\n\n"+m.hint(tr("y: quit   any other key: keep it running"))))
+	}
+	if m.notice != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, title, m.fit(helpStyle).Render(m.notice+"\n\n"+m.hint(tr("Press any key to close."))))
+	}
+
+	// Menu rendering with fixed width and left alignment
+	menu := strings.Builder{}
+	if m.filtering || m.filter != "" {
+		field := "/" + m.filter
+		if m.filtering {
+			field += "_"
+		}
+		menu.WriteString(cursorStyle.Render(field) + "\n")
+		if len(m.choices) == 0 {
+			menu.WriteString(m.hint(tr("No entry matches; esc clears the filter.")) + "\n")
+		}
+	}
+	for i, choice := range m.choices {
+		label := tr(choice)
+		if !safeActions[choice] {
+			label += " " + mutatingMark
+		}
+		// Pad by the width on screen, the mark takes more than one cell
+		label += strings.Repeat(" ", max(0, menuItemWidth-2-lipgloss.Width(label)))
+		if m.cursor == i {
+			// Selected item with cursor, ensure the same width for alignment
+			menu.WriteString(cursorStyle.Render("> "+label) + "\n")
+		} else {
+			// Non-selected items with consistent width and left padding
+			menu.WriteString(m.hint("  "+label) + "\n")
+		}
+	}
+	menu.WriteString(m.hint(trf("%s changes your system", mutatingMark)) + "\n")
+	menu.WriteString(m.hint(tr("? explains the highlighted entry   /: filter")) + "\n")
+	menu.WriteString(m.hint(tr("↑/↓ j/k g/G: move   enter: select   q: quit")) + "\n")
 
-    // Menu rendering with fixed width and left alignment
-    menu := strings.Builder{}
-    for i, choice := range m.choices {
-        if m.cursor == i {
-            // Selected item with cursor, ensure the same width for alignment
-            menu.WriteString(cursorStyle.Render(fmt.Sprintf("> %-"+fmt.Sprintf("%d", menuItemWidth-2)+"s", choice)) + "\n")
-        } else {
-            // Non-selected items with consistent width and left padding
-            menu.WriteString(disabledStyle.Render(fmt.Sprintf("  %-"+fmt.Sprintf("%d", menuItemWidth-2)+"s", choice)) + "\n")
-        }
-    }
+	// Show the outcome of the last action below the menu
+	if m.actionMsg != "" {
+		style := m.fit(logStyle)
+		if m.actionMsg == m.actionErr {
+			style = style.Foreground(errorStyle.GetForeground())
+		}
+		menu.WriteString(style.Render(m.actionMsg))
+	}
 
-    // Join title and menu together and render them with consistent alignment
-    return lipgloss.JoinVertical(lipgloss.Left, title, menuStyle.Render(menu.String()))
+	// Join title and menu together and render them with consistent alignment
+	return lipgloss.JoinVertical(lipgloss.Left, title, m.fit(menuStyle).Render(menu.String()))
 }
 
 func (m model) renderInstallView() string {
 	// Title and logs section with consistent width
-	s := titleStyle.Render("Installing Niri...")
+	parts := []string{m.fit(titleStyle).Render(tr("Installing Niri..."))}
+	if m.dryRun {
+		parts = append(parts, m.fit(actionStyle).Render(tr("(dry run) Nothing is installed.")))
+	}
 
 	// Logs section
-	for _, log := range m.logs {
-		s += logStyle.Render(log + "\n")
+	if note := m.spillNote(); note != "" {
+		parts = append(parts, m.hint(note))
+	}
+	parts = append(parts, m.fit(logStyle).Render(m.logView.View()))
+	if !m.logView.AtTop() || !m.logView.AtBottom() {
+		parts = append(parts, m.hint(tr("↑/↓ pgup/pgdn: scroll the log")))
+	}
+	if p := m.install; p.total > 0 {
+		parts = append(parts, m.fit(actionStyle).Render(m.bar.ViewAs(float64(p.done)/float64(p.total))+"  "+trf("%d of %d packages", p.done, p.total)))
+		if p.stage != "" {
+			var part float64
+			if p.size > 0 {
+				part = float64(p.current) / float64(p.size)
+			}
+			parts = append(parts, m.fit(logStyle).Render(m.bar.ViewAs(part)+"  "+p.stage))
+		}
+	}
+	switch {
+	case m.failed != nil && !m.isProcessing:
+		parts = append(parts, m.fit(logStyle).Render(m.installTotals()))
+		parts = append(parts, m.fit(errorStyle).Render(trf("%d packages are not installed: %s\n\n[r] Retry them   [esc] Back to the menu", len(m.failed), strings.Join(m.failed, ", "))))
+	case m.remaining != nil:
+		parts = append(parts, m.fit(actionStyle).Render(trf("Paused. %d packages left: %s\n\n[r] Resume", len(m.remaining), strings.Join(m.remaining, ", "))))
+	case m.pausing:
+		parts = append(parts, m.fit(logStyle).Render(m.nowInstalling()+tr("Pausing after the current package...  [r] Resume")))
+	default:
+		parts = append(parts, m.fit(logStyle).Render(m.nowInstalling()+tr("Please wait...")+"  "+tr("[p] Pause")))
+	}
+	switch {
+	case m.confirmingQuit:
+		parts = append(parts, m.stopPrompt())
+	case m.isProcessing:
+		parts = append(parts, m.hint(tr("ctrl+c: cancel   other keys wait until it is done")))
 	}
-	s += logStyle.Render("Please wait...\n")
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
 
-	// Ensure fixed height for the view
-	return lipgloss.JoinVertical(lipgloss.Left, s)
+// stopPrompt asks whether ctrl+c should really stop what runs in
+// installView or actionView.
+func (m model) stopPrompt() string {
+	return m.fit(errorStyle).Render(tr("Really cancel? It is still running, and cancelling stops it.") + "\n\n" + m.hint(tr("y: cancel it   any other key: keep it running")))
+}
+
+// nowInstalling is the line naming the packages pkg is installing right
+// now, so a hang shows where it is stuck, or "" between two pkg runs.
+func (m model) nowInstalling() string {
+	if m.currentPackage == "" {
+		return ""
+	}
+	return cursorStyle.Render(trf("Now installing: %s", m.currentPackage)) + "\n"
 }
 
 func (m model) renderActionView() string {
 	// Display the action message prominently with consistent width
-	return lipgloss.JoinVertical(lipgloss.Left, actionStyle.Render(fmt.Sprintf("%s\n\nPlease wait...", m.actionMsg)))
+	parts := []string{m.fit(actionStyle).Render(fmt.Sprintf("%s\n\n%s %s", m.actionMsg, m.spinner.View(), tr("Please wait...")))}
+	if len(m.actionOutput) > 0 {
+		// one screen line each, so the view does not jump as they come
+		var lines []string
+		for _, line := range m.actionOutput {
+			lines = append(lines, lipgloss.NewStyle().MaxWidth(m.fitWidth(viewWidth)-4).Render(line))
+		}
+		parts = append(parts, m.fit(logStyle).Render(strings.Join(lines, "\n")))
+	}
+	if m.confirmingQuit {
+		parts = append(parts, m.stopPrompt())
+	} else {
+		parts = append(parts, m.hint(tr("ctrl+c: cancel   other keys wait until it is done")))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
 }
 
-func installNiri() tea.Cmd {
-	return func() tea.Msg {
-		pkgs := []string{"niri", "wlroots", "xwayland-satellite", "seatd", "waybar", "grim", "jq", "wofi", "alacritty", "pam_xdg", "fuzzel", "swaylock", "foot", "wlsunset", "swaybg", "mako", "swayidle"}
-		var logs []string
-
-		for _, pkg := range pkgs {
-			cmd := exec.Command("sudo", "pkg", "install", "-y", pkg)
-			out, err := cmd.CombinedOutput()
-			if err != nil {
-				return statusMsg{status: fmt.Sprintf("Failed to install %s", pkg), err: fmt.Errorf(string(out))}
-			}
-			time.Sleep(500 * time.Millisecond) // Simulate install time for visual feedback
+func (m model) renderConfirmView() string {
+	return lipgloss.JoinVertical(lipgloss.Left, m.fit(actionStyle).Render(fmt.Sprintf("%s\n\n%s", m.confirm.question, tr("[y] Yes   [n] No"))))
+}
 
-			// Append success message to logs
-			log := fmt.Sprintf("Successfully installed %s", pkg)
-			logs = append(logs, log)
+func (m model) renderChoiceView() string {
+	options := strings.Builder{}
+	for i, opt := range m.choice.options {
+		if m.choice.cursor == i {
+			options.WriteString(cursorStyle.Render("> "+opt.label) + "\n")
+		} else {
+			options.WriteString(m.hint("  "+opt.label) + "\n")
 		}
+	}
+	help := m.hint(tr("↑/↓: move   enter: pick   esc: back to the menu"))
+	return lipgloss.JoinVertical(lipgloss.Left, m.fit(logStyle).Render(m.choice.question), m.fit(menuStyle).Render(options.String()), help)
+}
+
+func (m model) renderEditView() string {
+	title := m.fit(titleStyle.Width(editorWidth)).Render(trf("Editing %s", niri.ConfigPath()))
+	help := m.hint(tr("ctrl+s: validate and save   esc: discard changes"))
+	parts := []string{title, m.editor.View(), help}
+	if m.editErr != "" {
+		parts = append(parts, m.fit(logStyle.Width(editorWidth)).Render(m.editErr))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
+
+func (m model) renderTextView() string {
+	title := m.fit(titleStyle.Width(editorWidth)).Render(m.textTitle)
+	help := m.hint(tr("↑/↓ pgup/pgdn: scroll   esc: back to the menu"))
+	if m.textConfirm != nil {
+		help = m.hint(tr("↑/↓ pgup/pgdn: scroll   y: go ahead   n: back to the menu"))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, title, m.text.View(), help)
+}
+
+func (m model) renderInputView() string {
+	help := m.hint(tr("enter: continue   esc: cancel"))
+	return lipgloss.JoinVertical(lipgloss.Left, m.fit(logStyle).Render(m.prompt.question), m.fit(menuStyle).Render(m.prompt.field.View()), help)
+}
+
+func (m model) renderPickView() string {
+	question := trf("Install these %d packages? Uncheck the ones you do not want.", len(m.pick.selected()))
+	options := strings.Builder{}
+	for i, pkg := range m.pick.pkgs {
+		mark := "[ ] "
+		if m.pick.checked[i] {
+			mark = "[x] "
+		}
+		if m.pick.cursor == i {
+			options.WriteString(cursorStyle.Render("> "+mark+pkg) + "\n")
+		} else {
+			options.WriteString(m.hint("  "+mark+pkg) + "\n")
+		}
+	}
+	parts := []string{m.fit(logStyle).Render(question), m.fit(menuStyle).Render(options.String())}
+	if m.pick.note != "" {
+		parts = append(parts, m.fit(logStyle).Render(m.pick.note))
+	}
+	help := m.hint(tr("space: check   enter: install   esc: cancel"))
+	return lipgloss.JoinVertical(lipgloss.Left, append(parts, help)...)
+}
+
+// installTotals counts how the packages of the install ended.
+func (m model) installTotals() string {
+	return trf("%d packages: %d installed, %d skipped, %d failed", m.install.total,
+		m.outcomes["ok"]+m.outcomes["cached"], m.outcomes["skipped"], m.outcomes["failed"])
+}
+
+// renderSummaryView sums up a finished install: how its packages ended,
+// how long it took, what it reported and what to run next.
+func (m model) renderSummaryView() string {
+	took := m.cmds.now().Sub(m.installStart).Round(time.Second)
+	summary := []string{
+		m.installTotals(),
+		trf("Took %s.", took),
+		"",
+		m.actionMsg,
+		"",
+		tr("Next: Configure Niri writes a config, System Check checks the system, then Launch Niri starts it."),
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, m.fit(titleStyle).Render(tr("Install finished")),
+		m.fit(logStyle).Render(strings.Join(summary, "\n")),
+		m.hint(tr("any key: back to the menu")))
+}
 
-		// Return all logs as a combined message
-		return statusMsg{status: strings.Join(logs, "\n")}
+// reportMsg turns the lines an action reported, and its error if any, into
+// the statusMsg shown when it finishes.
+func reportMsg(report []string, err error) statusMsg {
+	if err != nil {
+		report = append(report, trf("Error: %s", err))
 	}
+	return statusMsg{status: strings.Join(report, "\n"), err: err}
 }
 
-func configureNiri() tea.Cmd {
+func showManPage(name string) tea.Cmd {
 	return func() tea.Msg {
-		// Simulate configuration work
-		time.Sleep(2 * time.Second)
-		return statusMsg{status: "Niri configuration completed successfully."}
+		page, err := niri.ManPage(name)
+		return textMsg{title: name + "(1)", text: page, err: err}
+	}
+}
+
+// versionWarning says when the installed niri is older than the configs
+// NiriSetup writes need. A missing niri is no reason to warn: installing it
+// is what NiriSetup is for.
+func versionWarning() string {
+	version, err := niri.InstalledVersion()
+	if err != nil || niri.SupportedVersion(version) {
+		return ""
 	}
+	return trf("Warning: niri %s is older than %s; some configure actions write options it cannot parse.", version, niri.MinVersion)
 }
 
-func validateNiriConfig() tea.Cmd {
+// memoryWarning says when the machine has less RAM than a niri desktop
+// wants, and how much it has.
+func memoryWarning() string {
+	mem, err := niri.PhysicalMemory()
+	if err != nil || mem >= niri.MinMemory {
+		return ""
+	}
+	return trf("Warning: this machine has %.1f GiB of RAM, less than %d GiB. Expect the desktop to swap, and install binary packages rather than building from ports, which would thrash.", float64(mem)/(1<<30), niri.MinMemory>>30)
+}
+
+// systemCheck shows whether the system is ready to run niri as a checklist.
+func systemCheck() tea.Cmd {
 	return func() tea.Msg {
-		cmd := exec.Command("niri", "validate")
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			return statusMsg{status: fmt.Sprintf("Validation failed: %s", string(out)), err: err}
+		checks := niri.SystemChecks()
+		var lines []string
+		for _, c := range checks {
+			lines = append(lines, c.String())
 		}
-		return statusMsg{status: "Niri configuration is valid."}
+		verdict := tr("Ready to run niri.")
+		if niri.LaunchBlocked(checks) {
+			verdict = tr("Not ready yet: fix the items marked ✗ first.")
+		}
+		text := lipgloss.NewStyle().Width(editorWidth).Render(strings.Join(lines, "\n") + "\n\n" + verdict)
+		return textMsg{title: tr("System Check"), text: text}
 	}
 }
 
-func saveLogsToFile(m model) tea.Cmd {
+func runDoctor() tea.Cmd {
 	return func() tea.Msg {
-		logFile := filepath.Join(os.TempDir(), "nirisetup.log")
-		file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			return statusMsg{status: "Failed to open log file for writing", err: err}
+		var report []string
+		for _, c := range niri.Doctor() {
+			report = append(report, c.String())
 		}
-		defer file.Close()
+		return statusMsg{status: strings.Join(report, "\n")}
+	}
+}
 
-		for _, log := range m.logs {
-			if _, err := file.WriteString(log + "\n"); err != nil {
-				return statusMsg{status: "Failed to write to log file", err: err}
-			}
-		}
-		return statusMsg{status: fmt.Sprintf("Logs saved to %s", logFile)}
+// logFile is the --log-file path, "" for the default.
+var logFile string
+
+// logFilePath is where Save Logs and the install summaries are written:
+// --log-file, or nirisetup.log in the temporary directory.
+func logFilePath() string {
+	if logFile != "" {
+		return logFile
 	}
+	return filepath.Join(os.TempDir(), "nirisetup.log")
 }
 
-func setupEnvironment() {
-	// Get the current user's ID
-	userID := os.Geteuid()
+// openLogFile opens the log file for appending, creating it and the
+// directories it is in.
+func openLogFile() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(logFilePath()), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(logFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
 
-	// Construct the runtime directory path using the user ID
-	runtimeDir := fmt.Sprintf("/tmp/%d-runtime-dir", userID)
+// expandHome replaces a leading ~ in path with the home directory.
+func expandHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	if path == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(home, rest)
+	}
+	return path
+}
 
-	// Set the XDG_RUNTIME_DIR environment variable
-	os.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+// logStore is where Save Logs and lines spilled past the log limit are
+// appended. Tests swap in one that keeps the lines in memory.
+type logStore interface {
+	// Path is where the lines end up, as shown to the user.
+	Path() string
+	Append(lines []string) error
+}
 
-	// Check if the directory exists, if not create it
-	if _, err := os.Stat(runtimeDir); os.IsNotExist(err) {
-		// Create the directory with 0700 permissions to ensure it's secure
-		if err := os.Mkdir(runtimeDir, 0700); err != nil {
-			log.Fatalf("Failed to create runtime directory: %v", err)
-		}
-	} else {
-		// Check if the existing directory is owned by the current user
-		info, err := os.Stat(runtimeDir)
-		if err != nil {
-			log.Fatalf("Failed to stat runtime directory: %v", err)
-		}
+// fileLog is the logStore at logFilePath.
+type fileLog struct{}
 
-		// Get the owner UID of the existing directory
-		stat, ok := info.Sys().(*syscall.Stat_t)
-		if !ok {
-			log.Fatalf("Failed to get ownership information of runtime directory")
+func (fileLog) Path() string { return logFilePath() }
+
+// Append adds lines to the end of the log file.
+func (fileLog) Append(lines []string) error {
+	file, err := openLogFile()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, log := range lines {
+		if _, err := file.WriteString(log + "\n"); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if stat.Uid != uint32(userID) {
-			log.Fatalf("XDG_RUNTIME_DIR '%s' is owned by UID %d, not our UID %d", runtimeDir, stat.Uid, userID)
+func saveLogsToFile(m model) tea.Cmd {
+	store, logs, preview := m.cmds.logFile, m.stampedLogs(), niri.OptionsFrom(m.ctx).Preview
+	return func() tea.Msg {
+		if len(logs) == 0 {
+			return statusMsg{status: tr("No logs to save.")}
+		}
+		if preview != nil {
+			preview(fmt.Sprintf("Would append %d log lines to %s", len(logs), store.Path()))
+			return statusMsg{}
+		}
+		if err := store.Append(logs); err != nil {
+			return statusMsg{status: tr("Failed to write to log file"), err: err}
 		}
+		return statusMsg{status: trf("Logs saved to %s", store.Path())}
+	}
+}
+
+// settings describes how NiriSetup was started, for bug reports.
+func (m model) settings() []string {
+	profile := "none"
+	if p := niri.CurrentSetupProfile(); p != nil {
+		profile = p.Path
+	}
+	return []string{
+		fmt.Sprintf("version: %s", versionLine()),
+		fmt.Sprintf("debug: %v", debug),
+		fmt.Sprintf("log-level: %s", verbosity),
+		fmt.Sprintf("color: %s", colorMode),
+		fmt.Sprintf("yes: %v", m.autoYes),
+		fmt.Sprintf("force: %v", m.force),
+		fmt.Sprintf("no-confirm-quit: %v", m.noConfirmQuit),
+		fmt.Sprintf("dry-run: %v", m.dryRun),
+		fmt.Sprintf("privilege-tool: %s", cmp.Or(niri.PrivilegeTool, "auto")),
+		fmt.Sprintf("inside niri: %v", m.insideNiri),
+		fmt.Sprintf("log-lines: %d", m.logLimit),
+		fmt.Sprintf("log-file: %s", logFilePath()),
+		fmt.Sprintf("locale: %s", locale),
+		fmt.Sprintf("packages file: %s", niri.PackagesFile()),
+		fmt.Sprintf("setup profile: %s", profile),
+	}
+}
+
+func writeBugReport(m model) tea.Cmd {
+	ctx, settings, logs := m.ctx, m.settings(), m.stampedLogs()
+	return func() tea.Msg {
+		path, err := niri.WriteBugReport(ctx, settings, logs)
+		return bugReportMsg{path: path, err: err}
+	}
+}
+
+// showFile shows the file at path in textView.
+func showFile(path string) tea.Cmd {
+	return func() tea.Msg {
+		data, err := os.ReadFile(path)
+		return textMsg{title: path, text: string(data), err: err}
 	}
 }
 
 func main() {
-	setupEnvironment()
-	p := tea.NewProgram(initialModel())
+	var autoYes, force, dryRun, status, asJSON, showVersion, jsonLogLines, noColor, noConfirmQuit bool
+	var logLimit int
+	var validate, headless, profile string
+	flag.BoolVar(&debug, "debug", os.Getenv("DEBUG") != "", "log every command before it runs (same as --log-level debug)")
+	level := flag.String("log-level", "info", "what to log: error, warn, info or debug")
+	flag.BoolVar(&autoYes, "yes", false, "answer yes to every confirmation")
+	flag.BoolVar(&autoYes, "y", false, "shorthand for --yes")
+	flag.BoolVar(&force, "force", false, "with --yes, also accept destructive confirmations; also start on systems other than FreeBSD")
+	flag.BoolVar(&noConfirmQuit, "no-confirm-quit", false, "quit or cancel at once on q or ctrl+c, without asking while an action runs")
+	flag.IntVar(&logLimit, "log-lines", defaultLogLimit, "most log lines to keep in memory; older ones go to the log file")
+	flag.BoolVar(&dryRun, "dry-run", false, "show the commands and changes actions would make without making them")
+	flag.StringVar(&validate, "validate", "", "validate the config at `path` (- for stdin) and exit")
+	flag.BoolVar(&status, "status", false, "report how complete the setup is and exit, non-zero if it is not")
+	flag.BoolVar(&asJSON, "json", false, "with --status, print the report as JSON")
+	flag.StringVar(&headless, "headless", "", "run `action` (install or configure) without the TUI, printing its progress, and exit non-zero if it fails")
+	flag.BoolVar(&showVersion, "version", false, "print the version and exit")
+	flag.BoolVar(&noColor, "no-color", false, "draw without colors (same as the Colors setting off)")
+	flag.BoolVar(&jsonLogLines, "json-logs", false, "also write every log line to stdout as a JSON object; the TUI then draws on /dev/tty")
+	flag.StringVar(&logFile, "log-file", "", "write Save Logs and the install summaries to `path` (default: nirisetup.log in the temporary directory)")
+	flag.DurationVar(&niri.InstallTimeout, "install-timeout", niri.InstallTimeout, "fail a package whose pkg install has not finished after `duration` (0: no limit)")
+	flag.StringVar(&niri.RuntimeDirOverride, "runtime-dir", "", "use `path` as XDG_RUNTIME_DIR, whatever the session sets")
+	flag.StringVar(&profile, "profile", "", "install and configure as the setup profile at `path` says instead of the built-in defaults")
+	flag.StringVar(&niri.PrivilegeTool, "privilege-tool", "", "run commands as root with `tool`, doas or sudo (default: doas if installed, else sudo)")
+	flag.Parse()
+	if showVersion {
+		fmt.Println(versionLine())
+		os.Exit(0)
+	}
+	locale = detectLocale()
+	// pkg, sysrc and service are FreeBSD's, so anywhere else an install
+	// would only fail halfway through
+	if runtime.GOOS != "freebsd" && !force {
+		fmt.Fprintln(os.Stderr, trf("NiriSetup only supports FreeBSD, not %s. --force starts it anyway, for experimenting.", runtime.GOOS))
+		os.Exit(1)
+	}
+	var ok bool
+	if verbosity, ok = parseLogLevel(*level); !ok {
+		fmt.Fprintln(os.Stderr, trf("Unknown --log-level %s, want error, warn, info or debug.", *level))
+		os.Exit(2)
+	}
+	if debug {
+		verbosity = levelDebug
+	}
+	debug = verbosity == levelDebug
+	if tool := niri.PrivilegeTool; tool != "" && tool != "doas" && tool != "sudo" {
+		fmt.Fprintln(os.Stderr, trf("Unknown --privilege-tool %s, want doas or sudo.", tool))
+		os.Exit(2)
+	}
+	logFile = expandHome(logFile)
+	if profile != "" {
+		p, err := niri.LoadSetupProfile(expandHome(profile))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, trf("Error: %s", err))
+			os.Exit(2)
+		}
+		niri.UseSetupProfile(p)
+	}
+	if asJSON && !status {
+		fmt.Fprintln(os.Stderr, tr("--json only works with --status."))
+		os.Exit(2)
+	}
+	// prefs holds the settings until the TUI's model is made
+	prefs := model{autoYes: autoYes, force: force, dryRun: dryRun}
+	var err error
+	if prefs.saved, err = niri.LoadSettings(); err != nil {
+		fmt.Fprintln(os.Stderr, trf("Warning: ignoring the settings file: %s", err))
+	}
+	flags := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { flags[f.Name] = true })
+	flags["yes"] = flags["yes"] || flags["y"]
+	flags["log-level"] = flags["log-level"] || flags["debug"]
+	if jsonLogLines {
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprintln(os.Stderr, tr("--json-logs writes JSON to stdout; redirect it to a file or a pipe."))
+			os.Exit(2)
+		}
+		if screen, err = os.OpenFile("/dev/tty", os.O_RDWR, 0); err != nil {
+			fmt.Fprintln(os.Stderr, trf("--json-logs needs a terminal to draw on: %s", err))
+			os.Exit(2)
+		}
+		lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(screen))
+		jsonLogs = json.NewEncoder(os.Stdout)
+	}
+	if noColor {
+		colorMode, flags["color"] = "off", true
+	}
+	terminalColors = lipgloss.ColorProfile()
+	setColorMode(colorMode)
+	warnings := applySettings(&prefs, prefs.saved, flags)
+	if niri.RunningAsRoot() {
+		warnings = append(warnings, rootWarning(cmp.Or(os.Getenv("SUDO_USER"), os.Getenv("DOAS_USER"))))
+	}
+	theme, err := niri.LoadTheme()
+	if err != nil {
+		warnings = append(warnings, trf("Warning: %s", err))
+	}
+	applyTheme(theme)
+	if headless != "" && !slices.Contains(headlessActions, headless) {
+		fmt.Fprintln(os.Stderr, trf("Unknown --headless %s, want install or configure.", headless))
+		os.Exit(2)
+	}
+	if validate != "" || status || headless != "" {
+		if debug {
+			niri.Trace = func(line string) { fmt.Fprintln(os.Stderr, "$ "+line) }
+		}
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, w)
+		}
+		if status {
+			os.Exit(statusCLI(asJSON))
+		}
+		if headless != "" {
+			if locked := (*niri.LockedError)(nil); errors.As(niri.Lock(), &locked) {
+				fmt.Fprintln(os.Stderr, trf("Another NiriSetup (pid %d) is running; try again once it exits.", locked.PID))
+				os.Exit(1)
+			}
+			os.Exit(headlessCLI(os.Stdout, os.Stderr, defaultCommands, headless, prefs.dryRun))
+		}
+		os.Exit(validateCLI(validate))
+	}
+	niri.Trace = func(line string) {
+		if debug { // Settings can turn it on and off
+			traces <- traceMsg("$ " + line)
+		}
+	}
+	niri.Written = func(backup string) { written <- configWrittenMsg{backup: backup} }
+
+	// Clear the terminal screen
+	clearScreen()
+
+	m := initialModel()
+	m.autoYes, m.force, m.dryRun, m.saved = prefs.autoYes, prefs.force, prefs.dryRun, prefs.saved
+	m.noConfirmQuit = noConfirmQuit
+	m.logAt(levelWarn, warnings...)
+	if p := niri.CurrentSetupProfile(); p != nil {
+		m.log(trf("Using the setup profile %s.", p.Path))
+	}
+	env, runtimeErr := nirisetup.SetUpEnvironment()
+	m.log(env.Chosen)
+	m.logAt(levelWarn, env.Report...)
+	if runtimeErr != nil {
+		m.logAt(levelError, trf("Error: %s", runtimeErr))
+	}
+	m.logLimit = max(logLimit, 1)
+	if pending, err := niri.PendingChanges(); err != nil {
+		m.logAt(levelWarn, trf("Warning: %s", err))
+	} else {
+		m.notice = changesNotice(pending)
+	}
+	m.notice = joinNotices(runtimeNotice(runtimeErr), m.notice)
+	if locked := (*niri.LockedError)(nil); errors.As(niri.Lock(), &locked) {
+		m.actionMsg = trf("Another NiriSetup (pid %d) is running. Only the entries without %s work until it exits.", locked.PID, mutatingMark)
+	}
+	var options []tea.ProgramOption
+	if screen != os.Stdout {
+		options = append(options, tea.WithInput(screen), tea.WithOutput(screen))
+	}
+	p := tea.NewProgram(m, options...)
 	if err := p.Start(); err != nil {
 		log.Fatalf("Alas, there's been an error: %v", err)
 	}
 }
-
@@ -0,0 +1,1971 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"NiriSetup/internal/niri"
+	"NiriSetup/internal/niri/niritest"
+)
+
+// stubMsg is returned by the stubbed commands so tests can tell which action
+// Update dispatched without running anything.
+type stubMsg string
+
+func stubCommands() commands {
+	return commands{
+		choices: func() []string {
+			return []string{"Install Niri", "Configure Niri", "Validate Config", "Save Logs", "Exit"}
+		},
+		check: func() tea.Cmd { return func() tea.Msg { return stubMsg("check") } },
+		install: func(pkgs []string, files map[string]string, _ bool, _ *pauser) tea.Cmd {
+			from := ""
+			if len(files) > 0 {
+				from = fmt.Sprintf(" (%d cached)", len(files))
+			}
+			return func() tea.Msg { return stubMsg("install:" + strings.Join(pkgs, " ") + from) }
+		},
+		toolsets: func() tea.Cmd { return func() tea.Msg { return stubMsg("toolsets") } },
+		configure: func(_ context.Context, ts niri.Toolset, tools []string) tea.Cmd {
+			with := ""
+			if ts.Terminal.Package != "" {
+				with = fmt.Sprintf(" with %s, %s and %q", ts.Terminal.Package, ts.Launcher.Package, ts.Bar.Package)
+			}
+			return func() tea.Msg { return stubMsg("configure:" + strings.Join(tools, ",") + with) }
+		},
+		planConfigure: func(ts niri.Toolset, tools []string) tea.Cmd {
+			return func() tea.Msg {
+				return stubMsg("plan configure:" + strings.Join(tools, ",") + " with " + ts.Terminal.Package)
+			}
+		},
+		validate: func() tea.Cmd { return func() tea.Msg { return stubMsg("validate") } },
+		repairSetup: func(_ context.Context) tea.Cmd {
+			return func() tea.Msg { return stubMsg("repair setup") }
+		},
+		appearance: func(_ context.Context, l niri.Appearance) tea.Cmd {
+			return func() tea.Msg {
+				return stubMsg(fmt.Sprintf("appearance: gaps=%d ring=%d color=%s slowdown=%g", l.Gaps, l.FocusRing, l.Color, l.Slowdown))
+			}
+		},
+		launchLog: func() tea.Cmd { return func() tea.Msg { return stubMsg("launch log") } },
+		launch:    func(_ context.Context) tea.Cmd { return func() tea.Msg { return stubMsg("launch") } },
+		session:   func(_ context.Context) tea.Cmd { return func() tea.Msg { return stubMsg("session") } },
+		edit:      func() tea.Cmd { return func() tea.Msg { return stubMsg("edit") } },
+		editorBackup: func(_ context.Context) tea.Cmd {
+			return func() tea.Msg { return stubMsg("editor backup") }
+		},
+		openEditor: func(_ context.Context) tea.Cmd { return func() tea.Msg { return stubMsg("editor") } },
+		restore: func(_ context.Context, backup string) tea.Cmd {
+			return func() tea.Msg { return stubMsg("restore:" + backup) }
+		},
+		autoValidate: func(undo string) tea.Cmd {
+			return func() tea.Msg { return stubMsg("auto validate:" + undo) }
+		},
+		saveLogs: func(m model) tea.Cmd {
+			return func() tea.Msg { return stubMsg("save:" + strings.Join(m.logs, "|")) }
+		},
+		lock:         func() error { return nil },
+		acknowledge:  func() error { return nil },
+		saveSettings: func(map[string]string) error { return nil },
+		logFile:      &memLog{},
+		now:          func() time.Time { return stubNow },
+		spin:         func(spinner.Model) tea.Cmd { return nil },
+		cancel:       func() {},
+	}
+}
+
+// stubNow is when everything in a test is logged.
+var stubNow = time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+
+// memLog is a logStore that keeps the lines in memory.
+type memLog struct {
+	lines []string
+	err   error
+}
+
+func (l *memLog) Path() string { return "/tmp/test.log" }
+
+func (l *memLog) Append(lines []string) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.lines = append(l.lines, lines...)
+	return nil
+}
+
+func testModel() model {
+	cmds := stubCommands()
+	return model{state: menuView, ctx: context.Background(), choices: cmds.choices(), cmds: cmds, spinner: spinner.New(spinner.WithSpinner(spinner.Line)), bar: newProgressBar()}
+}
+
+func key(s string) tea.KeyMsg {
+	switch s {
+	case "up":
+		return tea.KeyMsg{Type: tea.KeyUp}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "ctrl+c":
+		return tea.KeyMsg{Type: tea.KeyCtrlC}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// feed runs msgs through Update in order and returns the final model and the
+// message produced by the command returned for the last msg, if any.
+func feed(m model, msgs ...tea.Msg) (model, tea.Msg) {
+	var last tea.Msg
+	for _, msg := range msgs {
+		next, cmd := m.Update(msg)
+		m = next.(model)
+		last = nil
+		if cmd != nil {
+			last = cmd()
+		}
+	}
+	return m, last
+}
+
+func TestUpdate(t *testing.T) {
+	fail := errors.New("boom")
+	// installing gets through the package check with everything available
+	installing := func(msgs ...tea.Msg) []tea.Msg {
+		return append([]tea.Msg{key("enter"), packagesCheckedMsg{pkgs: []string{"niri", "waybar"}}, key("enter")}, msgs...)
+	}
+
+	tests := []struct {
+		name           string
+		msgs           []tea.Msg
+		wantState      appState
+		wantCursor     int
+		wantLogs       []string
+		wantActionMsg  string
+		wantProcessing bool
+		wantMsg        tea.Msg
+	}{
+		{
+			name:       "cursor wraps from the top to the bottom",
+			msgs:       []tea.Msg{key("up")},
+			wantState:  menuView,
+			wantCursor: 4,
+		},
+		{
+			name:      "cursor wraps from the bottom to the top",
+			msgs:      []tea.Msg{key("down"), key("down"), key("down"), key("down"), key("down")},
+			wantState: menuView,
+		},
+		{
+			name:       "j and k move like down and up",
+			msgs:       []tea.Msg{key("j"), key("j"), key("k")},
+			wantState:  menuView,
+			wantCursor: 1,
+		},
+		{
+			name:       "G jumps to the last entry",
+			msgs:       []tea.Msg{key("G")},
+			wantState:  menuView,
+			wantCursor: 4,
+		},
+		{
+			name:      "g jumps to the first entry",
+			msgs:      []tea.Msg{key("down"), key("down"), key("g")},
+			wantState: menuView,
+		},
+		{
+			name:           "safe actions run from the menu",
+			msgs:           []tea.Msg{key("down"), key("down"), key("enter")},
+			wantState:      menuView,
+			wantCursor:     2,
+			wantActionMsg:  "Validating Niri config...",
+			wantProcessing: true,
+			wantMsg:        stubMsg("validate"),
+		},
+		{
+			name:          "safe action results show under the menu",
+			msgs:          []tea.Msg{key("down"), key("down"), key("enter"), statusMsg{status: "Niri configuration is valid."}},
+			wantState:     menuView,
+			wantCursor:    2,
+			wantLogs:      []string{"Niri configuration is valid."},
+			wantActionMsg: "Niri configuration is valid.",
+		},
+		{
+			name:          "failed safe action results show under the menu too",
+			msgs:          []tea.Msg{key("down"), key("down"), key("enter"), statusMsg{status: "Validation failed: bad", err: fail}},
+			wantState:     menuView,
+			wantCursor:    2,
+			wantLogs:      []string{"Validation failed: bad"},
+			wantActionMsg: "Validation failed: bad",
+		},
+		{
+			name:           "the menu waits for a safe action to finish",
+			msgs:           []tea.Msg{key("down"), key("down"), key("enter"), key("up"), key("enter")},
+			wantState:      menuView,
+			wantCursor:     1,
+			wantActionMsg:  "Validating Niri config...",
+			wantProcessing: true,
+		},
+		{
+			name:           "install first checks the packages",
+			msgs:           []tea.Msg{key("enter")},
+			wantState:      actionView,
+			wantActionMsg:  "Checking the packages are in the repositories...",
+			wantProcessing: true,
+			wantMsg:        stubMsg("check"),
+		},
+		{
+			name:           "install lists the packages to pick from first",
+			msgs:           []tea.Msg{key("enter"), packagesCheckedMsg{pkgs: []string{"niri", "waybar"}}},
+			wantState:      pickView,
+			wantActionMsg:  "Checking the packages are in the repositories...",
+			wantProcessing: true,
+		},
+		{
+			name:           "low disk space asks before the install",
+			msgs:           []tea.Msg{key("enter"), packagesCheckedMsg{pkgs: []string{"niri"}, download: []niri.Check{{Name: "Free space for /var/cache/pkg", Warn: true, Detail: "0.5 GiB free"}}}},
+			wantState:      confirmView,
+			wantLogs:       []string{"The download may fail:", "! Free space for /var/cache/pkg: 0.5 GiB free"},
+			wantActionMsg:  "Checking the packages are in the repositories...",
+			wantProcessing: true,
+		},
+		{
+			name:           "installing despite low disk space goes on to the package list",
+			msgs:           []tea.Msg{key("enter"), packagesCheckedMsg{pkgs: []string{"niri"}, download: []niri.Check{{Name: "Free space for /var/cache/pkg", Warn: true}}}, key("y")},
+			wantState:      actionView,
+			wantLogs:       []string{"The download may fail:", "! Free space for /var/cache/pkg"},
+			wantActionMsg:  "Checking the packages are in the repositories...",
+			wantProcessing: true,
+			wantMsg:        packagesCheckedMsg{pkgs: []string{"niri"}},
+		},
+		{
+			name:          "esc at the package list goes back to the menu",
+			msgs:          []tea.Msg{key("enter"), packagesCheckedMsg{pkgs: []string{"niri", "waybar"}}, key("esc")},
+			wantState:     menuView,
+			wantActionMsg: "Cancelled.",
+		},
+		{
+			name:           "install starts once it is confirmed",
+			msgs:           installing(),
+			wantState:      installView,
+			wantProcessing: true,
+			wantMsg:        stubMsg("install:niri waybar"),
+		},
+		{
+			name:           "only the checked packages are installed",
+			msgs:           []tea.Msg{key("enter"), packagesCheckedMsg{pkgs: []string{"niri", "foot", "waybar"}}, key("down"), key(" "), key("enter")},
+			wantState:      installView,
+			wantProcessing: true,
+			wantMsg:        stubMsg("install:niri waybar"),
+		},
+		{
+			name:           "enter with nothing checked stays on the list",
+			msgs:           []tea.Msg{key("enter"), packagesCheckedMsg{pkgs: []string{"niri"}}, key(" "), key("enter")},
+			wantState:      pickView,
+			wantActionMsg:  "Checking the packages are in the repositories...",
+			wantProcessing: true,
+		},
+		{
+			name: "missing packages offer to install the rest",
+			msgs: []tea.Msg{
+				key("enter"), packagesCheckedMsg{pkgs: []string{"niri", "wayber"}, missing: []string{"wayber"}},
+				key("enter"), pickPackagesMsg{"niri"}, key("enter"),
+			},
+			wantState:      installView,
+			wantLogs:       []string{"Not in the repositories: wayber"},
+			wantProcessing: true,
+			wantMsg:        stubMsg("install:niri"),
+		},
+		{
+			name: "install is abandoned when no package is available",
+			msgs: []tea.Msg{
+				key("enter"), packagesCheckedMsg{pkgs: []string{"wayber"}, missing: []string{"wayber"}},
+			},
+			wantState:     actionView,
+			wantLogs:      []string{"Not in the repositories: wayber", "Error: Not in the repositories: wayber"},
+			wantActionMsg: "Checking the packages are in the repositories...",
+		},
+		{
+			name: "a complete cache installs from its files",
+			msgs: []tea.Msg{
+				cacheScannedMsg{dir: "/cache", pkgs: []string{"niri"}, files: map[string]string{"niri": "/cache/niri-25.02.pkg"}},
+				key("y"), startInstallMsg{pkgs: []string{"niri"}, files: map[string]string{"niri": "/cache/niri-25.02.pkg"}},
+			},
+			wantState:      installView,
+			wantProcessing: true,
+			wantMsg:        stubMsg("install:niri (1 cached)"),
+		},
+		{
+			name: "packages missing from the cache can be fetched",
+			msgs: []tea.Msg{
+				cacheScannedMsg{dir: "/cache", pkgs: []string{"niri", "waybar"}, files: map[string]string{"niri": "/cache/niri-25.02.pkg"}, missing: []string{"waybar"}},
+				key("enter"),
+			},
+			wantState:     actionView,
+			wantLogs:      []string{"Not in /cache: waybar"},
+			wantActionMsg: "Installing Niri...",
+			wantMsg:       startInstallMsg{pkgs: []string{"niri", "waybar"}, files: map[string]string{"niri": "/cache/niri-25.02.pkg"}},
+		},
+		{
+			name:          "an empty cache installs nothing",
+			msgs:          []tea.Msg{cacheScannedMsg{dir: "/cache", pkgs: []string{"niri"}, missing: []string{"niri"}}},
+			wantState:     menuView,
+			wantLogs:      []string{"Error: None of the packages are in /cache"},
+			wantActionMsg: "Error: None of the packages are in /cache",
+		},
+		{
+			name: "upgrades list every version that changed",
+			msgs: []tea.Msg{upgradeMsg{changes: []niri.VersionChange{
+				{Package: "niri", Old: "25.01", New: "25.02"},
+				{Package: "libinput", New: "1.27.1"},
+			}}},
+			wantState: textView,
+			wantLogs:  []string{"What changed:\n  niri      25.01 → 25.02\n  libinput  (new) → 1.27.1"},
+		},
+		{
+			name:          "an upgrade that changed nothing says so",
+			msgs:          []tea.Msg{upgradeMsg{}},
+			wantState:     menuView,
+			wantLogs:      []string{"Everything was already up to date."},
+			wantActionMsg: "Everything was already up to date.",
+		},
+		{
+			name:          "a custom look is written after its last answer",
+			msgs:          []tea.Msg{appearanceStepMsg{look: niri.Appearance{Gaps: 8, Slowdown: 0.5}, step: len(appearanceSteps)}},
+			wantState:     actionView,
+			wantActionMsg: "Updating niri config...",
+			wantMsg:       stubMsg("appearance: gaps=8 ring=0 color= slowdown=0.5"),
+		},
+		{
+			name:           "keys are ignored while installing",
+			msgs:           installing(key("down"), key("q")),
+			wantState:      installView,
+			wantProcessing: true,
+		},
+		{
+			name:           "install progress is logged as it arrives",
+			msgs:           installing(progressMsg{line: "Successfully installed niri"}),
+			wantState:      installView,
+			wantLogs:       []string{"Successfully installed niri"},
+			wantProcessing: true,
+		},
+		{
+			name:           "p asks the install to pause",
+			msgs:           installing(key("p")),
+			wantState:      installView,
+			wantProcessing: true,
+		},
+		{
+			name:           "a paused install resumes with r",
+			msgs:           installing(key("p"), pausedMsg{remaining: []string{"waybar"}}, key("r")),
+			wantState:      installView,
+			wantLogs:       []string{"Paused with 1 packages left.", "Resuming the install..."},
+			wantProcessing: true,
+		},
+		{
+			name:          "successful install shows a summary and keeps the logs",
+			msgs:          installing(statusMsg{status: "Successfully installed niri"}),
+			wantState:     summaryView,
+			wantLogs:      []string{"Successfully installed niri"},
+			wantActionMsg: "Successfully installed niri",
+		},
+		{
+			name:          "any key leaves the summary for the menu",
+			msgs:          installing(statusMsg{status: "Successfully installed niri"}, key("x")),
+			wantState:     menuView,
+			wantLogs:      []string{"Successfully installed niri"},
+			wantActionMsg: "Successfully installed niri",
+		},
+		{
+			name:      "failed install stays on the install view",
+			msgs:      installing(statusMsg{status: "Failed to install niri", err: fail}),
+			wantState: installView,
+			wantLogs:  []string{"Failed to install niri"},
+		},
+		{
+			name:           "r after a failed install retries only what it did not install",
+			msgs:           installing(statusMsg{status: "Failed to install waybar", err: fail, failed: []string{"waybar"}}, key("r")),
+			wantState:      installView,
+			wantLogs:       []string{"Failed to install waybar", "Retrying 1 packages: waybar"},
+			wantProcessing: true,
+			wantMsg:        stubMsg("install:waybar"),
+		},
+		{
+			name:          "esc after a failed install goes back to the menu",
+			msgs:          installing(statusMsg{status: "Failed to install waybar", err: fail, failed: []string{"waybar"}}, key("esc")),
+			wantState:     menuView,
+			wantLogs:      []string{"Failed to install waybar"},
+			wantActionMsg: "1 packages are not installed: waybar. Retry Failed Packages tries them again.",
+		},
+		{
+			name:           "configure first works out what it would change",
+			msgs:           []tea.Msg{key("down"), key("enter"), configureToolsMsg{tools: []string{"waybar"}, cursor: 4}, key("enter")},
+			wantState:      actionView,
+			wantCursor:     1,
+			wantActionMsg:  "Checking what Configure Niri would change...",
+			wantProcessing: true,
+			wantMsg:        stubMsg("plan configure:waybar with "),
+		},
+		{
+			name:          "successful action returns to the menu with its result",
+			msgs:          []tea.Msg{key("down"), key("down"), key("enter"), statusMsg{status: "Niri configuration is valid."}},
+			wantState:     menuView,
+			wantCursor:    2,
+			wantLogs:      []string{"Niri configuration is valid."},
+			wantActionMsg: "Niri configuration is valid.",
+		},
+		{
+			name:          "failed action stays on the action view",
+			msgs:          []tea.Msg{key("down"), key("enter"), configureToolsMsg{tools: []string{"waybar"}, cursor: 4}, key("enter"), statusMsg{status: "Configuration failed: bad", err: fail}},
+			wantState:     actionView,
+			wantCursor:    1,
+			wantLogs:      []string{"Configuration failed: bad"},
+			wantActionMsg: "Checking what Configure Niri would change...",
+		},
+		{
+			name: "save logs receives the accumulated logs",
+			msgs: []tea.Msg{
+				key("down"), key("down"), key("enter"), statusMsg{status: "Niri configuration is valid."},
+				key("down"), key("enter"),
+			},
+			wantState:      actionView,
+			wantCursor:     3,
+			wantLogs:       []string{"Niri configuration is valid."},
+			wantActionMsg:  "Saving logs...",
+			wantProcessing: true,
+			wantMsg:        stubMsg("save:Niri configuration is valid."),
+		},
+		{
+			name:          "niri can run on this terminal once the checks pass",
+			msgs:          []tea.Msg{launchReadyMsg{report: []string{"✓ seatd running"}}, key("enter")},
+			wantState:     actionView,
+			wantLogs:      []string{"✓ seatd running"},
+			wantActionMsg: "Running niri...",
+			wantMsg:       stubMsg("session"),
+		},
+		{
+			name:          "or in the background",
+			msgs:          []tea.Msg{launchReadyMsg{report: []string{"✓ seatd running"}}, key("down"), key("enter")},
+			wantState:     actionView,
+			wantLogs:      []string{"✓ seatd running"},
+			wantActionMsg: "Launching niri...",
+			wantMsg:       stubMsg("launch"),
+		},
+		{
+			name:          "a session that exits returns to the menu",
+			msgs:          []tea.Msg{sessionEndedMsg{}},
+			wantState:     menuView,
+			wantLogs:      []string{"niri exited; back in NiriSetup."},
+			wantActionMsg: "niri exited; back in NiriSetup.",
+		},
+		{
+			name:          "a session that fails says how",
+			msgs:          []tea.Msg{sessionEndedMsg{err: fail}},
+			wantState:     menuView,
+			wantLogs:      []string{"Error: niri exited: boom"},
+			wantActionMsg: "Error: niri exited: boom",
+		},
+		{
+			name:      "failed launch offers the niri log",
+			msgs:      []tea.Msg{launchMsg{report: []string{"Started niri"}, err: fail}},
+			wantState: choiceView,
+			wantLogs:  []string{"Started niri\nError: boom"},
+		},
+		{
+			name:          "showing the niri log after a failed launch",
+			msgs:          []tea.Msg{launchMsg{err: fail}, key("enter")},
+			wantState:     actionView,
+			wantLogs:      []string{"Error: boom"},
+			wantActionMsg: "Reading the niri log...",
+			wantMsg:       stubMsg("launch log"),
+		},
+		{
+			name: "writes are validated once they settle",
+			msgs: []tea.Msg{
+				configWrittenMsg{backup: "config.kdl.bak.1"}, configWrittenMsg{backup: "config.kdl.bak.2"},
+				validateDueMsg(1), validateDueMsg(2),
+			},
+			wantState: menuView,
+			wantMsg:   stubMsg("auto validate:config.kdl.bak.1"),
+		},
+		{
+			name:      "an invalid write offers to undo it",
+			msgs:      []tea.Msg{autoValidatedMsg{problem: "unknown node", undo: "config.kdl.bak.1"}},
+			wantState: choiceView,
+			wantLogs:  []string{"The config just written fails niri validate:\nunknown node"},
+		},
+		{
+			name:      "q quits from the menu",
+			msgs:      []tea.Msg{key("q")},
+			wantState: menuView,
+			wantMsg:   tea.QuitMsg{},
+		},
+		{
+			name:           "exit entry quits",
+			msgs:           []tea.Msg{key("down"), key("down"), key("down"), key("down"), key("enter")},
+			wantState:      menuView,
+			wantCursor:     4,
+			wantProcessing: true,
+			wantMsg:        tea.QuitMsg{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, msg := feed(testModel(), tt.msgs...)
+
+			if m.state != tt.wantState {
+				t.Errorf("state = %v, want %v", m.state, tt.wantState)
+			}
+			if m.cursor != tt.wantCursor {
+				t.Errorf("cursor = %d, want %d", m.cursor, tt.wantCursor)
+			}
+			if !reflect.DeepEqual(m.logs, tt.wantLogs) {
+				t.Errorf("logs = %q, want %q", m.logs, tt.wantLogs)
+			}
+			if m.actionMsg != tt.wantActionMsg {
+				t.Errorf("actionMsg = %q, want %q", m.actionMsg, tt.wantActionMsg)
+			}
+			if m.isProcessing != tt.wantProcessing {
+				t.Errorf("isProcessing = %v, want %v", m.isProcessing, tt.wantProcessing)
+			}
+			if !reflect.DeepEqual(msg, tt.wantMsg) {
+				t.Errorf("last command produced %#v, want %#v", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestAsk(t *testing.T) {
+	run := func() tea.Msg { return stubMsg("run") }
+
+	tests := []struct {
+		name        string
+		autoYes     bool
+		force       bool
+		destructive bool
+		wantState   appState
+		wantMsg     tea.Msg
+	}{
+		{name: "asks by default", wantState: confirmView},
+		{name: "yes accepts", autoYes: true, wantState: actionView, wantMsg: stubMsg("run")},
+		{name: "yes still asks before destructive actions", autoYes: true, destructive: true, wantState: confirmView},
+		{name: "yes and force accept destructive actions", autoYes: true, force: true, destructive: true, wantState: actionView, wantMsg: stubMsg("run")},
+		{name: "force alone asks", force: true, destructive: true, wantState: confirmView},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testModel()
+			m.autoYes, m.force = tt.autoYes, tt.force
+			m, cmd := m.ask(confirmation{question: "Reset?", working: "Resetting...", run: run, destructive: tt.destructive})
+
+			if m.state != tt.wantState {
+				t.Errorf("state = %v, want %v", m.state, tt.wantState)
+			}
+			var msg tea.Msg
+			if cmd != nil {
+				msg = cmd()
+			}
+			if !reflect.DeepEqual(msg, tt.wantMsg) {
+				t.Errorf("command produced %#v, want %#v", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestHeadlessCLI(t *testing.T) {
+	msgOf := func(msg tea.Msg) tea.Cmd { return func() tea.Msg { return msg } }
+	for _, tt := range []struct {
+		name       string
+		action     string
+		checked    packagesCheckedMsg
+		installed  statusMsg
+		configured statusMsg
+		want       int
+		wantOut    []string
+		wantErr    string
+	}{
+		{
+			name:       "install then configure",
+			action:     "install",
+			checked:    packagesCheckedMsg{pkgs: []string{"niri", "waybar"}, download: []niri.Check{{Name: "Package repository pkg.FreeBSD.org", OK: true, Detail: "reachable"}}},
+			installed:  statusMsg{status: "Installed 2 packages."},
+			configured: statusMsg{status: "Niri configuration completed successfully."},
+			wantOut:    []string{"✓ Package repository pkg.FreeBSD.org: reachable", "Successfully installed niri (1/2)", "Installed 2 packages.", "Niri configuration completed successfully."},
+		},
+		{
+			name:    "missing packages fail the install",
+			action:  "install",
+			checked: packagesCheckedMsg{pkgs: []string{"niri", "wayber"}, missing: []string{"wayber"}},
+			want:    1,
+			wantErr: "Error: Not in the repositories: wayber",
+		},
+		{
+			name:      "a failed package fails the install",
+			action:    "install",
+			checked:   packagesCheckedMsg{pkgs: []string{"niri"}},
+			installed: statusMsg{status: "Installed 0 of 1 packages. Failed: niri", err: errors.New("failed")},
+			want:      1,
+			wantOut:   []string{"Successfully installed niri (1/2)"},
+			wantErr:   "Installed 0 of 1 packages. Failed: niri",
+		},
+		{
+			name:       "configure alone",
+			action:     "configure",
+			configured: statusMsg{status: "Error: bad config", err: errors.New("bad config")},
+			want:       1,
+			wantErr:    "Error: bad config",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := stubCommands()
+			c.check = func() tea.Cmd { return msgOf(tt.checked) }
+			c.install = func([]string, map[string]string, bool, *pauser) tea.Cmd {
+				return msgOf(progressMsg{line: "Successfully installed niri (1/2)", next: msgOf(packageProgressMsg{next: msgOf(tt.installed)})})
+			}
+			var tools []string
+			c.configure = func(_ context.Context, _ niri.Toolset, t []string) tea.Cmd { tools = t; return msgOf(tt.configured) }
+			var out, errOut strings.Builder
+			if got := headlessCLI(&out, &errOut, c, tt.action, false); got != tt.want {
+				t.Errorf("exit status %d, want %d", got, tt.want)
+			}
+			var lines []string
+			if out.Len() > 0 {
+				lines = strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			}
+			if !reflect.DeepEqual(lines, tt.wantOut) {
+				t.Errorf("printed %q, want %q", lines, tt.wantOut)
+			}
+			if got := strings.TrimSpace(errOut.String()); got != tt.wantErr {
+				t.Errorf("printed %q as the error, want %q", got, tt.wantErr)
+			}
+			if tt.want == 0 && !reflect.DeepEqual(tools, niri.DefaultConfigureTools) {
+				t.Errorf("configured with %q", tools)
+			}
+		})
+	}
+}
+
+func TestJSONLogs(t *testing.T) {
+	var out bytes.Buffer
+	jsonLogs = json.NewEncoder(&out)
+	t.Cleanup(func() { jsonLogs = nil })
+	m := testModel()
+	m.selected = "Configure Niri"
+	m.logAt(levelWarn, "Kept the existing config.kdl")
+	m.logAt(levelDebug, "left out at the info level")
+	m.selected = "Install Niri"
+	m, _ = feed(m, progressMsg{line: "Successfully installed niri (1/2) (took 3.2s)", pkg: "niri", status: "ok", took: 3200 * time.Millisecond})
+
+	var got []logEvent
+	for dec := json.NewDecoder(&out); dec.More(); {
+		var e logEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	want := []logEvent{
+		{Time: "2025-03-01T09:30:00Z", Level: "warn", Step: "configure-niri", Message: "Kept the existing config.kdl"},
+		{Time: "2025-03-01T09:30:00Z", Level: "info", Step: "install", Package: "niri", Status: "ok", Seconds: 3.2, Message: "Successfully installed niri (1/2) (took 3.2s)"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("--json-logs wrote %+v, want %+v", got, want)
+	}
+	if len(m.logs) != 2 {
+		t.Errorf("the TUI logs are %q", m.logs)
+	}
+}
+
+func TestQuitWhileAnActionRuns(t *testing.T) {
+	m := testModel()
+	m.isProcessing = true // a safe action runs under the menu
+	m, msg := feed(m, key("q"))
+	if !m.confirmingQuit || msg != nil || !strings.Contains(m.View(), "Really quit?") {
+		t.Fatalf("q while an action runs gave %v, want the question first", msg)
+	}
+	kept, msg := feed(m, key("down"))
+	if kept.confirmingQuit || msg != nil || !kept.isProcessing || kept.cursor != 0 {
+		t.Errorf("another key gave %v and cursor %d, want the action kept running where it was", msg, kept.cursor)
+	}
+	if _, msg = feed(m, key("y")); msg != (tea.QuitMsg{}) {
+		t.Errorf("y gave %v, want to quit", msg)
+	}
+
+	m = testModel()
+	m.isProcessing, m.noConfirmQuit = true, true
+	if _, msg = feed(m, key("ctrl+c")); msg != (tea.QuitMsg{}) {
+		t.Errorf("ctrl+c with --no-confirm-quit gave %v, want to quit at once", msg)
+	}
+}
+
+func TestFailedSafeActionIsAnError(t *testing.T) {
+	m, _ := feed(testModel(), key("down"), key("down"), key("enter"), statusMsg{status: "Validation failed: bad", err: errors.New("boom")})
+	if m.state != menuView || m.actionErr != "Validation failed: bad" {
+		t.Errorf("a failed safe action left state %v with actionErr %q", m.state, m.actionErr)
+	}
+}
+
+func TestSummaryCountsSkippedApart(t *testing.T) {
+	s := newSummary("install", []string{"niri", "waybar", "mako", "fuzzel"})
+	s.installed, s.skipped, s.failed = []string{"niri"}, []string{"waybar"}, []string{"mako"}
+	if line := s.String(); !strings.Contains(line, " total=4 installed=1 skipped=1 failed=1 pending=1 ") || !strings.HasSuffix(line, " failed_packages=mako") {
+		t.Errorf("the summary line is %q", line)
+	}
+}
+
+func TestTookNote(t *testing.T) {
+	for _, tt := range []struct {
+		took time.Duration
+		n    int
+		want string
+	}{
+		{3214 * time.Millisecond, 1, " (took 3.2s)"},
+		{64460 * time.Millisecond, 1, " (took 1m4.5s)"},
+		{12 * time.Second, 5, " (took 12s, installing 5 packages together)"},
+	} {
+		if got := tookNote(tt.took, tt.n); got != tt.want {
+			t.Errorf("tookNote(%v, %d) = %q, want %q", tt.took, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLogKinds(t *testing.T) {
+	m := testModel()
+	m.state = installView
+	m.log("Installing 2 packages")
+	m, _ = feed(m,
+		progressMsg{line: "Successfully installed niri (1/2)", pkg: "niri", status: "ok"},
+		progressMsg{line: "Failed to install waybar (2/2)", pkg: "waybar", status: "failed"},
+	)
+	m.logAt(levelWarn, "Warning: low disk space")
+	m.logAt(levelError, "Error: pkg exited 1")
+	want := []logKind{kindPlain, kindSuccess, kindError, kindWarning, kindError}
+	if !reflect.DeepEqual(m.logKinds, want) {
+		t.Errorf("the log kinds are %v, want %v", m.logKinds, want)
+	}
+	m.state = menuView
+	m, _ = feed(m, statusMsg{status: "Configured Niri."})
+	if got := m.logKinds[len(m.logKinds)-1]; got != kindSuccess {
+		t.Errorf("a result without an error is %v, want kindSuccess", got)
+	}
+}
+
+func TestSaveLogsToFile(t *testing.T) {
+	full := errors.New("disk full")
+
+	tests := []struct {
+		name      string
+		store     *memLog
+		saves     [][]string
+		wantLines []string
+		wantMsg   statusMsg
+	}{
+		{
+			name:      "writes the logs and reports where",
+			store:     &memLog{},
+			saves:     [][]string{{"Installed 3 packages.", "Niri configuration is valid."}},
+			wantLines: []string{"2025-03-01T09:30:00Z Installed 3 packages.", "2025-03-01T09:30:00Z Niri configuration is valid."},
+			wantMsg:   statusMsg{status: "Logs saved to /tmp/test.log"},
+		},
+		{
+			name:      "later saves append",
+			store:     &memLog{lines: []string{"from last time"}},
+			saves:     [][]string{{"first"}, {"second"}},
+			wantLines: []string{"from last time", "2025-03-01T09:30:00Z first", "2025-03-01T09:30:00Z second"},
+			wantMsg:   statusMsg{status: "Logs saved to /tmp/test.log"},
+		},
+		{
+			name:    "nothing to save",
+			store:   &memLog{},
+			saves:   [][]string{nil},
+			wantMsg: statusMsg{status: "No logs to save."},
+		},
+		{
+			name:    "write errors are reported",
+			store:   &memLog{err: full},
+			saves:   [][]string{{"Installed 3 packages."}},
+			wantMsg: statusMsg{status: "Failed to write to log file", err: full},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testModel()
+			m.cmds.logFile = tt.store
+			var msg tea.Msg
+			for _, logs := range tt.saves {
+				m.logs, m.logTimes, m.logKinds = nil, nil, nil
+				m.log(logs...)
+				msg = saveLogsToFile(m)()
+			}
+
+			if !reflect.DeepEqual(tt.store.lines, tt.wantLines) {
+				t.Errorf("wrote %q, want %q", tt.store.lines, tt.wantLines)
+			}
+			if !reflect.DeepEqual(msg, tt.wantMsg) {
+				t.Errorf("produced %#v, want %#v", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	defer func(old string) { logFile = old }(logFile)
+
+	logFile = ""
+	if got, want := logFilePath(), filepath.Join(os.TempDir(), "nirisetup.log"); got != want {
+		t.Errorf("default log file is %s, want %s", got, want)
+	}
+	logFile = expandHome("~/logs/niri/setup.log")
+	if want := filepath.Join(home, "logs", "niri", "setup.log"); logFile != want {
+		t.Fatalf("~ expanded to %s, want %s", logFile, want)
+	}
+	if err := (fileLog{}).Append([]string{"first"}); err != nil {
+		t.Fatalf("appending to a log file in missing directories: %v", err)
+	}
+	if err := (fileLog{}).Append([]string{"second"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(logFile); err != nil || string(data) != "first\nsecond\n" {
+		t.Errorf("log file holds %q (%v), want both lines", data, err)
+	}
+}
+
+func TestValidateNiriConfig(t *testing.T) {
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	status := func() string { return validateNiriConfig()().(statusMsg).status }
+
+	if got := status(); !strings.HasPrefix(got, "niri is not installed") {
+		t.Errorf("without niri, Validate Config says %q", got)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "niri"), []byte("#!/bin/sh\necho 'config is valid'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := status(), "There is no niri config at "+niri.ConfigPath()+" yet. Configure Niri writes one."; got != want {
+		t.Errorf("without a config, Validate Config says %q, want %q", got, want)
+	}
+	if err := os.MkdirAll(filepath.Dir(niri.ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(niri.ConfigPath(), []byte("prefer-no-csd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != "Niri configuration is valid." {
+		t.Errorf("with niri and a config, Validate Config says %q", got)
+	}
+
+	runner := &niritest.Runner{Answer: func(string) niritest.Reply {
+		return niritest.Reply{Stderr: "error: unknown node prefer-no-csd", Exit: 1}
+	}}
+	real := niri.Runner
+	niri.Runner = runner
+	defer func() { niri.Runner = real }()
+	if got, want := status(), "Validation failed: error: unknown node prefer-no-csd"; got != want {
+		t.Errorf("a config niri rejects gets %q, want %q", got, want)
+	}
+	if got := runner.Lines(); !reflect.DeepEqual(got, []string{"niri validate"}) {
+		t.Errorf("Validate Config ran %q", got)
+	}
+}
+
+func TestAppearanceSteps(t *testing.T) {
+	tests := []struct {
+		step    int
+		answer  string
+		wantErr bool
+	}{
+		{0, "8", false},
+		{0, "99", true},
+		{0, "wide", true},
+		{1, "0", false},
+		{2, "#ff8800", false},
+		{2, "red", false},
+		{2, "#ff88", false},
+		{2, "#ff880", true},
+		{3, "0", false},
+		{3, "0.5", false},
+		{3, "-1", true},
+	}
+	for _, tt := range tests {
+		l := niri.DefaultAppearance
+		err := appearanceSteps[tt.step].set(&l, tt.answer)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("step %d with %q returned %v, want error %v", tt.step, tt.answer, err, tt.wantErr)
+		}
+	}
+	if appearanceSteps[2].asked(niri.Appearance{FocusRing: 0}) {
+		t.Error("the ring colour is asked for with the ring off")
+	}
+}
+
+func TestMenu(t *testing.T) {
+	m := testModel()
+	m.cmds.choices = func() []string { return []string{"Validate Config", "Launch Niri", "Exit"} }
+	if got, want := m.menu(), []string{"Validate Config", "Launch Niri", "Exit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("menu outside niri is %q, want %q", got, want)
+	}
+	m.insideNiri = true
+	if got, want := m.menu(), []string{"Validate Config", "Reload Config", "Exit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("menu inside niri is %q, want %q", got, want)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	m := testModel()
+	next, _ := m.Update(key("?")) // Install Niri
+	m = next.(model)
+	if view := m.View(); !strings.Contains(view, "Needs sudo: yes") || strings.Contains(view, "Exit") {
+		t.Fatalf("? on Install Niri shows %q", view)
+	}
+	next, cmd := m.Update(key("enter"))
+	m = next.(model)
+	if m.help != "" || m.state != menuView || cmd != nil {
+		t.Errorf("the key closing the help went to state %v with help %q", m.state, m.help)
+	}
+	for _, e := range menuEntries {
+		if e.help == "" || !e.safe && e.undo == "" {
+			t.Errorf("%s has help %q and undo %q", e.name, e.help, e.undo)
+		}
+	}
+}
+
+func TestChangesNotice(t *testing.T) {
+	if changesNotice(nil) != "" {
+		t.Error("a notice without changes")
+	}
+	m := testModel()
+	acknowledged := false
+	m.cmds.acknowledge = func() error { acknowledged = true; return nil }
+	m.notice = changesNotice([]niri.Change{{Version: niri.Version, Notes: []string{"Regenerate waybar"}}})
+	if view := m.View(); !strings.Contains(view, "Regenerate waybar") || strings.Contains(view, "Exit") {
+		t.Fatalf("the notice shows %q", view)
+	}
+	next, cmd := m.Update(key("down"))
+	m = next.(model)
+	if m.notice != "" || !acknowledged || m.cursor != 0 || cmd != nil {
+		t.Errorf("the key dismissing the notice left notice %q, acknowledged %v, cursor %d", m.notice, acknowledged, m.cursor)
+	}
+}
+
+func TestConfigureTools(t *testing.T) {
+	m, _ := feed(testModel(), configureToolsMsg{tools: niri.DefaultConfigureTools})
+	var labels []string
+	for _, o := range m.choice.options {
+		labels = append(labels, o.label)
+	}
+	if want := []string{"[x] Starter waybar config", "[x] Starter mako config", "[ ] Starter fuzzel config"}; m.state != choiceView || !reflect.DeepEqual(labels[:3], want) {
+		t.Fatalf("state %v offers %q, want %q first", m.state, labels, want)
+	}
+	_, msg := feed(m, key("down"), key("enter"))
+	if want := (configureToolsMsg{tools: []string{"waybar"}, cursor: 1}); !reflect.DeepEqual(msg, want) {
+		t.Errorf("unchecking mako gave %#v, want %#v", msg, want)
+	}
+	_, msg = feed(m, key("down"), key("down"), key("enter"))
+	if want := (configureToolsMsg{tools: []string{"waybar", "mako", "fuzzel"}, cursor: 2}); !reflect.DeepEqual(msg, want) {
+		t.Errorf("checking fuzzel gave %#v, want %#v", msg, want)
+	}
+	if niri.DefaultConfigureTools[1] != "mako" {
+		t.Errorf("toggling changed the defaults to %q", niri.DefaultConfigureTools)
+	}
+}
+
+func TestEditInEditor(t *testing.T) {
+	t.Setenv("EDITOR", "nvim -p")
+	m := testModel()
+	m.cmds.choices = func() []string { return []string{"Edit Config", "Exit"} }
+	m.choices = m.menu()
+	m, _ = feed(m, key("enter"))
+	if m.state != choiceView || m.choice.options[0].label != "In nvim -p, then validate it" {
+		t.Fatalf("Edit Config offers %+v in state %v", m.choice.options, m.state)
+	}
+	m, msg := feed(m, key("enter"))
+	if msg != stubMsg("editor backup") {
+		t.Fatalf("picking the editor ran %v, want the backup first", msg)
+	}
+	m, msg = feed(m, editorBackupMsg{backup: "/tmp/config.kdl.bak"})
+	if msg != stubMsg("editor") || !slices.Contains(m.logs, "Backed up niri config to /tmp/config.kdl.bak") {
+		t.Fatalf("after the backup it ran %v and logged %q", msg, m.logs)
+	}
+	editing := m
+	m, msg = feed(m, editorExitedMsg{})
+	if m.state != actionView || m.actionMsg != "Validating Niri config..." || msg != stubMsg("validate") {
+		t.Errorf("after the editor exits the state is %v, %q and it ran %v, want the validation", m.state, m.actionMsg, msg)
+	}
+	m, _ = feed(editing, editorExitedMsg{err: errors.New("exit status 1")})
+	if m.state != actionView || m.isProcessing || !slices.Contains(m.logs, "Error: nvim -p failed: exit status 1") {
+		t.Errorf("an editor failing leaves %v, processing %v, logs %q", m.state, m.isProcessing, m.logs)
+	}
+
+	m = testModel()
+	m.cmds.choices = func() []string { return []string{"Edit Config", "Exit"} }
+	m.choices = m.menu()
+	if _, msg := feed(m, key("enter"), key("down"), key("enter")); msg != stubMsg("edit") {
+		t.Errorf("editing inside NiriSetup ran %v", msg)
+	}
+}
+
+func TestToolsetWizard(t *testing.T) {
+	labels := func(m model) []string {
+		var labels []string
+		for _, o := range m.choice.options {
+			labels = append(labels, o.label)
+		}
+		return labels
+	}
+	m, _ := feed(testModel(), toolsetMsg{toolset: niri.DefaultToolset, installed: map[string]bool{"alacritty": true, "fuzzel": true}})
+	if want := []string{"alacritty", "foot (not installed)", "kitty (not installed)", "Back to the menu"}; m.state != choiceView || !reflect.DeepEqual(labels(m), want) {
+		t.Fatalf("state %v offers the terminals %q, want %q", m.state, labels(m), want)
+	}
+	_, msg := feed(m, key("down"), key("enter"))
+	m, _ = feed(m, msg)
+	if want := []string{"fuzzel", "wofi (not installed)", "Back to the menu"}; !reflect.DeepEqual(labels(m), want) {
+		t.Fatalf("after the terminal the launchers are %q, want %q", labels(m), want)
+	}
+	_, msg = feed(m, key("down"), key("enter"))
+	m, _ = feed(m, msg)
+	if want := []string{"waybar (not installed)", "yambar (not installed)", "No bar", "Back to the menu"}; !reflect.DeepEqual(labels(m), want) {
+		t.Fatalf("after the launcher the bars are %q, want %q", labels(m), want)
+	}
+	_, msg = feed(m, key("down"), key("down"), key("enter"))
+	m, _ = feed(m, msg)
+	if want := []string{"[ ] Starter waybar config", "[x] Starter mako config"}; !reflect.DeepEqual(labels(m)[:2], want) {
+		t.Errorf("without a bar the tool configs are %q, want %q first", labels(m), want)
+	}
+	_, msg = feed(m, key("down"), key("down"), key("enter")) // check fuzzel
+	m, _ = feed(m, msg)
+	_, msg = feed(m, key("down"), key("down"), key("enter")) // Configure
+	if want := stubMsg("plan configure:mako,fuzzel with foot"); msg != want {
+		t.Errorf("configuring gave %v, want %v", msg, want)
+	}
+}
+
+func TestConfigurePlan(t *testing.T) {
+	m := testModel()
+	m.isProcessing, m.state = true, actionView
+	ts := niri.Toolset{Terminal: niri.StarterTerminals[1], Launcher: niri.StarterLaunchers[1]}
+	diff := "--- config.kdl\n+++ config.kdl\n-prefer-no-csd\n"
+
+	m, msg := feed(m, configurePlanMsg{plan: niri.ConfigurePlan{Diff: diff}, tools: []string{"mako"}, toolset: ts})
+	if m.state != textView || m.textConfirm == nil || msg != nil {
+		t.Fatalf("a plan with a diff gave state %v and %v, want the diff in textView asking first", m.state, msg)
+	}
+	if !strings.Contains(m.View(), "-prefer-no-csd") || !strings.Contains(m.View(), "y: go ahead") {
+		t.Errorf("the diff view does not show the diff and how to answer:\n%s", m.View())
+	}
+	declined, _ := feed(m, key("n"))
+	if declined.state != menuView || declined.actionMsg != "Cancelled." {
+		t.Errorf("n gave state %v and %q, want the menu and Cancelled.", declined.state, declined.actionMsg)
+	}
+	m, msg = feed(m, key("y"))
+	if want := stubMsg(`configure:mako with foot, wofi and ""`); m.state != actionView || m.actionMsg != "Configuring Niri..." || msg != want {
+		t.Errorf("y gave state %v, %q and %v, want Configuring Niri... and %v", m.state, m.actionMsg, msg, want)
+	}
+
+	m, msg = feed(m, configurePlanMsg{plan: niri.ConfigurePlan{Created: []string{"config.kdl"}}, tools: []string{"mako"}, toolset: ts})
+	if want := stubMsg(`configure:mako with foot, wofi and ""`); msg != want {
+		t.Errorf("a plan that only creates files gave %v, want %v without asking", msg, want)
+	}
+
+	m, msg = feed(m, configurePlanMsg{})
+	if m.state != menuView || msg != nil || !strings.HasPrefix(m.actionMsg, "Nothing to change") {
+		t.Errorf("a plan without changes gave state %v, %q and %v, want the menu saying nothing changes", m.state, m.actionMsg, msg)
+	}
+
+	m.autoYes = true
+	if _, msg = feed(m, configurePlanMsg{plan: niri.ConfigurePlan{Diff: diff}}); msg != nil {
+		t.Errorf("--yes without --force wrote over the config: %v", msg)
+	}
+	m.force = true
+	if _, msg = feed(m, configurePlanMsg{plan: niri.ConfigurePlan{Diff: diff}}); msg != stubMsg("configure:") {
+		t.Errorf("--yes --force gave %v, want configure without asking", msg)
+	}
+}
+
+func TestRuntimeNotice(t *testing.T) {
+	if runtimeNotice(nil) != "" {
+		t.Error("a notice without an error")
+	}
+	m := testModel()
+	m.notice = joinNotices(runtimeNotice(errors.New("failed to create XDG_RUNTIME_DIR /tmp/1001-runtime-dir: permission denied")), "", "Regenerate waybar")
+	view := m.View()
+	for _, want := range []string{"XDG_RUNTIME_DIR is not ready", "permission denied", "Regenerate waybar"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("the notice lacks %q: %q", want, view)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	widest := func(view string) int {
+		w := 0
+		for _, line := range strings.Split(view, "\n") {
+			w = max(w, lipgloss.Width(line))
+		}
+		return w
+	}
+	m := testModel()
+	if w := widest(m.View()); w != viewWidth {
+		t.Errorf("before the terminal says its size the menu is %d wide, want %d", w, viewWidth)
+	}
+	m, _ = feed(m, tea.WindowSizeMsg{Width: 40, Height: 30})
+	if w := widest(m.View()); w > 40 {
+		t.Errorf("on a 40 column terminal the menu is %d wide:\n%s", w, m.View())
+	}
+
+	m, _ = m.startInstall([]string{"niri"}, nil)
+	for i := range 40 {
+		m.log(fmt.Sprintf("a log line long enough to need wrapping on a narrow terminal %d", i))
+	}
+	m.followLogs()
+	if m.logView.Width != 36 || m.logView.Height != 30-installChrome {
+		t.Errorf("the install log is %dx%d on a 40x30 terminal, want 36x%d", m.logView.Width, m.logView.Height, 30-installChrome)
+	}
+	if w := widest(m.View()); w > 40 {
+		t.Errorf("on a 40 column terminal the install view is %d wide:\n%s", w, m.View())
+	}
+	m, _ = feed(m, tea.WindowSizeMsg{Width: 120, Height: 50})
+	if m.logView.Width != viewWidth-4 || m.logView.Height != 50-installChrome {
+		t.Errorf("the install log is %dx%d on a 120x50 terminal, want %dx%d", m.logView.Width, m.logView.Height, viewWidth-4, 50-installChrome)
+	}
+	if !m.logView.AtBottom() {
+		t.Error("the install log stopped following the newest line after a resize")
+	}
+
+	m, _ = feed(m, textMsg{title: "Text", text: strings.Repeat("word ", 40)})
+	m, _ = feed(m, tea.WindowSizeMsg{Width: 50, Height: 20})
+	if m.text.Width != 50 || m.text.Height != 20-textChrome {
+		t.Errorf("the text view is %dx%d on a 50x20 terminal, want 50x%d", m.text.Width, m.text.Height, 20-textChrome)
+	}
+	if w := widest(m.View()); w > 50 {
+		t.Errorf("on a 50 column terminal the text view is %d wide:\n%s", w, m.View())
+	}
+}
+
+func TestActionOutput(t *testing.T) {
+	m := testModel()
+	m.state, m.isProcessing, m.actionMsg = actionView, true, "Upgrading packages..."
+	for i := 1; i <= actionOutputLines+2; i++ {
+		m, _ = feed(m, progressMsg{line: fmt.Sprintf("[%d/8] Upgrading pkg%d", i, i)})
+	}
+	view := m.View()
+	if strings.Contains(view, "Upgrading pkg2") || !strings.Contains(view, "[8/8] Upgrading pkg8") {
+		t.Errorf("the view does not show the newest %d lines: %q", actionOutputLines, view)
+	}
+	if len(m.logs) != actionOutputLines+2 {
+		t.Errorf("the log kept %d of the lines", len(m.logs))
+	}
+	m, _ = feed(m, upgradeMsg{})
+	if m.actionOutput != nil {
+		t.Errorf("the output outlived the upgrade: %q", m.actionOutput)
+	}
+}
+
+func TestSpinner(t *testing.T) {
+	m := testModel()
+	starts := 0
+	m.cmds.spin = func(s spinner.Model) tea.Cmd { starts++; return s.Tick }
+	m, _ = m.ask(confirmation{question: "Upgrade?", working: "Upgrading packages..."})
+	m, _ = feed(m, key("y"))
+	if m.state != actionView || starts != 1 {
+		t.Fatalf("yes left state %v after %d spinner starts", m.state, starts)
+	}
+	first := m.View()
+	next, cmd := m.Update(m.spinner.Tick())
+	m = next.(model)
+	if cmd == nil || m.View() == first || starts != 1 {
+		t.Errorf("a tick did not turn the spinner on: %q", m.View())
+	}
+	m, _ = feed(m, statusMsg{status: "Upgraded."})
+	if _, cmd := m.Update(m.spinner.Tick()); cmd != nil || m.state != menuView {
+		t.Errorf("the spinner kept turning after the result in state %v", m.state)
+	}
+}
+
+func TestMenuFilter(t *testing.T) {
+	m := testModel()
+	m, _ = feed(m, key("/"), key("c"), key("o"), key("N"))
+	if want := []string{"Configure Niri", "Validate Config"}; !reflect.DeepEqual(m.choices, want) || !m.filtering {
+		t.Fatalf("the filter con left %q, want %q", m.choices, want)
+	}
+	m, _ = feed(m, key("j"))
+	if len(m.choices) != 0 || !strings.Contains(m.View(), "No entry matches") {
+		t.Errorf("j typed into the filter should match nothing: %q", m.choices)
+	}
+	m, last := feed(m, tea.KeyMsg{Type: tea.KeyBackspace}, key("enter"), key("down"), key("enter"))
+	if m.filtering || m.selected != "Validate Config" || last != stubMsg("validate") {
+		t.Errorf("the filtered menu selected %q with %v", m.selected, last)
+	}
+	m, _ = feed(m, key("esc"))
+	if m.filter != "" || len(m.choices) != 5 {
+		t.Errorf("esc left the filter %q and %q", m.filter, m.choices)
+	}
+}
+
+func TestApplyTheme(t *testing.T) {
+	t.Cleanup(func() { applyTheme(niri.Theme{}) })
+	applyTheme(niri.Theme{Accent: "#ffa07a", Error: "196"})
+	if got := titleStyle.GetForeground(); got != lipgloss.Color("#ffa07a") {
+		t.Errorf("the title is %v, want the theme's accent", got)
+	}
+	if got := errorStyle.GetForeground(); got != lipgloss.Color("196") {
+		t.Errorf("failures are %v, want the theme's error color", got)
+	}
+	if got := logStyle.GetForeground(); got != defaultTheme.text {
+		t.Errorf("the log is %v, want the default the theme leaves it", got)
+	}
+}
+
+func TestWorkspaceAppSteps(t *testing.T) {
+	m := testModel()
+	var got niri.WorkspaceApp
+	m.cmds.setWorkspace = func(_ context.Context, app niri.WorkspaceApp) tea.Cmd { got = app; return nil }
+	next, _ := m.Update(workspaceAppIDMsg{command: "firefox --private-window"})
+	m = next.(model)
+	if v := m.prompt.field.Value(); m.state != inputView || v != "firefox" {
+		t.Fatalf("asking for the app ID went to state %v with %q filled in", m.state, v)
+	}
+	next, _ = m.Update(key("enter"))
+	m = next.(model)
+	for _, answer := range []string{"0", "2"} {
+		m.prompt.field.SetValue(answer)
+		next, _ = m.Update(key("enter"))
+		m = next.(model)
+	}
+	if want := (niri.WorkspaceApp{Workspace: 2, Command: "firefox --private-window", AppID: "firefox"}); got != want || m.state != actionView {
+		t.Errorf("the steps set %+v and went to state %v, want %+v", got, m.state, want)
+	}
+}
+
+func TestRetryFailedPackages(t *testing.T) {
+	m := testModel()
+	m.failed = []string{"waybar"}
+	if got := m.menu(); !slices.Contains(got, "Retry Failed Packages") {
+		t.Fatalf("menu after a failed install is %q", got)
+	}
+	m.cursor = slices.Index(m.menu(), "Retry Failed Packages")
+	m.choices = m.menu()
+	next, cmd := m.Update(key("enter"))
+	m = next.(model)
+	if m.state != installView || cmd == nil || cmd() != stubMsg("install:waybar") {
+		t.Fatalf("Retry Failed Packages went to state %v", m.state)
+	}
+	m, _ = feed(m, statusMsg{status: "Installed 1 packages."}, key("enter"))
+	if m.state != menuView || m.failed != nil || !strings.Contains(m.actionMsg, "Installed 1 packages.") || slices.Contains(m.choices, "Retry Failed Packages") {
+		t.Errorf("a successful retry left state %v, failed %q, message %q and menu %q", m.state, m.failed, m.actionMsg, m.choices)
+	}
+}
+
+func TestInstallSummary(t *testing.T) {
+	m := testModel()
+	m, _ = m.startInstall([]string{"niri", "waybar", "jq", "foot"}, nil)
+	m.cmds.now = func() time.Time { return stubNow.Add(83 * time.Second) }
+	m, _ = feed(m,
+		progressMsg{line: "Successfully installed niri (1/4)", pkg: "niri", status: "ok"},
+		progressMsg{line: "Installed waybar from the cache (2/4)", pkg: "waybar", status: "cached"},
+		progressMsg{line: "Skipping jq, already installed (3/4)", pkg: "jq", status: "skipped"},
+		progressMsg{line: "Failed to install foot (4/4)", pkg: "foot", status: "failed"},
+		statusMsg{status: "Installed 3 packages."},
+	)
+	view := m.View()
+	for _, want := range []string{"4 packages: 2 installed, 1 skipped, 1 failed", "Took 1m23s.", "Installed 3 packages.", "Configure Niri"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("the summary lacks %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestDryRunInstall(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no hooks
+	bin := t.TempDir()
+	fake := "#!/bin/sh\n[ \"$*\" = \"info -e niri\" ]\n" // only niri is installed
+	if err := os.WriteFile(filepath.Join(bin, "pkg"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	niri.PrivilegeTool = "sudo" // even where doas is installed
+	defer func() { niri.PrivilegeTool = "" }()
+	updates := make(chan tea.Msg)
+	go runInstall(updates, []string{"niri", "waybar"}, map[string]string{"waybar": "/cache/waybar.pkg"}, true, newPauser())
+	var lines []string
+	for msg := range updates {
+		if line, ok := msg.(progressMsg); ok {
+			lines = append(lines, line.line)
+		}
+		if status, ok := msg.(statusMsg); ok {
+			if status.err != nil || !strings.HasPrefix(status.status, "Dry run") || !strings.Contains(status.status, "skipped: niri") {
+				t.Errorf("a dry run install ended with %q, %v", status.status, status.err)
+			}
+			break
+		}
+	}
+	if want := []string{"Skipping niri, already installed (1/2)", "Would run sudo pkg add /cache/waybar.pkg"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("a dry run install logged %q, want %q", lines, want)
+	}
+
+	m := testModel()
+	m.dryRun = true
+	m, _ = m.startInstall([]string{"niri"}, nil)
+	if view := m.View(); !strings.Contains(view, "(dry run)") {
+		t.Errorf("the install view of a dry run shows %q", view)
+	}
+}
+
+// TestDryRun runs menu entries that change the system, with the commands
+// they really run, under --dry-run and checks none of their changes is made.
+func TestDryRun(t *testing.T) {
+	none := func() tea.Cmd { return nil } // what the entry starts with, fed below
+	for _, tt := range []struct {
+		entry  string
+		msgs   func(m *model) []tea.Msg // set the real command and get to it
+		answer map[string]string        // what the commands print, by line
+		not    string                   // what the commands must not run
+	}{
+		{
+			entry: "Uninstall Niri",
+			msgs: func(m *model) []tea.Msg {
+				m.cmds.setupPkgs, m.cmds.uninstall = none, uninstallNiri
+				return []tea.Msg{setupPackagesMsg{pkgs: []string{"niri", "waybar"}}, key("y")}
+			},
+			not: "pkg delete",
+		},
+		{
+			entry: "Upgrade Packages",
+			msgs: func(m *model) []tea.Msg {
+				m.cmds.upgrade = upgradePackages
+				return []tea.Msg{key("y")}
+			},
+			answer: map[string]string{"pkg query %n\t%v\t%t": "niri\t25.02\t1700000000\n"},
+			not:    "pkg upgrade",
+		},
+		{
+			entry: "Manage Packages",
+			msgs: func(m *model) []tea.Msg {
+				m.cmds.packages, m.cmds.remove = none, removePackage
+				return []tea.Msg{removePackageMsg("foot"), key("y")}
+			},
+			not: "pkg delete",
+		},
+		{
+			entry: "Verify Packages",
+			msgs: func(m *model) []tea.Msg {
+				m.cmds.verify, m.cmds.reinstall = none, reinstallPackages
+				return []tea.Msg{integrityMsg{damaged: []string{"foot"}}, key("y")}
+			},
+			not: "pkg install",
+		},
+		{
+			entry: "Benchmark Mirrors",
+			msgs: func(m *model) []tea.Msg {
+				m.cmds.mirrors, m.cmds.useMirror = none, useMirror
+				return []tea.Msg{mirrorsMsg{timings: []niri.MirrorTiming{{URL: "pkg+https://pkg0.example.org/${ABI}/latest"}}}, key("y")}
+			},
+			not: "tee",
+		},
+		{
+			entry: "Set Up Login Manager",
+			msgs: func(m *model) []tea.Msg {
+				m.cmds.loginDMs, m.cmds.login = none, setUpDisplayManager
+				return []tea.Msg{displayManagersMsg{managers: niri.DisplayManagers[:1], installed: []bool{false}}, key("enter")}
+			},
+			not: "sudo",
+		},
+		{
+			entry: "Launch Niri",
+			msgs: func(m *model) []tea.Msg {
+				m.cmds.launchChecks, m.cmds.launch = none, launchNiri
+				return []tea.Msg{launchReadyMsg{}, key("down"), key("enter")}
+			},
+			not: "niri --session",
+		},
+	} {
+		t.Run(tt.entry, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			runner := &niritest.Runner{Answer: func(line string) niritest.Reply { return niritest.Reply{Stdout: tt.answer[line]} }}
+			real := niri.Runner
+			niri.Runner, niri.PrivilegeTool = runner, "sudo"
+			defer func() { niri.Runner, niri.PrivilegeTool = real, "" }()
+
+			m := testModel()
+			m.dryRun = true
+			m.cmds.choices = func() []string { return []string{tt.entry, "Exit"} }
+			m.choices = m.menu()
+			msgs := append([]tea.Msg{key("enter")}, tt.msgs(&m)...)
+			m, msg := feed(m, msgs...)
+			for msg != nil { // what streams its progress
+				m, msg = feed(m, msg)
+			}
+			if !strings.HasPrefix(m.actionMsg, "Dry run, nothing was changed.") {
+				t.Errorf("the dry run ended with %q in state %v, want the dry run result", m.actionMsg, m.state)
+			}
+			for _, line := range runner.Lines() {
+				if strings.Contains(line, tt.not) {
+					t.Errorf("the dry run ran %q", line)
+				}
+			}
+		})
+	}
+}
+
+func TestInstallStatus(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no hooks
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	runner := &niritest.Runner{Answer: func(line string) niritest.Reply {
+		switch {
+		case strings.HasPrefix(line, "pkg info -e "):
+			return niritest.Fail // nothing is installed yet
+		case strings.HasSuffix(line, "install -y niri waybar mako"):
+			return niritest.Reply{Stderr: "pkg: waybar: checksum mismatch\n", Exit: 1}
+		case strings.HasSuffix(line, "install -y waybar"):
+			return niritest.Reply{Stderr: "sudo: a password is required\n", Exit: 1}
+		}
+		return niritest.Reply{}
+	}}
+	real := niri.Runner
+	niri.Runner, niri.PrivilegeTool = runner, "sudo"
+	defer func() { niri.Runner, niri.PrivilegeTool = real, "" }()
+
+	updates := make(chan tea.Msg)
+	go runInstall(updates, []string{"niri", "waybar", "mako"}, nil, false, newPauser())
+	var lines []string
+	var status statusMsg
+	var total bool
+	for msg := range updates {
+		if line, ok := msg.(progressMsg); ok && line.pkg != "" {
+			// how long it took varies, that it is there does not
+			text, _, timed := strings.Cut(line.line, " (took ")
+			if timed != (line.status == "ok") {
+				t.Errorf("the line %q says how long it took: %v", line.line, timed)
+			}
+			lines = append(lines, text)
+		}
+		if line, ok := msg.(progressMsg); ok && strings.HasPrefix(line.line, "The install took ") {
+			total = true
+		}
+		if s, ok := msg.(statusMsg); ok {
+			status = s
+			break
+		}
+	}
+	// niri went in a pkg run of its own after the batch failed
+	if want := []string{"Successfully installed niri (1/3)", "Failed to install waybar (2/3)"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("the install logged %q, want %q", lines, want)
+	}
+	// sudo refusing stops the install, so mako is missing too
+	if !errors.Is(status.err, niri.ErrPrivilege) || !strings.HasPrefix(status.status, "Privilege escalation failed") || !reflect.DeepEqual(status.failed, []string{"waybar", "mako"}) {
+		t.Errorf("the install ended with %q, %v, failed %q", status.status, status.err, status.failed)
+	}
+	if !total {
+		t.Error("the install did not log how long it took in total")
+	}
+	var batched bool
+	for _, line := range runner.Lines() {
+		batched = batched || strings.HasSuffix(line, "install -y niri waybar mako")
+		if strings.HasSuffix(line, "install -y mako") {
+			t.Errorf("the install went on to %q after sudo refused", line)
+		}
+	}
+	if !batched {
+		t.Errorf("the install did not try the packages together first: %q", runner.Lines())
+	}
+}
+
+func TestInstallTimesEachPkgRun(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no hooks
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	const batch, single = 200 * time.Millisecond, 600 * time.Millisecond
+	var batched atomic.Bool
+	runner := &niritest.Runner{Answer: func(line string) niritest.Reply {
+		switch {
+		case line == "pkg info -e mako":
+			if batched.Load() {
+				return niritest.Reply{} // the batch got it in before failing
+			}
+			return niritest.Fail
+		case strings.HasPrefix(line, "pkg info -e "):
+			return niritest.Fail
+		case strings.HasSuffix(line, "install -y niri waybar mako"):
+			time.Sleep(batch)
+			batched.Store(true)
+			return niritest.Reply{Stdout: "[1/3] Installing niri-25.08...\n[2/3] Installing waybar-0.12...\n", Exit: 1}
+		case strings.HasSuffix(line, "install -y waybar"):
+			time.Sleep(single)
+		}
+		return niritest.Reply{}
+	}}
+	real := niri.Runner
+	niri.Runner, niri.PrivilegeTool = runner, "sudo"
+	defer func() { niri.Runner, niri.PrivilegeTool = real, "" }()
+
+	updates := make(chan tea.Msg)
+	go runInstall(updates, []string{"niri", "waybar", "mako"}, nil, false, newPauser())
+	took := map[string]time.Duration{}
+	together := map[string]bool{}
+	for msg := range updates {
+		if line, ok := msg.(progressMsg); ok && line.pkg != "" {
+			took[line.pkg] = line.took
+			together[line.pkg] = strings.Contains(line.line, "installing 3 packages together")
+		}
+		if status, ok := msg.(statusMsg); ok {
+			if status.err != nil {
+				t.Errorf("the install ended with %q, %v", status.status, status.err)
+			}
+			break
+		}
+	}
+	// niri and mako went in with the batch, waybar in a run of its own
+	for _, pkg := range []string{"niri", "mako"} {
+		if took[pkg] < batch || took[pkg] >= single || !together[pkg] {
+			t.Errorf("%s took %s, together %v, want the batch's time", pkg, took[pkg], together[pkg])
+		}
+	}
+	if took["waybar"] < single || took["waybar"] >= single+batch || together["waybar"] {
+		t.Errorf("waybar took %s, together %v, want its own run's time", took["waybar"], together["waybar"])
+	}
+}
+
+func TestInstallPausesBetweenPkgRuns(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no hooks
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	runner := &niritest.Runner{Answer: func(line string) niritest.Reply {
+		switch {
+		case strings.HasPrefix(line, "pkg info -e "):
+			return niritest.Fail
+		case strings.HasSuffix(line, "install -y niri waybar mako"):
+			// niri is in when pkg gets to waybar, which fails the run
+			return niritest.Reply{Stdout: "[1/3] Installing niri-25.08...\n[2/3] Installing waybar-0.12...\n", Stderr: "pkg: waybar: checksum mismatch\n", Exit: 1}
+		}
+		return niritest.Reply{}
+	}}
+	real := niri.Runner
+	niri.Runner, niri.PrivilegeTool = runner, "sudo"
+	defer func() { niri.Runner, niri.PrivilegeTool = real, "" }()
+
+	pause := newPauser()
+	updates := make(chan tea.Msg)
+	go runInstall(updates, []string{"niri", "waybar", "mako"}, nil, false, pause)
+	var lines, current, paused []string
+	for msg := range updates {
+		switch msg := msg.(type) {
+		case installingMsg:
+			current = append(current, strings.Join(msg.pkgs, " "))
+			// asked during the batch, which pkg cannot stop in the middle of
+			if paused == nil {
+				pause.set(true)
+			}
+		case pausedMsg:
+			paused = msg.remaining
+			pause.set(false)
+		case progressMsg:
+			if msg.pkg != "" {
+				text, _, _ := strings.Cut(msg.line, " (took ")
+				lines = append(lines, text)
+			}
+		}
+		if status, ok := msg.(statusMsg); ok {
+			if status.err != nil {
+				t.Errorf("the install ended with %q, %v", status.status, status.err)
+			}
+			break
+		}
+	}
+	if want := []string{"waybar", "mako"}; !slices.Equal(paused, want) {
+		t.Errorf("the install paused with %q to go, want %q", paused, want)
+	}
+	if want := []string{"niri waybar mako", "niri", "waybar", "waybar", "mako"}; !slices.Equal(current, want) {
+		t.Errorf("the install showed it was installing %q, want %q", current, want)
+	}
+	if want := []string{"Successfully installed niri (1/3)", "Successfully installed waybar (2/3)", "Successfully installed mako (3/3)"}; !slices.Equal(lines, want) {
+		t.Errorf("the install logged %q, want %q", lines, want)
+	}
+}
+
+func TestInstallProgressBar(t *testing.T) {
+	const cells = progressBarWidth - len(" 100%") // what the percentage leaves
+	m := testModel()
+	m, _ = m.startInstall([]string{"niri", "waybar", "mako", "fuzzel"}, nil)
+	if view := m.View(); !strings.Contains(view, strings.Repeat("-", cells)+"   0%  0 of 4 packages") {
+		t.Errorf("the install starts with %q", view)
+	}
+	next, _ := m.Update(packageProgressMsg{progress: installProgress{done: 3, total: 4, stage: "Fetching jq.pkg", current: 1, size: 2}})
+	m = next.(model)
+	filled := cells * 3 / 4
+	if view := m.View(); !strings.Contains(view, strings.Repeat("#", filled)+strings.Repeat("-", cells-filled)+"  75%  3 of 4 packages") {
+		t.Errorf("three of four packages show %q", view)
+	}
+	if view := m.View(); !strings.Contains(view, strings.Repeat("#", cells/2)+strings.Repeat("-", cells-cells/2)+"  50%  Fetching jq.pkg") {
+		t.Errorf("half of a fetch shows %q", view)
+	}
+}
+
+func TestProgressTrackerConcurrency(t *testing.T) {
+	// pkg's event pipe reports stages while the install counts packages;
+	// go test -race catches them sharing the bar unguarded
+	const pkgs = 50
+	tracker := newProgressTracker(pkgs)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range int64(1000) {
+			tracker.stage("niri", "Fetching niri.pkg", i, 1000)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for done := 1; done <= pkgs; done++ {
+			if bar := tracker.finished(done); bar.done != done || bar.total != pkgs || bar.stage != "" {
+				t.Errorf("finishing %d packages gave %+v", done, bar)
+			}
+		}
+	}()
+	wg.Wait()
+	if bar, changed := tracker.stage("niri", "Fetching niri.pkg", 0, 1000); bar.done != pkgs || bar.total != pkgs {
+		t.Errorf("after the install the bar is %+v (changed %v)", bar, changed)
+	}
+	if _, changed := tracker.stage("niri", "Fetching niri.pkg", 1, 1000); changed {
+		t.Error("a tick within the same percent changed the bar")
+	}
+}
+
+func TestInstallShowsCurrentPackage(t *testing.T) {
+	m := testModel()
+	m, _ = m.startInstall([]string{"niri", "waybar"}, nil)
+	m, _ = feed(m, installingMsg{pkgs: []string{"niri", "waybar"}})
+	if view := m.View(); !strings.Contains(view, "Now installing: niri, waybar") {
+		t.Errorf("the batch install shows %q", view)
+	}
+	m, _ = feed(m, progressMsg{line: "Failed to install waybar (2/2)", pkg: "waybar", status: "failed"}, installingMsg{pkgs: []string{"waybar"}})
+	if view := m.View(); !strings.Contains(view, "Now installing: waybar") {
+		t.Errorf("installing waybar on its own shows %q", view)
+	}
+	m, _ = feed(m, progressMsg{line: "Successfully installed waybar (2/2)", pkg: "waybar", status: "ok"})
+	if view := m.View(); strings.Contains(view, "Now installing") {
+		t.Errorf("once waybar is done the install still shows %q", view)
+	}
+}
+
+func TestInstallLogScrolls(t *testing.T) {
+	m := testModel()
+	m, _ = m.startInstall([]string{"niri"}, nil)
+	for i := range 3 * installLogHeight {
+		next, _ := m.Update(progressMsg{line: fmt.Sprintf("line %d", i)})
+		m = next.(model)
+	}
+	if !m.logView.AtBottom() || !strings.Contains(m.View(), fmt.Sprintf("line %d", 3*installLogHeight-1)) {
+		t.Fatalf("the install log does not follow the newest line:\n%s", m.View())
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyPgUp})
+	m = next.(model)
+	next, _ = m.Update(progressMsg{line: "newest"})
+	m = next.(model)
+	if m.logView.AtBottom() || strings.Contains(m.View(), "newest") {
+		t.Errorf("a new line scrolled the log back down after pgup:\n%s", m.View())
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+	m = next.(model)
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+	m = next.(model)
+	if !strings.Contains(m.View(), "newest") {
+		t.Errorf("pgdn does not get back to the newest line:\n%s", m.View())
+	}
+}
+
+func TestViewLastLogs(t *testing.T) {
+	m := testModel()
+	m.choices = []string{"View Last Logs"}
+	next, _ := m.Update(key("enter"))
+	if m := next.(model); m.state != menuView || m.isProcessing || m.actionMsg != "Nothing has been logged yet." {
+		t.Errorf("View Last Logs without logs left state %v, processing %v and %q", m.state, m.isProcessing, m.actionMsg)
+	}
+	m.log("Successfully installed niri (1/1)")
+	next, _ = m.Update(key("enter"))
+	m = next.(model)
+	if m.state != textView || !strings.Contains(m.View(), "Successfully installed niri (1/1)") {
+		t.Fatalf("View Last Logs went to state %v showing %q", m.state, m.View())
+	}
+	next, _ = m.Update(key("esc"))
+	if m := next.(model); m.state != menuView || m.isProcessing {
+		t.Errorf("esc left state %v, processing %v", m.state, m.isProcessing)
+	}
+}
+
+func TestVersionLine(t *testing.T) {
+	defer func(v, c string) { version, commit = v, c }(version, commit)
+	version, commit = "1.2.3", "abc1234"
+	if got, want := versionLine(), "NiriSetup 1.2.3 (commit abc1234, "+runtime.Version()+")"; got != want {
+		t.Errorf("versionLine() = %q, want %q", got, want)
+	}
+	if view := testModel().View(); !strings.Contains(view, "Version 1.2.3") {
+		t.Errorf("the menu header does not show the version: %q", view)
+	}
+}
+
+func TestRootWarning(t *testing.T) {
+	if got := rootWarning("alice"); !strings.Contains(got, "not alice's. Run it as alice instead") {
+		t.Errorf("run through sudo by alice, the warning is %q", got)
+	}
+	if got := rootWarning(""); !strings.Contains(got, "Run it as the user who will log in to niri") {
+		t.Errorf("run as root directly, the warning is %q", got)
+	}
+}
+
+func TestKeyHints(t *testing.T) {
+	m := testModel()
+	if view := m.View(); !strings.Contains(view, "enter: select") || !strings.Contains(view, "q: quit") {
+		t.Errorf("the menu does not say how to use it: %q", view)
+	}
+	m.state, m.actionMsg = actionView, "Configuring Niri..."
+	if view := m.View(); !strings.Contains(view, "ctrl+c: cancel   other keys wait until it is done") {
+		t.Errorf("an action does not say input waits for it: %q", view)
+	}
+	m.state, m.isProcessing = installView, true
+	if view := m.View(); !strings.Contains(view, "ctrl+c: cancel   other keys wait until it is done") {
+		t.Errorf("the install does not say input waits for it: %q", view)
+	}
+	m.state, m.choice = choiceView, choice{question: "Which?", options: []option{{label: "Back to the menu"}}}
+	if view := m.View(); !strings.Contains(view, "esc: back to the menu") {
+		t.Errorf("a choice does not say how to leave it: %q", view)
+	}
+	m, _ = m.pickPackages([]string{"niri", "foot"})
+	if view := m.View(); !strings.Contains(view, "space: check") || !strings.Contains(view, "[x] foot") {
+		t.Errorf("the package list does not say how to uncheck one: %q", view)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m := testModel()
+	cancels := 0
+	m.cmds.cancel = func() { cancels++ }
+	m, _ = m.startInstall([]string{"niri", "waybar"}, nil)
+	m, _ = feed(m, key("ctrl+c"), key("y"), key("ctrl+c"))
+	if m.state != installView || cancels != 1 || !slices.Contains(m.logs, "Cancelling the install...") {
+		t.Fatalf("ctrl+c during the install left state %v after %d cancels, logs %q", m.state, cancels, m.logs)
+	}
+	m, _ = feed(m, statusMsg{status: "Install cancelled. Installed 1 of 2 packages.", err: niri.ErrCancelled, failed: []string{"waybar"}})
+	if m.state != menuView || m.actionMsg != "Install cancelled. Installed 1 of 2 packages." || !slices.Contains(m.choices, "Retry Failed Packages") {
+		t.Errorf("a cancelled install went to state %v with %q and menu %q", m.state, m.actionMsg, m.choices)
+	}
+
+	m.state, m.isProcessing, m.actionMsg = actionView, true, "Configuring Niri..."
+	m, _ = feed(m, key("ctrl+c"), key("y"))
+	if m.state != menuView || m.isProcessing || cancels != 2 || m.actionMsg != "Cancelled." || m.logs[len(m.logs)-1] != "Cancelled: Configuring Niri..." {
+		t.Errorf("ctrl+c during an action left state %v, processing %v, %d cancels and %q", m.state, m.isProcessing, cancels, m.actionMsg)
+	}
+}
+
+func TestCancelAsksFirst(t *testing.T) {
+	m := testModel()
+	cancels := 0
+	m.cmds.cancel = func() { cancels++ }
+	installing, _ := m.startInstall([]string{"niri"}, nil)
+	acting := m
+	acting.state, acting.isProcessing, acting.actionMsg = actionView, true, "Configuring Niri..."
+	for name, m := range map[string]model{"the install": installing, "an action": acting} {
+		state := m.state
+		m, _ = feed(m, key("ctrl+c"))
+		if !m.confirmingQuit || cancels != 0 || !strings.Contains(m.View(), "Really cancel?") {
+			t.Fatalf("ctrl+c during %s cancelled %d times, want the question first:\n%s", name, cancels, m.View())
+		}
+		kept, _ := feed(m, key("n"))
+		if kept.confirmingQuit || cancels != 0 || kept.state != state || !kept.isProcessing {
+			t.Errorf("another key during %s gave state %v, want it kept running", name, kept.state)
+		}
+		// what was asked about is gone once it finishes on its own
+		if done, _ := feed(m, statusMsg{status: "done"}); done.confirmingQuit {
+			t.Errorf("%s finishing left the question up", name)
+		}
+		m, _ = feed(m, key("y"))
+		if cancels != 1 || m.confirmingQuit {
+			t.Errorf("y during %s cancelled %d times", name, cancels)
+		}
+		cancels = 0
+	}
+	if m, _ := feed(acting, key("ctrl+c"), key("y")); m.state != menuView || m.actionMsg != "Cancelled." {
+		t.Errorf("cancelling an action left state %v with %q", m.state, m.actionMsg)
+	}
+	cancels = 0
+
+	installing.noConfirmQuit, acting.noConfirmQuit = true, true
+	if m, _ := feed(installing, key("ctrl+c")); m.confirmingQuit || cancels != 1 || !m.cancelling {
+		t.Errorf("ctrl+c during the install with --no-confirm-quit cancelled %d times", cancels)
+	}
+	if m, _ := feed(acting, key("ctrl+c")); m.confirmingQuit || cancels != 2 || m.state != menuView {
+		t.Errorf("ctrl+c during an action with --no-confirm-quit cancelled %d times, state %v", cancels, m.state)
+	}
+	// nothing runs on a failed action's view, so ctrl+c goes straight back
+	acting.isProcessing, acting.noConfirmQuit = false, false
+	if m, _ := feed(acting, key("ctrl+c")); m.confirmingQuit || m.state != menuView {
+		t.Errorf("ctrl+c on a finished action asked %v, state %v", m.confirmingQuit, m.state)
+	}
+}
+
+func TestRestoreConfig(t *testing.T) {
+	m := testModel()
+	m.cmds.backups = func() tea.Cmd {
+		return func() tea.Msg {
+			return backupsMsg{backups: []string{"/c/config.kdl.bak.20250302-100000", "/c/config.kdl.bak.20250301-090000"}}
+		}
+	}
+	m.choices = []string{"Restore Config"}
+	next, cmd := m.Update(key("enter"))
+	m = next.(model)
+	msg := cmd()
+	if m.state != actionView || !msg.(backupsMsg).restore {
+		t.Fatalf("Restore Config went to state %v and produced %#v", m.state, msg)
+	}
+	m, msg = feed(m, msg, key("down"), key("enter"))
+	if m.state != actionView || m.actionMsg != "Restoring backup..." || msg != stubMsg("restore:/c/config.kdl.bak.20250301-090000") {
+		t.Errorf("picking the second backup went to state %v with %q and produced %#v", m.state, m.actionMsg, msg)
+	}
+
+	m = testModel()
+	next, _ = m.Update(backupsMsg{restore: true})
+	if m := next.(model); !strings.HasPrefix(m.actionMsg, "There are no backups of ") {
+		t.Errorf("Restore Config without backups says %q", m.actionMsg)
+	}
+}
+
+func TestUninstallNiri(t *testing.T) {
+	m := testModel()
+	m.cmds.setupPkgs = func() tea.Cmd { return func() tea.Msg { return stubMsg("setup packages") } }
+	m.cmds.uninstall = func(_ context.Context, pkgs []string) tea.Cmd {
+		return func() tea.Msg { return stubMsg("uninstall:" + strings.Join(pkgs, " ")) }
+	}
+	m.choices = []string{"Uninstall Niri"}
+	next, cmd := m.Update(key("enter"))
+	m = next.(model)
+	if m.state != actionView || cmd == nil || cmd() != stubMsg("setup packages") {
+		t.Fatalf("Uninstall Niri went to state %v", m.state)
+	}
+	next, _ = m.Update(setupPackagesMsg{pkgs: []string{"niri", "fuzzel"}})
+	m = next.(model)
+	if m.state != confirmView || !strings.Contains(m.confirm.question, "niri, fuzzel") || m.confirm.run() != stubMsg("uninstall:niri fuzzel") {
+		t.Errorf("the packages NiriSetup installed led to state %v asking %q", m.state, m.confirm.question)
+	}
+	next, _ = testModel().Update(setupPackagesMsg{})
+	if m := next.(model); m.state != menuView || !strings.Contains(m.actionMsg, "nothing to uninstall") {
+		t.Errorf("without packages to remove Uninstall Niri left state %v and %q", m.state, m.actionMsg)
+	}
+}
+
+func TestRepairSetup(t *testing.T) {
+	m := testModel()
+	m.cmds.choices = func() []string { return []string{"Repair", "Exit"} }
+	m.choices = m.menu()
+	m, _ = feed(m, key("enter"))
+	if m.state != confirmView || !strings.Contains(m.confirm.question, "Nothing that works is changed") {
+		t.Fatalf("Repair went to state %v asking %q", m.state, m.confirm.question)
+	}
+	m, msg := feed(m, key("y"))
+	if m.state != actionView || m.actionMsg != "Repairing the niri setup..." || msg != stubMsg("repair setup") {
+		t.Errorf("confirming Repair left state %v, %q and ran %v", m.state, m.actionMsg, msg)
+	}
+}
+
+func TestInputBehavior(t *testing.T) {
+	m := testModel()
+	var saved niri.InputBehavior
+	m.cmds.setBehavior = func(_ context.Context, b niri.InputBehavior) tea.Cmd { saved = b; return nil }
+	next, _ := m.Update(inputBehaviorMsg{})
+	m = next.(model)
+	msg := m.choice.options[2].run() // workspace-auto-back-and-forth
+	if want := (inputBehaviorMsg{behavior: niri.InputBehavior{WorkspaceAutoBackAndForth: true}, cursor: 2}); msg != want {
+		t.Fatalf("toggling returned %+v, want %+v", msg, want)
+	}
+	next, _ = m.Update(msg)
+	m = next.(model)
+	if m.choice.cursor != 2 || saved != msg.(inputBehaviorMsg).behavior {
+		t.Errorf("after the toggle the cursor is on %d and Save writes %+v", m.choice.cursor, saved)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	defer func(v logLevel) { verbosity = v }(verbosity)
+	verbosity = levelWarn
+	m := testModel()
+	m.logAt(levelError, "error")
+	m.logAt(levelWarn, "warn")
+	m.log("info")
+	m.logAt(levelDebug, "trace")
+	if want := []string{"error", "warn"}; !reflect.DeepEqual(m.logs, want) {
+		t.Errorf("logs at warn are %q, want %q", m.logs, want)
+	}
+}
+
+func TestLockedOut(t *testing.T) {
+	m := testModel()
+	m.cmds.lock = func() error { return &niri.LockedError{PID: 42} }
+	next, cmd := m.Update(key("enter")) // Install Niri
+	m = next.(model)
+	if m.state != menuView || cmd != nil || !strings.Contains(m.actionMsg, "pid 42") {
+		t.Fatalf("Install Niri with the lock held elsewhere went to state %v with %q", m.state, m.actionMsg)
+	}
+	m.cursor = 2 // Validate Config
+	if _, cmd := m.Update(key("enter")); cmd == nil {
+		t.Error("Validate Config did not run with the lock held elsewhere")
+	}
+	// it writes the settings file the other NiriSetup may write too
+	m.cmds.choices = func() []string { return []string{"Settings", "Exit"} }
+	m.choices, m.cursor = m.menu(), 0
+	if next, _ := m.Update(key("enter")); next.(model).state != menuView {
+		t.Error("Settings opened with the lock held elsewhere")
+	}
+}
+
+func TestSettings(t *testing.T) {
+	m := testModel()
+	var saved map[string]string
+	m.cmds.saveSettings = func(s map[string]string) error { saved = maps.Clone(s); return nil }
+	next, _ := m.Update(settingPickedMsg(0)) // dry-run
+	m = next.(model)
+	if !m.dryRun || m.state != choiceView || saved["dry-run"] != "on" {
+		t.Fatalf("stepping dry-run left dryRun=%v in state %v and saved %q", m.dryRun, m.state, saved)
+	}
+	if m.choice.cursor != 0 || !strings.HasSuffix(m.choice.options[0].label, " on") {
+		t.Errorf("Settings shows %q with the cursor on %d", m.choice.options[0].label, m.choice.cursor)
+	}
+
+	m = testModel()
+	warnings := applySettings(&m, map[string]string{"dry-run": "on", "yes": "on", "force": "maybe"}, map[string]bool{"yes": true})
+	if !m.dryRun || m.autoYes || m.force || len(warnings) != 1 {
+		t.Errorf("applySettings set dryRun=%v autoYes=%v force=%v with warnings %q, want only dry-run and a warning about force", m.dryRun, m.autoYes, m.force, warnings)
+	}
+}
+
+func TestAudio(t *testing.T) {
+	m := testModel()
+	next, _ := m.Update(audioMsg{{Name: "Sound device", OK: true}, {Name: "pipewire running", OK: true}})
+	if m = next.(model); m.state != menuView || !strings.Contains(m.actionMsg, "Audio already works.") {
+		t.Errorf("working audio went to state %v with %q", m.state, m.actionMsg)
+	}
+	m = testModel()
+	m.cmds.audio = func(_ context.Context) tea.Cmd { return func() tea.Msg { return stubMsg("audio") } }
+	next, _ = m.Update(audioMsg{{Name: "Sound device", OK: true}, {Name: "pipewire running"}})
+	if m = next.(model); m.state != confirmView || !strings.Contains(m.confirm.question, "✗ pipewire running") {
+		t.Errorf("missing pipewire went to state %v asking %q", m.state, m.confirm.question)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"NiriSetup/internal/niri"
+)
+
+// validateCLI runs niri validate on the config at path, or on standard
+// input when path is "-", prints the outcome and returns the exit status.
+func validateCLI(path string) int {
+	if path == "-" {
+		tmp, err := os.CreateTemp("", "nirisetup-*.kdl")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, trf("Error: %s", err))
+			return 2
+		}
+		defer os.Remove(tmp.Name())
+		_, err = io.Copy(tmp, os.Stdin)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, trf("Error: %s", fmt.Errorf("failed to read the config from stdin: %w", err)))
+			return 2
+		}
+		path = tmp.Name()
+	}
+
+	out, err := niri.ValidateFile(path)
+	if out = strings.TrimSpace(out); out != "" && (err != nil || verbosity >= levelInfo) {
+		fmt.Println(out) // niri's explanation of a failure is part of the error
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, trf("Validation failed: %s", err))
+		return 1
+	}
+	if verbosity >= levelInfo {
+		fmt.Println(tr("Niri configuration is valid."))
+	}
+	return 0
+}
+
+// statusCLI prints niri.Status, as JSON when asJSON is set, and returns the
+// exit status: 0 when the setup is complete, 1 when it is not.
+func statusCLI(asJSON bool) int {
+	status := niri.Status()
+	if asJSON {
+		out, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, trf("Error: %s", err))
+			return 2
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, c := range status.Checks {
+			fmt.Println(c.String())
+		}
+		if status.Complete {
+			fmt.Println(tr("The setup is complete."))
+		} else {
+			fmt.Println(tr("The setup is incomplete."))
+		}
+	}
+	if !status.Complete {
+		return 1
+	}
+	return 0
+}
+
+// headlessActions are what --headless can run.
+var headlessActions = []string{"install", "configure"}
+
+// headlessCLI runs action without the TUI, for provisioning scripts:
+// "install" installs the package list and then configures niri as
+// Configure Niri does with its default starter configs, "configure" only
+// configures. Progress goes to out line by line and a failure to errOut.
+// Nobody is there to answer a question, so packages missing from the
+// repositories fail the install and the download checks only warn. It
+// returns the exit status: 0 on success, 1 on any failure.
+func headlessCLI(out, errOut io.Writer, c commands, action string, dryRun bool) int {
+	fail := func(msg statusMsg) int {
+		fmt.Fprintln(errOut, msg.status)
+		return 1
+	}
+	if action == "install" {
+		checked, _ := c.check()().(packagesCheckedMsg)
+		for _, w := range checked.warnings {
+			fmt.Fprintln(errOut, w)
+		}
+		if checked.err != nil {
+			return fail(reportMsg(nil, checked.err))
+		}
+		if len(checked.missing) > 0 {
+			return fail(reportMsg(nil, errors.New(trf("Not in the repositories: %s", strings.Join(checked.missing, ", ")))))
+		}
+		for _, check := range checked.download {
+			fmt.Fprintln(out, check.String())
+		}
+		installed, _ := drain(out, c.install(checked.pkgs, nil, dryRun, newPauser())()).(statusMsg)
+		if installed.err != nil {
+			return fail(installed)
+		}
+		fmt.Fprintln(out, installed.status)
+	}
+	ctx := context.Background()
+	var preview *changeLog
+	if dryRun {
+		ctx, preview = previewing(ctx)
+	}
+	configured, _ := c.configure(ctx, niri.StarterToolset(), niri.DefaultConfigureTools)().(statusMsg)
+	if preview != nil {
+		configured.status = preview.report(configured.status, configured.err)
+	}
+	if configured.err != nil {
+		return fail(configured)
+	}
+	fmt.Fprintln(out, configured.status)
+	return 0
+}
+
+// drain prints the progress lines of a background action to out as they
+// come and returns its result.
+func drain(out io.Writer, msg tea.Msg) tea.Msg {
+	for {
+		switch m := msg.(type) {
+		case progressMsg:
+			fmt.Fprintln(out, m.line)
+			msg = m.next()
+		case packageProgressMsg:
+			msg = m.next()
+		case pausedMsg:
+			msg = m.next()
+		case installingMsg:
+			msg = m.next()
+		default:
+			return msg
+		}
+	}
+}
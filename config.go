@@ -0,0 +1,427 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"NiriSetup/internal/niri"
+	"NiriSetup/nirisetup"
+)
+
+// editorMsg carries the config text loaded for editView.
+type editorMsg struct {
+	text string
+	err  error
+}
+
+// editSavedMsg reports the outcome of saving from editView. problem holds
+// niri's complaint when validation refused the edit.
+type editSavedMsg struct {
+	problem string
+	err     error
+}
+
+// repairMsg reports that the niri config failed validation, so the user has
+// to pick how to repair it.
+type repairMsg struct {
+	problem string // output of niri validate
+	backup  string // newest backup that passes validation, if any
+}
+
+// backupsMsg carries the config backups, newest first.
+type backupsMsg struct {
+	backups []string
+	restore bool // listed for Restore Config rather than Compare Backups
+	err     error
+}
+
+// backupPickedMsg is the first backup picked for comparison, along with
+// the backups it can be compared to.
+type backupPickedMsg struct {
+	first   string
+	backups []string
+}
+
+// editorBackupMsg is the niri config backed up before the user's editor
+// gets it, "" when there was none yet.
+type editorBackupMsg struct {
+	backup string
+	err    error
+}
+
+// editorExitedMsg is the user's editor exiting.
+type editorExitedMsg struct {
+	err error
+}
+
+// configWrittenMsg reports that an action saved the niri config, with the
+// backup of the config it replaced.
+type configWrittenMsg struct {
+	backup string
+}
+
+// written carries config writes from running actions back to the TUI.
+var written = make(chan configWrittenMsg, 8)
+
+// waitForWrite delivers the next config write to Update.
+func waitForWrite() tea.Cmd {
+	return func() tea.Msg {
+		return <-written
+	}
+}
+
+// validateDelay is how long after the last config write the config is
+// validated, so an action that writes several times is checked once.
+const validateDelay = 500 * time.Millisecond
+
+// validateDueMsg is sent validateDelay after config write number seq.
+type validateDueMsg int
+
+// autoValidatedMsg is the outcome of validating the config after a write.
+// undo is the backup taken before the first write since the last check.
+type autoValidatedMsg struct {
+	problem string // niri's complaint, "" if the config is valid
+	undo    string
+}
+
+// updateConfig handles the messages of editing, validating, repairing and
+// restoring the niri config, and of checking it after every write.
+func (m model) updateConfig(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case repairMsg:
+		// Walk the user through the ways to get back to a valid config
+		m.logAt(levelError, trf("Validation failed: %s", msg.problem))
+		m.state = choiceView
+		m.choice = choice{question: trf("The niri config is invalid:\n\n%s\n\nHow do you want to repair it?", strings.TrimSpace(msg.problem))}
+		if msg.backup != "" {
+			m.choice.options = append(m.choice.options, option{
+				label:   trf("Restore %s", filepath.Base(msg.backup)),
+				working: tr("Restoring backup..."),
+				run:     m.cmds.restore(m.ctx, msg.backup),
+			})
+		}
+		m.choice.options = append(m.choice.options,
+			option{label: tr("Regenerate the default config"), working: tr("Writing default config..."), run: m.cmds.defaults(m.ctx)},
+			option{label: tr("Leave it as it is")},
+		)
+		return m, nil
+	case backupsMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if msg.restore {
+			return m.pickRestore(msg.backups)
+		}
+		if len(msg.backups) < 2 {
+			return m.Update(statusMsg{status: tr("Comparing needs at least two config backups.")})
+		}
+		m.state = choiceView
+		m.choice = choice{question: tr("Compare which backup...")}
+		for _, b := range msg.backups {
+			m.choice.options = append(m.choice.options, option{
+				label: filepath.Base(b),
+				run:   func() tea.Msg { return backupPickedMsg{first: b, backups: msg.backups} },
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case backupPickedMsg:
+		m.state = choiceView
+		m.choice = choice{question: trf("...with which backup?\n(first: %s)", filepath.Base(msg.first))}
+		for _, b := range msg.backups {
+			if b != msg.first {
+				m.choice.options = append(m.choice.options, option{
+					label:   filepath.Base(b),
+					working: tr("Comparing backups..."),
+					run:     m.cmds.compare(msg.first, b),
+				})
+			}
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case editorBackupMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if msg.backup != "" {
+			m.log(trf("Backed up niri config to %s", msg.backup))
+		}
+		m.actionMsg = trf("Editing %s...", niri.ConfigPath())
+		return m, m.cmds.openEditor(m.ctx)
+	case editorExitedMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, errors.New(trf("%s failed: %s", strings.Join(niri.Editor(), " "), msg.err))))
+		}
+		m.state = actionView
+		return m.runSafe(tr("Validating Niri config..."), m.cmds.validate())
+	case editorMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		m.state = editView
+		m.editErr = ""
+		m.editor = textarea.New()
+		m.editor.MaxHeight = editorMaxLines
+		m.editor.SetWidth(m.fitWidth(editorWidth))
+		m.editor.SetHeight(m.textHeight())
+		m.editor.CharLimit = 0
+		m.editor.SetValue(msg.text)
+		return m, m.editor.Focus()
+	case editSavedMsg:
+		if msg.err != nil {
+			// Keep the edits so the user can fix what niri complained about
+			m.editErr = strings.TrimSpace(msg.problem)
+			if m.editErr == "" {
+				m.editErr = msg.err.Error()
+			}
+			m.logAt(levelWarn, trf("Edit rejected: %s", m.editErr))
+			return m, nil
+		}
+		m.state = actionView
+		return m.Update(statusMsg{status: trf("Saved %s\nNiri configuration is valid.", niri.ConfigPath())})
+	case configWrittenMsg:
+		if m.unchecked == 0 {
+			m.undo = msg.backup
+		}
+		m.unchecked++
+		seq := m.unchecked
+		return m, tea.Batch(waitForWrite(), tea.Tick(validateDelay, func(time.Time) tea.Msg { return validateDueMsg(seq) }))
+	case validateDueMsg:
+		if int(msg) != m.unchecked {
+			return m, nil // another write came in since; its tick validates
+		}
+		undo := m.undo
+		m.unchecked, m.undo = 0, ""
+		return m, m.cmds.autoValidate(undo)
+	case autoValidatedMsg:
+		if msg.problem == "" {
+			m.log(tr("Checked the new config: niri validate passed."))
+			if m.state == menuView {
+				m.actionMsg = strings.TrimSpace(m.actionMsg + "\n" + tr("Checked the new config: niri validate passed."))
+			}
+			return m, nil
+		}
+		failed := trf("The config just written fails niri validate:\n%s", msg.problem)
+		m.logAt(levelError, failed)
+		if m.state != menuView || msg.undo == "" {
+			return m, nil // don't interrupt another action; the log has it
+		}
+		m.state = choiceView
+		m.choice = choice{
+			question: trf("%s\n\nUndo the change by restoring %s?", failed, msg.undo),
+			options: []option{
+				{label: tr("Undo the change"), working: tr("Restoring backup..."), run: m.cmds.restore(m.ctx, msg.undo)},
+				{label: tr("Keep it")},
+			},
+		}
+		return m, nil
+	}
+	return m, nil
+}
+
+// editKey handles a key in editView, which edits the config inside NiriSetup.
+func (m model) editKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+s":
+		m.editErr = tr("Validating...")
+		return m, m.cmds.saveEdit(m.ctx, m.editor.Value())
+	case "esc":
+		m.state = menuView
+		m.isProcessing = false
+		m.actionMsg = tr("Edit cancelled, config unchanged.")
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.editor, cmd = m.editor.Update(msg)
+	return m, cmd
+}
+
+func showKeybinds() tea.Cmd {
+	return func() tea.Msg {
+		sheet, err := niri.KeybindSheet()
+		return textMsg{title: tr("Keybinds"), text: sheet, err: err}
+	}
+}
+
+func showConfigPaths() tea.Cmd {
+	return func() tea.Msg {
+		return textMsg{title: tr("Where niri looks for its config"), text: niri.ConfigSearchReport()}
+	}
+}
+
+func reloadConfig(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ReloadConfig(ctx))
+	}
+}
+
+// validateWrittenConfig checks the config after an action wrote it. Without
+// niri installed there is nothing to check with, so it stays quiet.
+func validateWrittenConfig(undo string) tea.Cmd {
+	return func() tea.Msg {
+		if _, err := exec.LookPath("niri"); err != nil {
+			return nil
+		}
+		out, err := niri.ValidateConfig()
+		if err == nil {
+			return autoValidatedMsg{undo: undo}
+		}
+		return autoValidatedMsg{problem: cmp.Or(strings.TrimSpace(out), err.Error()), undo: undo}
+	}
+}
+
+// validateNiriConfig runs niri validate on the config, saying so plainly
+// when niri or the config is not there yet to validate.
+func validateNiriConfig() tea.Cmd {
+	return func() tea.Msg {
+		out, err := nirisetup.Validate()
+		switch {
+		case errors.Is(err, nirisetup.ErrNoNiri):
+			return statusMsg{status: tr("niri is not installed, so there is nothing to validate with. Run Install Niri first."), err: err}
+		case errors.Is(err, nirisetup.ErrNoConfig):
+			return statusMsg{status: trf("There is no niri config at %s yet. Configure Niri writes one.", niri.ConfigPath()), err: err}
+		case err != nil:
+			return statusMsg{status: trf("Validation failed: %s", out), err: err}
+		}
+		return statusMsg{status: tr("Niri configuration is valid.")}
+	}
+}
+
+// checkConfigForRepair validates the niri config, handing over to the
+// repair choices when it is broken.
+func checkConfigForRepair() tea.Cmd {
+	return func() tea.Msg {
+		d, err := niri.Diagnose()
+		if err != nil {
+			return reportMsg(nil, err)
+		}
+		if d.Valid {
+			return statusMsg{status: tr("Niri configuration is valid, nothing to repair.")}
+		}
+		return repairMsg{problem: d.Problem, backup: d.Backup}
+	}
+}
+
+func auditConfig() tea.Cmd {
+	return func() tea.Msg {
+		checks, version, err := niri.AuditConfig()
+		if err != nil {
+			return reportMsg(nil, err)
+		}
+		if len(checks) == 0 {
+			return statusMsg{status: trf("No deprecated options for niri %s.", version)}
+		}
+		report := []string{trf("Options to migrate for niri %s:", version)}
+		for _, c := range checks {
+			report = append(report, c.String())
+		}
+		return statusMsg{status: strings.Join(report, "\n")}
+	}
+}
+
+func restoreConfigBackup(ctx context.Context, backup string) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.RestoreBackup(ctx, backup))
+	}
+}
+
+func restoreDefaultConfig(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.RestoreDefaults(ctx))
+	}
+}
+
+func listBackups() tea.Cmd {
+	return func() tea.Msg {
+		backups, err := niri.Backups()
+		return backupsMsg{backups: backups, err: err}
+	}
+}
+
+// toRestore marks the backups cmd lists as ones for Restore Config.
+func toRestore(cmd tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		msg, _ := cmd().(backupsMsg)
+		msg.restore = true
+		return msg
+	}
+}
+
+// pickRestore offers the backups to restore config.kdl from.
+func (m model) pickRestore(backups []string) (tea.Model, tea.Cmd) {
+	if len(backups) == 0 {
+		return m.Update(statusMsg{status: trf("There are no backups of %s yet. One is taken before every change NiriSetup makes to it.", niri.ConfigPath())})
+	}
+	m.state = choiceView
+	m.choice = choice{question: tr("Restore which backup?\nThe current config is backed up first.")}
+	for _, b := range backups {
+		m.choice.options = append(m.choice.options, option{
+			label:   filepath.Base(b),
+			working: tr("Restoring backup..."),
+			run:     m.cmds.restore(m.ctx, b),
+		})
+	}
+	m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+	return m, nil
+}
+
+func compareBackups(a, b string) tea.Cmd {
+	return func() tea.Msg {
+		diff, err := niri.CompareBackups(a, b)
+		if diff == "" && err == nil {
+			diff = tr("The two backups are identical.")
+		}
+		return textMsg{title: tr("Changes between backups"), text: diff, err: err}
+	}
+}
+
+func loadConfigForEditing() tea.Cmd {
+	return func() tea.Msg {
+		data, err := os.ReadFile(niri.ConfigPath())
+		if err != nil {
+			return editorMsg{err: fmt.Errorf("failed to read niri config: %w", err)}
+		}
+		return editorMsg{text: string(data)}
+	}
+}
+
+// backUpForEditor backs up the niri config before openEditor hands it to
+// the user's editor, creating its directory for a config not written yet.
+func backUpForEditor(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		if err := niri.EnsureWritableDir(filepath.Dir(niri.ConfigPath())); err != nil {
+			return editorBackupMsg{err: err}
+		}
+		backup, err := niri.Backup(ctx)
+		return editorBackupMsg{backup: backup, err: err}
+	}
+}
+
+// openEditor hands the terminal to the user's editor on the niri config
+// until it exits.
+func openEditor(ctx context.Context) tea.Cmd {
+	if preview := niri.OptionsFrom(ctx).Preview; preview != nil {
+		return func() tea.Msg {
+			preview("Would open " + niri.ConfigPath() + " in " + strings.Join(niri.Editor(), " "))
+			return statusMsg{}
+		}
+	}
+	return tea.ExecProcess(niri.EditCommand(), func(err error) tea.Msg { return editorExitedMsg{err: err} })
+}
+
+func saveEditedConfig(ctx context.Context, text string) tea.Cmd {
+	return func() tea.Msg {
+		problem, err := niri.SaveSource(ctx, text)
+		return editSavedMsg{problem: problem, err: err}
+	}
+}
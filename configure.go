@@ -0,0 +1,683 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"NiriSetup/internal/niri"
+	"NiriSetup/nirisetup"
+)
+
+// bindConflictMsg reports that the key an action wanted to bind is taken,
+// so the user has to pick another one.
+type bindConflictMsg struct {
+	conflict niri.BindConflict
+}
+
+// xwaylandMsg carries whether niri starts xwayland-satellite, for Toggle
+// XWayland.
+type xwaylandMsg struct {
+	on  bool
+	err error
+}
+
+// audioMsg carries the audio checks Configure Audio starts from.
+type audioMsg []niri.Check
+
+// outputsMsg carries the outputs found for Configure Outputs.
+type outputsMsg struct {
+	names []string
+	err   error
+}
+
+// outputPickedMsg is the output whose scale the user chose to set.
+type outputPickedMsg string
+
+// cursorThemesMsg carries the installed cursor themes.
+type cursorThemesMsg struct {
+	themes []string
+	err    error
+}
+
+// cursorThemePickedMsg is the cursor theme the user chose.
+type cursorThemePickedMsg string
+
+// appearanceStepMsg asks the next question of a custom look, step indexing
+// appearanceSteps. problem says what was wrong with the last answer.
+type appearanceStepMsg struct {
+	look    niri.Appearance
+	step    int
+	problem string
+}
+
+// appearanceSteps are the questions of a custom look, in order. set takes
+// the answer into the look, or says what is wrong with it; a step whose
+// asked returns false is skipped.
+var appearanceSteps = []struct {
+	question func() string
+	current  func(niri.Appearance) string
+	asked    func(niri.Appearance) bool
+	set      func(look *niri.Appearance, answer string) error
+}{
+	{
+		question: func() string { return trf("Gaps around windows, in pixels (0 to %d):", niri.MaxGaps) },
+		current:  func(l niri.Appearance) string { return strconv.Itoa(l.Gaps) },
+		set: func(l *niri.Appearance, answer string) (err error) {
+			l.Gaps, err = parseBounded(answer, niri.MaxGaps)
+			return err
+		},
+	},
+	{
+		question: func() string {
+			return trf("Width of the ring around the focused window, in pixels (0 to %d, 0 turns it off):", niri.MaxFocusRing)
+		},
+		current: func(l niri.Appearance) string { return strconv.Itoa(l.FocusRing) },
+		set: func(l *niri.Appearance, answer string) (err error) {
+			l.FocusRing, err = parseBounded(answer, niri.MaxFocusRing)
+			return err
+		},
+	},
+	{
+		question: func() string { return tr("Colour of the ring, a CSS name or #rrggbb:") },
+		current:  func(l niri.Appearance) string { return l.Color },
+		asked:    func(l niri.Appearance) bool { return l.FocusRing > 0 },
+		set: func(l *niri.Appearance, answer string) error {
+			if !niri.ValidColor(answer) {
+				return errors.New(trf("%s is not a colour niri understands.", answer))
+			}
+			l.Color = answer
+			return nil
+		},
+	},
+	{
+		question: func() string {
+			return trf("Animation slowdown: 1 is normal, less is faster, 0 turns animations off (up to %g):", niri.MaxSlowdown)
+		},
+		current: func(l niri.Appearance) string { return strconv.FormatFloat(l.Slowdown, 'f', -1, 64) },
+		set: func(l *niri.Appearance, answer string) error {
+			f, err := strconv.ParseFloat(answer, 64)
+			if err != nil || f < 0 || f > niri.MaxSlowdown {
+				return errors.New(trf("Enter a number from 0 to %g.", niri.MaxSlowdown))
+			}
+			l.Slowdown = f
+			return nil
+		},
+	},
+}
+
+// parseBounded parses a whole number from 0 to limit.
+func parseBounded(answer string, limit int) (int, error) {
+	n, err := strconv.Atoi(answer)
+	if err != nil || n < 0 || n > limit {
+		return 0, errors.New(trf("Enter a whole number from 0 to %d.", limit))
+	}
+	return n, nil
+}
+
+// configureToolsMsg shows the starter tool configs Configure Niri would
+// write, with the cursor on option cursor. toolset is what a new niri
+// config starts.
+type configureToolsMsg struct {
+	tools   []string
+	toolset niri.Toolset
+	cursor  int
+}
+
+// configurePlanMsg is what Configure Niri would change with tools, to
+// show before anything is overwritten.
+type configurePlanMsg struct {
+	plan    niri.ConfigurePlan
+	tools   []string
+	toolset niri.Toolset
+	err     error
+}
+
+// toolsetMsg asks which program the starter niri config should start for
+// step: the terminal, the launcher, then the bar.
+type toolsetMsg struct {
+	toolset   niri.Toolset
+	step      int
+	installed map[string]bool // by package, whether each program offered is
+}
+
+// inputBehaviorMsg shows the input behavior to save, with the cursor on
+// option cursor.
+type inputBehaviorMsg struct {
+	behavior niri.InputBehavior
+	cursor   int
+	err      error
+}
+
+// swayConfigsMsg lists the sway and i3 configs that could be imported.
+type swayConfigsMsg []string
+
+// nightLightPickedMsg carries the night light temperatures chosen so far;
+// night is 0 until the second pick.
+type nightLightPickedMsg struct {
+	day, night int
+}
+
+// polkitAgentsMsg lists the polkit agents there are to choose from and
+// which of them are installed.
+type polkitAgentsMsg struct {
+	agents    []niri.PolkitAgent
+	installed []bool
+	err       error
+}
+
+// updateConfigure handles the messages of the Configure entries, each a step
+// towards a change to the niri config.
+func (m model) updateConfigure(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case bindConflictMsg:
+		c := msg.conflict
+		m.state = choiceView
+		m.choice = choice{question: trf("%s is already bound to %s.\n\nWhich key should open clipboard history?", c.Key, c.Existing.Action)}
+		for _, key := range c.Free {
+			m.choice.options = append(m.choice.options, option{
+				label:   trf("Use %s", key),
+				working: tr("Configuring clipboard manager..."),
+				run:     m.cmds.clipboard(m.ctx, key),
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Leave the keybinds as they are")})
+		return m, nil
+	case xwaylandMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if msg.on {
+			return m.ask(confirmation{
+				question: tr("XWayland is on. Turn it off for a pure-Wayland session?\n\nThis removes xwayland-satellite from spawn-at-startup and DISPLAY from the environment block, so X11 apps no longer start."),
+				working:  tr("Turning XWayland off..."),
+				run:      m.cmds.noXwayland(m.ctx),
+			})
+		}
+		return m.ask(confirmation{
+			question: tr("XWayland is off. Enable X11 app support?\n\nThis starts xwayland-satellite with niri and sets DISPLAY in the environment block."),
+			working:  tr("Configuring XWayland..."),
+			run:      m.cmds.xwayland(m.ctx),
+		})
+	case audioMsg:
+		var lines []string
+		for _, c := range msg {
+			lines = append(lines, c.String())
+		}
+		summary := strings.Join(lines, "\n")
+		if niri.AudioWorks(msg) {
+			return m.Update(statusMsg{status: summary + "\n" + tr("Audio already works.")})
+		}
+		return m.ask(confirmation{
+			question: trf("%s\n\nInstall %s and start them with niri?", summary, strings.Join(niri.AudioPackages, ", ")),
+			working:  tr("Setting up audio..."),
+			declined: summary,
+			run:      m.cmds.audio(m.ctx),
+		})
+	case outputsMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		m.state = choiceView
+		m.choice = choice{question: tr("Which output do you want to scale?")}
+		for _, name := range msg.names {
+			m.choice.options = append(m.choice.options, option{
+				label: name,
+				run:   func() tea.Msg { return outputPickedMsg(name) },
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case outputPickedMsg:
+		name := string(msg)
+		m.state = choiceView
+		m.choice = choice{question: trf("Scale for %s:\nHiDPI laptop screens usually want 1.5 or 2.", name)}
+		for _, scale := range niri.ScalePresets {
+			m.choice.options = append(m.choice.options, option{
+				label:   fmt.Sprintf("%g", scale),
+				working: tr("Updating niri config..."),
+				run:     m.cmds.scale(m.ctx, name, scale),
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case cursorThemesMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if len(msg.themes) == 0 {
+			return m.ask(confirmation{
+				question: trf("No cursor themes are installed.\n\nInstall %s?", niri.DefaultCursorPackage),
+				working:  trf("Installing %s...", niri.DefaultCursorPackage),
+				run:      m.cmds.cursorPkg(m.ctx),
+			})
+		}
+		m.state = choiceView
+		m.choice = choice{question: tr("Which cursor theme do you want?")}
+		for _, theme := range msg.themes {
+			m.choice.options = append(m.choice.options, option{
+				label: theme,
+				run:   func() tea.Msg { return cursorThemePickedMsg(theme) },
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case cursorThemePickedMsg:
+		theme := string(msg)
+		m.state = choiceView
+		m.choice = choice{question: trf("Cursor size for %s:", theme)}
+		for _, size := range niri.CursorSizes {
+			m.choice.options = append(m.choice.options, option{
+				label:   fmt.Sprintf("%dpx", size),
+				working: tr("Updating niri config..."),
+				run:     m.cmds.cursor(m.ctx, theme, size),
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case toolsetMsg:
+		steps := []struct {
+			question string
+			programs []niri.Program
+			set      func(*niri.Toolset, niri.Program)
+		}{
+			{tr("Which terminal should Mod+T open?"), niri.StarterTerminals, func(t *niri.Toolset, p niri.Program) { t.Terminal = p }},
+			{tr("Which launcher should Mod+D open?"), niri.StarterLaunchers, func(t *niri.Toolset, p niri.Program) { t.Launcher = p }},
+			{tr("Which bar should niri start?"), append(slices.Clone(niri.StarterBars), niri.Program{}), func(t *niri.Toolset, p niri.Program) { t.Bar = p }},
+		}
+		if msg.step == len(steps) {
+			tools := slices.Clone(niri.DefaultConfigureTools)
+			if msg.toolset.Bar.Package != "waybar" {
+				tools = slices.DeleteFunc(tools, func(t string) bool { return t == "waybar" }) // it would not start
+			}
+			return m.Update(configureToolsMsg{tools: tools, toolset: msg.toolset})
+		}
+		step := steps[msg.step]
+		m.state = choiceView
+		m.choice = choice{question: trf("There is no niri config yet, so Configure Niri writes the starter one (%d of %d). %s", msg.step+1, len(steps), step.question)}
+		for _, p := range step.programs {
+			label := p.Package
+			switch {
+			case p.Package == "":
+				label = tr("No bar")
+			case !msg.installed[p.Package]:
+				label += " " + tr("(not installed)")
+			}
+			next := msg
+			next.step++
+			step.set(&next.toolset, p)
+			m.choice.options = append(m.choice.options, option{label: label, run: func() tea.Msg { return next }})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case configureToolsMsg:
+		m.state = choiceView
+		m.choice = choice{question: tr("Configure Niri can also write starter configs for the programs niri starts, backing up the files they replace. Pick one to check or uncheck it, then configure."), cursor: msg.cursor}
+		for i, tool := range niri.ToolNames() {
+			mark := "[ ]"
+			if slices.Contains(msg.tools, tool) {
+				mark = "[x]"
+			}
+			m.choice.options = append(m.choice.options, option{
+				label: mark + " " + trf("Starter %s config", tool),
+				run: func() tea.Msg {
+					tools := slices.DeleteFunc(slices.Clone(msg.tools), func(t string) bool { return t == tool })
+					if len(tools) == len(msg.tools) {
+						tools = append(tools, tool)
+					}
+					return configureToolsMsg{tools: tools, toolset: msg.toolset, cursor: i}
+				},
+			})
+		}
+		m.choice.options = append(m.choice.options,
+			option{label: tr("Configure"), working: tr("Checking what Configure Niri would change..."), run: m.cmds.planConfigure(msg.toolset, msg.tools)},
+			option{label: tr("Back to the menu")},
+		)
+		return m, nil
+	case configurePlanMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		configure := confirmation{
+			question:    tr("What Configure Niri would change"),
+			working:     tr("Configuring Niri..."),
+			run:         m.cmds.configure(m.ctx, msg.toolset, msg.tools),
+			destructive: true, // the user's own changes would be gone
+		}
+		switch {
+		case msg.plan.Diff == "" && len(msg.plan.Created) == 0:
+			return m.Update(statusMsg{status: tr("Nothing to change: the niri config and the starter configs are already what Configure Niri would write.")})
+		case msg.plan.Diff == "":
+			// nothing there yet is overwritten
+			m.state, m.actionMsg = actionView, configure.working
+			return m, configure.run
+		}
+		return m.askAbout(msg.plan.Diff, configure)
+	case inputBehaviorMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		b := msg.behavior
+		toggle := func(i int, flip func(*niri.InputBehavior)) func() tea.Msg {
+			return func() tea.Msg {
+				next := b
+				flip(&next)
+				return inputBehaviorMsg{behavior: next, cursor: i}
+			}
+		}
+		m.state = choiceView
+		m.choice = choice{question: tr("How should focus and the mouse behave? Pick a setting to turn it on or off, then save."), cursor: msg.cursor, options: []option{
+			{label: fmt.Sprintf("%-48s %s", tr("Focus follows the mouse"), onOff(b.FocusFollowsMouse)), run: toggle(0, func(b *niri.InputBehavior) { b.FocusFollowsMouse = !b.FocusFollowsMouse })},
+			{label: fmt.Sprintf("%-48s %s", tr("Move the mouse to the focused window"), onOff(b.WarpMouseToFocus)), run: toggle(1, func(b *niri.InputBehavior) { b.WarpMouseToFocus = !b.WarpMouseToFocus })},
+			{label: fmt.Sprintf("%-48s %s", tr("Switching to the current workspace goes back"), onOff(b.WorkspaceAutoBackAndForth)), run: toggle(2, func(b *niri.InputBehavior) { b.WorkspaceAutoBackAndForth = !b.WorkspaceAutoBackAndForth })},
+			{label: tr("Use the defaults"), run: func() tea.Msg { return inputBehaviorMsg{behavior: niri.DefaultInputBehavior, cursor: 4} }},
+			{label: tr("Save"), working: tr("Updating niri config..."), run: m.cmds.setBehavior(m.ctx, b)},
+			{label: tr("Back to the menu")},
+		}}
+		return m, nil
+	case appearanceStepMsg:
+		for msg.step < len(appearanceSteps) && appearanceSteps[msg.step].asked != nil && !appearanceSteps[msg.step].asked(msg.look) {
+			msg.step++
+		}
+		if msg.step == len(appearanceSteps) {
+			m.state = actionView
+			m.actionMsg = tr("Updating niri config...")
+			return m, m.cmds.appearance(m.ctx, msg.look)
+		}
+		step := appearanceSteps[msg.step]
+		question := step.question()
+		if msg.problem != "" {
+			question = msg.problem + "\n\n" + question
+		}
+		return m.askText(question, step.current(msg.look), func(answer string) tea.Msg {
+			look := msg.look
+			if err := step.set(&look, answer); err != nil {
+				return appearanceStepMsg{look: msg.look, step: msg.step, problem: err.Error()}
+			}
+			return appearanceStepMsg{look: look, step: msg.step + 1}
+		})
+	case swayConfigsMsg:
+		if len(msg) == 0 {
+			return m.Update(reportMsg(nil, errors.New(tr("No sway or i3 config found in ~/.config/sway, ~/.sway, ~/.config/i3 or ~/.i3."))))
+		}
+		m.state = choiceView
+		m.choice = choice{question: trf("Import which config?\n\nIt replaces %s; the current config is backed up first.", niri.ConfigPath())}
+		for _, path := range msg {
+			m.choice.options = append(m.choice.options, option{
+				label:   path,
+				working: tr("Importing the sway config..."),
+				run:     m.cmds.importSway(m.ctx, path),
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case nightLightPickedMsg:
+		m.state = choiceView
+		if msg.night == 0 {
+			m.choice = choice{question: trf("Night colour temperature (day is %dK):", msg.day)}
+			for _, night := range niri.NightTemperatures {
+				m.choice.options = append(m.choice.options, option{
+					label: fmt.Sprintf("%dK", night),
+					run:   func() tea.Msg { return nightLightPickedMsg{day: msg.day, night: night} },
+				})
+			}
+		} else {
+			m.choice = choice{question: trf("Fade between %dK and %dK over:", msg.day, msg.night)}
+			for _, minutes := range niri.NightLightTransitions {
+				m.choice.options = append(m.choice.options, option{
+					label:   trf("%d minutes", minutes),
+					working: tr("Updating niri config..."),
+					run:     m.cmds.nightLight(m.ctx, msg.day, msg.night, minutes),
+				})
+			}
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case polkitAgentsMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if len(msg.agents) == 0 {
+			return m.Update(reportMsg(nil, errors.New(tr("None of the polkit agents NiriSetup knows is in the repositories."))))
+		}
+		m.state = choiceView
+		m.choice = choice{question: tr("Which polkit agent should show authentication prompts?")}
+		for i, agent := range msg.agents {
+			label := trf("Install %s", agent.Package)
+			if msg.installed[i] {
+				label = trf("%s (installed)", agent.Package)
+			}
+			m.choice.options = append(m.choice.options, option{
+				label:   label,
+				working: trf("Setting up %s...", agent.Package),
+				run:     m.cmds.polkit(m.ctx, agent),
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	}
+	return m, nil
+}
+
+// checkToolset starts Configure Niri. Without a niri config it asks which
+// programs the starter one should start, unless the setup profile says;
+// an existing config is kept, so it goes straight on to the tool configs.
+func checkToolset() tea.Cmd {
+	return func() tea.Msg {
+		// a setup profile that says what the starter config starts has
+		// answered the questions
+		profile := niri.CurrentSetupProfile()
+		if _, err := os.Stat(niri.ConfigPath()); err == nil || profile != nil && (profile.Config != "" || profile.Toolset != nil) {
+			return configureToolsMsg{tools: niri.DefaultConfigureTools, toolset: niri.StarterToolset()}
+		}
+		installed := map[string]bool{}
+		for _, p := range slices.Concat(niri.StarterTerminals, niri.StarterLaunchers, niri.StarterBars) {
+			installed[p.Package] = p.Installed()
+		}
+		return toolsetMsg{toolset: niri.DefaultToolset, installed: installed}
+	}
+}
+
+// planConfigure works out what Configure Niri would change with tools.
+func planConfigure(ts niri.Toolset, tools []string) tea.Cmd {
+	return func() tea.Msg {
+		plan, err := niri.PlanConfigure(tools...)
+		return configurePlanMsg{plan: plan, tools: tools, toolset: ts, err: err}
+	}
+}
+
+func configureNiri(ctx context.Context, ts niri.Toolset, tools []string) tea.Cmd {
+	return func() tea.Msg {
+		report, err := nirisetup.ConfigureWith(ts, tools...)
+		if err != nil {
+			return reportMsg(report, err)
+		}
+		report = append(report, tr("Niri configuration completed successfully."))
+		return reportMsg(append(report, niri.RunHooks(ctx, "configure")...), nil)
+	}
+}
+
+// configureClipboard sets up clipboard history bound to key. With an empty
+// key it uses the default one, unless that is taken, in which case the user
+// is asked to pick another before anything is installed or written.
+func configureClipboard(ctx context.Context, key string) tea.Cmd {
+	return func() tea.Msg {
+		if key == "" {
+			conflict, err := niri.ClipboardBindConflict()
+			if err != nil {
+				return reportMsg(nil, err)
+			}
+			if conflict != nil {
+				return bindConflictMsg{conflict: *conflict}
+			}
+			key = niri.ClipboardKeys[0]
+		}
+		return reportMsg(niri.ConfigureClipboard(ctx, key))
+	}
+}
+
+func checkXWayland() tea.Cmd {
+	return func() tea.Msg {
+		on, err := niri.XWaylandEnabled()
+		return xwaylandMsg{on: on, err: err}
+	}
+}
+
+func disableXWayland(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.DisableXWayland(ctx))
+	}
+}
+
+func configureXWayland(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureXWayland(ctx))
+	}
+}
+
+func detectOutputs() tea.Cmd {
+	return func() tea.Msg {
+		names, err := niri.Outputs()
+		return outputsMsg{names: names, err: err}
+	}
+}
+
+func setOutputScale(ctx context.Context, output string, scale float64) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.SetOutputScale(ctx, output, scale))
+	}
+}
+
+func listCursorThemes() tea.Cmd {
+	return func() tea.Msg {
+		themes, err := niri.CursorThemes()
+		return cursorThemesMsg{themes: themes, err: err}
+	}
+}
+
+// installCursorTheme installs the default cursor theme, then lists the
+// themes again so the user can pick it.
+func installCursorTheme(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		if err := niri.InstallPackage(ctx, niri.DefaultCursorPackage); err != nil {
+			return reportMsg(nil, err)
+		}
+		return listCursorThemes()()
+	}
+}
+
+func configureCursor(ctx context.Context, theme string, size int) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureCursor(ctx, theme, size))
+	}
+}
+
+func configureScreenshots(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureScreenshots(ctx))
+	}
+}
+
+func configureBrightness(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureBrightness(ctx))
+	}
+}
+
+func configureMediaKeys(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureMediaKeys(ctx))
+	}
+}
+
+func configurePortal(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigurePortal(ctx))
+	}
+}
+
+func generateDefaults(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.GenerateDefaultConfigs(ctx))
+	}
+}
+
+func checkAudio() tea.Cmd {
+	return func() tea.Msg {
+		return audioMsg(niri.AudioChecks())
+	}
+}
+
+func configureAudio(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureAudio(ctx))
+	}
+}
+
+func configureIdle(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureIdle(ctx))
+	}
+}
+
+func configureAppearance(ctx context.Context, look niri.Appearance) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureAppearance(ctx, look))
+	}
+}
+
+func readInputBehavior() tea.Cmd {
+	return func() tea.Msg {
+		b, err := niri.CurrentInputBehavior()
+		return inputBehaviorMsg{behavior: b, err: err}
+	}
+}
+
+func configureInputBehavior(ctx context.Context, b niri.InputBehavior) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureInputBehavior(ctx, b))
+	}
+}
+
+func findSwayConfigs() tea.Cmd {
+	return func() tea.Msg {
+		return swayConfigsMsg(niri.SwayConfigs())
+	}
+}
+
+func importSwayConfig(ctx context.Context, path string) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ImportSwayConfig(ctx, path))
+	}
+}
+
+func configureNightLight(ctx context.Context, day, night, transition int) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureNightLight(ctx, day, night, transition))
+	}
+}
+
+func detectPolkitAgents() tea.Cmd {
+	return func() tea.Msg {
+		agents, installed, err := niri.PolkitAgentChoices()
+		return polkitAgentsMsg{agents: agents, installed: installed, err: err}
+	}
+}
+
+func configureInputMethod(ctx context.Context, im niri.InputMethod) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigureInputMethod(ctx, im))
+	}
+}
+
+func configurePolkitAgent(ctx context.Context, agent niri.PolkitAgent) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ConfigurePolkitAgent(ctx, agent))
+	}
+}
@@ -0,0 +1,789 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
+
+	"NiriSetup/internal/niri"
+	"NiriSetup/nirisetup"
+)
+
+// packagePick is the checklist of packages pickView offers to install.
+type packagePick struct {
+	pkgs    []string
+	checked []bool
+	cursor  int
+	note    string // why enter did nothing, if it did not
+}
+
+// selected returns the checked packages, in order.
+func (p packagePick) selected() []string {
+	var pkgs []string
+	for i, pkg := range p.pkgs {
+		if p.checked[i] {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// packagesCheckedMsg is the package list to install, with the packages the
+// repositories do not have and the niri.DownloadChecks, nil once the user
+// chose to install despite them.
+type packagesCheckedMsg struct {
+	pkgs     []string
+	missing  []string
+	download []niri.Check
+	warnings []string
+	err      error
+}
+
+// pickPackagesMsg offers the packages to install in pickView.
+type pickPackagesMsg []string
+
+// startInstallMsg starts installing pkgs, the ones in files from their
+// local package file.
+type startInstallMsg struct {
+	pkgs  []string
+	files map[string]string
+}
+
+// cacheDirMsg is the directory of package files to install from.
+type cacheDirMsg string
+
+// cacheScannedMsg is the package list to install, with the files found for
+// it in dir and the packages that are not there.
+type cacheScannedMsg struct {
+	dir      string
+	pkgs     []string
+	files    map[string]string
+	missing  []string
+	warnings []string
+	err      error
+}
+
+// progressMsg is a line of progress from a running action. next waits for
+// whatever the action reports after it.
+type progressMsg struct {
+	line   string
+	pkg    string        // the package the line says the install is done with
+	status string        // and how, as in logEvent
+	took   time.Duration // how long what the line reports on took, if timed
+	next   tea.Cmd
+}
+
+// installProgress is how far an install has got: done of total packages,
+// and for the package being installed, current of size for the stage pkg
+// reports, if its event pipe works.
+type installProgress struct {
+	done, total   int
+	pkg, stage    string
+	current, size int64
+}
+
+// progressTracker keeps an install's installProgress. pkg's progress
+// arrives on the event pipe's goroutine while the package count moves on
+// the install's, so both go through mu.
+type progressTracker struct {
+	mu      sync.Mutex
+	bar     installProgress
+	percent int64 // of the stage pkg reported last, -1 before the first
+}
+
+func newProgressTracker(total int) *progressTracker {
+	return &progressTracker{bar: installProgress{total: total}, percent: -1}
+}
+
+// stage records pkg's progress on pkg and reports whether the bar changed,
+// as pkg ticks far more often than it can.
+func (t *progressTracker) stage(pkg, stage string, current, size int64) (installProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := int64(0)
+	if size > 0 {
+		p = current * 100 / size
+	}
+	if stage == t.bar.stage && p == t.percent {
+		return t.bar, false
+	}
+	t.bar.pkg, t.bar.stage, t.bar.current, t.bar.size, t.percent = pkg, stage, current, size, p
+	return t.bar, true
+}
+
+// finished records done packages, clearing the stage of the last one.
+func (t *progressTracker) finished(done int) installProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bar = installProgress{done: done, total: t.bar.total}
+	return t.bar
+}
+
+// packageProgressMsg is a new installProgress. next waits for the next
+// message from the install.
+type packageProgressMsg struct {
+	progress installProgress
+	next     tea.Cmd
+}
+
+// progressBarWidth is how many cells the install progress bars take with
+// their percentage, small enough for the package count to fit beside them.
+const progressBarWidth = 25
+
+// newProgressBar returns the model drawing the install progress bars, in
+// plain ASCII for the console.
+func newProgressBar() progress.Model {
+	return progress.New(progress.WithWidth(progressBarWidth), progress.WithFillCharacters('#', '-'), progress.WithColorProfile(termenv.Ascii))
+}
+
+// installingMsg reports the packages the install has started a pkg run
+// for, or the one package of a batch run pkg has got to. next waits for
+// the next message from the install.
+type installingMsg struct {
+	pkgs []string
+	next tea.Cmd
+}
+
+// pausedMsg reports that the install has paused, with the packages still
+// to install.
+type pausedMsg struct {
+	remaining []string
+	next      tea.Cmd
+}
+
+// pauser lets the TUI hold a background install between two pkg runs.
+type pauser struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+}
+
+func newPauser() *pauser {
+	p := &pauser{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// set asks for the install to pause before its next pkg run, or lets a
+// paused one carry on.
+func (p *pauser) set(paused bool) {
+	p.mu.Lock()
+	p.paused = paused
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+func (p *pauser) requested() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// wait blocks for as long as a pause is requested.
+func (p *pauser) wait() {
+	p.mu.Lock()
+	for p.paused {
+		p.cond.Wait()
+	}
+	p.mu.Unlock()
+}
+
+// updateInstall handles the messages of an install, from checking the
+// packages to the last line pkg prints.
+func (m model) updateInstall(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case packagesCheckedMsg:
+		m.logAt(levelWarn, msg.warnings...)
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if niri.DownloadBlocked(msg.download) {
+			lines := []string{tr("The download may fail:")}
+			for _, c := range msg.download {
+				lines = append(lines, c.String())
+			}
+			m.logAt(levelWarn, lines...)
+			next := msg
+			next.download, next.warnings = nil, nil
+			return m.ask(confirmation{
+				question: trf("%s\n\nInstall anyway?", strings.Join(lines, "\n")),
+				working:  tr("Checking the packages are in the repositories..."),
+				run:      func() tea.Msg { return next },
+			})
+		}
+		for _, c := range msg.download {
+			m.log(c.String())
+		}
+		if len(msg.missing) == 0 {
+			return m.pickPackages(msg.pkgs)
+		}
+		var available []string
+		for _, pkg := range msg.pkgs {
+			if !slices.Contains(msg.missing, pkg) {
+				available = append(available, pkg)
+			}
+		}
+		notFound := trf("Not in the repositories: %s", strings.Join(msg.missing, ", "))
+		m.logAt(levelWarn, notFound)
+		if len(available) == 0 {
+			return m.Update(reportMsg(nil, errors.New(notFound)))
+		}
+		m.state = choiceView
+		m.choice = choice{
+			question: trf("%s\n\nCheck the package list for typos, or whether your pkg branch carries them.", notFound),
+			options: []option{
+				{
+					label:   trf("Install the %d available packages", len(available)),
+					working: tr("Installing Niri..."),
+					run:     func() tea.Msg { return pickPackagesMsg(available) },
+				},
+				{label: tr("Back to the menu")},
+			},
+		}
+		return m, nil
+	case pickPackagesMsg:
+		return m.pickPackages(msg)
+	case startInstallMsg:
+		return m.startInstall(msg.pkgs, msg.files)
+	case cacheDirMsg:
+		m.state = actionView
+		m.actionMsg = trf("Looking for the packages in %s...", string(msg))
+		return m, m.cmds.scanCache(string(msg))
+	case cacheScannedMsg:
+		m.logAt(levelWarn, msg.warnings...)
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if len(msg.files) == 0 {
+			return m.Update(reportMsg(nil, errors.New(trf("None of the packages are in %s", msg.dir))))
+		}
+		if len(msg.missing) == 0 {
+			return m.confirmInstall(msg.pkgs, msg.files)
+		}
+		notCached := trf("Not in %s: %s", msg.dir, strings.Join(msg.missing, ", "))
+		m.logAt(levelWarn, notCached)
+		m.state = choiceView
+		m.choice = choice{
+			question: trf("%s\n\nFetching them needs the network.", notCached),
+			options: []option{
+				{
+					label:   trf("Install %d from the cache and fetch the other %d", len(msg.files), len(msg.missing)),
+					working: tr("Installing Niri..."),
+					run:     func() tea.Msg { return startInstallMsg{pkgs: msg.pkgs, files: msg.files} },
+				},
+				{label: tr("Back to the menu")},
+			},
+		}
+		return m, nil
+	case progressMsg:
+		m.logEvent(levelInfo, logEvent{Package: msg.pkg, Status: msg.status, Seconds: msg.took.Seconds()}, msg.line)
+		if msg.status != "" {
+			m.currentPackage = "" // done, until the next pkg run starts
+		}
+		if msg.status != "" && m.outcomes != nil {
+			m.outcomes[msg.status]++
+		}
+		if m.state == actionView {
+			m.actionOutput = append(m.actionOutput, msg.line)
+			m.actionOutput = m.actionOutput[max(len(m.actionOutput)-actionOutputLines, 0):]
+		}
+		return m, msg.next
+	case packageProgressMsg:
+		m.install = msg.progress
+		return m, msg.next
+	case installingMsg:
+		m.currentPackage = strings.Join(msg.pkgs, ", ")
+		return m, msg.next
+	case pausedMsg:
+		m.remaining = msg.remaining
+		m.log(trf("Paused with %d packages left.", len(msg.remaining)))
+		return m, msg.next
+	}
+	return m, nil
+}
+
+// pickKey handles a key in pickView, the checklist of packages to install.
+func (m model) pickKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.pick.note = ""
+	switch msg.String() {
+	case "up":
+		if m.pick.cursor > 0 {
+			m.pick.cursor--
+		}
+	case "down":
+		if m.pick.cursor < len(m.pick.pkgs)-1 {
+			m.pick.cursor++
+		}
+	case " ":
+		m.pick.checked[m.pick.cursor] = !m.pick.checked[m.pick.cursor]
+	case "enter":
+		pkgs := m.pick.selected()
+		if len(pkgs) == 0 {
+			m.pick.note = tr("Check at least one package, or press esc.")
+			return m, nil
+		}
+		return m.startInstall(pkgs, nil)
+	case "esc":
+		m.state = menuView
+		m.isProcessing = false
+		m.actionMsg = tr("Cancelled.")
+	}
+	return m, nil
+}
+
+// installKey handles a key in installView while an install runs and after it.
+func (m model) installKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.confirmingQuit {
+		m.confirmingQuit = false
+		if msg.String() == "y" || msg.String() == "Y" {
+			return m.cancelInstall(), nil
+		}
+		return m, nil // any other key keeps it running
+	}
+	switch msg.String() {
+	case "p":
+		if m.pause != nil && !m.pausing {
+			m.pause.set(true)
+			m.pausing = true
+		}
+	case "r":
+		if m.failed != nil && !m.isProcessing {
+			m.log(trf("Retrying %d packages: %s", len(m.failed), strings.Join(m.failed, ", ")))
+			return m.startInstall(m.failed, m.installFiles)
+		}
+		if m.pausing {
+			m.pause.set(false)
+			if m.remaining != nil {
+				m.log(tr("Resuming the install..."))
+			}
+			m.pausing, m.remaining = false, nil
+		}
+	case "ctrl+c":
+		if m.isProcessing && !m.cancelling {
+			if !m.noConfirmQuit {
+				m.confirmingQuit = true
+				return m, nil
+			}
+			return m.cancelInstall(), nil
+		}
+	case "esc":
+		if m.failed != nil && !m.isProcessing {
+			m.state = menuView
+			m.actionMsg = trf("%d packages are not installed: %s. Retry Failed Packages tries them again.", len(m.failed), strings.Join(m.failed, ", "))
+			m.choices = m.menu()
+		}
+	default:
+		// the arrows and pgup/pgdn scroll the log
+		var cmd tea.Cmd
+		m.logView, cmd = m.logView.Update(msg)
+		return m, cmd
+	}
+	// Nothing else is allowed while installing
+	return m, nil
+}
+
+// cancelInstall stops the running install; what pkg reports as it stops
+// still arrives and is logged.
+func (m model) cancelInstall() model {
+	m.cancelling = true
+	m.log(tr("Cancelling the install..."))
+	m.cmds.cancel()
+	if m.pause != nil {
+		m.pause.set(false) // a paused install has to run on to stop
+	}
+	return m
+}
+
+// confirmInstall lists pkgs and installs them once the user says yes, so a
+// stray Enter on the menu does not start an install.
+func (m model) confirmInstall(pkgs []string, files map[string]string) (model, tea.Cmd) {
+	return m.ask(confirmation{
+		question: trf("Install these %d packages?\n\n%s", len(pkgs), strings.Join(pkgs, ", ")),
+		working:  tr("Installing Niri..."),
+		run:      func() tea.Msg { return startInstallMsg{pkgs: pkgs, files: files} },
+	})
+}
+
+// pickPackages lets the user uncheck the packages of pkgs they do not want
+// in pickView before installing the rest. With --yes there is nobody to
+// ask, so they are all installed after the usual confirmation.
+func (m model) pickPackages(pkgs []string) (model, tea.Cmd) {
+	if m.autoYes {
+		return m.confirmInstall(pkgs, nil)
+	}
+	m.state = pickView
+	m.pick = packagePick{pkgs: pkgs, checked: make([]bool, len(pkgs))}
+	for i := range m.pick.checked {
+		m.pick.checked[i] = true
+	}
+	return m, nil
+}
+
+// startInstall switches to installView and installs pkgs, the ones in files
+// from their local package file.
+func (m model) startInstall(pkgs []string, files map[string]string) (model, tea.Cmd) {
+	m.state = installView
+	m.isProcessing = true
+	m.actionMsg = ""
+	m.pause, m.pausing, m.remaining = newPauser(), false, nil
+	m.installFiles = files
+	m.install, m.currentPackage = installProgress{total: len(pkgs)}, ""
+	m.installStart, m.outcomes = m.cmds.now(), map[string]int{}
+	m.logView = viewport.New(m.fitWidth(viewWidth)-4, m.logHeight()) // inside logStyle's padding
+	m.followLogs()
+	return m, m.cmds.install(pkgs, files, m.dryRun, m.pause)
+}
+
+// formatTook is d to a tenth of a second, such as 3.2s or 1m4.5s.
+func formatTook(d time.Duration) string {
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// tookNote is what the log line of an installed package says about how
+// long its pkg run took, which installed n packages.
+func tookNote(d time.Duration, n int) string {
+	if n > 1 {
+		return trf(" (took %s, installing %d packages together)", formatTook(d), n)
+	}
+	return trf(" (took %s)", formatTook(d))
+}
+
+// preflight runs niri.InstallChecks, failing with every check when one
+// means no package could be installed.
+func preflight() error {
+	checks := niri.InstallChecks()
+	if !niri.InstallBlocked(checks) {
+		return nil
+	}
+	lines := []string{tr("Nothing can be installed yet:")}
+	for _, c := range checks {
+		lines = append(lines, c.String())
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// checkPackages makes sure pkg and sudo can be used, then loads the package
+// list and looks each package up in the repositories, so a typo or branch
+// mismatch shows before anything is installed. It also checks there is
+// room to download them and a repository to download them from.
+func checkPackages() tea.Cmd {
+	return func() tea.Msg {
+		if err := preflight(); err != nil {
+			return packagesCheckedMsg{err: err}
+		}
+		pkgs, warnings, err := niri.PackageList()
+		if err != nil {
+			return packagesCheckedMsg{warnings: warnings, err: err}
+		}
+		missing, err := niri.MissingPackages(pkgs)
+		return packagesCheckedMsg{pkgs: pkgs, missing: missing, download: niri.DownloadChecks(), warnings: warnings, err: err}
+	}
+}
+
+// scanPackageCache makes the same checks as checkPackages, then loads the
+// package list and looks for each package among the package files in dir.
+func scanPackageCache(dir string) tea.Cmd {
+	return func() tea.Msg {
+		if err := preflight(); err != nil {
+			return cacheScannedMsg{dir: dir, err: err}
+		}
+		pkgs, warnings, err := niri.PackageList()
+		if err != nil {
+			return cacheScannedMsg{dir: dir, warnings: warnings, err: err}
+		}
+		files, missing, err := niri.CachedPackages(dir, pkgs)
+		return cacheScannedMsg{dir: dir, pkgs: pkgs, files: files, missing: missing, warnings: warnings, err: err}
+	}
+}
+
+// installNiri installs pkgs in the background, streaming a progressMsg per
+// package and finishing with a statusMsg. The packages in files are added
+// from those files instead of fetched. With dryRun it only logs the pkg
+// command for each package. pause can hold it between packages.
+func installNiri(pkgs []string, files map[string]string, dryRun bool, pause *pauser) tea.Cmd {
+	return func() tea.Msg {
+		updates := make(chan tea.Msg)
+		go runInstall(updates, pkgs, files, dryRun, pause)
+		return listen(updates)()
+	}
+}
+
+func runInstall(updates chan<- tea.Msg, pkgs []string, files map[string]string, dryRun bool, pause *pauser) {
+	progress := func(line string) { updates <- progressMsg{line: line} }
+	done := func(pkg, status, line string) { updates <- progressMsg{line: line, pkg: pkg, status: status} }
+	// a package is timed by the pkg run that installed it, which in a
+	// batch installed the others too
+	start := time.Now()
+	type pkgRun struct {
+		start time.Time
+		size  int // how many packages it installs
+	}
+	runs := map[string]pkgRun{} // the latest pkg run of each package
+	installed := func(pkg, status, line string) {
+		run := runs[pkg]
+		took := time.Since(run.start)
+		updates <- progressMsg{line: line + tookNote(took, run.size), pkg: pkg, status: status, took: took}
+	}
+	// what pkg prints streams to the log too, and a dry run logs the
+	// command each package would be installed with
+	o := niri.Options{Output: progress, PackageFiles: files}
+	if dryRun {
+		o.Preview = progress
+	}
+	tracker := newProgressTracker(len(pkgs))
+	o.Progress = func(pkg, stage string, current, size int64) {
+		if bar, changed := tracker.stage(pkg, stage, current, size); changed {
+			updates <- packageProgressMsg{progress: bar}
+		}
+	}
+	var through []string // the packages done has been called for
+	o.Installing = func(run []string) {
+		// pkg installs the packages of a run atomically, so before the
+		// next run is the safe place to stop
+		if pause.requested() {
+			updates <- pausedMsg{remaining: slices.DeleteFunc(slices.Clone(pkgs), func(pkg string) bool { return slices.Contains(through, pkg) })}
+			pause.wait()
+		}
+		started := pkgRun{start: time.Now(), size: len(run)}
+		for _, pkg := range run {
+			runs[pkg] = started
+		}
+		updates <- installingMsg{pkgs: run}
+	}
+	o.Current = func(pkg string) { updates <- installingMsg{pkgs: []string{pkg}} }
+	ctx := niri.WithOptions(context.Background(), o)
+	finish := func(msg statusMsg) {
+		if !dryRun {
+			took := time.Since(start)
+			updates <- progressMsg{line: trf("The install took %s in total.", formatTook(took)), took: took}
+		}
+		updates <- msg
+	}
+
+	summary := newSummary("install", pkgs)
+	var cached []string
+	attempted := 0
+	result, err := nirisetup.InstallContext(ctx, pkgs, func(pkg string, skipped bool, err error) {
+		attempted++
+		through = append(through, pkg)
+		count := fmt.Sprintf(" (%d/%d)", attempted, len(pkgs))
+		switch {
+		case err != nil:
+			done(pkg, "failed", trf("Failed to install %s", pkg)+count)
+			var perr *niri.PackageError
+			// the whole output is in the log already; stderr says why
+			if errors.As(err, &perr) && !perr.Denied && perr.Stderr != "" {
+				for _, line := range strings.Split(strings.TrimSpace(perr.Stderr), "\n") {
+					progress("  " + line)
+				}
+			}
+		case skipped:
+			done(pkg, "skipped", trf("Skipping %s, already installed", pkg)+count)
+		case dryRun:
+			// Preview already logged the command
+		case files[pkg] != "":
+			installed(pkg, "cached", trf("Installed %s from the cache", pkg)+count)
+			cached = append(cached, pkg)
+		default:
+			installed(pkg, "ok", trf("Successfully installed %s", pkg)+count)
+		}
+		switch {
+		case err != nil:
+			summary.failed = append(summary.failed, pkg)
+		case skipped:
+			summary.skipped = append(summary.skipped, pkg)
+		default:
+			summary.installed = append(summary.installed, pkg)
+		}
+		updates <- packageProgressMsg{progress: tracker.finished(attempted)}
+	})
+	// The audit trail must not hide the install result, so a failure
+	// here is only logged. A dry run installed nothing to record.
+	if !dryRun {
+		if serr := appendSummary(summary); serr != nil {
+			progress(trf("Could not write install summary: %s", serr))
+		}
+	}
+	if err != nil {
+		// the packages already there count as installed to the user
+		done := len(summary.installed) + len(summary.skipped)
+		status := trf("Installed %d of %d packages. Failed: %s", done, len(pkgs), strings.Join(summary.failed, ", "))
+		switch {
+		case errors.Is(err, niri.ErrCancelled):
+			status = trf("Install cancelled. Installed %d of %d packages.", done, len(pkgs))
+		case errors.Is(err, niri.ErrPrivilege):
+			status = tr("Privilege escalation failed — ensure your user can run sudo/doas (running sudo -v in this terminal first caches your password).")
+		}
+		// after a denial or a cancel the packages not tried yet are missing too
+		finish(statusMsg{status: status, err: err, failed: result.Failed})
+		return
+	}
+	status := trf("Installed %d packages.", len(pkgs))
+	switch {
+	case dryRun:
+		status = trf("Dry run, nothing was installed. The log shows the commands for the %d packages.", len(pkgs))
+	case len(cached) > 0:
+		status = trf("Installed %d packages, from the cache: %s", len(pkgs), strings.Join(cached, ", "))
+	}
+	if len(result.Present) > 0 {
+		status += "\n" + trf("Already installed, skipped: %s", strings.Join(result.Present, ", "))
+	}
+	// niri gets no seat from a seatd that is only installed
+	if slices.Contains(pkgs, "seatd") || niri.PackageInstalled("seatd") {
+		progress(tr("Setting up seatd..."))
+		report, err := niri.SetUpSeatd(ctx)
+		for _, line := range report {
+			progress("  " + line)
+		}
+		if err != nil {
+			status += "\n" + trf("Warning: %s", err)
+		}
+	}
+	if profile := niri.CurrentSetupProfile(); profile != nil && len(profile.Services) > 0 {
+		progress(trf("Enabling the services of %s...", profile.Path))
+		report, err := niri.EnableProfileServices(ctx)
+		for _, line := range report {
+			progress("  " + line)
+		}
+		if err != nil {
+			status += "\n" + trf("Warning: %s", err)
+		}
+	}
+	// What the hooks printed goes to the log, which of them ran to the result
+	var ran []string
+	for _, line := range niri.RunHooks(ctx, "install") {
+		progress(line)
+		if !strings.HasPrefix(line, "  ") {
+			ran = append(ran, line)
+		}
+	}
+	finish(statusMsg{status: strings.Join(append([]string{status}, ran...), "\n")})
+}
+
+// listen delivers the next message from a background action, re-arming
+// itself after every progressMsg or pausedMsg until the final result
+// arrives.
+func listen(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		switch msg := (<-updates).(type) {
+		case progressMsg:
+			msg.next = listen(updates)
+			return msg
+		case pausedMsg:
+			msg.next = listen(updates)
+			return msg
+		case packageProgressMsg:
+			msg.next = listen(updates)
+			return msg
+		case installingMsg:
+			msg.next = listen(updates)
+			return msg
+		default:
+			return msg
+		}
+	}
+}
+
+func showDependencies() tea.Cmd {
+	return func() tea.Msg {
+		tree, err := niri.DependencyTree("niri")
+		if err != nil {
+			return textMsg{err: err}
+		}
+		lines := []string{tr("✓ installed   ↓ will be fetched"), ""}
+		var fetch int
+		var walk func(n *niri.DepNode, depth int)
+		walk = func(n *niri.DepNode, depth int) {
+			mark := "↓"
+			if n.Installed {
+				mark = "✓"
+			} else if !n.Seen {
+				fetch++
+			}
+			line := strings.Repeat("  ", depth) + mark + " " + n.Name
+			if n.Seen && depth > 0 {
+				line += " " + tr("(see above)")
+			}
+			lines = append(lines, line)
+			for _, d := range n.Deps {
+				walk(d, depth+1)
+			}
+		}
+		walk(tree, 0)
+		lines = append(lines, "", trf("%d packages would be fetched.", fetch))
+		return textMsg{title: tr("What niri depends on"), text: strings.Join(lines, "\n")}
+	}
+}
+
+// installScriptName is where Write Install Script saves the script, relative
+// to the working directory.
+const installScriptName = "install.sh"
+
+func writeInstallScript(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		pkgs, report, err := niri.PackageList()
+		if err != nil {
+			return reportMsg(report, err)
+		}
+		path, err := filepath.Abs(installScriptName)
+		if err == nil {
+			err = niri.WriteInstallScript(ctx, path, pkgs)
+		}
+		if err != nil {
+			return reportMsg(report, err)
+		}
+		return reportMsg(append(report, trf("Wrote %s\nReview it, then run it to install Niri.", path)), nil)
+	}
+}
+
+// summary is the machine-readable line appended to the log file after
+// every install, so there is an audit trail even if Save Logs is never used.
+type summary struct {
+	run       string // identifies one install run
+	action    string
+	packages  []string
+	installed []string
+	skipped   []string // already installed, so left alone
+	failed    []string
+}
+
+func newSummary(action string, packages []string) summary {
+	return summary{
+		run:      fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano()),
+		action:   action,
+		packages: packages,
+	}
+}
+
+func (s summary) String() string {
+	pending := len(s.packages) - len(s.installed) - len(s.skipped) - len(s.failed)
+	return fmt.Sprintf("%s nirisetup-summary run=%s action=%s total=%d installed=%d skipped=%d failed=%d pending=%d packages=%s failed_packages=%s",
+		time.Now().Format(time.RFC3339), s.run, s.action, len(s.packages), len(s.installed), len(s.skipped), len(s.failed), pending,
+		strings.Join(s.packages, ","), strings.Join(s.failed, ","))
+}
+
+// appendSummary adds s to the log file, unless its run is already recorded
+// there.
+func appendSummary(s summary) error {
+	if data, err := os.ReadFile(logFilePath()); err == nil && strings.Contains(string(data), " run="+s.run+" ") {
+		return nil
+	}
+	file, err := openLogFile()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(s.String() + "\n")
+	return err
+}
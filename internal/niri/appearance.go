@@ -0,0 +1,150 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Appearance is the look ConfigureAppearance gives niri.
+type Appearance struct {
+	Name      string
+	Gaps      int     // logical pixels around windows
+	FocusRing int     // width of the ring around the focused window, 0 for none
+	Color     string  // colour of that ring
+	Slowdown  float64 // animation slowdown, 1 for niri's own speed, 0 for none
+}
+
+// AppearancePresets are the looks offered before a custom one.
+var AppearancePresets = []Appearance{
+	{Name: "Compact", Gaps: 4, FocusRing: 2, Color: "#7fc8ff", Slowdown: 0.5},
+	{Name: "Comfortable", Gaps: 16, FocusRing: 4, Color: "#7fc8ff", Slowdown: 1},
+	{Name: "No animations", Gaps: 16, FocusRing: 4, Color: "#7fc8ff"},
+}
+
+// Bounds on the custom values Configure Appearance accepts.
+const (
+	MaxGaps      = 64
+	MaxFocusRing = 32
+	MaxSlowdown  = 10.0
+)
+
+// DefaultAppearance is what a custom look starts from, the look of the
+// config NiriSetup ships.
+var DefaultAppearance = Appearance{Gaps: 16, FocusRing: 4, Color: "#7fc8ff", Slowdown: 1}
+
+// colorPattern matches the colours niri takes that are worth typing in a
+// prompt: CSS names and hex.
+var colorPattern = regexp.MustCompile(`^([a-z]+|#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8}))$`)
+
+// ValidColor reports whether c is a CSS colour name or a #rgb, #rgba,
+// #rrggbb or #rrggbbaa hex colour.
+func ValidColor(c string) bool {
+	return colorPattern.MatchString(c)
+}
+
+// setIn puts line among the children of the block at path, replacing the
+// child with the same name and creating the blocks that are missing.
+func setIn(cfg *Config, path []string, line string) error {
+	parent := cfg.Section(path[0])
+	if parent == nil {
+		return cfg.AddNode(nestedBlock(path, line))
+	}
+	for i, name := range path[1:] {
+		child := parent.Child(name)
+		if child == nil {
+			return cfg.AddChildTo(parent, nestedBlock(path[1+i:], line))
+		}
+		parent = child
+	}
+	if n := parent.Child(strings.Fields(line)[0]); n != nil {
+		return cfg.Replace(n, line)
+	}
+	return cfg.AddChildTo(parent, line)
+}
+
+// removeIn deletes the child called name of the block at path, if there is
+// one.
+func removeIn(cfg *Config, path []string, name string) error {
+	n := cfg.Section(path[0])
+	for _, p := range slices.Concat(path[1:], []string{name}) {
+		if n == nil {
+			return nil
+		}
+		n = n.Child(p)
+	}
+	if n == nil {
+		return nil
+	}
+	return cfg.Remove(n)
+}
+
+// nestedBlock wraps line in blocks named path, all on one line.
+func nestedBlock(path []string, line string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		line = path[i] + " { " + line + "; }"
+	}
+	return line
+}
+
+// formatFloat writes f with a decimal point, as niri wants for its floating
+// point settings.
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// ConfigureAppearance sets the gaps, focus ring and animation speed in the
+// layout and animations blocks of the niri config.
+func ConfigureAppearance(ctx context.Context, look Appearance) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	ring := []string{"layout", "focus-ring"}
+	report := []string{checked, fmt.Sprintf("Set the gaps to %dpx", look.Gaps)}
+	sets := []func() error{
+		func() error { return setIn(cfg, []string{"layout"}, "gaps "+strconv.Itoa(look.Gaps)) },
+	}
+	if look.FocusRing == 0 {
+		sets = append(sets, func() error { return setIn(cfg, ring, "off") })
+		report = append(report, "Turned the focus ring off")
+	} else {
+		sets = append(sets,
+			func() error { return removeIn(cfg, ring, "off") },
+			func() error { return setIn(cfg, ring, "width "+strconv.Itoa(look.FocusRing)) },
+			func() error { return setIn(cfg, ring, FormatNode("active-color", look.Color)) },
+		)
+		report = append(report, fmt.Sprintf("Set the focus ring to %dpx in %s", look.FocusRing, look.Color))
+	}
+	if look.Slowdown == 0 {
+		sets = append(sets, func() error { return setIn(cfg, []string{"animations"}, "off") })
+		report = append(report, "Turned animations off")
+	} else {
+		sets = append(sets,
+			func() error { return removeIn(cfg, []string{"animations"}, "off") },
+			func() error { return setIn(cfg, []string{"animations"}, "slowdown "+formatFloat(look.Slowdown)) },
+		)
+		report = append(report, "Set the animation slowdown to "+formatFloat(look.Slowdown))
+	}
+	for _, set := range sets {
+		if err := set(); err != nil {
+			return []string{checked}, fmt.Errorf("failed to update niri config: %w", err)
+		}
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return []string{checked}, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return append(report, "Updated "+ConfigPath()), nil
+}
@@ -0,0 +1,94 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// AudioPackages are the sound server niri sessions use and its session
+// manager.
+var AudioPackages = []string{"pipewire", "wireplumber"}
+
+// audioDaemons are what has to run in the session for sound: the server,
+// its session manager and the PulseAudio replacement most apps talk to.
+var audioDaemons = []string{"pipewire", "wireplumber", "pipewire-pulse"}
+
+// audioStartup starts audioDaemons in order. wireplumber and
+// pipewire-pulse connect to pipewire, so they wait for it to come up.
+const audioStartup = "pipewire & sleep 1; wireplumber & pipewire-pulse &"
+
+// AudioChecks reports whether there is a sound device and whether each of
+// audioDaemons runs, with how to fix what is missing.
+func AudioChecks() []Check {
+	dev := Check{Name: "Sound device"}
+	if dsps, _ := filepath.Glob("/dev/dsp*"); len(dsps) > 0 {
+		dev.OK, dev.Detail = true, strings.Join(dsps, " ")
+	} else {
+		dev.Detail = "no /dev/dsp; load the driver with sudo kldload snd_driver and add snd_driver_load=\"YES\" to /boot/loader.conf"
+	}
+	checks := []Check{dev}
+
+	running := map[string]bool{}
+	if procs, err := processesOf(audioDaemons); err == nil {
+		for _, p := range procs {
+			running[p.Name] = true
+		}
+	}
+	for _, d := range audioDaemons {
+		c := Check{Name: d + " running", OK: running[d]}
+		if !c.OK {
+			c.Detail = "not running; Configure Audio starts it with niri"
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// AudioWorks reports whether checks, from AudioChecks, found everything
+// sound needs.
+func AudioWorks(checks []Check) bool {
+	return !slices.ContainsFunc(checks, func(c Check) bool { return !c.OK })
+}
+
+// ConfigureAudio installs AudioPackages and has niri start the audio
+// daemons at startup, unless it already starts pipewire. The report ends
+// with AudioChecks as they stand now.
+func ConfigureAudio(ctx context.Context) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	for _, pkg := range AudioPackages {
+		if err := InstallPackage(ctx, pkg); err != nil {
+			return report, err
+		}
+		report = append(report, "Installed "+pkg)
+	}
+
+	if SpawnAtStartup(cfg, "pipewire") != nil || HasSpawnAtStartup(cfg, "sh", "-c", audioStartup) {
+		report = append(report, "niri already starts pipewire")
+	} else {
+		if err := cfg.AddNode(FormatNode("spawn-at-startup", "sh", "-c", audioStartup)); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		if err := WriteConfig(ctx, cfg); err != nil {
+			return report, fmt.Errorf("failed to write niri config: %w", err)
+		}
+		report = append(report, "Added spawn-at-startup: "+strings.Join(audioDaemons, ", "), "Updated "+ConfigPath())
+	}
+
+	report = append(report, "Audio now:")
+	for _, c := range AudioChecks() {
+		report = append(report, "  "+c.String())
+	}
+	return append(report, "Daemons that are not running yet start with the next niri session"), nil
+}
@@ -0,0 +1,98 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+)
+
+// InputBehavior is how focus and the pointer follow each other, and what
+// switching to the workspace already focused does.
+type InputBehavior struct {
+	FocusFollowsMouse         bool // focus windows the pointer moves into
+	WarpMouseToFocus          bool // move the pointer to windows focused from the keyboard
+	WorkspaceAutoBackAndForth bool // switching to the focused workspace goes back to the previous one
+}
+
+// DefaultInputBehavior is what Configure Input Behavior suggests: focus
+// follows the mouse, but only into windows fully on screen, so moving the
+// pointer never scrolls the view.
+var DefaultInputBehavior = InputBehavior{FocusFollowsMouse: true}
+
+// focusFollowsMouse is the line for FocusFollowsMouse. max-scroll-amount 0%
+// keeps focus from jumping to windows partly off screen.
+const focusFollowsMouse = `focus-follows-mouse max-scroll-amount="0%"`
+
+// inputBehaviorOf reads the behavior cfg configures.
+func inputBehaviorOf(cfg *Config) InputBehavior {
+	input := cfg.Section("input")
+	if input == nil {
+		return InputBehavior{}
+	}
+	return InputBehavior{
+		FocusFollowsMouse:         input.Child("focus-follows-mouse") != nil,
+		WarpMouseToFocus:          input.Child("warp-mouse-to-focus") != nil,
+		WorkspaceAutoBackAndForth: input.Child("workspace-auto-back-and-forth") != nil,
+	}
+}
+
+// CurrentInputBehavior returns the behavior the niri config sets.
+func CurrentInputBehavior() (InputBehavior, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return InputBehavior{}, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	return inputBehaviorOf(cfg), nil
+}
+
+// setInputBehavior writes b into the input block of cfg and reports each
+// setting.
+func setInputBehavior(cfg *Config, b InputBehavior) ([]string, error) {
+	var report []string
+	for _, s := range []struct {
+		name, line string
+		on         bool
+	}{
+		{"focus-follows-mouse", focusFollowsMouse, b.FocusFollowsMouse},
+		{"warp-mouse-to-focus", "warp-mouse-to-focus", b.WarpMouseToFocus},
+		{"workspace-auto-back-and-forth", "workspace-auto-back-and-forth", b.WorkspaceAutoBackAndForth},
+	} {
+		var err error
+		state := "on"
+		switch input := cfg.Section("input"); {
+		case s.on && input != nil && input.Child(s.name) != nil:
+			// kept as it is, e.g. with its own max-scroll-amount
+		case s.on:
+			err = setIn(cfg, []string{"input"}, s.line)
+		default:
+			state = "off"
+			err = removeIn(cfg, []string{"input"}, s.name)
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, s.name+": "+state)
+	}
+	return report, nil
+}
+
+// ConfigureInputBehavior writes b into the input block of the niri config
+// and saves it once niri has validated it.
+func ConfigureInputBehavior(ctx context.Context, b InputBehavior) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	report, err := setInputBehavior(cfg, b)
+	report = append([]string{checked}, report...)
+	if err != nil {
+		return report, err
+	}
+	if out, err := SaveSource(ctx, cfg.String()); err != nil {
+		return append(report, out), err
+	}
+	return append(report, "Updated "+ConfigPath()), nil
+}
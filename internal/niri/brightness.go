@@ -0,0 +1,72 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Brightness keys ConfigureBrightness binds.
+const (
+	BrightnessUpKey   = "XF86MonBrightnessUp"
+	BrightnessDownKey = "XF86MonBrightnessDown"
+)
+
+// brightnessStep is the percentage each brightness key changes the
+// backlight by.
+const brightnessStep = "5"
+
+// BacklightDevices lists the backlight devices the kernel exposes for
+// backlight(8). They only show up once the GPU driver, such as drm-kmod's
+// i915kms or amdgpu, is loaded.
+func BacklightDevices() []string {
+	devices, _ := filepath.Glob("/dev/backlight/*")
+	return devices
+}
+
+// brightnessBinds bind the brightness keys to backlight(8) on device.
+func brightnessBinds(device string) []spawnBind {
+	return []spawnBind{
+		{BrightnessUpKey, []string{"backlight", "-f", device, "incr", brightnessStep}, "raises the brightness"},
+		{BrightnessDownKey, []string{"backlight", "-f", device, "decr", brightnessStep}, "lowers the brightness"},
+	}
+}
+
+// ConfigureBrightness binds the brightness keys to backlight(8), which is
+// part of the base system, on the first of BacklightDevices. It fails when
+// there is no backlight device, and says so when the user cannot change
+// the device without root.
+func ConfigureBrightness(ctx context.Context) ([]string, error) {
+	devices := BacklightDevices()
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("no backlight device in /dev/backlight; load the GPU driver (e.g. kld_list=\"i915kms\" or \"amdgpu\" in /etc/rc.conf) first")
+	}
+	device := devices[0]
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked, "Backlight device: " + device}
+	bound, err := bindSpawns(cfg, brightnessBinds(device))
+	report = append(report, bound...)
+	if err != nil {
+		return report, err
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	report = append(report, "Updated "+ConfigPath())
+
+	if f, err := os.OpenFile(device, os.O_RDWR, 0); err != nil {
+		report = append(report, fmt.Sprintf("You cannot change %s yet (%v); add yourself to its group, usually video, with sudo pw groupmod video -m $USER and log in again", device, err))
+	} else {
+		f.Close()
+	}
+	return report, nil
+}
@@ -0,0 +1,117 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// bugReportLogLines is how much of the niri log a bug report includes.
+const bugReportLogLines = 50
+
+// secretEnv matches environment block entries whose values should not leave
+// the machine, e.g. GITHUB_TOKEN "..." or API_KEY "...".
+var secretEnv = regexp.MustCompile(`(?im)^(\s*[A-Z0-9_]*(TOKEN|SECRET|PASSWORD|PASSWD|KEY|CREDENTIALS?)[A-Z0-9_]*\s+)"[^"]*"`)
+
+// BugReport gathers what maintainers usually ask for into one text: the
+// system, niri's version, the installed package versions, the diagnostics,
+// config.kdl and the tail of the niri log, plus the settings and logs the
+// caller passes in. The home directory, user name and host name are
+// replaced with placeholders, as are environment values that look secret.
+func BugReport(settings, logs []string) string {
+	var b strings.Builder
+	section := func(title string) { fmt.Fprintf(&b, "\n== %s ==\n", title) }
+
+	fmt.Fprintf(&b, "NiriSetup bug report, %s\n", time.Now().Format(time.RFC1123))
+
+	section("System")
+	for _, args := range [][]string{{"uname", "-mrs"}, {"freebsd-version", "-ku"}} {
+		out, err := Command(args[0], args[1:]...).Output()
+		if err != nil {
+			fmt.Fprintf(&b, "%s: %v\n", strings.Join(args, " "), err)
+			continue
+		}
+		b.WriteString(strings.TrimSpace(string(out)) + "\n")
+	}
+	if version, err := InstalledVersion(); err != nil {
+		fmt.Fprintf(&b, "niri: %v\n", err)
+	} else {
+		fmt.Fprintf(&b, "niri %s\n", version)
+	}
+
+	section("Packages")
+	if pkgs, err := InstalledPackages(); err != nil {
+		fmt.Fprintf(&b, "%v\n", err)
+	} else {
+		for _, p := range pkgs {
+			fmt.Fprintf(&b, "%s-%s\n", p.Name, p.Version)
+		}
+	}
+
+	section("Checks")
+	for _, c := range Doctor() {
+		b.WriteString(c.String() + "\n")
+	}
+
+	section("Settings")
+	for _, s := range settings {
+		b.WriteString(s + "\n")
+	}
+
+	section(ConfigPath())
+	if data, err := os.ReadFile(ConfigPath()); err != nil {
+		fmt.Fprintf(&b, "%v\n", err)
+	} else {
+		b.WriteString(secretEnv.ReplaceAllString(string(data), `$1"<redacted>"`))
+	}
+
+	section(LaunchLog())
+	if tail, err := LaunchLogTail(bugReportLogLines); err != nil {
+		fmt.Fprintf(&b, "%v\n", err)
+	} else {
+		b.WriteString(tail + "\n")
+	}
+
+	section("NiriSetup log")
+	for _, l := range logs {
+		b.WriteString(l + "\n")
+	}
+	return redact(b.String())
+}
+
+// redact swaps the home directory, user name and host name in s for
+// placeholders. The home directory goes first since it usually contains
+// the user name; very short user names are left alone, since they would
+// match inside ordinary words.
+func redact(s string) string {
+	var pairs []string
+	if home, err := os.UserHomeDir(); err == nil && home != "/" {
+		pairs = append(pairs, home, "~")
+	}
+	if u, err := user.Current(); err == nil && len(u.Username) > 2 && u.Username != "root" {
+		pairs = append(pairs, u.Username, "<user>")
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		pairs = append(pairs, host, "<host>")
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// WriteBugReport writes BugReport to a new file in the temporary directory
+// and returns its path, "" in dry-run mode.
+func WriteBugReport(ctx context.Context, settings, logs []string) (string, error) {
+	path := filepath.Join(os.TempDir(), "nirisetup-bugreport-"+time.Now().Format("20060102-150405")+".txt")
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would write a bug report to " + path)
+		return "", nil
+	}
+	if err := writeFile(ctx, path, []byte(BugReport(settings, logs)), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,68 @@
+package niri
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPackageCache is where pkg keeps the packages it fetches, and so
+// where a machine that has installed them before already has them.
+const DefaultPackageCache = "/var/cache/pkg"
+
+// CachedPackages looks for pkgs among the package files in dir, and in its
+// All subdirectory where pkg fetch -o puts them. It returns the file for
+// each package found and the packages that are not there.
+func CachedPackages(dir string, pkgs []string) (map[string]string, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	if all, err := os.ReadDir(filepath.Join(dir, "All")); err == nil {
+		for _, e := range all {
+			paths = append(paths, filepath.Join(dir, "All", e.Name()))
+		}
+	}
+
+	files := map[string]string{}
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if ext := filepath.Ext(base); ext != ".pkg" && ext != ".txz" {
+			continue
+		}
+		// Files are named name-version.pkg, but names have dashes too, so
+		// only ask pkg about the ones that could be a package we want.
+		var wanted bool
+		for _, pkg := range pkgs {
+			if files[pkg] == "" && strings.HasPrefix(base, pkg+"-") {
+				wanted = true
+			}
+		}
+		if !wanted {
+			continue
+		}
+		out, err := Command("pkg", "query", "-F", path, "%n").Output()
+		if err != nil {
+			continue // not a package file after all
+		}
+		if name := strings.TrimSpace(string(out)); files[name] == "" {
+			files[name] = path
+		}
+	}
+
+	found := map[string]string{}
+	var missing []string
+	for _, pkg := range pkgs {
+		if files[pkg] == "" {
+			missing = append(missing, pkg)
+		} else {
+			found[pkg] = files[pkg]
+		}
+	}
+	return found, missing, nil
+}
@@ -0,0 +1,56 @@
+package niri
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// cancelWait is how long a cancelled command gets to exit after SIGTERM
+// before it is killed.
+const cancelWait = 5 * time.Second
+
+// ErrCancelled means CancelCommands stopped an install before it was done.
+var ErrCancelled = errors.New("cancelled")
+
+var (
+	runningMu     sync.Mutex
+	running       context.Context
+	cancelRunning context.CancelFunc
+)
+
+func init() {
+	running, cancelRunning = context.WithCancel(context.Background())
+}
+
+// commandContext is the context Command starts commands in.
+func commandContext() context.Context {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	return running
+}
+
+// cancellable is the context Command starts commands in, handing on the
+// Options of ctx, so that CancelCommands stops an action run with them.
+func cancellable(ctx context.Context) context.Context {
+	return WithOptions(commandContext(), OptionsFrom(ctx))
+}
+
+// CancelCommands stops the commands Command started that are still running,
+// and the install they are part of, so a hung pkg can be aborted. Commands
+// started afterwards run as usual.
+func CancelCommands() {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	cancelRunning()
+	running, cancelRunning = context.WithCancel(context.Background())
+}
+
+// cancelled returns ErrCancelled once ctx is done.
+func cancelled(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return ErrCancelled
+}
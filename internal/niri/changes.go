@@ -0,0 +1,124 @@
+package niri
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Version is NiriSetup's own version. It goes up whenever a release changes
+// what NiriSetup writes, with a Change saying what to do about it.
+const Version = "0.2.0"
+
+// Change is what changed in the configs NiriSetup writes in a release, and
+// what users upgrading to it may want to regenerate.
+type Change struct {
+	Version string
+	Notes   []string
+}
+
+// Changes are the releases that changed the configs NiriSetup writes,
+// oldest first.
+var Changes = []Change{
+	{"0.2.0", []string{
+		"Enable X11 Apps is now Toggle XWayland, which can also remove xwayland-satellite again.",
+		"Generate Default Configs writes starter configs for waybar, mako, fuzzel and swaylock; run it to get them, your own files are backed up.",
+		"Configs written before lack brightness and media key binds; Configure Brightness Keys and Configure Media Keys add them.",
+		"Every config NiriSetup writes is now checked with niri validate first, so a change niri would reject no longer reaches config.kdl.",
+	}},
+}
+
+// VersionState is which NiriSetup last wrote a config and which one's
+// changes the user has seen.
+type VersionState struct {
+	Wrote string // "" before this NiriSetup first writes a config
+	Seen  string
+}
+
+// versionStatePath is where VersionState is kept.
+func versionStatePath() string {
+	return filepath.Join(stateHome(), "nirisetup", "version")
+}
+
+// loadVersionState reads the VersionState. There is none before the first
+// config is written.
+func loadVersionState() (VersionState, error) {
+	var s VersionState
+	data, err := os.ReadFile(versionStatePath())
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read %s: %w", versionStatePath(), err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch key {
+		case "wrote":
+			s.Wrote = value
+		case "seen":
+			s.Seen = value
+		}
+	}
+	return s, nil
+}
+
+// saveVersionState writes s.
+func saveVersionState(s VersionState) error {
+	path := versionStatePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	return replaceFile(path, []byte(fmt.Sprintf("wrote %s\nseen %s\n", s.Wrote, s.Seen)), 0644)
+}
+
+// noteWrite records that this version wrote a config. It is only
+// bookkeeping, so a failure is ignored.
+func noteWrite() {
+	s, err := loadVersionState()
+	if err != nil || s.Wrote == Version {
+		return
+	}
+	s.Wrote = Version
+	saveVersionState(s)
+}
+
+// PendingChanges returns the changes since the version that last wrote a
+// config, or since the last ones the user acknowledged if that is later. A
+// config written before NiriSetup kept track of its version gets every
+// change; without any config there is nothing to migrate.
+func PendingChanges() ([]Change, error) {
+	s, err := loadVersionState()
+	if err != nil {
+		return nil, err
+	}
+	since := s.Wrote
+	if since == "" {
+		if _, err := os.Stat(ConfigPath()); err != nil {
+			return nil, nil
+		}
+		since = "0"
+	}
+	if s.Seen != "" && versionAtLeast(s.Seen, since) {
+		since = s.Seen
+	}
+	var pending []Change
+	for _, c := range Changes {
+		if !versionAtLeast(since, c.Version) && versionAtLeast(Version, c.Version) {
+			pending = append(pending, c)
+		}
+	}
+	return pending, nil
+}
+
+// AcknowledgeChanges records that the user has seen the changes up to
+// Version, so PendingChanges stops returning them.
+func AcknowledgeChanges() error {
+	s, err := loadVersionState()
+	if err != nil {
+		return err
+	}
+	s.Seen = Version
+	return saveVersionState(s)
+}
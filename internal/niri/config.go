@@ -0,0 +1,503 @@
+package niri
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultConfig is the starter niri config shipped with NiriSetup.
+//
+//go:embed config.kdl
+var DefaultConfig string
+
+// configHome is XDG_CONFIG_HOME, or ~/.config when it is unset.
+func configHome() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return dir
+}
+
+// ConfigPath is where niri reads its config from, honouring XDG_CONFIG_HOME.
+func ConfigPath() string {
+	return filepath.Join(configHome(), "niri", "config.kdl")
+}
+
+// LoadConfig reads and parses the user's niri config.
+func LoadConfig() (*Config, error) {
+	data, err := os.ReadFile(ConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(string(data))
+}
+
+// EnsureWritableDir creates dir if needed and checks that the current user
+// can create files in it, so configure actions fail up front rather than
+// halfway through writing.
+func EnsureWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	probe, err := os.CreateTemp(dir, ".nirisetup-probe-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable by uid %d: %w", dir, os.Geteuid(), err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+// writeFile replaces path with data like os.WriteFile, but through a
+// temporary file in the same directory that is renamed into place once it
+// is complete, so an interrupted write leaves the old file rather than half
+// of the new one. A symlink at path is followed, so a profile link stays a
+// link, and an existing file keeps its mode. In dry-run mode it only
+// reports the write.
+func writeFile(ctx context.Context, path string, data []byte, perm os.FileMode) error {
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would write " + path)
+		return nil
+	}
+	return replaceFile(path, data, perm)
+}
+
+// replaceFile is writeFile even in dry-run mode, for NiriSetup's own
+// state.
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// prepareConfigDir runs EnsureWritableDir on the niri config directory and
+// returns the line configure actions report for it.
+func prepareConfigDir() (string, error) {
+	dir := filepath.Dir(ConfigPath())
+	if err := EnsureWritableDir(dir); err != nil {
+		return "", fmt.Errorf("cannot write the niri config: %w", err)
+	}
+	return fmt.Sprintf("Checked %s is writable", dir), nil
+}
+
+// ValidationError is niri validate turning down a config NiriSetup was
+// about to write.
+type ValidationError struct {
+	Output string // niri's explanation
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	if out := strings.TrimSpace(e.Output); out != "" {
+		return "niri rejected the config: " + out
+	}
+	return fmt.Sprintf("niri rejected the config: %v", e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// WriteConfig saves cfg back to the user's niri config, backing up the
+// current file first. Every action that edits the config goes through here,
+// so this is where the config is checked: it is rendered to a temporary
+// file and run through niri validate, and a config niri rejects is never
+// written; the *ValidationError says why. Before niri is installed there is
+// nothing to check against and the config is written as it is.
+func WriteConfig(ctx context.Context, cfg *Config) error {
+	src := cfg.String()
+	if _, err := exec.LookPath("niri"); err == nil {
+		if out, err := ValidateSource(src); err != nil {
+			return &ValidationError{Output: out, Err: err}
+		}
+	}
+	if OptionsFrom(ctx).Preview != nil {
+		old, err := os.ReadFile(ConfigPath())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if diff := UnifiedDiff(ConfigPath(), ConfigPath(), string(old), src); diff != "" {
+			OptionsFrom(ctx).Preview(diff)
+		}
+		return nil
+	}
+	backup, err := Backup(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(ctx, ConfigPath(), []byte(src), 0644); err != nil {
+		return err
+	}
+	noteWrite()
+	if Written != nil {
+		Written(backup)
+	}
+	return nil
+}
+
+// backupMarker separates the config name from the time a backup was taken,
+// so backup names sort oldest to newest.
+const backupMarker = ".bak."
+
+// Backup copies the current niri config to a timestamped file next to it
+// and returns its path. It does nothing when there is no config yet.
+func Backup(ctx context.Context) (string, error) {
+	data, err := os.ReadFile(ConfigPath())
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	backup, err := writeBackup(ctx, ConfigPath(), data)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up config: %w", err)
+	}
+	return backup, nil
+}
+
+// writeBackup writes data to a new backup of path, named for the time down
+// to the nanosecond. The name is taken with O_EXCL, and one already taken
+// gets a -N suffix, so two backups in the same second never overwrite
+// each other.
+func writeBackup(ctx context.Context, path string, data []byte) (string, error) {
+	name := path + backupMarker + time.Now().Format("20060102-150405.000000000")
+	if OptionsFrom(ctx).Preview != nil {
+		return name, writeFile(ctx, name, data, 0644)
+	}
+	backup := name
+	for n := 1; ; n++ {
+		f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			backup = fmt.Sprintf("%s-%d", name, n)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		f.Close()
+		break
+	}
+	if err := writeFile(ctx, backup, data, 0644); err != nil {
+		os.Remove(backup)
+		return "", err
+	}
+	return backup, nil
+}
+
+// Backups returns the config backups, newest first.
+func Backups() ([]string, error) {
+	backups, err := filepath.Glob(ConfigPath() + backupMarker + "*")
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(backups)
+	slices.Reverse(backups)
+	return backups, nil
+}
+
+// CompareBackups returns a unified diff from the older of two config
+// backups to the newer one.
+func CompareBackups(a, b string) (string, error) {
+	if a > b { // backup names sort by the time they were taken
+		a, b = b, a
+	}
+	old, err := os.ReadFile(a)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", a, err)
+	}
+	new, err := os.ReadFile(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", b, err)
+	}
+	return UnifiedDiff(filepath.Base(a), filepath.Base(b), string(old), string(new)), nil
+}
+
+// Configure prepares the niri config directory, writes DefaultConfig, or
+// the starter config of the setup profile in use, when there is no config
+// yet and merges in the user's snippets from
+// SnippetsDir. An existing config is kept. Once written, the config is
+// validated again as niri will load it, and put back as it was if niri
+// rejects it. It then writes the starter ToolConfigs of tools, backing up
+// the files they replace.
+func Configure(ctx context.Context, tools ...string) ([]string, error) {
+	return ConfigureWith(ctx, StarterToolset(), tools...)
+}
+
+// ConfigureWith is Configure with a starter config that starts the
+// programs of t, warning about the ones that are not installed.
+func ConfigureWith(ctx context.Context, t Toolset, tools ...string) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	report := []string{checked}
+	old, err := os.ReadFile(ConfigPath())
+	existed := err == nil
+	switch {
+	case os.IsNotExist(err):
+		if out, err := SaveSource(ctx, starterConfig(t)); err != nil {
+			return append(report, out), err
+		}
+		if setupProfile != nil && setupProfile.Config != "" {
+			report = append(report, "Wrote the starter config of "+setupProfile.Path+" to "+ConfigPath())
+			break
+		}
+		report = append(report, "Wrote the starter config to "+ConfigPath())
+		report = append(report, t.missing()...)
+	case err != nil:
+		return report, fmt.Errorf("failed to read niri config: %w", err)
+	default:
+		// the user's own config wins; Repair Config replaces a broken one
+		report = append(report, "Kept the existing "+ConfigPath())
+	}
+	applied, err := configureSnippets(ctx)
+	report = append(report, applied...)
+	if err != nil {
+		return report, err
+	}
+	if err := keepValid(ctx, old, existed); err != nil {
+		return report, err
+	}
+	written, err := writeToolConfigs(ctx, tools)
+	return append(report, written...), err
+}
+
+// ConfigurePlan is what ConfigureWith would change: Diff is a unified
+// diff of the files already there that it would overwrite, "" when there
+// are none, and Created the files it would write that are not there yet.
+type ConfigurePlan struct {
+	Diff    string
+	Created []string
+}
+
+// PlanConfigure works out what ConfigureWith would change with tools,
+// without changing anything, so its overwrites can be shown first: the
+// snippets it would merge into an existing config and the starter
+// ToolConfigs of tools that would replace different files. The Toolset
+// only shapes a config that is not there yet, so it is not needed.
+func PlanConfigure(tools ...string) (ConfigurePlan, error) {
+	var plan ConfigurePlan
+	var diffs []string
+	old, err := os.ReadFile(ConfigPath())
+	switch {
+	case os.IsNotExist(err):
+		plan.Created = append(plan.Created, ConfigPath())
+	case err != nil:
+		return plan, fmt.Errorf("failed to read niri config: %w", err)
+	default:
+		snippets, err := Snippets()
+		if err != nil {
+			return plan, err
+		}
+		if len(snippets) > 0 {
+			src, err := applySnippets(string(old), snippets)
+			if err != nil {
+				return plan, err
+			}
+			cfg, err := ParseConfig(src)
+			if err != nil {
+				return plan, err
+			}
+			diffs = append(diffs, UnifiedDiff(ConfigPath(), ConfigPath(), string(old), cfg.String()))
+		}
+	}
+	for _, tc := range ToolConfigs {
+		if !slices.Contains(tools, tc.Tool) {
+			continue
+		}
+		path := filepath.Join(configHome(), tc.Path)
+		data, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+			plan.Created = append(plan.Created, path)
+		case err != nil:
+			return plan, fmt.Errorf("failed to read %s: %w", path, err)
+		default:
+			diffs = append(diffs, UnifiedDiff(path, path, string(data), tc.Content))
+		}
+	}
+	plan.Diff = strings.Join(slices.DeleteFunc(diffs, func(d string) bool { return d == "" }), "\n")
+	return plan, nil
+}
+
+// keepValid runs niri validate on the config Configure wrote and, if niri
+// rejects it, puts back old, or removes the config when there was none
+// before. A config Configure left alone is the user's to fix.
+func keepValid(ctx context.Context, old []byte, existed bool) error {
+	if OptionsFrom(ctx).Preview != nil {
+		return nil
+	}
+	if _, err := exec.LookPath("niri"); err != nil {
+		return nil
+	}
+	if now, err := os.ReadFile(ConfigPath()); err == nil && existed && string(now) == string(old) {
+		return nil
+	}
+	out, err := ValidateFile(ConfigPath())
+	if err == nil {
+		return nil
+	}
+	problem := cmp.Or(strings.TrimSpace(out), err.Error())
+	var restore error
+	if existed {
+		restore = writeFile(ctx, ConfigPath(), old, 0644)
+	} else {
+		restore = os.Remove(ConfigPath())
+	}
+	if restore != nil {
+		return fmt.Errorf("niri rejected the new config (%s) and putting back the old one failed: %w", problem, restore)
+	}
+	if !existed {
+		return fmt.Errorf("niri rejected the new config, so it was removed again: %s", problem)
+	}
+	return fmt.Errorf("niri rejected the new config, so the old one was put back: %s", problem)
+}
+
+// ValidateConfig runs niri validate on the active config and returns its
+// output.
+func ValidateConfig() (string, error) {
+	out, err := Command("niri", "validate").CombinedOutput()
+	return string(out), err
+}
+
+// ValidateFile runs niri validate against the config at path.
+func ValidateFile(path string) (string, error) {
+	out, err := Command("niri", "validate", "-c", path).CombinedOutput()
+	return string(out), err
+}
+
+// ValidateSource runs niri validate on src without touching the active
+// config. The text is checked from a temporary file next to the config, so
+// relative paths in it resolve the same way.
+func ValidateSource(src string) (string, error) {
+	dir := filepath.Dir(ConfigPath())
+	if err := EnsureWritableDir(dir); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".config-*.kdl")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return ValidateFile(tmp.Name())
+}
+
+// SaveSource makes src the niri config through WriteConfig, so only if
+// niri accepts it. When it does not the returned string is niri's
+// explanation.
+func SaveSource(ctx context.Context, src string) (string, error) {
+	cfg, err := ParseConfig(src)
+	if err != nil {
+		return err.Error(), err
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		var invalid *ValidationError
+		if errors.As(err, &invalid) { // callers report niri's output themselves
+			return invalid.Output, fmt.Errorf("niri rejected the config: %w", invalid.Err)
+		}
+		return "", fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return "", nil
+}
+
+// Diagnosis is the outcome of checking the niri config for problems.
+type Diagnosis struct {
+	Valid   bool
+	Problem string // output of niri validate when the config is invalid
+	Backup  string // newest backup that still validates, if any
+}
+
+// Diagnose validates the niri config and, when it is broken, looks for the
+// newest backup that still validates so it can be offered as a way out.
+func Diagnose() (Diagnosis, error) {
+	if _, err := exec.LookPath("niri"); err != nil {
+		return Diagnosis{}, fmt.Errorf("niri is not installed; run Install Niri first")
+	}
+	out, err := ValidateFile(ConfigPath())
+	if err == nil {
+		return Diagnosis{Valid: true}, nil
+	}
+	d := Diagnosis{Problem: out}
+	backups, _ := Backups()
+	for _, backup := range backups {
+		if _, err := ValidateFile(backup); err == nil {
+			d.Backup = backup
+			break
+		}
+	}
+	return d, nil
+}
+
+// RestoreBackup replaces the niri config with backup and re-validates it.
+func RestoreBackup(ctx context.Context, backup string) ([]string, error) {
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", backup, err)
+	}
+	return replaceConfig(ctx, string(data), "Restored "+backup)
+}
+
+// RestoreDefaults replaces the niri config with DefaultConfig and
+// re-validates it.
+func RestoreDefaults(ctx context.Context) ([]string, error) {
+	return replaceConfig(ctx, DefaultConfig, "Wrote the default niri config")
+}
+
+// replaceConfig overwrites the niri config with src, keeping a backup of the
+// broken one, and re-validates the result.
+func replaceConfig(ctx context.Context, src, done string) ([]string, error) {
+	cfg, err := ParseConfig(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the replacement config: %w", err)
+	}
+	if _, err := prepareConfigDir(); err != nil {
+		return nil, err
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return nil, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	report := []string{done}
+	if out, err := ValidateFile(ConfigPath()); err != nil {
+		return report, fmt.Errorf("the config still fails validation: %s", out)
+	}
+	return append(report, "Niri configuration is valid."), nil
+}
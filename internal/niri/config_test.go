@@ -0,0 +1,818 @@
+package niri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"NiriSetup/internal/niri/niritest"
+)
+
+func TestWriteFileReplacesContents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.kdl")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(context.Background(), path, []byte("new"), 0644); err != nil {
+		t.Fatalf("writeFile returned %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Fatalf("file holds %q (%v), want %q", data, err, "new")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode is %v, want the file's own 0600", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the config", len(entries))
+	}
+}
+
+func TestWriteFileKeepsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "work.kdl")
+	link := filepath.Join(dir, "config.kdl")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(context.Background(), link, []byte("new"), 0644); err != nil {
+		t.Fatalf("writeFile returned %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("%s is no longer a symlink", link)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("target holds %q, want %q", data, "new")
+	}
+}
+
+func TestSetIn(t *testing.T) {
+	cfg, err := ParseConfig("layout {\n    gaps 16\n    focus-ring {\n        off\n        width 4\n    }\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, edit := range []func() error{
+		func() error { return setIn(cfg, []string{"layout"}, "gaps 4") },
+		func() error { return removeIn(cfg, []string{"layout", "focus-ring"}, "off") },
+		func() error { return setIn(cfg, []string{"layout", "focus-ring"}, "width 2") },
+		func() error { return setIn(cfg, []string{"animations"}, "off") },
+	} {
+		if err := edit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "layout {\n    gaps 4\n    focus-ring {\n        width 2\n    }\n}\n\nanimations { off; }\n"
+	if cfg.String() != want {
+		t.Errorf("config is\n%s\nwant\n%s", cfg, want)
+	}
+}
+
+func TestKeyUsage(t *testing.T) {
+	cfg, err := ParseConfig("binds {\n    Mod+T { spawn \"foot\"; }\n    Super+Shift+q { quit; }\n    /-Mod+Ctrl+A { quit; }\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := map[string][]string{}
+	for _, u := range KeyUsage(cfg) {
+		used[u.Modifier] = u.Used
+		if len(u.Used)+len(u.Free) != len(commonKeys) {
+			t.Errorf("%s has %d used and %d free keys, want %d in all", u.Modifier, len(u.Used), len(u.Free), len(commonKeys))
+		}
+	}
+	for mod, want := range map[string]string{"Mod": "T", "Mod+Shift": "Q", "Mod+Ctrl": ""} {
+		if got := strings.Join(used[mod], " "); got != want {
+			t.Errorf("%s+ keys bound are %q, want %q", mod, got, want)
+		}
+	}
+}
+
+func TestWriteConfigValidates(t *testing.T) {
+	bin := t.TempDir()
+	fake := "#!/bin/sh\nif grep -q broken \"$3\"; then echo 'unknown node broken'; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "niri"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigPath(), []byte("prefer-no-csd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := ParseConfig("broken\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteConfig(context.Background(), cfg)
+	if invalid, ok := err.(*ValidationError); !ok || invalid.Output != "unknown node broken\n" {
+		t.Fatalf("WriteConfig of a rejected config returned %v, want a *ValidationError with niri's output", err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != "prefer-no-csd\n" {
+		t.Errorf("config holds %q after the rejected write, want it unchanged", data)
+	}
+	if backups, _ := Backups(); len(backups) != 0 {
+		t.Errorf("rejected write left backups %q", backups)
+	}
+}
+
+func TestBackupsWithinASecond(t *testing.T) {
+	writeTestConfig(t, "first\n")
+	// a backup named the old way, to the second, stays the oldest
+	older := ConfigPath() + backupMarker + "20200101-000000"
+	if err := os.WriteFile(older, []byte("older\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var made []string
+	for _, next := range []string{"second\n", "third\n"} {
+		backup, err := Backup(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		made = append(made, backup)
+		if err := os.WriteFile(ConfigPath(), []byte(next), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := Backups()
+	if want := []string{made[1], made[0], older}; err != nil || !slices.Equal(backups, want) {
+		t.Fatalf("Backups = %q, %v, want %q", backups, err, want)
+	}
+	for i, want := range []string{"second\n", "first\n", "older\n"} {
+		if data, _ := os.ReadFile(backups[i]); string(data) != want {
+			t.Errorf("%s holds %q, want %q", backups[i], data, want)
+		}
+	}
+}
+
+func TestStripOverstrike(t *testing.T) {
+	if got := stripOverstrike("N\bNA\bAM\bME\bE\n  _\bc_\bo_\bn_\bf_\bi_\bg"); got != "NAME\n  config" {
+		t.Errorf("stripOverstrike returned %q", got)
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	got, err := parseSettings("# comment\ndry-run = on\n\n  log-level=debug # noisy\n")
+	if err != nil || got["dry-run"] != "on" || got["log-level"] != "debug" || len(got) != 2 {
+		t.Errorf("parseSettings returned %q, %v", got, err)
+	}
+	if _, err := parseSettings("dry-run on\n"); err == nil {
+		t.Error("parseSettings accepted a line without =")
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if got, err := LoadTheme(); err != nil || got != (Theme{}) {
+		t.Errorf("LoadTheme without a file returned %+v, %v", got, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(ThemePath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ThemePath(), []byte(`{"accent": "#ffa07a", "dim": "244", "error": "red"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadTheme()
+	if err == nil || !strings.Contains(err.Error(), `error is "red"`) {
+		t.Errorf("LoadTheme accepted a color name: %v", err)
+	}
+	if want := (Theme{Accent: "#ffa07a", Dim: "244"}); got != want {
+		t.Errorf("LoadTheme returned %+v, want %+v", got, want)
+	}
+	if err := os.WriteFile(ThemePath(), []byte(`{"accent": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := LoadTheme(); err == nil || got != (Theme{}) {
+		t.Errorf("LoadTheme of broken JSON returned %+v, %v", got, err)
+	}
+}
+
+func TestConfigSearchPath(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Setenv("NIRI_CONFIG", "")
+	if err := os.MkdirAll(filepath.Dir(ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigPath(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got := ConfigSearchPath()
+	if len(got) != 4 || got[0].Skipped == "" || got[1].Path != ConfigPath() || !got[1].Exists || got[2].Skipped == "" {
+		t.Fatalf("ConfigSearchPath returned %+v", got)
+	}
+	if report := ConfigSearchReport(); !strings.Contains(report, "2. "+ConfigPath()+"\n   from XDG_CONFIG_HOME: exists, this is the config niri uses") {
+		t.Errorf("ConfigSearchReport does not mark %s as used:\n%s", ConfigPath(), report)
+	}
+}
+
+func TestWriteToolConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	tool := ToolConfig{Tool: "mako", Path: "mako/config", Content: "default-timeout=5000\n"}
+	path := filepath.Join(configHome(), tool.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeToolConfig(context.Background(), tool); err != nil {
+		t.Fatalf("writeToolConfig returned %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != tool.Content {
+		t.Errorf("%s holds %q, want the default", path, data)
+	}
+	backups, _ := filepath.Glob(path + backupMarker + "*")
+	if len(backups) != 1 {
+		t.Fatalf("found backups %q, want one", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "mine\n" {
+		t.Errorf("backup holds %q, want the old config", data)
+	}
+	if line, _ := writeToolConfig(context.Background(), tool); !strings.Contains(line, "already has the default config") {
+		t.Errorf("writing the default again reported %q", line)
+	}
+}
+
+func TestDisableXWayland(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir()) // no niri to validate with
+	if err := os.MkdirAll(filepath.Dir(ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := "environment {\n    DISPLAY \":0\"\n    QT_QPA_PLATFORM \"wayland\"\n}\n\nspawn-at-startup \"xwayland-satellite\"\nspawn-at-startup \"waybar\"\n"
+	if err := os.WriteFile(ConfigPath(), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DisableXWayland(context.Background()); err != nil {
+		t.Fatalf("DisableXWayland returned %v", err)
+	}
+	want := "environment {\n    QT_QPA_PLATFORM \"wayland\"\n}\n\nspawn-at-startup \"waybar\"\n"
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != want {
+		t.Errorf("config is\n%s\nwant\n%s", data, want)
+	}
+	if on, err := XWaylandEnabled(); on || err != nil {
+		t.Errorf("XWaylandEnabled after DisableXWayland returned %v, %v", on, err)
+	}
+}
+
+func TestBindSpawns(t *testing.T) {
+	cfg, err := ParseConfig("binds {\n    XF86MonBrightnessUp { spawn \"light\" \"-A\" \"10\"; }\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bindSpawns(cfg, brightnessBinds("/dev/backlight/backlight0")); err != nil {
+		t.Fatal(err)
+	}
+	for key, verb := range map[string]string{BrightnessUpKey: "incr", BrightnessDownKey: "decr"} {
+		b := cfg.Bind(key)
+		if want := []string{"backlight", "-f", "/dev/backlight/backlight0", verb, brightnessStep}; b == nil || !slices.Equal(b.Args, want) || b.Node.Props["allow-when-locked"] != "true" {
+			t.Errorf("%s is bound to %+v, want spawn %q while locked", key, b, want)
+		}
+	}
+	report, _ := bindSpawns(cfg, brightnessBinds("/dev/backlight/backlight0"))
+	if len(report) != 2 || !strings.Contains(report[0], "left it unchanged") {
+		t.Errorf("binding the keys again reports %q", report)
+	}
+}
+
+func TestStatusWithoutConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	status := Status()
+	if status.Complete {
+		t.Error("the setup is complete without a niri config")
+	}
+	if c := status.Checks[1]; c.Name != "Config valid" || c.OK || !strings.Contains(c.Detail, "does not exist") {
+		t.Errorf("config check without a config is %+v", c)
+	}
+}
+
+func TestWorkspaceApps(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // no niri to validate with
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigPath(), []byte("workspace \"2\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []WorkspaceApp{{3, "firefox --new-window", ""}, {1, "foot", ""}, {2, "firefox --new-window", "firefox"}} {
+		if _, err := SetWorkspaceApp(context.Background(), a.Command, a.AppID, a.Workspace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apps, err := WorkspaceApps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WorkspaceApp{{1, "foot", "foot"}, {2, "firefox --new-window", "firefox"}}
+	if !slices.Equal(apps, want) {
+		t.Errorf("workspace apps are %+v, want %+v", apps, want)
+	}
+	if _, err := RemoveWorkspaceApp(context.Background(), apps[0]); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(ConfigPath())
+	cfg := string(data)
+	if strings.Contains(cfg, "foot") || strings.Count(cfg, "spawn-at-startup") != 1 {
+		t.Errorf("config after removing foot is\n%s", cfg)
+	}
+	if i, j, k := strings.Index(cfg, `workspace "1"`), strings.Index(cfg, `workspace "2"`), strings.Index(cfg, `workspace "3"`); i < 0 || i > j || j > k {
+		t.Errorf("workspaces are not declared in order in\n%s", cfg)
+	}
+}
+
+func TestSetInputBehavior(t *testing.T) {
+	cfg, err := ParseConfig("input {\n    warp-mouse-to-focus\n    focus-follows-mouse max-scroll-amount=\"10%\"\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := InputBehavior{FocusFollowsMouse: true, WorkspaceAutoBackAndForth: true}
+	if _, err := setInputBehavior(cfg, want); err != nil {
+		t.Fatal(err)
+	}
+	if got := inputBehaviorOf(cfg); got != want {
+		t.Errorf("behavior after writing %+v is %+v", want, got)
+	}
+	if !strings.Contains(cfg.String(), `max-scroll-amount="10%"`) {
+		t.Errorf("focus-follows-mouse lost its own max-scroll-amount:\n%s", cfg)
+	}
+}
+
+func TestPendingChanges(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if pending, err := PendingChanges(); err != nil || pending != nil {
+		t.Fatalf("without a config the pending changes are %v, %v", pending, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigPath(), []byte("prefer-no-csd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := PendingChanges(); len(pending) != len(Changes) {
+		t.Errorf("a config from before version tracking has %d pending changes, want all %d", len(pending), len(Changes))
+	}
+	if err := AcknowledgeChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := PendingChanges(); len(pending) != 0 {
+		t.Errorf("acknowledged changes are still pending: %v", pending)
+	}
+	if err := saveVersionState(VersionState{Wrote: "0.1.0", Seen: "0.1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := PendingChanges(); len(pending) == 0 || pending[0].Version == "0.1.0" {
+		t.Errorf("after an upgrade from 0.1.0 the pending changes are %v", pending)
+	}
+	noteWrite()
+	if pending, _ := PendingChanges(); len(pending) != 0 {
+		t.Errorf("a config written by this version still has changes pending: %v", pending)
+	}
+}
+
+func TestConfigureWithToolset(t *testing.T) {
+	if DefaultToolset.Config() != DefaultConfig {
+		t.Error("the default toolset changes the starter config")
+	}
+	t.Setenv("PATH", t.TempDir()) // neither niri nor the programs
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	report, err := ConfigureWith(context.Background(), Toolset{Terminal: StarterTerminals[1], Launcher: StarterLaunchers[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(ConfigPath())
+	cfg := string(data)
+	for _, want := range []string{`Mod+T { spawn "foot"; }`, `Mod+D { spawn "wofi" "--show" "drun"; }`} {
+		if !strings.Contains(cfg, want) {
+			t.Errorf("the config does not have %s", want)
+		}
+	}
+	if strings.Contains(cfg, `spawn "alacritty";`) || strings.Contains(cfg, `spawn "fuzzel";`) || strings.Contains(cfg, `spawn-at-startup "waybar"`) {
+		t.Errorf("the config still starts the default programs:\n%s", cfg)
+	}
+	want := []string{
+		"Warning: foot is not installed, so Mod+T opens nothing until it is (pkg install foot)",
+		"Warning: wofi is not installed, so Mod+D opens nothing until it is (pkg install wofi)",
+	}
+	if got := slices.DeleteFunc(report, func(l string) bool { return !strings.HasPrefix(l, "Warning:") }); !slices.Equal(got, want) {
+		t.Errorf("ConfigureWith warned %q, want %q", got, want)
+	}
+}
+
+func TestConfigureWritesStarterConfig(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // no niri to validate with
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	report, err := Configure(context.Background())
+	if err != nil || !slices.Contains(report, "Wrote the starter config to "+ConfigPath()) {
+		t.Fatalf("Configure without a config reported %q, %v", report, err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != DefaultConfig {
+		t.Errorf("Configure wrote %q", data)
+	}
+	if err := os.WriteFile(ConfigPath(), []byte("prefer-no-csd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err = Configure(context.Background())
+	if err != nil || !slices.Contains(report, "Kept the existing "+ConfigPath()) {
+		t.Errorf("Configure with a config reported %q, %v", report, err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != "prefer-no-csd\n" {
+		t.Errorf("Configure replaced the existing config with %q", data)
+	}
+	for _, path := range []string{"waybar/config.jsonc", "waybar/style.css", "mako/config"} {
+		if _, err := os.Stat(filepath.Join(configHome(), path)); err == nil {
+			t.Errorf("Configure without tools wrote %s", path)
+		}
+	}
+
+	style := filepath.Join(configHome(), "waybar/style.css")
+	if err := os.MkdirAll(filepath.Dir(style), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(style, []byte("* { color: red; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = Configure(context.Background(), "waybar"); err != nil || len(report) < 3 {
+		t.Fatalf("Configure with waybar reported %q, %v", report, err)
+	}
+	if !strings.Contains(report[len(report)-1], style+", the old one is in "+style+backupMarker) {
+		t.Errorf("replacing the waybar style reported %q", report[len(report)-1])
+	}
+	for _, path := range []string{"waybar/config.jsonc", "waybar/style.css"} {
+		if _, err := os.Stat(filepath.Join(configHome(), path)); err != nil {
+			t.Errorf("Configure with waybar did not write %s: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(configHome(), "mako/config")); err == nil {
+		t.Error("Configure with waybar also wrote the mako config")
+	}
+}
+
+func TestConfigureKeepsAValidConfig(t *testing.T) {
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	// the temporary copies pass, but niri turns down the config itself
+	script := "#!/bin/sh\n[ \"$3\" = " + ConfigPath() + " ] && { echo 'bad output'; exit 1; }\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(bin, "niri"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Configure(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "so it was removed again: bad output") {
+		t.Errorf("Configure with a rejected starter config returned %v", err)
+	}
+	if _, err := os.Stat(ConfigPath()); !os.IsNotExist(err) {
+		t.Errorf("the rejected starter config is still there: %v", err)
+	}
+
+	mine := "prefer-no-csd\n"
+	if err := os.WriteFile(ConfigPath(), []byte(mine), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Configure(context.Background()); err != nil {
+		t.Errorf("Configure that kept the config as it was returned %v", err)
+	}
+	if err := os.MkdirAll(SnippetsDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(SnippetsDir(), "gaps.kdl"), []byte("layout {\n    gaps 4\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Configure(context.Background()); err == nil || !strings.Contains(err.Error(), "so the old one was put back") {
+		t.Errorf("Configure with a rejected snippet returned %v", err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != mine {
+		t.Errorf("Configure left %q", data)
+	}
+}
+
+func TestPlanConfigure(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	plan, err := PlanConfigure("mako")
+	if err != nil || plan.Diff != "" || len(plan.Created) != 2 {
+		t.Errorf("the plan without any config is %+v, %v, want the two files created", plan, err)
+	}
+
+	mako := ToolConfigs[slices.IndexFunc(ToolConfigs, func(tc ToolConfig) bool { return tc.Tool == "mako" })]
+	mine := "prefer-no-csd\n"
+	for path, data := range map[string]string{ConfigPath(): mine, filepath.Join(configHome(), mako.Path): mako.Content} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if plan, err := PlanConfigure("mako"); err != nil || plan.Diff != "" || len(plan.Created) != 0 {
+		t.Errorf("the plan with nothing to change is %+v, %v", plan, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(configHome(), mako.Path), []byte("font=monospace 12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(SnippetsDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(SnippetsDir(), "gaps.kdl"), []byte("layout {\n    gaps 4\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = PlanConfigure("mako")
+	if err != nil || len(plan.Created) != 0 {
+		t.Fatalf("the plan with changes is %+v, %v", plan, err)
+	}
+	for _, want := range []string{"--- " + ConfigPath(), "+    gaps 4", "-font=monospace 12"} {
+		if !strings.Contains(plan.Diff, want) {
+			t.Errorf("the diff has no %q:\n%s", want, plan.Diff)
+		}
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != mine {
+		t.Errorf("PlanConfigure changed the config to %q", data)
+	}
+}
+
+// fakePkg puts sudo and a pkg on PATH that keep the installed packages in
+// a file, with dependents listed in deps, and returns that file. Every pkg
+// command line is logged to the file's name plus .log. A package called
+// broken never installs, and fails an install of several with it.
+func fakePkg(t *testing.T, installed string, deps map[string]string) string {
+	bin, db := t.TempDir(), filepath.Join(t.TempDir(), "installed")
+	if err := os.WriteFile(db, []byte(installed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\ndb=" + db + "\necho \"$*\" >> $db.log\ncase \"$1\" in\n" +
+		"info) grep -qx \"$3\" $db ;;\n" +
+		"install) shift; for p; do [ \"$p\" = broken ] && exit 1; done; for p; do case \"$p\" in -*) ;; *) echo \"$p\" >> $db ;; esac; done ;;\n" +
+		"delete) grep -vx \"$3\" $db > $db.new; mv $db.new $db ;;\n" +
+		"query) case \"$3\" in\n"
+	for pkg, users := range deps {
+		script += pkg + ") echo " + users + " ;;\n"
+	}
+	script += "esac ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(bin, "pkg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "sudo"), []byte("#!/bin/sh\nexec \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	PrivilegeTool = "sudo" // even where doas is installed
+	t.Cleanup(func() { PrivilegeTool = "" })
+	return db
+}
+
+func TestUninstall(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	db := fakePkg(t, "jq\n", map[string]string{"wlroots": "niri mygame"})
+	if err := InstallPackages(context.Background(), []string{"jq", "wlroots", "niri", "fuzzel"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := SetupPackages()
+	if want := []string{"wlroots", "niri", "fuzzel"}; err != nil || !slices.Equal(pkgs, want) {
+		t.Fatalf("SetupPackages after the install = %q, %v, want %q without the jq that was there", pkgs, err, want)
+	}
+	manifest, err := LoadManifest()
+	if err != nil || !slices.Equal(manifest.Present, []string{"jq"}) || manifest.Version != Version || manifest.Updated == "" {
+		t.Errorf("the manifest is %+v, %v, want jq present and this version", manifest, err)
+	}
+	if p := manifest.Installed[0]; p.Name != "wlroots" || p.Version != Version || p.Time == "" {
+		t.Errorf("the manifest records %+v, want when and by which version wlroots was installed", p)
+	}
+
+	report, err := Uninstall(context.Background(), pkgs)
+	if want := []string{"Removed fuzzel", "Removed niri", "Kept wlroots: mygame needs it"}; err != nil || !slices.Equal(report, want) {
+		t.Errorf("Uninstall reported %q, %v, want %q", report, err, want)
+	}
+	if data, _ := os.ReadFile(db); string(data) != "jq\nwlroots\n" {
+		t.Errorf("Uninstall left the packages %q", data)
+	}
+	if pkgs, _ := SetupPackages(); !slices.Equal(pkgs, []string{"wlroots"}) {
+		t.Errorf("SetupPackages after the uninstall = %q, want only the kept wlroots", pkgs)
+	}
+}
+
+func TestLoadManifestReadsTheOldList(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(legacyManifestPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacyManifestPath(), []byte("niri\nfuzzel\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recordInstalled(context.Background(), "mako")
+	if pkgs, err := SetupPackages(); err != nil || !slices.Equal(pkgs, []string{"niri", "fuzzel", "mako"}) {
+		t.Errorf("SetupPackages = %q, %v, want the old list and mako", pkgs, err)
+	}
+	if _, err := os.Stat(legacyManifestPath()); !os.IsNotExist(err) {
+		t.Errorf("the old list is still there: %v", err)
+	}
+}
+
+func TestInstallPackagesInOneRun(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	db := fakePkg(t, "jq\n", nil)
+	ctx := WithOptions(context.Background(), Options{PackageFiles: map[string]string{"mako": "/cache/mako.pkg"}})
+	if err := InstallPackages(ctx, []string{"jq", "niri", "fuzzel", "mako"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(db + ".log")
+	var commands []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if !strings.HasPrefix(line, "info") {
+			commands = append(commands, line)
+		}
+	}
+	want := []string{"install -y niri fuzzel", "add /cache/mako.pkg"}
+	if !slices.Equal(commands, want) {
+		t.Errorf("InstallPackages ran %q, want %q", commands, want)
+	}
+}
+
+func TestInstallPackagesRejectsBadNames(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	db := fakePkg(t, "", nil)
+	err := InstallPackages(context.Background(), []string{"niri", "-f", "mako; rm -rf ~"}, nil)
+	var ierr *InstallError
+	if !errors.As(err, &ierr) || len(ierr.Failed) != 2 || !strings.Contains(ierr.Failed[0].Error(), `"-f" is not a valid package name`) {
+		t.Fatalf("InstallPackages returned %v, want both bad names refused", err)
+	}
+	data, _ := os.ReadFile(db + ".log")
+	if log := string(data); strings.Contains(log, "-f\n") || strings.Contains(log, "mako") {
+		t.Errorf("a bad name reached pkg:\n%s", log)
+	}
+	if missing, err := MissingPackages([]string{"-f"}); err != nil || !slices.Equal(missing, []string{"-f"}) {
+		t.Errorf("MissingPackages = %q, %v, want the bad name missing", missing, err)
+	}
+	if err := RemovePackage(context.Background(), "--all"); err == nil {
+		t.Error("RemovePackage accepted --all")
+	}
+}
+
+func TestInstallPackagesGoesOnAfterAFailure(t *testing.T) {
+	stubSleep(t)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	db := fakePkg(t, "", nil)
+	var results []string
+	err := InstallPackages(context.Background(), []string{"niri", "broken", "fuzzel"}, func(pkg string, skipped bool, err error) {
+		results = append(results, fmt.Sprintf("%s %v", pkg, err != nil))
+	})
+	var ierr *InstallError
+	if !errors.As(err, &ierr) || len(ierr.Failed) != 1 || ierr.Failed[0].Package != "broken" {
+		t.Fatalf("InstallPackages returned %v, want an *InstallError for broken", err)
+	}
+	if want := []string{"niri false", "broken true", "fuzzel false"}; !slices.Equal(results, want) {
+		t.Errorf("InstallPackages went through %q, want %q", results, want)
+	}
+	if pkgs, _ := SetupPackages(); !slices.Equal(pkgs, []string{"niri", "fuzzel"}) {
+		t.Errorf("SetupPackages = %q, want the two that installed", pkgs)
+	}
+	data, _ := os.ReadFile(db + ".log")
+	if log := string(data); !strings.Contains(log, "install -y niri broken fuzzel\n") || !strings.Contains(log, "install -y niri\n") {
+		t.Errorf("broken did not fail the batch and send niri on its own:\n%s", log)
+	}
+}
+
+func TestCancelCommands(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	bin := t.TempDir()
+	for name, script := range map[string]string{
+		"sudo": "#!/bin/sh\nexec \"$@\"\n",
+		"pkg":  "#!/bin/sh\ncase \"$1\" in\ninfo) exit 1 ;;\ninstall) exec sleep 30 ;;\nesac\n",
+	} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("no sleep to hang pkg with")
+	}
+	if err := os.Symlink(sleep, filepath.Join(bin, "sleep")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	PrivilegeTool = "sudo" // even where doas is installed
+	t.Cleanup(func() { PrivilegeTool = "" })
+
+	var reported []string
+	start := time.Now()
+	time.AfterFunc(200*time.Millisecond, CancelCommands)
+	err = InstallPackages(context.Background(), []string{"niri", "waybar"}, func(pkg string, skipped bool, err error) { reported = append(reported, pkg) })
+	if !errors.Is(err, ErrCancelled) || len(reported) > 0 {
+		t.Errorf("a cancelled install returned %v and reported %q", err, reported)
+	}
+	if took := time.Since(start); took >= cancelWait {
+		t.Errorf("the hung pkg took %v to stop, want it stopped by SIGTERM", took)
+	}
+	if err := Command("sleep", "0").Run(); err != nil {
+		t.Errorf("after CancelCommands a new command failed: %v", err)
+	}
+}
+
+func TestPackageList(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if pkgs, _, err := PackageList(); err != nil || !slices.Equal(pkgs, DefaultPackages) {
+		t.Fatalf("without a packages file PackageList = %q, %v", pkgs, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(PackagesFile()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(PackagesJSONFile(), []byte(`["niri", "firefox", "niri"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, warnings, err := PackageList()
+	if err != nil || !slices.Equal(pkgs, []string{"niri", "firefox"}) || len(warnings) != 1 {
+		t.Errorf("packages.json gave %q, warnings %q, %v", pkgs, warnings, err)
+	}
+
+	var malformed *PackageListError
+	_, _, err = ParsePackageJSON("packages.json", []byte(`["thunar", "", "foo; rm -rf /", 3]`))
+	if !errors.As(err, &malformed) || len(malformed.Problems) != 3 {
+		t.Errorf("a malformed packages.json gave %v, want three problems", err)
+	}
+	if _, _, err := ParsePackageJSON("packages.json", []byte(`{"packages": []}`)); !errors.As(err, &malformed) {
+		t.Errorf("a JSON object gave %v, want a *PackageListError", err)
+	}
+
+	if err := os.WriteFile(PackagesFile(), []byte("niri\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := PackageList(); err == nil {
+		t.Error("both packages files were accepted")
+	}
+}
+
+func TestSetupProfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	var invalid *SetupProfileError
+	bad := write("bad.json", `{"packages": ["niri", "niri", "a;b"], "tools": ["waybar", "dunst"], "services": ["dbus", "rm -rf"], "toolset": {"terminal": "xterm"}, "config": "missing.kdl"}`)
+	if _, err := LoadSetupProfile(bad); !errors.As(err, &invalid) || len(invalid.Problems) != 8 {
+		t.Errorf("a profile with eight problems gave %v", err)
+	}
+	if _, err := LoadSetupProfile(write("typo.json", `{"version": 1, "servics": ["dbus"]}`)); !errors.As(err, &invalid) || !strings.Contains(err.Error(), `"servics"`) {
+		t.Errorf("a profile with an unknown key gave %v", err)
+	}
+
+	write("niri.kdl", "prefer-no-csd\n")
+	p, err := LoadSetupProfile(write("setup.json", `{"version": 1, "packages": ["niri", "foot"], "config": "niri.kdl", "tools": ["mako"], "services": ["dbus"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := DefaultConfigureTools
+	UseSetupProfile(p)
+	t.Cleanup(func() { UseSetupProfile(nil); DefaultConfigureTools = tools })
+	if !slices.Equal(DefaultConfigureTools, []string{"mako"}) {
+		t.Errorf("with the profile DefaultConfigureTools = %q", DefaultConfigureTools)
+	}
+	if pkgs, _, err := PackageList(); err != nil || !slices.Equal(pkgs, []string{"niri", "foot"}) {
+		t.Errorf("with the profile PackageList = %q, %v", pkgs, err)
+	}
+	if got := starterConfig(DefaultToolset); got != "prefer-no-csd\n" {
+		t.Errorf("the starter config is %q, want the profile's", got)
+	}
+
+	r := fakeCommands(t, func(line string) niritest.Reply {
+		if line == "service dbus status" {
+			return niritest.Fail
+		}
+		return niritest.Reply{}
+	})
+	report, err := EnableProfileServices(context.Background())
+	if want := []string{"service dbus status", "sudo sysrc dbus_enable=YES", "sudo service dbus start"}; err != nil || !slices.Equal(r.Lines(), want) {
+		t.Errorf("enabling the services ran %q, %v, want %q", r.Lines(), err, want)
+	}
+	if want := []string{"Enabled and started the dbus service"}; !slices.Equal(report, want) {
+		t.Errorf("enabling the services reported %q, want %q", report, want)
+	}
+}
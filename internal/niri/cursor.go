@@ -0,0 +1,69 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// iconsDir is where FreeBSD packages install icon and cursor themes.
+const iconsDir = "/usr/local/share/icons"
+
+// DefaultCursorPackage provides a cursor theme when none is installed.
+const DefaultCursorPackage = "adwaita-icon-theme"
+
+// CursorSizes are the cursor sizes offered, in pixels.
+var CursorSizes = []int{24, 32, 48, 64}
+
+// CursorThemes returns the installed themes that include cursors.
+func CursorThemes() ([]string, error) {
+	dirs, err := filepath.Glob(filepath.Join(iconsDir, "*", "cursors"))
+	if err != nil {
+		return nil, err
+	}
+	var themes []string
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			themes = append(themes, filepath.Base(filepath.Dir(dir)))
+		}
+	}
+	slices.Sort(themes)
+	return themes, nil
+}
+
+// ConfigureCursor sets the cursor theme and size niri uses, and exports
+// them through XCURSOR_THEME and XCURSOR_SIZE for clients that read those.
+func ConfigureCursor(ctx context.Context, theme string, size int) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	sz := strconv.Itoa(size)
+	for _, set := range []func() error{
+		func() error { return cfg.SetChild("cursor", FormatNode("xcursor-theme", theme)) },
+		func() error { return cfg.SetChild("cursor", "xcursor-size "+sz) },
+		func() error { return cfg.SetEnv("XCURSOR_THEME", theme) },
+		func() error { return cfg.SetEnv("XCURSOR_SIZE", sz) },
+	} {
+		if err := set(); err != nil {
+			return []string{checked}, fmt.Errorf("failed to update niri config: %w", err)
+		}
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return []string{checked}, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return []string{
+		checked,
+		fmt.Sprintf("Set the cursor to %s at %dpx", theme, size),
+		fmt.Sprintf("Set XCURSOR_THEME=%s and XCURSOR_SIZE=%s in the environment block", theme, sz),
+		"Updated " + ConfigPath(),
+	}, nil
+}
@@ -0,0 +1,152 @@
+package niri
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ToolConfig is the starter config NiriSetup writes for one of the
+// programs a niri desktop runs besides niri.
+type ToolConfig struct {
+	Tool    string
+	Path    string // relative to the user's config directory
+	Content string
+}
+
+// ToolConfigs are the configs Generate Default Configs writes: the bar and
+// its style, notifications, the launcher and the lock screen. None sets a
+// wallpaper.
+var ToolConfigs = []ToolConfig{
+	{"waybar", "waybar/config.jsonc", `{
+    "layer": "top",
+    "position": "top",
+    "modules-left": ["niri/workspaces", "niri/window"],
+    "modules-center": ["clock"],
+    "modules-right": ["pulseaudio", "network", "battery", "tray"],
+    "clock": { "format": "{:%a %d %b  %H:%M}" },
+    "network": { "format-wifi": "{essid}", "format-ethernet": "wired", "format-disconnected": "offline" },
+    "tray": { "spacing": 8 }
+}
+`},
+	{"waybar", "waybar/style.css", `* {
+    font-family: monospace;
+    font-size: 13px;
+}
+
+window#waybar {
+    background: rgba(30, 30, 46, 0.9);
+    color: #cdd6f4;
+}
+
+#workspaces button {
+    padding: 0 6px;
+    color: #cdd6f4;
+}
+
+#workspaces button.focused,
+#workspaces button.active {
+    background: #45475a;
+}
+
+#clock, #pulseaudio, #network, #battery, #tray {
+    padding: 0 10px;
+}
+`},
+	{"mako", "mako/config", `font=monospace 10
+default-timeout=5000
+border-radius=6
+max-visible=5
+`},
+	{"fuzzel", "fuzzel/fuzzel.ini", `[main]
+terminal=foot
+font=monospace:size=11
+width=40
+`},
+	{"swaylock", "swaylock/config", `color=1e1e2e
+show-failed-attempts
+ignore-empty-password
+`},
+}
+
+// DefaultConfigureTools are the tools Configure Niri offers to write
+// starter configs for unless told otherwise: DefaultConfig starts waybar
+// and mako, and without a config neither shows much.
+var DefaultConfigureTools = []string{"waybar", "mako"}
+
+// ToolNames returns the tools ToolConfigs are for, each once, in order.
+func ToolNames() []string {
+	var tools []string
+	for _, t := range ToolConfigs {
+		if !slices.Contains(tools, t.Tool) {
+			tools = append(tools, t.Tool)
+		}
+	}
+	return tools
+}
+
+// writeToolConfigs writes the ToolConfigs of tools, stopping at the first
+// failure.
+func writeToolConfigs(ctx context.Context, tools []string) ([]string, error) {
+	var report []string
+	for _, t := range ToolConfigs {
+		if !slices.Contains(tools, t.Tool) {
+			continue
+		}
+		line, err := writeToolConfig(ctx, t)
+		if err != nil {
+			return report, err
+		}
+		if line != "" {
+			report = append(report, line)
+		}
+	}
+	return report, nil
+}
+
+// writeToolConfig writes t under the user's config directory. A different
+// file already there is kept next to it with a timestamped .bak suffix.
+func writeToolConfig(ctx context.Context, t ToolConfig) (string, error) {
+	path := filepath.Join(configHome(), t.Path)
+	old, err := os.ReadFile(path)
+	switch {
+	case err == nil && bytes.Equal(old, []byte(t.Content)):
+		return fmt.Sprintf("%s already has the default config in %s", t.Tool, path), nil
+	case err != nil && !os.IsNotExist(err):
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	case OptionsFrom(ctx).Preview != nil:
+		OptionsFrom(ctx).Preview("Would write " + path)
+		return "", nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	line := fmt.Sprintf("Wrote the %s config to %s", t.Tool, path)
+	if old != nil {
+		backup, err := writeBackup(ctx, path, old)
+		if err != nil {
+			return "", fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+		line += ", the old one is in " + backup
+	}
+	if err := writeFile(ctx, path, []byte(t.Content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	noteWrite()
+	return line, nil
+}
+
+// GenerateDefaultConfigs writes every ToolConfig and then DefaultConfig as
+// the niri config, backing up each file it replaces, and validates the
+// niri config at the end. It stops at the first failure.
+func GenerateDefaultConfigs(ctx context.Context) ([]string, error) {
+	report, err := writeToolConfigs(ctx, ToolNames())
+	if err != nil {
+		return report, err
+	}
+	written, err := RestoreDefaults(ctx)
+	return append(report, written...), err
+}
@@ -0,0 +1,139 @@
+package niri
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Deprecation is a config option niri replaced, from the niri version that
+// stopped needing it.
+type Deprecation struct {
+	Since       string // niri version, e.g. "25.08"
+	Path        string // node path, e.g. "environment/DISPLAY"
+	Replacement string // what to do instead
+}
+
+// Deprecations are the known deprecated options. Users can add their own in
+// DeprecationsFile without waiting for a NiriSetup release.
+//
+// A path is made of node names separated by "/". A "name:arg" step only
+// matches nodes whose first argument is arg.
+var Deprecations = []Deprecation{
+	{"25.08", "spawn-at-startup:xwayland-satellite", "niri starts xwayland-satellite on its own; remove this line"},
+	{"25.08", "environment/DISPLAY", "niri sets DISPLAY for its own xwayland-satellite; remove it"},
+}
+
+// DeprecationsFile extends Deprecations, one per line in the form
+// "since path replacement...", with # starting a comment.
+func DeprecationsFile() string {
+	return filepath.Join(configHome(), "nirisetup", "deprecations")
+}
+
+// loadDeprecations returns Deprecations plus the entries in
+// DeprecationsFile, reporting malformed lines by number.
+func loadDeprecations() ([]Deprecation, error) {
+	deps := Deprecations
+	data, err := os.ReadFile(DeprecationsFile())
+	if os.IsNotExist(err) {
+		return deps, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deprecations: %w", err)
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s line %d: want \"since path replacement\"", DeprecationsFile(), i+1)
+		}
+		deps = append(deps, Deprecation{fields[0], fields[1], strings.Join(fields[2:], " ")})
+	}
+	return deps, nil
+}
+
+// InstalledVersion returns the version of the installed niri, e.g. "25.08".
+func InstalledVersion() (string, error) {
+	out, err := Command("niri", "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("niri is not installed; run Install Niri first")
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 {
+		return "", fmt.Errorf("cannot read the niri version from %q", strings.TrimSpace(string(out)))
+	}
+	return fields[1], nil
+}
+
+// versionAtLeast compares dotted version numbers, ignoring anything after
+// the numeric parts.
+func versionAtLeast(version, min string) bool {
+	v, m := strings.Split(version, "."), strings.Split(min, ".")
+	for i := range max(len(v), len(m)) {
+		a, b := versionPart(v, i), versionPart(m, i)
+		if a != b {
+			return a > b
+		}
+	}
+	return true
+}
+
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	digits := strings.TrimRightFunc(parts[i], func(r rune) bool { return r < '0' || r > '9' })
+	n, _ := strconv.Atoi(digits)
+	return n
+}
+
+// findPath returns the enabled nodes matching a deprecation path.
+func findPath(nodes []*Node, path string) []*Node {
+	step, rest, more := strings.Cut(path, "/")
+	name, arg, hasArg := strings.Cut(step, ":")
+	var found []*Node
+	for _, n := range nodes {
+		if n.Disabled || n.Name != name || (hasArg && (len(n.Args) == 0 || n.Args[0] != arg)) {
+			continue
+		}
+		if !more {
+			found = append(found, n)
+		} else {
+			found = append(found, findPath(n.Children, rest)...)
+		}
+	}
+	return found
+}
+
+// AuditConfig checks the niri config for options that the installed niri
+// version has deprecated, returning a failed check for each with what to do
+// instead. It also returns the niri version checked against.
+func AuditConfig() ([]Check, string, error) {
+	version, err := InstalledVersion()
+	if err != nil {
+		return nil, "", err
+	}
+	deps, err := loadDeprecations()
+	if err != nil {
+		return nil, version, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, version, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	var checks []Check
+	for _, d := range deps {
+		if !versionAtLeast(version, d.Since) {
+			continue
+		}
+		if len(findPath(cfg.Nodes, d.Path)) > 0 {
+			checks = append(checks, Check{Name: fmt.Sprintf("%s (deprecated in %s)", d.Path, d.Since), Detail: d.Replacement})
+		}
+	}
+	return checks, version, nil
+}
@@ -0,0 +1,259 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Launchers maps the launchers niri can be bound to onto the command that
+// runs them as a dmenu-style picker.
+var Launchers = map[string]string{
+	"fuzzel": "fuzzel --dmenu",
+	"wofi":   "wofi --dmenu",
+}
+
+// ConfiguredLauncher returns the launcher spawned by one of the config's
+// binds, or "" if none is bound.
+func ConfiguredLauncher(cfg *Config) string {
+	for _, b := range cfg.Binds() {
+		if b.Action == "spawn" && len(b.Args) > 0 {
+			if _, ok := Launchers[b.Args[0]]; ok {
+				return b.Args[0]
+			}
+		}
+	}
+	return ""
+}
+
+// SpawnAtStartup returns the spawn-at-startup node that runs program, if any.
+func SpawnAtStartup(cfg *Config, program string) *Node {
+	for _, n := range cfg.All("spawn-at-startup") {
+		if len(n.Args) > 0 && n.Args[0] == program {
+			return n
+		}
+	}
+	return nil
+}
+
+// HasSpawnAtStartup reports whether the config already starts args.
+func HasSpawnAtStartup(cfg *Config, args ...string) bool {
+	for _, n := range cfg.All("spawn-at-startup") {
+		if slices.Equal(n.Args, args) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClipboardKeys are the keys ConfigureClipboard can bind to clipboard
+// history, in order of preference.
+var ClipboardKeys = []string{"Mod+V", "Mod+Shift+V", "Mod+Alt+V", "Mod+Ctrl+V"}
+
+// BindConflict is a key an action wants to bind that the config already uses
+// for something else.
+type BindConflict struct {
+	Key      string
+	Existing Bind
+	Free     []string // alternatives that are still unbound
+}
+
+// checkBind reports a conflict if the first of keys is bound to something
+// other than what ours accepts.
+func checkBind(cfg *Config, keys []string, ours func(*Bind) bool) *BindConflict {
+	b := cfg.Bind(keys[0])
+	if b == nil || ours(b) {
+		return nil
+	}
+	conflict := &BindConflict{Key: keys[0], Existing: *b}
+	for _, k := range keys[1:] {
+		if cfg.Bind(k) == nil {
+			conflict.Free = append(conflict.Free, k)
+		}
+	}
+	return conflict
+}
+
+// opensClipboardHistory matches the bind ConfigureClipboard writes.
+func opensClipboardHistory(b *Bind) bool {
+	return b.Action == "spawn" && strings.Contains(strings.Join(b.Args, " "), "cliphist")
+}
+
+// ClipboardBindConflict checks whether the default clipboard history key is
+// already taken, so the user can pick another one before anything is
+// written. It returns nil when there is no conflict, including when some key
+// already opens clipboard history.
+func ClipboardBindConflict() (*BindConflict, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	for _, b := range cfg.Binds() {
+		if opensClipboardHistory(&b) {
+			return nil, nil
+		}
+	}
+	return checkBind(cfg, ClipboardKeys, opensClipboardHistory), nil
+}
+
+// DuplicateBindWarnings describes every key the config binds more than once.
+func DuplicateBindWarnings(cfg *Config) []string {
+	var warnings []string
+	for _, binds := range cfg.DuplicateBinds() {
+		var actions []string
+		for _, b := range binds {
+			actions = append(actions, b.Action)
+		}
+		warnings = append(warnings, fmt.Sprintf("Warning: %s is bound %d times (%s), niri only uses one", binds[0].Key, len(binds), strings.Join(actions, ", ")))
+	}
+	return warnings
+}
+
+// ConfigureClipboard installs wl-clipboard and cliphist, starts the history
+// daemon with niri and binds key to pick an entry with the configured
+// launcher. It returns a line for everything it did.
+func ConfigureClipboard(ctx context.Context, key string) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	launcher := ConfiguredLauncher(cfg)
+	if launcher == "" {
+		return nil, fmt.Errorf("clipboard history needs a launcher; bind fuzzel or wofi in %s first", ConfigPath())
+	}
+
+	if err := InstallPackage(ctx, "wl-clipboard"); err != nil {
+		return nil, err
+	}
+	report := []string{checked, "Installed wl-clipboard"}
+
+	// cliphist is optional: without it wl-copy/wl-paste still work, there is
+	// just no history to pick from.
+	if err := InstallPackage(ctx, "cliphist"); err != nil {
+		return append(report, "cliphist is unavailable, skipped clipboard history"), nil
+	}
+	report = append(report, "Installed cliphist")
+
+	watch := []string{"wl-paste", "--watch", "cliphist", "store"}
+	if !HasSpawnAtStartup(cfg, watch...) {
+		if err := cfg.AddNode(FormatNode("spawn-at-startup", watch...)); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, "Added spawn-at-startup: "+strings.Join(watch, " "))
+	}
+
+	pick := fmt.Sprintf("cliphist list | %s | cliphist decode | wl-copy", Launchers[launcher])
+	existing := slices.IndexFunc(cfg.Binds(), func(b Bind) bool { return opensClipboardHistory(&b) })
+	switch b := cfg.Bind(key); {
+	case existing >= 0:
+		report = append(report, cfg.Binds()[existing].Key+" already opens clipboard history")
+	case b == nil:
+		bind := fmt.Sprintf("%s { %s; }", key, FormatNode("spawn", "sh", "-c", pick))
+		if err := cfg.AddChild("binds", bind); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, fmt.Sprintf("Bound %s to clipboard history (%s)", key, launcher))
+	default:
+		report = append(report, fmt.Sprintf("%s is already bound to %s, left it unchanged", key, b.Action))
+	}
+	report = append(report, DuplicateBindWarnings(cfg)...)
+
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return append(report, "Updated "+ConfigPath()), nil
+}
+
+// xwaylandDisplay is the X11 display xwayland-satellite listens on when
+// started without arguments.
+const xwaylandDisplay = ":0"
+
+// ConfigureXWayland makes niri start xwayland-satellite and exports DISPLAY
+// so X11 apps can connect to it.
+func ConfigureXWayland(ctx context.Context) ([]string, error) {
+	if _, err := exec.LookPath("xwayland-satellite"); err != nil {
+		return nil, fmt.Errorf("xwayland-satellite is not installed; run Install Niri first")
+	}
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	display := xwaylandDisplay
+	if n := SpawnAtStartup(cfg, "xwayland-satellite"); n != nil {
+		if len(n.Args) > 1 {
+			display = n.Args[1]
+		}
+		report = append(report, "xwayland-satellite already starts with niri")
+	} else {
+		if err := cfg.AddNode(FormatNode("spawn-at-startup", "xwayland-satellite")); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, "Added spawn-at-startup: xwayland-satellite")
+	}
+	if err := cfg.SetEnv("DISPLAY", display); err != nil {
+		return report, fmt.Errorf("failed to update niri config: %w", err)
+	}
+	report = append(report, fmt.Sprintf("Set DISPLAY=%s in the environment block", display))
+
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return append(report, "Updated "+ConfigPath(), "XWayland is on: X11 apps will work after niri restarts"), nil
+}
+
+// XWaylandEnabled reports whether niri starts xwayland-satellite.
+func XWaylandEnabled() (bool, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return false, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	return SpawnAtStartup(cfg, "xwayland-satellite") != nil, nil
+}
+
+// DisableXWayland undoes ConfigureXWayland for a pure-Wayland session:
+// niri no longer starts xwayland-satellite and DISPLAY leaves the
+// environment block.
+func DisableXWayland(ctx context.Context) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	for n := SpawnAtStartup(cfg, "xwayland-satellite"); n != nil; n = SpawnAtStartup(cfg, "xwayland-satellite") {
+		if err := cfg.Remove(n); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, "Removed spawn-at-startup: xwayland-satellite")
+	}
+	if env := cfg.Section("environment"); env != nil && env.Child("DISPLAY") != nil {
+		if err := cfg.Remove(env.Child("DISPLAY")); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, "Removed DISPLAY from the environment block")
+	}
+	if len(report) == 1 {
+		return append(report, "XWayland is already off"), nil
+	}
+
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return append(report, "Updated "+ConfigPath(), "XWayland is off: X11 apps will not start after niri restarts"), nil
+}
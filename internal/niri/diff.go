@@ -0,0 +1,123 @@
+package niri
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines surround each hunk.
+const diffContext = 3
+
+// UnifiedDiff returns a unified diff turning old into new, labelled with
+// their names, or "" when they are the same.
+func UnifiedDiff(oldName, newName, old, new string) string {
+	a, b := splitLines(old), splitLines(new)
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	changed := false
+	for start := 0; start < len(ops); {
+		// Find the next change and the run of changes close enough to it to
+		// share a hunk.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind == ' ' {
+				continue
+			}
+			if i-last > 2*diffContext {
+				break
+			}
+			last = i
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(ops))
+
+		aStart, bStart, aLen, bLen := ops[from].a, ops[from].b, 0, 0
+		var hunk strings.Builder
+		for _, op := range ops[from:to] {
+			hunk.WriteString(string(op.kind) + op.line + "\n")
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n%s", hunkRange(aStart, aLen), hunkRange(bStart, bLen), hunk.String())
+		changed = true
+		start = to
+	}
+	if !changed {
+		return ""
+	}
+	return out.String()
+}
+
+// diffOp is one line of a diff: kept (' '), removed ('-') or added ('+'),
+// with its position in the old and new text.
+type diffOp struct {
+	kind byte
+	line string
+	a, b int
+}
+
+// diffLines computes a shortest edit script from a to b using the longest
+// common subsequence. Configs are a few hundred lines, so the quadratic
+// table is cheap.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
+
+// hunkRange formats the 0-based start and length of a hunk side the way
+// diff does: 1-based, and pointing before the hunk when it is empty.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,68 @@
+package niri
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// WikiURL is niri's documentation online, which covers every config
+// option.
+const WikiURL = "https://github.com/YaLTeR/niri/wiki"
+
+// WikiPages are the wiki pages worth starting from, with what they cover.
+var WikiPages = [][2]string{
+	{WikiURL + "/Getting-Started", "first steps, default keys and what to install next"},
+	{WikiURL + "/Configuration:-Overview", "how config.kdl is laid out, with a page per section"},
+	{WikiURL + "/Configuration:-Key-Bindings", "binds and the actions they can run"},
+	{WikiURL + "/FAQ", "answers to common questions"},
+}
+
+// manWidth is the width man pages are formatted for, so they fit the text
+// view without wrapping.
+const manWidth = 80
+
+// ManPage returns the man page for name as plain text. When it is not
+// installed the text says why and points at WikiPages instead, so there is
+// always something to read.
+func ManPage(name string) (string, error) {
+	if Command("man", "-w", name).Run() != nil {
+		why := fmt.Sprintf("There is no %s man page on this system; its package does not ship one.", name)
+		if !PackageInstalled(name) {
+			why = fmt.Sprintf("%s is not installed, and neither is its man page; run Install Niri first.", name)
+		}
+		return why + "\n\n" + WikiLinks(), nil
+	}
+	cmd := Command("man", name)
+	cmd.Env = append(os.Environ(), "MANPAGER=cat", "PAGER=cat", fmt.Sprintf("MANWIDTH=%d", manWidth))
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to format the %s man page: %w", name, err)
+	}
+	return stripOverstrike(string(out)), nil
+}
+
+// stripOverstrike removes the backspace sequences man uses for bold and
+// underlined text when it writes to a pipe: "x\bx" and "_\bx" become "x".
+func stripOverstrike(s string) string {
+	var out []rune
+	for _, r := range s {
+		switch {
+		case r != '\b':
+			out = append(out, r)
+		case len(out) > 0:
+			out = out[:len(out)-1] // the character the backspace steps back over
+		}
+	}
+	return string(out)
+}
+
+// WikiLinks lists WikiPages, one per line with what it covers.
+func WikiLinks() string {
+	var b strings.Builder
+	b.WriteString("Read niri's documentation online:\n")
+	for _, p := range WikiPages {
+		fmt.Fprintf(&b, "  %s\n    %s\n", p[0], p[1])
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package niri
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Check is the outcome of one diagnostic.
+type Check struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Warn   bool   `json:"warn"`   // a failure is only worth a warning
+	Detail string `json:"detail"` // what was found, or how to fix it
+}
+
+func (c Check) String() string {
+	mark := "✓"
+	switch {
+	case !c.OK && c.Warn:
+		mark = "!"
+	case !c.OK:
+		mark = "✗"
+	}
+	if c.Detail == "" {
+		return fmt.Sprintf("%s %s", mark, c.Name)
+	}
+	return fmt.Sprintf("%s %s: %s", mark, c.Name, c.Detail)
+}
+
+// Doctor runs every diagnostic and returns the results in a stable order.
+func Doctor() []Check {
+	return slices.Concat([]Check{VersionCheck(), MemoryCheck()}, SessionChecks(), SystemChecks())
+}
+
+// MinVersion is the oldest niri that understands every config NiriSetup
+// writes, e.g. toggle-window-floating binds and open-floating window rules.
+const MinVersion = "25.01"
+
+// SupportedVersion reports whether niri version is MinVersion or newer.
+func SupportedVersion(version string) bool {
+	return versionAtLeast(version, MinVersion)
+}
+
+// VersionCheck compares the installed niri with MinVersion.
+func VersionCheck() Check {
+	c := Check{Name: "niri version"}
+	version, err := InstalledVersion()
+	switch {
+	case err != nil:
+		c.Detail = err.Error()
+	case !SupportedVersion(version):
+		c.Detail = fmt.Sprintf("found %s, but the configs NiriSetup writes need %s or newer; update niri with pkg upgrade niri", version, MinVersion)
+	default:
+		c.OK = true
+		c.Detail = fmt.Sprintf("found %s, %s or newer is needed", version, MinVersion)
+	}
+	return c
+}
+
+// compositors are process names of Wayland compositors that would fight
+// niri for the seat, or end up hosting it as a nested window.
+var compositors = []string{"niri", "sway", "Hyprland", "wayfire", "labwc", "river", "weston", "kwin_wayland", "gnome-shell", "cage"}
+
+// SessionChecks looks for signs that a graphical session is already running:
+// a WAYLAND_DISPLAY pointing at a live socket, or a compositor process.
+// Launching niri on top of either leads to confusing nested sessions.
+//
+// Inside niri itself both are expected, and reported as such instead.
+func SessionChecks() []Check {
+	inside := InsideNiri()
+	display := Check{Name: "No Wayland session active", OK: true}
+	if wd := os.Getenv("WAYLAND_DISPLAY"); inside {
+		display.Detail = fmt.Sprintf("running inside niri (WAYLAND_DISPLAY=%s, NIRI_SOCKET=%s); Launch Niri is not needed", wd, os.Getenv("NIRI_SOCKET"))
+	} else if wd != "" {
+		socket := wd
+		if !filepath.IsAbs(socket) {
+			socket = filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), wd)
+		}
+		if _, err := os.Stat(socket); err == nil {
+			display.OK = false
+			display.Detail = fmt.Sprintf("WAYLAND_DISPLAY=%s is live (%s)", wd, socket)
+		} else {
+			display.Detail = fmt.Sprintf("WAYLAND_DISPLAY=%s is set but %s is gone", wd, socket)
+		}
+	}
+
+	running := Check{Name: "No other compositor running", OK: true}
+	found, err := RunningCompositors()
+	if inside {
+		// the niri NiriSetup runs in is not another compositor
+		found = slices.DeleteFunc(found, func(name string) bool { return name == "niri" })
+	}
+	switch {
+	case err != nil:
+		running.Detail = fmt.Sprintf("could not list processes: %v", err)
+	case len(found) > 0:
+		running.OK = false
+		running.Detail = strings.Join(found, ", ")
+	}
+	return []Check{display, running}
+}
+
+// RunningCompositors returns the known compositors that currently have a
+// process running.
+func RunningCompositors() ([]string, error) {
+	out, err := Command("ps", "-axo", "comm=").Output()
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for _, name := range strings.Fields(string(out)) {
+		if slices.Contains(compositors, name) && !slices.Contains(found, name) {
+			found = append(found, name)
+		}
+	}
+	return found, nil
+}
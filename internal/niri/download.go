@@ -0,0 +1,119 @@
+package niri
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MinFreeSpace is the least free space the pkg cache needs before an
+// install: the full package list, with its dependencies, downloads well
+// over a gigabyte, and pkg fails partway when the disk fills up.
+var MinFreeSpace uint64 = 2 << 30
+
+// defaultRepoURL is the repository pkg uses when no configuration names
+// one.
+const defaultRepoURL = "http://pkg.FreeBSD.org"
+
+// repoProbeTimeout is how long DownloadChecks waits for the repository to
+// answer.
+var repoProbeTimeout = 3 * time.Second
+
+// DownloadChecks probes what downloading packages needs: room in the pkg
+// cache and a repository that answers. Neither stops an install, since
+// the packages may already be in the cache, so both only warn.
+func DownloadChecks() []Check {
+	return []Check{spaceCheck(), repoCheck()}
+}
+
+// DownloadBlocked reports whether any of checks, from DownloadChecks, is
+// worth asking about before the install starts.
+func DownloadBlocked(checks []Check) bool {
+	for _, c := range checks {
+		if !c.OK {
+			return true
+		}
+	}
+	return false
+}
+
+// packageCacheDir is where pkg fetches packages to: PKG_CACHEDIR, or
+// DefaultPackageCache if pkg cannot say.
+func packageCacheDir() string {
+	out, err := Command("pkg", "config", "PKG_CACHEDIR").Output()
+	if dir := strings.TrimSpace(string(out)); err == nil && dir != "" {
+		return dir
+	}
+	return DefaultPackageCache
+}
+
+// spaceCheck checks that the filesystem of the pkg cache has MinFreeSpace
+// free. A cache directory pkg has not created yet is measured on the
+// closest directory above it that exists.
+func spaceCheck() Check {
+	dir := packageCacheDir()
+	c := Check{Name: "Free space for " + dir, Warn: true}
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		c.Detail = fmt.Sprintf("failed to check %s: %v", dir, err)
+		return c
+	}
+	free := uint64(st.Bavail) * uint64(st.Bsize)
+	if free < MinFreeSpace {
+		c.Detail = fmt.Sprintf("%s free, less than %s: the install may stop partway; free some space or run pkg clean -a", gib(free), gib(MinFreeSpace))
+		return c
+	}
+	c.OK, c.Detail = true, gib(free)+" free"
+	return c
+}
+
+// repoCheck checks that the FreeBSD repository's server accepts a
+// connection, which is all a quick probe can tell without downloading.
+func repoCheck() Check {
+	raw := defaultRepoURL
+	if repos, err := ConfiguredRepos(); err == nil {
+		for _, r := range repos {
+			if r.Name == "FreeBSD" {
+				raw = strings.TrimPrefix(r.URL, "pkg+")
+			}
+		}
+	}
+	u, err := url.Parse(raw)
+	switch {
+	case err == nil && u.Scheme == "file":
+		return Check{Name: "Package repository " + u.Path, OK: true, Warn: true, Detail: "local"}
+	case err != nil || u.Hostname() == "":
+		return Check{Name: "Package repository", Warn: true, Detail: fmt.Sprintf("cannot tell the server from %s", raw)}
+	}
+	c := Check{Name: "Package repository " + u.Hostname(), Warn: true}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	var conn net.Conn
+	err = retry(probeAttempts, probeBackoff, func() (err error) {
+		conn, err = net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), repoProbeTimeout)
+		return err
+	})
+	if err != nil {
+		c.Detail = fmt.Sprintf("unreachable (%v); check the network, or use Install from Cache", err)
+		return c
+	}
+	conn.Close()
+	c.OK, c.Detail = true, "reachable"
+	return c
+}
@@ -0,0 +1,94 @@
+package niri
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// pkgEvent is one event from pkg's JSON event stream.
+type pkgEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		URL        string `json:"url"`
+		PkgName    string `json:"pkgname"`
+		PkgVersion string `json:"pkgversion"`
+		Current    int64  `json:"current"`
+		Total      int64  `json:"total"`
+	} `json:"data"`
+}
+
+// eventPipe is a fifo pkg writes its events to, read in the background.
+type eventPipe struct {
+	dir, path string
+	done      chan struct{}
+}
+
+// openEventPipe makes a fifo for pkg's events while installing pkg and
+// starts passing them on to the Progress of ctx's Options.
+func openEventPipe(ctx context.Context, pkg string) (*eventPipe, error) {
+	dir, err := os.MkdirTemp("", "nirisetup-events-")
+	if err != nil {
+		return nil, err
+	}
+	p := &eventPipe{dir: dir, path: filepath.Join(dir, "events"), done: make(chan struct{})}
+	if err := syscall.Mkfifo(p.path, 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	go func() {
+		defer close(p.done)
+		f, err := os.Open(p.path) // blocks until pkg, or close, opens it
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		var stage string
+		dec := json.NewDecoder(f)
+		for {
+			var e pkgEvent
+			if err := dec.Decode(&e); err != nil {
+				return
+			}
+			switch e.Type {
+			case "INFO_FETCH_BEGIN":
+				stage = "Fetching " + filepath.Base(e.Data.URL)
+				OptionsFrom(ctx).Progress(pkg, stage, 0, 0)
+			case "INFO_EXTRACT_BEGIN":
+				stage = "Extracting " + e.Data.PkgName + "-" + e.Data.PkgVersion
+				OptionsFrom(ctx).Progress(pkg, stage, 0, 0)
+			case "INFO_PROGRESS_TICK":
+				OptionsFrom(ctx).Progress(pkg, stage, e.Data.Current, e.Data.Total)
+			}
+		}
+	}()
+	return p, nil
+}
+
+// command adds the option sending pkg's events to the pipe to args, a pkg
+// command line possibly run through sudo.
+func (p *eventPipe) command(args []string) []string {
+	i := slices.Index(args, "pkg")
+	return slices.Insert(slices.Clone(args), i+1, "-o", "EVENT_PIPE="+p.path)
+}
+
+// close stops reading events and removes the fifo. If pkg never opened the
+// fifo the reader is still waiting for a writer, so it is given one.
+func (p *eventPipe) close() {
+	for {
+		select {
+		case <-p.done:
+			os.RemoveAll(p.dir)
+			return
+		default:
+		}
+		if f, err := os.OpenFile(p.path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			f.Close()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
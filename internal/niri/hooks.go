@@ -0,0 +1,59 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// HooksDir holds the user's own finishing steps: executable scripts that
+// RunHooks runs after a successful install or configure.
+func HooksDir() string {
+	return filepath.Join(configHome(), "nirisetup", "hooks")
+}
+
+// RunHooks runs the executable files in HooksDir in name order, each with
+// stage ("install" or "configure") as its argument. A hook that fails is
+// reported as a warning and the rest still run, so the report says which
+// hooks ran, what they printed and which failed. There are no hooks when
+// HooksDir does not exist.
+func RunHooks(ctx context.Context, stage string) []string {
+	entries, err := os.ReadDir(HooksDir())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("Warning: could not read %s: %v", HooksDir(), err)}
+	}
+	var hooks []string
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 && !strings.HasPrefix(e.Name(), ".") {
+			hooks = append(hooks, e.Name())
+		}
+	}
+	slices.Sort(hooks)
+
+	var report []string
+	for _, name := range hooks {
+		path := filepath.Join(HooksDir(), name)
+		if OptionsFrom(ctx).Preview != nil {
+			OptionsFrom(ctx).Preview(fmt.Sprintf("Would run hook %s %s", path, stage))
+			continue
+		}
+		out, err := Command(path, stage).CombinedOutput()
+		if err != nil {
+			report = append(report, fmt.Sprintf("Warning: hook %s failed: %v", name, err))
+		} else {
+			report = append(report, "Ran hook "+name)
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+			if line != "" {
+				report = append(report, "  "+line)
+			}
+		}
+	}
+	return report
+}
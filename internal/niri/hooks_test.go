@@ -0,0 +1,41 @@
+package niri
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRunHooks(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if got := RunHooks(context.Background(), "install"); got != nil {
+		t.Fatalf("RunHooks without a hooks directory reported %q", got)
+	}
+	if err := os.MkdirAll(HooksDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, script := range map[string]string{
+		"20-fail":  "#!/bin/sh\necho broke\nexit 3\n",
+		"10-greet": "#!/bin/sh\necho \"hello from $1\"\n",
+		"30-notes": "not a script",
+	} {
+		mode := os.FileMode(0755)
+		if name == "30-notes" {
+			mode = 0644
+		}
+		if err := os.WriteFile(filepath.Join(HooksDir(), name), []byte(script), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"Ran hook 10-greet",
+		"  hello from install",
+		"Warning: hook 20-fail failed: exit status 3",
+		"  broke",
+	}
+	if got := RunHooks(context.Background(), "install"); !slices.Equal(got, want) {
+		t.Errorf("RunHooks reported %q, want %q", got, want)
+	}
+}
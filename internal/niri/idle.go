@@ -0,0 +1,77 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Idle timeouts ConfigureIdle sets, in seconds.
+const (
+	LockTimeout        = 600
+	MonitorsOffTimeout = 900
+)
+
+// IdleInhibitingPlayers are players known to keep the screen on while they
+// play video, by asking niri through the Wayland idle-inhibit protocol.
+var IdleInhibitingPlayers = []string{"mpv", "Firefox", "Chromium (with --ozone-platform=wayland)", "VLC"}
+
+// idleCommand is the swayidle line ConfigureIdle starts with niri. niri
+// holds back idle notifications while a window inhibits idling, so swayidle
+// respects video players without any extra flags.
+func idleCommand() []string {
+	return []string{
+		"swayidle", "-w",
+		"timeout", strconv.Itoa(LockTimeout), "swaylock -f",
+		"timeout", strconv.Itoa(MonitorsOffTimeout), "niri msg action power-off-monitors",
+		"before-sleep", "swaylock -f",
+	}
+}
+
+// ConfigureIdle installs swayidle and swaylock and starts swayidle with
+// niri: the screen locks after LockTimeout and the monitors turn off after
+// MonitorsOffTimeout, except while a video player inhibits idling. An
+// existing swayidle line is replaced.
+func ConfigureIdle(ctx context.Context) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	for _, pkg := range []string{"swayidle", "swaylock"} {
+		if err := InstallPackage(ctx, pkg); err != nil {
+			return report, err
+		}
+		report = append(report, "Installed "+pkg)
+	}
+
+	args := idleCommand()
+	line := FormatNode("spawn-at-startup", args...)
+	switch existing := SpawnAtStartup(cfg, "swayidle"); {
+	case existing == nil:
+		err = cfg.AddNode(line)
+	case HasSpawnAtStartup(cfg, args...):
+		report = append(report, "swayidle is already set up")
+	default:
+		report = append(report, "Replaced spawn-at-startup: "+strings.Join(existing.Args, " "))
+		err = cfg.Replace(existing, line)
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to update niri config: %w", err)
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return append(report,
+		fmt.Sprintf("Locks after %d minutes and turns the monitors off after %d, unless a window inhibits idling", LockTimeout/60, MonitorsOffTimeout/60),
+		"Players that keep the screen on while playing: "+strings.Join(IdleInhibitingPlayers, ", "),
+		"X11 players running through xwayland-satellite cannot inhibit idling",
+		"Updated "+ConfigPath(),
+	), nil
+}
@@ -0,0 +1,122 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// InputMethod is a fcitx5 engine for typing a language the keyboard has no
+// keys for.
+type InputMethod struct {
+	Language string
+	Package  string
+	Engine   string // the fcitx5 name of the engine
+}
+
+// InputMethods are the engines Configure Input Method offers.
+var InputMethods = []InputMethod{
+	{Language: "Chinese (Pinyin)", Package: "zh-fcitx5-chinese-addons", Engine: "pinyin"},
+	{Language: "Japanese (Mozc)", Package: "ja-fcitx5-mozc", Engine: "mozc"},
+	{Language: "Japanese (Anthy)", Package: "ja-fcitx5-anthy", Engine: "anthy"},
+	{Language: "Korean (Hangul)", Package: "ko-fcitx5-hangul", Engine: "hangul"},
+	{Language: "Vietnamese (Unikey)", Package: "vi-fcitx5-unikey", Engine: "unikey"},
+}
+
+// FcitxPackages are fcitx5 itself, the modules that let GTK and Qt
+// applications talk to it and the tool to configure it.
+var FcitxPackages = []string{"fcitx5", "fcitx5-gtk", "fcitx5-qt5", "fcitx5-qt6", "fcitx5-configtool"}
+
+// fcitxEnv are the variables that point GTK, Qt and X11 applications at
+// fcitx5.
+var fcitxEnv = [][2]string{
+	{"GTK_IM_MODULE", "fcitx"},
+	{"QT_IM_MODULE", "fcitx"},
+	{"XMODIFIERS", "@im=fcitx"},
+}
+
+// fcitxProfile is where fcitx5 keeps its input method groups.
+func fcitxProfile() string {
+	return filepath.Join(configHome(), "fcitx5", "profile")
+}
+
+// fcitxProfileFor is a fcitx5 profile with engine after the US keyboard,
+// so Ctrl+Space switches between the two.
+func fcitxProfileFor(engine string) string {
+	return `[Groups/0]
+Name=Default
+Default Layout=us
+DefaultIM=` + engine + `
+
+[Groups/0/Items/0]
+Name=keyboard-us
+Layout=
+
+[Groups/0/Items/1]
+Name=` + engine + `
+Layout=
+
+[GroupOrder]
+0=Default
+`
+}
+
+// ConfigureInputMethod installs fcitx5 with im's engine, sets the input
+// method variables in the environment block and starts fcitx5 with niri.
+// A fcitx5 profile is written when there is none, so the engine is ready
+// to use; an existing one is left for fcitx5-configtool.
+func ConfigureInputMethod(ctx context.Context, im InputMethod) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	for _, pkg := range slices.Concat(FcitxPackages, []string{im.Package}) {
+		if err := InstallPackage(ctx, pkg); err != nil {
+			return report, err
+		}
+		report = append(report, "Installed "+pkg)
+	}
+
+	for _, env := range fcitxEnv {
+		if err := cfg.SetEnv(env[0], env[1]); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, fmt.Sprintf("Set %s=%s in the environment block", env[0], env[1]))
+	}
+	if SpawnAtStartup(cfg, "fcitx5") != nil {
+		report = append(report, "niri already starts fcitx5")
+	} else {
+		if err := cfg.AddNode(FormatNode("spawn-at-startup", "fcitx5", "-d")); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, "Added spawn-at-startup: fcitx5 -d")
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	report = append(report, "Updated "+ConfigPath())
+
+	switch _, err := os.Stat(fcitxProfile()); {
+	case err == nil:
+		report = append(report, fmt.Sprintf("Left %s as it is; add %s to it with fcitx5-configtool", fcitxProfile(), im.Engine))
+	case OptionsFrom(ctx).Preview != nil:
+		OptionsFrom(ctx).Preview("Would write " + fcitxProfile())
+	default:
+		if err := os.MkdirAll(filepath.Dir(fcitxProfile()), 0755); err != nil {
+			return report, fmt.Errorf("failed to create %s: %w", filepath.Dir(fcitxProfile()), err)
+		}
+		if err := writeFile(ctx, fcitxProfile(), []byte(fcitxProfileFor(im.Engine)), 0644); err != nil {
+			return report, fmt.Errorf("failed to write %s: %w", fcitxProfile(), err)
+		}
+		report = append(report, "Wrote "+fcitxProfile()+" with "+im.Engine+" after the US keyboard")
+	}
+	return append(report, fmt.Sprintf("From the next niri session, Ctrl+Space switches to %s", im.Language)), nil
+}
@@ -0,0 +1,566 @@
+package niri
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Node is a single KDL node from a niri config. Besides the decoded name,
+// arguments, properties and children it remembers where its text lives in
+// the source, so edits can splice the file without disturbing the comments
+// and formatting around it.
+type Node struct {
+	Name     string
+	Args     []string
+	Props    map[string]string
+	Children []*Node
+	Disabled bool // commented out with "/-"
+
+	start, end  int // from the name (or "/-") to the last token of the node
+	open, close int // offsets of the children braces, or -1
+}
+
+// Child returns the first enabled child node called name.
+func (n *Node) Child(name string) *Node {
+	for _, c := range n.Children {
+		if c.Name == name && !c.Disabled {
+			return c
+		}
+	}
+	return nil
+}
+
+// Config is a parsed niri config.kdl. It understands just enough KDL to find
+// and edit nodes; everything it doesn't touch is written back verbatim.
+type Config struct {
+	src   string
+	Nodes []*Node
+}
+
+// ParseConfig parses the text of a niri config.
+func ParseConfig(src string) (*Config, error) {
+	p := &parser{src: src}
+	nodes, err := p.nodes(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{src: src, Nodes: nodes}, nil
+}
+
+func (c *Config) String() string {
+	return c.src
+}
+
+// Section returns the first enabled top-level node called name.
+func (c *Config) Section(name string) *Node {
+	for _, n := range c.Nodes {
+		if n.Name == name && !n.Disabled {
+			return n
+		}
+	}
+	return nil
+}
+
+// All returns every enabled top-level node called name.
+func (c *Config) All(name string) []*Node {
+	var nodes []*Node
+	for _, n := range c.Nodes {
+		if n.Name == name && !n.Disabled {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// Bind is one entry of the binds section.
+type Bind struct {
+	Key    string
+	Action string   // name of the first action node, e.g. "spawn"
+	Args   []string // arguments of that action
+	Node   *Node
+}
+
+// Binds lists the enabled key bindings in the config.
+func (c *Config) Binds() []Bind {
+	section := c.Section("binds")
+	if section == nil {
+		return nil
+	}
+	var binds []Bind
+	for _, n := range section.Children {
+		if n.Disabled {
+			continue
+		}
+		b := Bind{Key: n.Name, Node: n}
+		for _, a := range n.Children {
+			if !a.Disabled {
+				b.Action, b.Args = a.Name, a.Args
+				break
+			}
+		}
+		binds = append(binds, b)
+	}
+	return binds
+}
+
+// Bind returns the binding for key, or nil if it is unbound.
+func (c *Config) Bind(key string) *Bind {
+	for _, b := range c.Binds() {
+		if SameKey(b.Key, key) {
+			return &b
+		}
+	}
+	return nil
+}
+
+// DuplicateBinds returns the binds of every key that is bound more than
+// once, grouped by key. niri only honours one of them.
+func (c *Config) DuplicateBinds() [][]Bind {
+	var groups [][]Bind
+	for _, b := range c.Binds() {
+		i := slices.IndexFunc(groups, func(g []Bind) bool { return SameKey(g[0].Key, b.Key) })
+		if i < 0 {
+			groups = append(groups, []Bind{b})
+		} else {
+			groups[i] = append(groups[i], b)
+		}
+	}
+	return slices.DeleteFunc(groups, func(g []Bind) bool { return len(g) < 2 })
+}
+
+// SameKey reports whether a and b name the same key combination, ignoring
+// case and the order of the modifiers.
+func SameKey(a, b string) bool {
+	return normalizeKey(a) == normalizeKey(b)
+}
+
+func normalizeKey(key string) string {
+	parts := strings.Split(strings.ToLower(key), "+")
+	mods := parts[:len(parts)-1]
+	slices.Sort(mods)
+	return strings.Join(append(mods, parts[len(parts)-1]), "+")
+}
+
+// SetEnv sets name to value in the environment section, replacing any
+// existing value.
+func (c *Config) SetEnv(name, value string) error {
+	return c.SetChild("environment", FormatNode(name, value))
+}
+
+// SetChild puts line in the top-level section, replacing the child with the
+// same name if there is one.
+func (c *Config) SetChild(section, line string) error {
+	name := strings.Fields(line)[0]
+	if s := c.Section(section); s != nil {
+		if n := s.Child(name); n != nil {
+			return c.Replace(n, line)
+		}
+	}
+	return c.AddChild(section, line)
+}
+
+// AddNode inserts text as a new top-level node, after the last enabled node
+// with the same name so related lines stay together, or at the end of the
+// file otherwise.
+func (c *Config) AddNode(text string) error {
+	name := strings.Fields(text)[0]
+	var last *Node
+	for _, n := range c.All(name) {
+		last = n
+	}
+	if last == nil {
+		src := strings.TrimRight(c.src, "\n")
+		if src != "" {
+			src += "\n\n"
+		}
+		return c.reparse(src + text + "\n")
+	}
+	at := lineEnd(c.src, last.end)
+	return c.reparse(c.src[:at] + "\n" + text + c.src[at:])
+}
+
+// AddChild appends text as the last child of the top-level section called
+// name, creating the section at the end of the file if it doesn't exist.
+func (c *Config) AddChild(name, text string) error {
+	section := c.Section(name)
+	if section == nil {
+		return c.AddNode(fmt.Sprintf("%s {\n    %s\n}", name, text))
+	}
+	return c.AddChildTo(section, text)
+}
+
+// AddChildTo appends text as the last child of section, giving it a block
+// if it has none.
+func (c *Config) AddChildTo(section *Node, text string) error {
+	if section.close < 0 {
+		at := section.end
+		return c.reparse(c.src[:at] + " {\n    " + text + "\n}" + c.src[at:])
+	}
+	indent := indentOf(c.src, section.start) + "    "
+	for _, child := range section.Children {
+		if onOwnLine(c.src, child.start) {
+			indent = indentOf(c.src, child.start)
+		}
+	}
+	if onOwnLine(c.src, section.close) {
+		at := lineStart(c.src, section.close)
+		return c.reparse(c.src[:at] + indent + text + "\n" + c.src[at:])
+	}
+	return c.reparse(c.src[:section.close] + text + "; " + c.src[section.close:])
+}
+
+// Replace swaps the text of n for text.
+func (c *Config) Replace(n *Node, text string) error {
+	return c.reparse(c.src[:n.start] + text + c.src[n.end:])
+}
+
+// Remove deletes n, along with its line if nothing else shares it.
+func (c *Config) Remove(n *Node) error {
+	start, end := n.start, n.end
+	if onOwnLine(c.src, start) && strings.TrimSpace(c.src[end:lineEnd(c.src, end)]) == "" {
+		start = lineStart(c.src, start)
+		end = lineEnd(c.src, end)
+		if end < len(c.src) {
+			end++
+		}
+	}
+	return c.reparse(c.src[:start] + c.src[end:])
+}
+
+func (c *Config) reparse(src string) error {
+	parsed, err := ParseConfig(src)
+	if err != nil {
+		return err
+	}
+	*c = *parsed
+	return nil
+}
+
+// FormatNode renders a node with string arguments, e.g.
+// spawn-at-startup "wl-paste" "--watch" "cliphist" "store".
+func FormatNode(name string, args ...string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, a := range args {
+		b.WriteString(" ")
+		b.WriteString(strconv.Quote(a))
+	}
+	return b.String()
+}
+
+func lineStart(s string, i int) int {
+	return strings.LastIndexByte(s[:i], '\n') + 1
+}
+
+func lineEnd(s string, i int) int {
+	if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+		return i + j
+	}
+	return len(s)
+}
+
+func onOwnLine(s string, i int) bool {
+	return strings.TrimSpace(s[lineStart(s, i):i]) == ""
+}
+
+func indentOf(s string, i int) string {
+	line := s[lineStart(s, i):i]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// parser is a small recursive-descent reader for the subset of KDL 1.0 that
+// niri configs use: nodes, string/bare values, properties, children, type
+// annotations, raw strings, line continuations and all three comment forms.
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	return fmt.Errorf("config line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// nodes reads nodes until EOF, or until the closing brace when inBlock.
+func (p *parser) nodes(inBlock bool) ([]*Node, error) {
+	var nodes []*Node
+	for {
+		if err := p.skipLinespace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			if inBlock {
+				return nil, p.errorf("missing closing brace")
+			}
+			return nodes, nil
+		}
+		if p.src[p.pos] == '}' {
+			if !inBlock {
+				return nil, p.errorf("unexpected closing brace")
+			}
+			return nodes, nil
+		}
+		n, err := p.node()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+func (p *parser) node() (*Node, error) {
+	n := &Node{start: p.pos, open: -1, close: -1}
+	if p.peek("/-") {
+		n.Disabled = true
+		p.pos += 2
+		p.skipSpace()
+	}
+	p.skipAnnotation()
+	name, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	n.Name = name
+	n.end = p.pos
+
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() || p.src[p.pos] == '}' {
+			return n, nil
+		}
+		switch {
+		case p.src[p.pos] == '\n' || p.src[p.pos] == '\r':
+			return n, nil
+		case p.src[p.pos] == ';':
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		case p.peek("//"):
+			p.pos = lineEnd(p.src, p.pos)
+			return n, nil
+		case p.peek("/-"):
+			p.pos += 2
+			p.skipSpace()
+			if p.peek("{") {
+				if err := p.children(&Node{}); err != nil {
+					return nil, err
+				}
+			} else if _, _, err := p.entry(); err != nil {
+				return nil, err
+			}
+		case p.src[p.pos] == '{':
+			if err := p.children(n); err != nil {
+				return nil, err
+			}
+		default:
+			key, val, err := p.entry()
+			if err != nil {
+				return nil, err
+			}
+			if key != "" {
+				if n.Props == nil {
+					n.Props = map[string]string{}
+				}
+				n.Props[key] = val
+			} else {
+				n.Args = append(n.Args, val)
+			}
+		}
+		n.end = p.pos
+	}
+}
+
+func (p *parser) children(n *Node) error {
+	n.open = p.pos
+	p.pos++
+	children, err := p.nodes(true)
+	if err != nil {
+		return err
+	}
+	n.Children = children
+	n.close = p.pos
+	p.pos++
+	return nil
+}
+
+// entry reads an argument, or a property when the value is followed by '='.
+func (p *parser) entry() (key, val string, err error) {
+	p.skipAnnotation()
+	val, err = p.value()
+	if err != nil {
+		return "", "", err
+	}
+	if p.peek("=") {
+		p.pos++
+		p.skipAnnotation()
+		key = val
+		val, err = p.value()
+	}
+	return key, val, err
+}
+
+func (p *parser) value() (string, error) {
+	if p.eof() {
+		return "", p.errorf("unexpected end of file")
+	}
+	if p.src[p.pos] == '"' {
+		return p.quoted()
+	}
+	if p.src[p.pos] == 'r' {
+		hashes := 0
+		for p.pos+1+hashes < len(p.src) && p.src[p.pos+1+hashes] == '#' {
+			hashes++
+		}
+		if p.pos+1+hashes < len(p.src) && p.src[p.pos+1+hashes] == '"' {
+			return p.raw(hashes)
+		}
+	}
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n\\/(){}<>;[]=,\"", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("unexpected %q", p.src[p.pos])
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *parser) quoted() (string, error) {
+	var b strings.Builder
+	for p.pos++; !p.eof(); p.pos++ {
+		c := p.src[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			p.pos++
+			if p.eof() {
+				return "", p.errorf("unterminated string")
+			}
+			switch e := p.src[p.pos]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				end := strings.IndexByte(p.src[p.pos:], '}')
+				if !p.peek("u{") || end < 0 {
+					return "", p.errorf("bad unicode escape")
+				}
+				code, err := strconv.ParseUint(p.src[p.pos+2:p.pos+end], 16, 32)
+				if err != nil {
+					return "", p.errorf("bad unicode escape")
+				}
+				b.WriteRune(rune(code))
+				p.pos += end
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) raw(hashes int) (string, error) {
+	p.pos += 2 + hashes
+	closing := "\"" + strings.Repeat("#", hashes)
+	end := strings.Index(p.src[p.pos:], closing)
+	if end < 0 {
+		return "", p.errorf("unterminated raw string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + len(closing)
+	return s, nil
+}
+
+func (p *parser) skipAnnotation() {
+	if p.peek("(") {
+		if end := strings.IndexByte(p.src[p.pos:], ')'); end >= 0 {
+			p.pos += end + 1
+		}
+	}
+}
+
+// skipSpace skips whitespace, block comments and line continuations within
+// a node.
+func (p *parser) skipSpace() error {
+	for !p.eof() {
+		switch {
+		case p.src[p.pos] == ' ' || p.src[p.pos] == '\t':
+			p.pos++
+		case p.peek("/*"):
+			if err := p.skipBlockComment(); err != nil {
+				return err
+			}
+		case p.src[p.pos] == '\\':
+			p.pos = lineEnd(p.src, p.pos)
+			if !p.eof() {
+				p.pos++
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// skipLinespace skips everything that may separate nodes: whitespace,
+// newlines, semicolons and comments.
+func (p *parser) skipLinespace() error {
+	for !p.eof() {
+		switch {
+		case strings.ContainsRune(" \t\r\n;", rune(p.src[p.pos])):
+			p.pos++
+		case p.peek("//"):
+			p.pos = lineEnd(p.src, p.pos)
+		case p.peek("/*"):
+			if err := p.skipBlockComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *parser) skipBlockComment() error {
+	depth := 0
+	for !p.eof() {
+		switch {
+		case p.peek("/*"):
+			depth++
+			p.pos += 2
+		case p.peek("*/"):
+			depth--
+			p.pos += 2
+			if depth == 0 {
+				return nil
+			}
+		default:
+			p.pos++
+		}
+	}
+	return p.errorf("unterminated comment")
+}
@@ -0,0 +1,147 @@
+package niri
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// describe writes nodes out in one line each level, so a test can say in a
+// string what the parser made of a config: the name and arguments quoted,
+// the properties sorted, "-" before a node disabled with "/-" and the
+// children in braces.
+func describe(nodes []*Node) string {
+	var parts []string
+	for _, n := range nodes {
+		s := fmt.Sprintf("%q", n.Name)
+		if n.Disabled {
+			s = "-" + s
+		}
+		for _, arg := range n.Args {
+			s += fmt.Sprintf(" %q", arg)
+		}
+		for _, key := range slices.Sorted(maps.Keys(n.Props)) {
+			s += fmt.Sprintf(" %s=%q", key, n.Props[key])
+		}
+		if n.Children != nil {
+			s += " { " + describe(n.Children) + " }"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestParseConfigNodes(t *testing.T) {
+	for _, tt := range []struct {
+		name, src, want string
+	}{
+		{name: "bare node", src: "prefer-no-csd\n", want: `"prefer-no-csd"`},
+		{name: "arguments", src: "gaps 16\nspawn-at-startup \"waybar\" \"-c\"\n", want: `"gaps" "16"; "spawn-at-startup" "waybar" "-c"`},
+		{name: "semicolons", src: "a; b;c", want: `"a"; "b"; "c"`},
+		{name: "properties", src: `focus-follows-mouse max-scroll-amount="10%" on=true`, want: `"focus-follows-mouse" max-scroll-amount="10%" on="true"`},
+		{name: "arguments and properties mixed", src: `mode "1920x1080" scale=2 "x"`, want: `"mode" "1920x1080" "x" scale="2"`},
+		{name: "children", src: "layout {\n    gaps 16\n    focus-ring {\n        off\n        width 4\n    }\n}\n", want: `"layout" { "gaps" "16"; "focus-ring" { "off"; "width" "4" } }`},
+		{name: "children on one line", src: `binds { Mod+T { spawn "foot"; }; Mod+Q { close-window; } }`, want: `"binds" { "Mod+T" { "spawn" "foot" }; "Mod+Q" { "close-window" } }`},
+		{name: "empty children", src: "animations {}\n", want: `"animations"`},
+		{name: "quoted name", src: `"Mod+Shift+/" { show-hotkey-overlay; }`, want: `"Mod+Shift+/" { "show-hotkey-overlay" }`},
+		{name: "type annotations", src: `(pos)output (u8)3 scale=(f64)1.5`, want: `"output" "3" scale="1.5"`},
+		{name: "line continuation", src: "spawn-at-startup \"swaybg\" \\\n    \"-i\" \"bg.png\"\nnext\n", want: `"spawn-at-startup" "swaybg" "-i" "bg.png"; "next"`},
+		{name: "crlf line ends", src: "a 1\r\nb 2\r\n", want: `"a" "1"; "b" "2"`},
+		{name: "nothing", src: "", want: ""},
+	} {
+		cfg, err := ParseConfig(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := describe(cfg.Nodes); got != tt.want {
+			t.Errorf("%s: parsed %q as\n%s\nwant\n%s", tt.name, tt.src, got, tt.want)
+		}
+		if cfg.String() != tt.src {
+			t.Errorf("%s: the config reads %q, not its source", tt.name, cfg)
+		}
+	}
+}
+
+func TestParseConfigStrings(t *testing.T) {
+	for _, tt := range []struct {
+		name, src, want string
+	}{
+		{name: "plain", src: `"foot"`, want: "foot"},
+		{name: "spaces and braces", src: `"a { b } c"`, want: "a { b } c"},
+		{name: "quote and backslash", src: `"say \"hi\" C:\\tmp"`, want: `say "hi" C:\tmp`},
+		{name: "control escapes", src: `"a\nb\tc\rd\be\ff"`, want: "a\nb\tc\rd\be\ff"},
+		{name: "unicode escape", src: `"\u{1F600} \u{e9}"`, want: "\U0001F600 é"},
+		{name: "other escapes", src: `"\/\q"`, want: "/q"},
+		{name: "raw", src: `r"C:\no\escapes"`, want: `C:\no\escapes`},
+		{name: "raw with hashes", src: `r#"a "quoted" word"#`, want: `a "quoted" word`},
+		{name: "raw across lines", src: "r##\"one\n\"# two\"##", want: "one\n\"# two"},
+		{name: "a bare r", src: "r", want: "r"},
+	} {
+		cfg, err := ParseConfig("node " + tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(cfg.Nodes) != 1 || !slices.Equal(cfg.Nodes[0].Args, []string{tt.want}) {
+			t.Errorf("%s: parsed %s as %s, want the argument %q", tt.name, tt.src, describe(cfg.Nodes), tt.want)
+		}
+	}
+}
+
+func TestParseConfigComments(t *testing.T) {
+	for _, tt := range []struct {
+		name, src, want string
+	}{
+		{name: "line comments", src: "// the layout\ngaps 16 // wide\n// done\n", want: `"gaps" "16"`},
+		{name: "block comment between nodes", src: "a /* not\na node */ 1\n/* b */ c\n", want: `"a" "1"; "c"`},
+		{name: "nested block comments", src: "/* outer /* inner */ still */ a\n", want: `"a"`},
+		{name: "comment in children", src: "layout {\n    // gaps 4\n    gaps 16\n}\n", want: `"layout" { "gaps" "16" }`},
+		{name: "slashdash node", src: "/-gaps 16\ngaps 8\n", want: `-"gaps" "16"; "gaps" "8"`},
+		{name: "slashdash with children", src: "/- binds {\n    Mod+T { spawn \"foot\"; }\n}\n", want: `-"binds" { "Mod+T" { "spawn" "foot" } }`},
+		{name: "slashdash child", src: "binds {\n    /-Mod+T { spawn \"foot\"; }\n}\n", want: `"binds" { -"Mod+T" { "spawn" "foot" } }`},
+		{name: "slashdash argument", src: "a 1 /-2 3\n", want: `"a" "1" "3"`},
+		{name: "slashdash property", src: "a x=1 /-y=2\n", want: `"a" x="1"`},
+		{name: "slashdash children block", src: "a /-{ b; }\n", want: `"a"`},
+	} {
+		cfg, err := ParseConfig(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := describe(cfg.Nodes); got != tt.want {
+			t.Errorf("%s: parsed %q as\n%s\nwant\n%s", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name, src, want string
+	}{
+		{name: "missing closing brace", src: "layout {\n    gaps 16\n", want: "config line 3: missing closing brace"},
+		{name: "missing nested brace", src: "layout {\n    focus-ring {\n}\n", want: "config line 4: missing closing brace"},
+		{name: "stray closing brace", src: "gaps 16\n}\n", want: "config line 2: unexpected closing brace"},
+		{name: "unterminated string", src: "spawn \"foot\n", want: "config line 2: unterminated string"},
+		{name: "backslash at the end", src: `spawn "foot\`, want: "config line 1: unterminated string"},
+		{name: "unicode escape without braces", src: `a "\u00e9"`, want: "config line 1: bad unicode escape"},
+		{name: "unicode escape not hex", src: `a "\u{zz}"`, want: "config line 1: bad unicode escape"},
+		{name: "unterminated raw string", src: "a r#\"x\"\n", want: "config line 1: unterminated raw string"},
+		{name: "unterminated block comment", src: "a\n/* b\n", want: "config line 3: unterminated comment"},
+		{name: "unterminated comment in a node", src: "a 1 /* b", want: "config line 1: unterminated comment"},
+		{name: "property without value", src: "a b=", want: "config line 1: unexpected end of file"},
+		{name: "stray character", src: "a\nb =c\n", want: "config line 2: unexpected '='"},
+		{name: "slashdash at the end", src: "a /-", want: "config line 1: unexpected end of file"},
+	} {
+		cfg, err := ParseConfig(tt.src)
+		if err == nil {
+			t.Errorf("%s: %q parsed as %s, want %q", tt.name, tt.src, describe(cfg.Nodes), tt.want)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("%s: %q failed with %q, want %q", tt.name, tt.src, err, tt.want)
+		}
+	}
+}
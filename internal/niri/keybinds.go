@@ -0,0 +1,112 @@
+package niri
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CommonModifiers are the modifier combinations KeyUsage looks at, the ones
+// niri's own binds are built from.
+var CommonModifiers = []string{"Mod", "Mod+Shift", "Mod+Ctrl", "Mod+Alt"}
+
+// commonKeys are the keys KeyUsage pairs with each modifier.
+var commonKeys = strings.Fields("A B C D E F G H I J K L M N O P Q R S T U V W X Y Z 1 2 3 4 5 6 7 8 9 0 Return Space Tab Escape Left Right Up Down")
+
+// keysPerLine is how many free keys KeybindSheet puts on a line.
+const keysPerLine = 15
+
+// ModifierUsage is which of the common keys are bound with Modifier.
+type ModifierUsage struct {
+	Modifier string
+	Free     []string
+	Used     []string
+}
+
+// KeyUsage reports, for each of CommonModifiers, which common keys are
+// bound in cfg and which are still free. Super counts as Mod, which is what
+// niri maps it to outside a nested session.
+func KeyUsage(cfg *Config) []ModifierUsage {
+	bound := map[string]bool{}
+	for _, b := range cfg.Binds() {
+		bound[normalizeKey(strings.ReplaceAll(b.Key, "Super+", "Mod+"))] = true
+	}
+	var usage []ModifierUsage
+	for _, mod := range CommonModifiers {
+		u := ModifierUsage{Modifier: mod}
+		for _, key := range commonKeys {
+			if bound[normalizeKey(mod+"+"+key)] {
+				u.Used = append(u.Used, key)
+			} else {
+				u.Free = append(u.Free, key)
+			}
+		}
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+// KeybindSheet lists the binds in the niri config, one per line with its
+// action, followed by the common key combinations that are still free.
+func KeybindSheet() (string, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to read niri config: %w", err)
+	}
+	binds := cfg.Binds()
+	width := 0
+	for _, b := range binds {
+		width = max(width, len(b.Key))
+	}
+	var sheet strings.Builder
+	fmt.Fprintf(&sheet, "%d binds in %s:\n", len(binds), ConfigPath())
+	for _, b := range binds {
+		action := FormatNode(b.Action, b.Args...)
+		if b.Action == "" {
+			action = "(no action)"
+		}
+		fmt.Fprintf(&sheet, "  %-*s  %s\n", width, b.Key, action)
+	}
+	sheet.WriteString("\nCommon keys still free for your own binds:\n")
+	for _, u := range KeyUsage(cfg) {
+		fmt.Fprintf(&sheet, "  %-10s %d bound, %d free\n", u.Modifier+"+", len(u.Used), len(u.Free))
+		// a line per handful of keys, so the sheet fits a narrow terminal
+		for free := u.Free; len(free) > 0; free = free[min(len(free), keysPerLine):] {
+			sheet.WriteString("    " + strings.Join(free[:min(len(free), keysPerLine)], " ") + "\n")
+		}
+	}
+	return sheet.String(), nil
+}
+
+// spawnBind is a key bound to run a command, also while the screen is
+// locked, as niri's own media key binds are.
+type spawnBind struct {
+	Key  string
+	Args []string
+	What string // what pressing it does, for the report
+}
+
+// bindSpawns binds each of binds in cfg, replacing other binds on those
+// keys, and reports what it changed.
+func bindSpawns(cfg *Config, binds []spawnBind) ([]string, error) {
+	var report []string
+	for _, s := range binds {
+		bind := fmt.Sprintf("%s allow-when-locked=true { %s; }", s.Key, FormatNode("spawn", s.Args...))
+		var err error
+		switch b := cfg.Bind(s.Key); {
+		case b == nil:
+			err = cfg.AddChild("binds", bind)
+		case b.Action == "spawn" && slices.Equal(b.Args, s.Args):
+			report = append(report, fmt.Sprintf("%s already %s, left it unchanged", s.Key, s.What))
+			continue
+		default:
+			report = append(report, fmt.Sprintf("Replaced the %s bind to %s", s.Key, b.Action))
+			err = cfg.Replace(b.Node, bind)
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, fmt.Sprintf("Bound %s: %s (%s)", s.Key, s.What, strings.Join(s.Args, " ")))
+	}
+	return report, nil
+}
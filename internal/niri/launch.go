@@ -0,0 +1,111 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LaunchLog is where the output of a niri started by Launch goes, so it can
+// be shown when niri fails to come up.
+func LaunchLog() string {
+	return filepath.Join(os.TempDir(), "niri.log")
+}
+
+// launchPoll is how often Launch checks whether niri is answering.
+const launchPoll = 250 * time.Millisecond
+
+// Launch starts niri in its own session with its output in LaunchLog and
+// waits up to timeout for it to answer niri msg version on its IPC socket.
+// It returns an error if niri exits or never answers in that time.
+func Launch(ctx context.Context, timeout time.Duration) ([]string, error) {
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would start niri --session in the background, output in " + LaunchLog())
+		return nil, nil
+	}
+	logFile, err := os.Create(LaunchLog())
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s: %w", LaunchLog(), err)
+	}
+	defer logFile.Close()
+
+	cmd := commandIn(context.Background(), "niri", "--session") // CancelCommands must not stop it
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true} // outlive NiriSetup
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start niri: %w", err)
+	}
+	report := []string{fmt.Sprintf("Started niri (pid %d), output in %s", cmd.Process.Pid, LaunchLog())}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case err := <-exited:
+			return report, fmt.Errorf("niri exited during startup: %v", err)
+		case <-deadline:
+			return report, fmt.Errorf("niri did not respond within %s", timeout)
+		case <-time.After(launchPoll):
+			if version, ok := responding(cmd.Process.Pid); ok {
+				return append(report, "niri is responding: "+version), nil
+			}
+		}
+	}
+}
+
+// SessionCommand runs niri --session in the foreground, for handing it the
+// terminal until the session ends.
+func SessionCommand() *exec.Cmd {
+	return commandIn(context.Background(), "niri", "--session")
+}
+
+// Editor is the command line of the user's editor: $EDITOR, or vi.
+func Editor() []string {
+	if editor := strings.Fields(os.Getenv("EDITOR")); len(editor) > 0 {
+		return editor
+	}
+	return []string{"vi"}
+}
+
+// EditCommand runs Editor on the niri config in the foreground, for handing
+// it the terminal until the editor exits.
+func EditCommand() *exec.Cmd {
+	editor := Editor()
+	return commandIn(context.Background(), editor[0], append(editor[1:], ConfigPath())...)
+}
+
+// responding asks the niri with the given pid for its version over its IPC
+// socket, which niri names after its pid in XDG_RUNTIME_DIR.
+func responding(pid int) (string, bool) {
+	sockets, _ := filepath.Glob(filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), fmt.Sprintf("niri.*.%d.sock", pid)))
+	if len(sockets) == 0 {
+		return "", false
+	}
+	msg := Command("niri", "msg", "version")
+	msg.Env = append(os.Environ(), "NIRI_SOCKET="+sockets[0])
+	out, err := msg.Output()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(out)), true
+}
+
+// LaunchLogTail returns the last n lines niri wrote to LaunchLog.
+func LaunchLogTail(n int) (string, error) {
+	data, err := os.ReadFile(LaunchLog())
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n"), nil
+}
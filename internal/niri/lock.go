@@ -0,0 +1,72 @@
+package niri
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// stateHome is XDG_STATE_HOME, or ~/.local/state when it is unset.
+func stateHome() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return dir
+}
+
+// LockPath is the file NiriSetup instances lock before changing the
+// system. It holds the pid of the instance that has it.
+func LockPath() string {
+	return filepath.Join(stateHome(), "nirisetup", "lock")
+}
+
+// LockedError reports that another NiriSetup holds the lock.
+type LockedError struct {
+	PID int
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("another NiriSetup (pid %d) is running", e.PID)
+}
+
+// heldLock keeps the locked file open, and so locked, until NiriSetup
+// exits.
+var heldLock *os.File
+
+// Lock takes the lock at LockPath for the rest of the process's life, so
+// two instances never run pkg or rewrite the config at the same time. It
+// returns a *LockedError when another instance holds it; the lock is an
+// flock, so it is freed however that instance exits. Taking it again once
+// it is held does nothing.
+func Lock() error {
+	if heldLock != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(LockPath()), 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", filepath.Dir(LockPath()), err)
+	}
+	f, err := os.OpenFile(LockPath(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", LockPath(), err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		data, _ := os.ReadFile(LockPath())
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			pid, _ := strconv.Atoi(strings.TrimSpace(string(data))) // 0 in the moment before the holder writes it
+			return &LockedError{PID: pid}
+		}
+		return fmt.Errorf("cannot lock %s: %w", LockPath(), err)
+	}
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintln(f, os.Getpid())
+	}
+	heldLock = f
+	return nil
+}
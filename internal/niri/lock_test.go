@@ -0,0 +1,25 @@
+package niri
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	defer func() { heldLock = nil }()
+	if err := Lock(); err != nil {
+		t.Fatalf("Lock returned %v", err)
+	}
+	first := heldLock
+	heldLock = nil // as if another instance asked
+	err := Lock()
+	if locked, ok := err.(*LockedError); !ok || locked.PID != os.Getpid() {
+		t.Fatalf("second Lock returned %v, want a *LockedError naming pid %d", err, os.Getpid())
+	}
+	first.Close()
+	if err := Lock(); err != nil {
+		t.Errorf("Lock after the holder closed returned %v", err)
+	}
+	heldLock.Close()
+}
@@ -0,0 +1,80 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DisplayManager is a graphical login manager that can offer niri as a
+// session.
+type DisplayManager struct {
+	Name    string
+	Package string
+	Service string // rc.conf name of its service
+}
+
+// DisplayManagers are the login managers NiriSetup knows how to set up. All
+// of them list the sessions in WaylandSessionsDir.
+var DisplayManagers = []DisplayManager{
+	{Name: "SDDM", Package: "sddm", Service: "sddm"},
+	{Name: "ly", Package: "ly", Service: "ly"},
+	{Name: "GDM", Package: "gdm", Service: "gdm"},
+}
+
+// WaylandSessionsDir is where login managers look for Wayland sessions.
+const WaylandSessionsDir = "/usr/local/share/wayland-sessions"
+
+// sessionFile makes niri selectable at login.
+const sessionFile = `[Desktop Entry]
+Name=Niri
+Comment=A scrollable-tiling Wayland compositor
+Exec=niri --session
+Type=Application
+DesktopNames=niri
+`
+
+// Installed reports whether pkg has the display manager installed.
+func (dm DisplayManager) Installed() bool {
+	return PackageInstalled(dm.Package)
+}
+
+// SetUpDisplayManager installs dm if needed, enables its service and adds
+// a niri session for it to offer, unless the niri package already ships one.
+func SetUpDisplayManager(ctx context.Context, dm DisplayManager) ([]string, error) {
+	var report []string
+	if dm.Installed() {
+		report = append(report, dm.Name+" is already installed")
+	} else {
+		if err := InstallPackage(ctx, dm.Package); err != nil {
+			return nil, err
+		}
+		if OptionsFrom(ctx).Preview == nil {
+			report = append(report, "Installed "+dm.Package)
+		}
+	}
+
+	enable := asRoot("sysrc", dm.Service+"_enable=YES")
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would run " + strings.Join(enable, " "))
+	} else {
+		if out, err := Command(enable[0], enable[1:]...).CombinedOutput(); err != nil {
+			return report, fmt.Errorf("failed to enable %s: %s", dm.Service, out)
+		}
+		report = append(report, fmt.Sprintf("Enabled the %s service, it starts at the next boot", dm.Service))
+	}
+
+	path := filepath.Join(WaylandSessionsDir, "niri.desktop")
+	if _, err := os.Stat(path); err == nil {
+		return append(report, path+" already exists, left it unchanged"), nil
+	}
+	if err := writeSystemFile(ctx, path, sessionFile); err != nil {
+		return report, err
+	}
+	if OptionsFrom(ctx).Preview != nil {
+		return report, nil
+	}
+	return append(report, "Wrote "+path, "Niri is now a session choice at login"), nil
+}
@@ -0,0 +1,65 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// MediaPackages are installed by ConfigureMediaKeys, besides wireplumber
+// for wpctl: playerctl controls whichever player is playing.
+var MediaPackages = []string{"playerctl"}
+
+// mediaBinds are the volume and player keys ConfigureMediaKeys binds, with
+// the same volume commands as niri's default config.
+var mediaBinds = []spawnBind{
+	{"XF86AudioRaiseVolume", []string{"wpctl", "set-volume", "@DEFAULT_AUDIO_SINK@", "0.1+"}, "raises the volume"},
+	{"XF86AudioLowerVolume", []string{"wpctl", "set-volume", "@DEFAULT_AUDIO_SINK@", "0.1-"}, "lowers the volume"},
+	{"XF86AudioMute", []string{"wpctl", "set-mute", "@DEFAULT_AUDIO_SINK@", "toggle"}, "mutes the sound"},
+	{"XF86AudioMicMute", []string{"wpctl", "set-mute", "@DEFAULT_AUDIO_SOURCE@", "toggle"}, "mutes the microphone"},
+	{"XF86AudioPlay", []string{"playerctl", "play-pause"}, "plays or pauses"},
+	{"XF86AudioPause", []string{"playerctl", "play-pause"}, "plays or pauses"},
+	{"XF86AudioNext", []string{"playerctl", "next"}, "skips to the next track"},
+	{"XF86AudioPrev", []string{"playerctl", "previous"}, "goes back a track"},
+}
+
+// MediaKeys are the keys ConfigureMediaKeys binds.
+func MediaKeys() []string {
+	keys := make([]string, len(mediaBinds))
+	for i, b := range mediaBinds {
+		keys[i] = b.Key
+	}
+	return keys
+}
+
+// ConfigureMediaKeys installs wireplumber and MediaPackages and binds the
+// volume and media keys, replacing other binds on them. WriteConfig has
+// niri validate the result before it is saved.
+func ConfigureMediaKeys(ctx context.Context) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	for _, pkg := range slices.Concat([]string{"wireplumber"}, MediaPackages) {
+		if err := InstallPackage(ctx, pkg); err != nil {
+			return report, err
+		}
+		report = append(report, "Installed "+pkg)
+	}
+
+	bound, err := bindSpawns(cfg, mediaBinds)
+	report = append(report, bound...)
+	if err != nil {
+		return report, err
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return append(report, "Updated "+ConfigPath()), nil
+}
@@ -0,0 +1,42 @@
+package niri
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MinMemory is the least RAM a niri desktop runs comfortably in. Below it
+// the session swaps, and building packages from ports thrashes.
+const MinMemory = 2 << 30
+
+// PhysicalMemory returns the machine's RAM in bytes, from sysctl
+// hw.physmem.
+func PhysicalMemory() (uint64, error) {
+	out, err := Command("sysctl", "-n", "hw.physmem").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read hw.physmem: %w", err)
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// gib formats n bytes in GiB.
+func gib(n uint64) string {
+	return strconv.FormatFloat(float64(n)/(1<<30), 'f', 1, 64) + " GiB"
+}
+
+// MemoryCheck reports the machine's RAM, warning when it is below
+// MinMemory.
+func MemoryCheck() Check {
+	c := Check{Name: "Memory", Warn: true}
+	mem, err := PhysicalMemory()
+	switch {
+	case err != nil:
+		c.Detail = err.Error()
+	case mem < MinMemory:
+		c.Detail = fmt.Sprintf("%s, less than %s: expect the desktop to swap, and install binary packages rather than building from ports, which would thrash", gib(mem), gib(MinMemory))
+	default:
+		c.OK, c.Detail = true, gib(mem)
+	}
+	return c
+}
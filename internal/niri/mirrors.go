@@ -0,0 +1,152 @@
+package niri
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Repo is a pkg repository from the pkg configuration.
+type Repo struct {
+	Name string
+	URL  string
+}
+
+// pkgRepoConfigs are the places pkg reads repository definitions from, in
+// the order it reads them; later definitions override earlier ones.
+var pkgRepoConfigs = []string{"/etc/pkg/*.conf", "/usr/local/etc/pkg/repos/*.conf"}
+
+// mirrorOverride is where UseMirror points the FreeBSD repository elsewhere.
+const mirrorOverride = "/usr/local/etc/pkg/repos/FreeBSD.conf"
+
+// KnownMirrors are FreeBSD package mirrors that can stand in for the
+// pkg.FreeBSD.org round-robin.
+var KnownMirrors = []string{
+	"http://pkg0.bme.freebsd.org",
+	"http://pkg0.chi.freebsd.org",
+	"http://pkg0.kul.freebsd.org",
+	"http://pkg0.nyi.freebsd.org",
+	"http://pkg0.tuk.freebsd.org",
+	"http://pkg0.twn.freebsd.org",
+}
+
+var (
+	repoStart = regexp.MustCompile(`^\s*"?([\w.-]+)"?\s*:\s*\{`)
+	repoURL   = regexp.MustCompile(`^\s*url\s*:\s*"([^"]+)"`)
+)
+
+// ConfiguredRepos lists the enabled-or-not repositories pkg knows about.
+func ConfiguredRepos() ([]Repo, error) {
+	var repos []Repo
+	for _, pattern := range pkgRepoConfigs {
+		files, _ := filepath.Glob(pattern)
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			var name string
+			for _, line := range strings.Split(string(data), "\n") {
+				if m := repoStart.FindStringSubmatch(line); m != nil {
+					name = m[1]
+				} else if m := repoURL.FindStringSubmatch(line); m != nil && name != "" {
+					repos = slices.DeleteFunc(repos, func(r Repo) bool { return r.Name == name })
+					repos = append(repos, Repo{Name: name, URL: m[1]})
+				}
+			}
+		}
+	}
+	return repos, nil
+}
+
+// MirrorTiming is how long a mirror took to serve its repository metadata.
+type MirrorTiming struct {
+	URL     string
+	Latency time.Duration
+	Err     error
+}
+
+// BenchmarkMirrors times a fetch of the small meta.conf file from the
+// configured FreeBSD repository and every known mirror, fastest first.
+// Mirrors that failed sort last.
+func BenchmarkMirrors() ([]MirrorTiming, error) {
+	abi, err := Command("pkg", "config", "ABI").Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine the pkg ABI: %w", err)
+	}
+	repos, err := ConfiguredRepos()
+	if err != nil {
+		return nil, err
+	}
+
+	// Mirrors serve the same tree as the configured repository, so reuse its
+	// path (and with it the quarterly/latest branch).
+	path := "/${ABI}/quarterly"
+	var urls []string
+	for _, r := range repos {
+		if r.Name == "FreeBSD" {
+			u := strings.TrimPrefix(r.URL, "pkg+")
+			urls = append(urls, u)
+			if _, rest, ok := strings.Cut(u, "://"); ok {
+				if i := strings.Index(rest, "/"); i >= 0 {
+					path = rest[i:]
+				}
+			}
+		}
+	}
+	for _, m := range KnownMirrors {
+		if u := m + path; !slices.Contains(urls, u) {
+			urls = append(urls, u)
+		}
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	timings := make([]MirrorTiming, 0, len(urls))
+	for _, u := range urls {
+		meta := strings.ReplaceAll(u, "${ABI}", strings.TrimSpace(string(abi))) + "/meta.conf"
+		// only the last attempt is timed, so a retry does not make a
+		// mirror look slow
+		var latency time.Duration
+		err := retry(probeAttempts, probeBackoff, func() error {
+			start := time.Now()
+			resp, err := client.Get(meta)
+			latency = time.Since(start)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			switch {
+			case resp.StatusCode >= 500:
+				return fmt.Errorf("HTTP %s", resp.Status)
+			case resp.StatusCode != http.StatusOK:
+				return permanent(fmt.Errorf("HTTP %s", resp.Status)) // the mirror lacks the file
+			}
+			return nil
+		})
+		timings = append(timings, MirrorTiming{URL: u, Latency: latency, Err: err})
+	}
+	slices.SortStableFunc(timings, func(a, b MirrorTiming) int {
+		if (a.Err == nil) != (b.Err == nil) {
+			if a.Err == nil {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.Latency, b.Latency)
+	})
+	return timings, nil
+}
+
+// UseMirror points the FreeBSD repository at url by writing a repository
+// override, which takes precedence over /etc/pkg/FreeBSD.conf.
+func UseMirror(ctx context.Context, url string) error {
+	conf := fmt.Sprintf("FreeBSD: {\n  url: %q,\n  mirror_type: \"none\"\n}\n", url)
+	return writeSystemFile(ctx, mirrorOverride, conf)
+}
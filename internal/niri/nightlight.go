@@ -0,0 +1,95 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MinTemperature and MaxTemperature bound the colour temperatures, in
+// kelvin, ConfigureNightLight accepts.
+const (
+	MinTemperature = 1000
+	MaxTemperature = 6500
+)
+
+// DayTemperatures, NightTemperatures and NightLightTransitions are the
+// choices offered for a wlsunset schedule. Transitions are in minutes.
+var (
+	DayTemperatures       = []int{6500, 6000, 5500, 5000}
+	NightTemperatures     = []int{4500, 4000, 3500, 3000, 2500}
+	NightLightTransitions = []int{15, 30, 60, 120}
+)
+
+// defaultSunTimes is the manual sunrise and sunset wlsunset is given when
+// the config has neither a location nor times of its own.
+var defaultSunTimes = []string{"-S", "07:00", "-s", "19:00"}
+
+// nightLightFlags are the wlsunset flags ConfigureNightLight owns; any other
+// flags on an existing spawn line are kept.
+var nightLightFlags = []string{"-t", "-T", "-d"}
+
+// ConfigureNightLight makes niri start wlsunset at night temperature
+// night and day temperature day, fading between them over transition
+// minutes. An existing location (-l/-L) or sunrise/sunset (-S/-s) is kept.
+func ConfigureNightLight(ctx context.Context, day, night, transition int) ([]string, error) {
+	for _, t := range []int{day, night} {
+		if t < MinTemperature || t > MaxTemperature {
+			return nil, fmt.Errorf("temperature %dK is outside %d-%dK", t, MinTemperature, MaxTemperature)
+		}
+	}
+	if night >= day {
+		return nil, fmt.Errorf("the night temperature (%dK) must be lower than the day temperature (%dK)", night, day)
+	}
+	if transition <= 0 {
+		return nil, fmt.Errorf("the transition must be longer than 0 minutes")
+	}
+
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	var kept []string
+	existing := SpawnAtStartup(cfg, "wlsunset")
+	if existing != nil {
+		for i := 1; i < len(existing.Args); i++ {
+			if slices.Contains(nightLightFlags, existing.Args[i]) {
+				i++ // skip its value too
+				continue
+			}
+			kept = append(kept, existing.Args[i])
+		}
+	}
+	report := []string{checked}
+	byLocation := slices.Contains(kept, "-l") || slices.Contains(kept, "-L")
+	if !byLocation && !slices.Contains(kept, "-S") && !slices.Contains(kept, "-s") {
+		kept = append(kept, defaultSunTimes...)
+		report = append(report, "No location or sunrise/sunset set; using sunrise at 07:00 and sunset at 19:00")
+	}
+
+	args := append([]string{"wlsunset", "-t", strconv.Itoa(night), "-T", strconv.Itoa(day), "-d", strconv.Itoa(transition * 60)}, kept...)
+	line := FormatNode("spawn-at-startup", args...)
+	if existing != nil {
+		err = cfg.Replace(existing, line)
+	} else {
+		err = cfg.AddNode(line)
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to update niri config: %w", err)
+	}
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	report = append(report, "Set spawn-at-startup: "+strings.Join(args, " "))
+	if byLocation {
+		report = append(report, "wlsunset ignores -d when it follows the sun by location (-l/-L); set -S and -s instead for a fixed transition")
+	}
+	return append(report, "Updated "+ConfigPath()), nil
+}
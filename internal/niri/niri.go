@@ -0,0 +1,228 @@
+// Package niri installs, configures and validates the niri Wayland
+// compositor on FreeBSD. It holds everything NiriSetup does to the system,
+// so the TUI in package main only has to present the results.
+package niri
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Trace, when set, is called with every command line just before the
+// command runs.
+var Trace func(line string)
+
+// maxOutputLines is how much of a command's output Run keeps. Builds from
+// ports can print far more than is worth holding in memory.
+const maxOutputLines = 1000
+
+// Written, when set, is called after WriteConfig saves the config, with the
+// backup it took of the previous one ("" if there was none).
+var Written func(backup string)
+
+// PrivilegeTool is the command that runs commands as root, "sudo" or
+// "doas". When it is "" doas is used if it is installed, sudo otherwise,
+// and neither when NiriSetup already runs as root.
+var PrivilegeTool string
+
+// geteuid is os.Geteuid, replaced in tests.
+var geteuid = os.Geteuid
+
+// RunningAsRoot reports whether NiriSetup runs as root.
+func RunningAsRoot() bool {
+	return geteuid() == 0
+}
+
+// privilegeTool returns PrivilegeTool, or the one found when it is unset:
+// "" as root, which needs neither.
+func privilegeTool() string {
+	if PrivilegeTool != "" {
+		return PrivilegeTool
+	}
+	if RunningAsRoot() {
+		return ""
+	}
+	if _, err := exec.LookPath("doas"); err == nil {
+		return "doas"
+	}
+	return "sudo"
+}
+
+// asRoot returns the command line that runs args as root: through
+// privilegeTool, unless it is "".
+func asRoot(args ...string) []string {
+	if tool := privilegeTool(); tool != "" {
+		return append([]string{tool}, args...)
+	}
+	return args
+}
+
+// rootCommand is Command for asRoot(args...).
+func rootCommand(args ...string) *exec.Cmd {
+	line := asRoot(args...)
+	return Command(line[0], line[1:]...)
+}
+
+// ErrPrivilege means sudo or doas refused to run a command for the user.
+var ErrPrivilege = errors.New("privilege escalation failed — ensure your user can run sudo/doas")
+
+// privilegeFailures are what sudo and doas print when they refuse to run a
+// command: a wrong or missing password, or a user they do not allow.
+var privilegeFailures = regexp.MustCompile(`(?i)sudo: .*password is required|incorrect password attempt|sorry, try again|is not in the sudoers file|sudo: a terminal is required|doas: authentication failed|doas: authorization required|doas: operation not permitted`)
+
+// privilegeFailed reports whether out shows sudo or doas refusing.
+func privilegeFailed(out string) bool {
+	return privilegeFailures.MatchString(out)
+}
+
+// Run runs cmd, streaming its combined output line by line to the Output
+// of ctx's Options, and returns the last maxOutputLines lines of it, noting
+// how many were dropped. It is the bounded-memory counterpart of
+// CombinedOutput.
+func Run(ctx context.Context, cmd *exec.Cmd) (string, error) {
+	out, _, err := RunSplit(ctx, cmd)
+	return out, err
+}
+
+// RunSplit is Run that also returns the last maxOutputLines lines cmd wrote
+// to stderr alone, which is where pkg and sudo say why they failed.
+func RunSplit(ctx context.Context, cmd *exec.Cmd) (out, stderr string, err error) {
+	r, w := io.Pipe()
+	var errs tailWriter
+	cmd.Stdout, cmd.Stderr = w, io.MultiWriter(w, &errs)
+	if err := cmd.Start(); err != nil {
+		return "", "", err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		w.Close()
+		done <- err
+	}()
+
+	var tail []string
+	dropped := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if OptionsFrom(ctx).Output != nil {
+			OptionsFrom(ctx).Output(line)
+		}
+		if len(tail) == maxOutputLines {
+			tail = tail[1:]
+			dropped++
+		}
+		tail = append(tail, line)
+	}
+	io.Copy(io.Discard, r) // keep the command from blocking on an overlong line
+	err = <-done
+
+	out = strings.Join(tail, "\n")
+	if dropped > 0 {
+		out = fmt.Sprintf("(%d earlier lines not kept)\n%s", dropped, out)
+	}
+	return out, errs.String(), err
+}
+
+// tailWriter keeps the last maxOutputLines lines written to it.
+type tailWriter struct {
+	lines   []string
+	partial string // the line being written, without its newline yet
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	lines := strings.Split(t.partial+string(p), "\n")
+	t.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		if len(t.lines) == maxOutputLines {
+			t.lines = t.lines[1:]
+		}
+		t.lines = append(t.lines, line)
+	}
+	return len(p), nil
+}
+
+func (t *tailWriter) String() string {
+	lines := t.lines
+	if t.partial != "" {
+		lines = append(lines, t.partial)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CommandRunner prepares the external commands the package runs. The
+// command must stop when ctx is done, as exec.CommandContext's does.
+type CommandRunner interface {
+	Command(ctx context.Context, name string, args ...string) *exec.Cmd
+}
+
+// execRunner is the CommandRunner that runs commands as they are.
+type execRunner struct{}
+
+func (execRunner) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// Runner prepares every command Command does. Tests replace it with one,
+// such as niritest.Runner, that records the commands and answers for them.
+var Runner CommandRunner = execRunner{}
+
+// Command prepares an external command, reporting it to Trace first. Every
+// command the package runs goes through here.
+func Command(name string, args ...string) *exec.Cmd {
+	return commandIn(commandContext(), name, args...)
+}
+
+// commandIn is Command for a command that stops when ctx is done: it gets
+// SIGTERM, which sudo and doas pass on to what they run, and is killed if
+// it has not exited cancelWait later.
+func commandIn(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if Trace != nil {
+		Trace(commandLine(name, args))
+	}
+	cmd := Runner.Command(ctx, name, args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = cancelWait
+	return cmd
+}
+
+// writeSystemFile writes content to a root-owned path as root, creating
+// its directory first.
+func writeSystemFile(ctx context.Context, path, content string) error {
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would write " + path + ":\n" + content)
+		return nil
+	}
+	if out, err := rootCommand("mkdir", "-p", filepath.Dir(path)).CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot create %s: %s", filepath.Dir(path), out)
+	}
+	tee := rootCommand("tee", path)
+	tee.Stdin = strings.NewReader(content)
+	if out, err := tee.CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot write %s: %s", path, out)
+	}
+	return nil
+}
+
+// commandLine renders a command the way it would be typed into a shell.
+func commandLine(name string, args []string) string {
+	line := []string{name}
+	for _, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"'") {
+			a = strconv.Quote(a)
+		}
+		line = append(line, a)
+	}
+	return strings.Join(line, " ")
+}
@@ -0,0 +1,57 @@
+// Package niritest fakes the commands package niri runs, so tests can see
+// which commands an install or a validation issues and decide what each
+// of them prints and how it exits, without pkg, sudo or niri.
+package niritest
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Reply is what a faked command prints and how it exits.
+type Reply struct {
+	Stdout string
+	Stderr string
+	Exit   int
+}
+
+// Fail is the Reply of a command that exits 1 printing nothing.
+var Fail = Reply{Exit: 1}
+
+// Runner is a niri.CommandRunner that runs none of the commands it is
+// given. It records each one and runs a shell that prints and exits as the
+// Reply of Answer says.
+type Runner struct {
+	// Answer returns the Reply for a command line, the command and its
+	// arguments joined by spaces such as "sudo pkg install -y niri". Without
+	// it every command succeeds printing nothing.
+	Answer func(line string) Reply
+
+	mu    sync.Mutex
+	lines []string
+}
+
+// Command records the command line and prepares the shell that gives its
+// Reply.
+func (r *Runner) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	line := strings.Join(append([]string{name}, args...), " ")
+	r.mu.Lock()
+	r.lines = append(r.lines, line)
+	r.mu.Unlock()
+	var reply Reply
+	if r.Answer != nil {
+		reply = r.Answer(line)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", `printf %s "$1"; printf %s "$2" >&2; exit "$3"`,
+		"sh", reply.Stdout, reply.Stderr, strconv.Itoa(reply.Exit))
+}
+
+// Lines returns the command lines prepared so far, in order.
+func (r *Runner) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...)
+}
@@ -0,0 +1,55 @@
+package niri
+
+import "context"
+
+// Options are how a caller follows a niri action and what it hands the
+// action to work with. They travel in the context the action is called
+// with, so actions running side by side each keep their own.
+type Options struct {
+	// Preview, when set, turns on dry-run mode: instead of writing the
+	// niri config or installing packages, the action calls Preview with a
+	// description of the change, a unified diff for config writes.
+	Preview func(change string)
+
+	// Output, when set, is called with every line that package installs
+	// and removals print, as they print it.
+	Output func(line string)
+
+	// Progress, when set, is called as pkg reports how far it has got
+	// installing pkg: stage is what it is doing, e.g. "Fetching
+	// niri-25.08.pkg", and current of total how much of that is done (both
+	// 0 when a new stage starts). pkg only reports this through its event
+	// pipe; when one cannot be made, Progress is not called.
+	Progress func(pkg, stage string, current, total int64)
+
+	// Installing, when set, is called as InstallPackages starts a pkg run,
+	// with the packages it installs: every missing one for its batch, then
+	// each one it installs on its own.
+	Installing func(pkgs []string)
+
+	// Current, when set, is called as the batch run of InstallPackages
+	// gets to each of its packages, so a caller can say which one pkg is
+	// installing.
+	Current func(pkg string)
+
+	// PackageFiles maps package names to local .pkg files that
+	// InstallPackage adds with pkg add instead of fetching them. pkg add
+	// looks for their dependencies in the same directory before it tries
+	// the repositories.
+	PackageFiles map[string]string
+}
+
+// optionsKey is the context key of Options.
+type optionsKey struct{}
+
+// WithOptions returns a copy of ctx that hands o to the niri actions
+// called with it.
+func WithOptions(ctx context.Context, o Options) context.Context {
+	return context.WithValue(ctx, optionsKey{}, o)
+}
+
+// OptionsFrom returns the Options ctx hands on, none if it has none.
+func OptionsFrom(ctx context.Context) Options {
+	o, _ := ctx.Value(optionsKey{}).(Options)
+	return o
+}
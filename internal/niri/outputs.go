@@ -0,0 +1,103 @@
+package niri
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ScalePresets are the output scales offered for HiDPI screens.
+var ScalePresets = []float64{1, 1.25, 1.5, 2}
+
+// Outputs returns the names of the connected outputs. Inside a running niri
+// session they come from niri msg outputs; otherwise the outputs already
+// configured in the config are used.
+func Outputs() ([]string, error) {
+	if os.Getenv("NIRI_SOCKET") != "" {
+		out, err := Command("niri", "msg", "--json", "outputs").Output()
+		if err == nil {
+			var outputs map[string]json.RawMessage
+			if err := json.Unmarshal(out, &outputs); err == nil && len(outputs) > 0 {
+				var names []string
+				for name := range outputs {
+					names = append(names, name)
+				}
+				slices.Sort(names)
+				return names, nil
+			}
+		}
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	var names []string
+	for _, n := range cfg.All("output") {
+		if len(n.Args) > 0 && !slices.Contains(names, n.Args[0]) {
+			names = append(names, n.Args[0])
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no outputs found; run NiriSetup inside niri, or add an output block to %s", ConfigPath())
+	}
+	return names, nil
+}
+
+// output returns the config's output block for name, if any.
+func output(cfg *Config, name string) *Node {
+	for _, n := range cfg.All("output") {
+		if len(n.Args) > 0 && n.Args[0] == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// formatScale renders scale as a KDL float, which niri expects even for
+// whole numbers.
+func formatScale(scale float64) string {
+	s := strconv.FormatFloat(scale, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// SetOutputScale sets the scale of the output called name, adding an output
+// block for it if needed. The config is only written if niri accepts it.
+func SetOutputScale(ctx context.Context, name string, scale float64) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	line := "scale " + formatScale(scale)
+	switch n := output(cfg, name); {
+	case n == nil:
+		err = cfg.AddNode(fmt.Sprintf("%s {\n    %s\n}", FormatNode("output", name), line))
+	case n.Child("scale") != nil:
+		err = cfg.Replace(n.Child("scale"), line)
+	default:
+		err = cfg.AddChildTo(n, line)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update niri config: %w", err)
+	}
+
+	report := []string{checked}
+	if problem, err := SaveSource(ctx, cfg.String()); err != nil {
+		if problem != "" {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(problem))
+		}
+		return report, err
+	}
+	return append(report, fmt.Sprintf("Set the scale of %s to %s", name, formatScale(scale)), "Updated "+ConfigPath()), nil
+}
@@ -0,0 +1,136 @@
+package niri
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PackagesFile lets users replace DefaultPackages with their own list: one
+// package per line, with # starting a comment.
+func PackagesFile() string {
+	return filepath.Join(configHome(), "nirisetup", "packages")
+}
+
+// PackagesJSONFile is PackagesFile as a JSON array of package names, for
+// lists other tools generate.
+func PackagesJSONFile() string {
+	return PackagesFile() + ".json"
+}
+
+// packageName matches the names pkg accepts. Starting with a letter or
+// digit, a name can never be taken for one of pkg's options.
+var packageName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// checkPackageName returns an error unless pkg matches packageName. Every
+// function that hands a package name to pkg checks it first, whatever the
+// name came from.
+func checkPackageName(pkg string) error {
+	if !packageName.MatchString(pkg) {
+		return fmt.Errorf("%q is not a valid package name: names are letters, digits and . _ + -, starting with a letter or digit", pkg)
+	}
+	return nil
+}
+
+// PackageListError lists every problem found in a packages file.
+type PackageListError struct {
+	Path     string
+	Problems []string // "line N: ..." or "entry N: ..."
+}
+
+func (e *PackageListError) Error() string {
+	return fmt.Sprintf("%s is malformed:\n%s", e.Path, strings.Join(e.Problems, "\n"))
+}
+
+// ParsePackageList reads a packages file. Duplicates are dropped with a
+// warning; any other problem makes it return a *PackageListError naming
+// every bad line, so they can all be fixed at once.
+func ParsePackageList(path, src string) (pkgs, warnings []string, err error) {
+	var problems []string
+	for i, line := range strings.Split(src, "\n") {
+		n := i + 1
+		if line != "" && strings.TrimSpace(line) == "" {
+			problems = append(problems, fmt.Sprintf("line %d: blank line contains only whitespace", n))
+			continue
+		}
+		entry, _, _ := strings.Cut(line, "#")
+		name := strings.TrimSpace(entry)
+		switch {
+		case name == "":
+			// empty line or comment
+		case !packageName.MatchString(name):
+			problems = append(problems, fmt.Sprintf("line %d: %q is not a valid package name", n, name))
+		case slices.Contains(pkgs, name):
+			warnings = append(warnings, fmt.Sprintf("%s line %d: %s is listed twice, ignoring the repeat", filepath.Base(path), n, name))
+		default:
+			pkgs = append(pkgs, name)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, warnings, &PackageListError{Path: path, Problems: problems}
+	}
+	if len(pkgs) == 0 {
+		return nil, warnings, fmt.Errorf("%s lists no packages", path)
+	}
+	return pkgs, warnings, nil
+}
+
+// ParsePackageJSON reads a packages.json file: a JSON array of package
+// names, checked like the lines of ParsePackageList.
+func ParsePackageJSON(path string, data []byte) (pkgs, warnings []string, err error) {
+	var entries []any
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, nil, &PackageListError{Path: path, Problems: []string{"not a JSON array of package names: " + err.Error()}}
+	}
+	var problems []string
+	for i, entry := range entries {
+		n := i + 1
+		name, ok := entry.(string)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("entry %d: %v is not a string", n, entry))
+		case !packageName.MatchString(name):
+			problems = append(problems, fmt.Sprintf("entry %d: %q is not a valid package name", n, name))
+		case slices.Contains(pkgs, name):
+			warnings = append(warnings, fmt.Sprintf("%s entry %d: %s is listed twice, ignoring the repeat", filepath.Base(path), n, name))
+		default:
+			pkgs = append(pkgs, name)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, warnings, &PackageListError{Path: path, Problems: problems}
+	}
+	if len(pkgs) == 0 {
+		return nil, warnings, fmt.Errorf("%s lists no packages", path)
+	}
+	return pkgs, warnings, nil
+}
+
+// PackageList returns the packages to install: those of the setup profile
+// in use if it lists any, else those in PackagesFile or PackagesJSONFile
+// if one exists, DefaultPackages otherwise. Having both files is an error,
+// since it is unclear which one is meant.
+func PackageList() (pkgs, warnings []string, err error) {
+	if setupProfile != nil && setupProfile.Packages != nil {
+		return setupProfile.Packages, nil, nil
+	}
+	data, err := os.ReadFile(PackagesFile())
+	jsonData, jsonErr := os.ReadFile(PackagesJSONFile())
+	switch {
+	case err == nil && jsonErr == nil:
+		return nil, nil, fmt.Errorf("both %s and %s exist; remove the one you do not use", PackagesFile(), PackagesJSONFile())
+	case jsonErr == nil:
+		return ParsePackageJSON(PackagesJSONFile(), jsonData)
+	case !os.IsNotExist(jsonErr):
+		return nil, nil, fmt.Errorf("failed to read package list: %w", jsonErr)
+	case os.IsNotExist(err):
+		return DefaultPackages, nil, nil
+	case err != nil:
+		return nil, nil, fmt.Errorf("failed to read package list: %w", err)
+	}
+	return ParsePackageList(PackagesFile(), string(data))
+}
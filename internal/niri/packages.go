@@ -0,0 +1,636 @@
+package niri
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPackages is the set of packages a complete niri desktop needs.
+var DefaultPackages = []string{"niri", "wlroots", "xwayland-satellite", "seatd", "waybar", "grim", "jq", "wofi", "alacritty", "pam_xdg", "fuzzel", "swaylock", "foot", "wlsunset", "swaybg", "mako", "swayidle"}
+
+// ClipboardPackages are installed by ConfigureClipboard.
+var ClipboardPackages = []string{"wl-clipboard", "cliphist"}
+
+// ManagedPackages returns every package NiriSetup may install.
+func ManagedPackages() []string {
+	pkgs := slices.Concat(DefaultPackages, ClipboardPackages, ScreenshotPackages, FcitxPackages, PortalPackages, AudioPackages, MediaPackages)
+	for _, im := range InputMethods {
+		pkgs = append(pkgs, im.Package)
+	}
+	return pkgs
+}
+
+// PackageError reports a package that pkg failed to install.
+type PackageError struct {
+	Package string
+	Output  string // what pkg printed
+	Stderr  string // the part of Output pkg printed to stderr, saying why
+	Denied  bool   // sudo refused to run pkg at all
+}
+
+func (e *PackageError) Error() string {
+	if e.Denied {
+		return fmt.Sprintf("failed to install %s: %v", e.Package, ErrPrivilege)
+	}
+	return fmt.Sprintf("failed to install %s: %s", e.Package, e.Reason())
+}
+
+// Reason is why the package failed: what pkg printed to stderr, or all of
+// its output when it printed nothing there.
+func (e *PackageError) Reason() string {
+	return strings.TrimSpace(cmp.Or(e.Stderr, e.Output))
+}
+
+// Unwrap makes errors.Is(err, ErrPrivilege) true for a denied install.
+func (e *PackageError) Unwrap() error {
+	if e.Denied {
+		return ErrPrivilege
+	}
+	return nil
+}
+
+// InstallError lists the packages InstallPackages could not install, in
+// order.
+type InstallError struct {
+	Failed []*PackageError
+}
+
+func (e *InstallError) Error() string {
+	names := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		names[i] = f.Package
+	}
+	return "failed to install " + strings.Join(names, ", ")
+}
+
+// Unwrap lets errors.As find each *PackageError.
+func (e *InstallError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f
+	}
+	return errs
+}
+
+// installCommand is the command line that installs pkg, from its file in
+// the PackageFiles of ctx's Options if it has one.
+func installCommand(ctx context.Context, pkg string) []string {
+	if file := OptionsFrom(ctx).PackageFiles[pkg]; file != "" {
+		return asRoot("pkg", "add", file)
+	}
+	return asRoot("pkg", "install", "-y", pkg)
+}
+
+// batchCommand is the command line that installs pkgs in one pkg run, so
+// pkg works out their dependencies together and fetches the ones they
+// share once.
+func batchCommand(pkgs []string) []string {
+	return asRoot(append([]string{"pkg", "install", "-y"}, pkgs...)...)
+}
+
+// toBatch returns the packages of pkgs that InstallPackages installs in
+// one batch: those without a file in the PackageFiles of ctx's Options,
+// when there is more than one.
+func toBatch(ctx context.Context, pkgs []string) []string {
+	var batch []string
+	for _, pkg := range pkgs {
+		if OptionsFrom(ctx).PackageFiles[pkg] == "" {
+			batch = append(batch, pkg)
+		}
+	}
+	if len(batch) < 2 {
+		return nil
+	}
+	return batch
+}
+
+// pkgStep matches the line pkg prints as it gets to a package, such as
+// "[2/5] Installing niri-25.08..." or "[3/5] Upgrading jq from 1.6 to
+// 1.7...", capturing what it does and the package, with its version
+// unless it upgrades it.
+var pkgStep = regexp.MustCompile(`^\[\d+/\d+\] (Installing|Reinstalling|Upgrading) (\S+?)(?:\.\.\.)?(?: from |$)`)
+
+// stepPackage returns the name of the package line shows pkg getting to,
+// if it is such a line.
+func stepPackage(line string) (string, bool) {
+	m := pkgStep.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	if m[1] == "Upgrading" {
+		return m[2], true
+	}
+	i := strings.LastIndex(m[2], "-")
+	if i <= 0 {
+		return "", false
+	}
+	return m[2][:i], true
+}
+
+// installBatch runs batchCommand for pkgs once, without retries: if it
+// fails, InstallPackages installs the packages it left out one at a time,
+// retrying each. pkg installs them one after the other, so installed is
+// called for each as its output moves on to the next package, or the run
+// ends.
+func installBatch(ctx context.Context, pkgs []string, installed func(pkg string)) error {
+	o := OptionsFrom(ctx)
+	if o.Output != nil && o.Preview == nil {
+		o.Output(fmt.Sprintf("Installing %d packages and their dependencies...", len(pkgs)))
+	}
+	var current string // the package pkg is at
+	output := o.Output
+	o.Output = func(line string) {
+		if output != nil {
+			output(line)
+		}
+		name, ok := stepPackage(line)
+		if !ok || name == current {
+			return
+		}
+		if slices.Contains(pkgs, current) {
+			installed(current)
+		}
+		current = name
+		if slices.Contains(pkgs, name) && o.Current != nil {
+			o.Current(name)
+		}
+	}
+	err := runPkgInstall(WithOptions(ctx, o), strings.Join(pkgs, " "), batchCommand(pkgs), 1, InstallTimeout*time.Duration(len(pkgs)))
+	if err == nil && slices.Contains(pkgs, current) {
+		installed(current)
+	}
+	return err
+}
+
+// installAttempts and installBackoff bound how hard InstallPackage tries
+// before giving up on a flaky mirror: the first try and three retries,
+// waiting about 1s, 2s and 4s before them.
+const (
+	installAttempts = 4
+	installBackoff  = time.Second
+)
+
+// InstallTimeout is how long one pkg install may run before the package
+// fails as timed out, so a wedged mirror cannot hang the install; 0 waits
+// as long as pkg takes. It is set with --install-timeout.
+var InstallTimeout = 5 * time.Minute
+
+// pkgLockWait and pkgLockPoll are how long installPackage waits for
+// another pkg, such as a periodic update, to let go of the package
+// database, and how often it tries again meanwhile.
+var (
+	pkgLockWait = 5 * time.Minute
+	pkgLockPoll = 5 * time.Second
+)
+
+// pkgLocked reports whether pkg failed because another pkg holds the lock
+// on its database.
+func pkgLocked(out string) bool {
+	return strings.Contains(out, "Cannot get an exclusive lock on a database") || strings.Contains(out, "Cannot get an advisory lock on a database")
+}
+
+// InstallPackage installs a single package with pkg, retrying a few times
+// since a failure is most often the mirror.
+func InstallPackage(ctx context.Context, pkg string) error {
+	return installPackage(cancellable(ctx), pkg)
+}
+
+// installPackage is InstallPackage under ctx.
+func installPackage(ctx context.Context, pkg string) error {
+	if err := checkPackageName(pkg); err != nil {
+		return &PackageError{Package: pkg, Stderr: err.Error()}
+	}
+	return runPkgInstall(ctx, pkg, installCommand(ctx, pkg), installAttempts, InstallTimeout)
+}
+
+// runPkgInstall runs args, a pkg command line installing what label names,
+// up to attempts times. Each run may take timeout, 0 for as long as pkg
+// takes, and waiting for another pkg to let go of the database does not
+// use up an attempt.
+func runPkgInstall(ctx context.Context, label string, args []string, attempts int, timeout time.Duration) error {
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would run " + strings.Join(args, " "))
+		return nil
+	}
+	attempt := 0
+	return retry(attempts, installBackoff, func() error {
+		if err := cancelled(ctx); err != nil {
+			return permanent(err)
+		}
+		attempt++
+		if attempt > 1 && OptionsFrom(ctx).Output != nil {
+			OptionsFrom(ctx).Output(fmt.Sprintf("Retrying %s (attempt %d of %d)...", label, attempt, attempts))
+		}
+		cmd := args
+		if OptionsFrom(ctx).Progress != nil {
+			// without the pipe pkg still installs, just without progress
+			if pipe, err := openEventPipe(ctx, label); err == nil {
+				defer pipe.close()
+				cmd = pipe.command(args)
+			}
+		}
+		timedOut := false
+		run := func() (string, string, error) {
+			runCtx, cancel := ctx, context.CancelFunc(func() {})
+			if timeout > 0 {
+				runCtx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			defer cancel()
+			out, stderr, err := RunSplit(ctx, commandIn(runCtx, cmd[0], cmd[1:]...))
+			timedOut = ctx.Err() == nil && runCtx.Err() != nil
+			return out, stderr, err
+		}
+		out, stderr, err := run()
+		// another pkg is busy, which is no reason to count an attempt
+		for waited := time.Duration(0); err != nil && pkgLocked(out) && waited < pkgLockWait && cancelled(ctx) == nil; waited += pkgLockPoll {
+			if waited == 0 && OptionsFrom(ctx).Output != nil {
+				OptionsFrom(ctx).Output(fmt.Sprintf("Waiting for pkg lock: another pkg is using the package database (up to %s)...", pkgLockWait))
+			}
+			sleep(pkgLockPoll)
+			out, stderr, err = run()
+		}
+		switch {
+		case err == nil:
+			return nil
+		case cancelled(ctx) != nil:
+			return permanent(ErrCancelled)
+		case timedOut:
+			// a stuck mirror would only hang the retries too
+			return permanent(&PackageError{Package: label, Output: out, Stderr: fmt.Sprintf("timed out after %s; the mirror may be stuck, so try another (Benchmark Mirrors) or a longer --install-timeout", timeout)})
+		case pkgLocked(out):
+			return permanent(&PackageError{Package: label, Output: out, Stderr: fmt.Sprintf("another pkg kept the package database locked for %s; wait for it to finish (pgrep -lf pkg), then retry", pkgLockWait)})
+		case privilegeFailed(out):
+			// asking again will not change sudo's mind
+			return permanent(&PackageError{Package: label, Output: out, Stderr: stderr, Denied: true})
+		}
+		return &PackageError{Package: label, Output: out, Stderr: stderr}
+	})
+}
+
+// PackageInstalled reports whether pkg is installed. pkg info -e exits
+// non-zero when it is not; a pkg that cannot run at all counts as not
+// installed too, so an install goes ahead and reports the real problem, as
+// does a name that is not a package name.
+func PackageInstalled(pkg string) bool {
+	return checkPackageName(pkg) == nil && Command("pkg", "info", "-e", pkg).Run() == nil
+}
+
+// InstallPackages installs pkgs, skipping those already installed, and
+// calls done as each one is through, saying whether it was skipped and
+// why it failed, if it did: first for the skipped ones, then as each
+// missing one is installed. The missing packages are installed in one pkg
+// run first, so their dependencies are worked out and fetched once; each
+// one that run did not install, as when one bad package fails it, is then
+// installed on its own, in the order of pkgs. The ones it installs are
+// recorded for Uninstall in the Manifest, and so are the ones already
+// there, which it must leave alone. A package that fails does not stop
+// the others, which are still installed, and the result is an
+// *InstallError listing every failure; only sudo refusing to run pkg stops
+// the install straight away, since every other package would fail the
+// same way, and CancelCommands, which returns ErrCancelled without calling
+// done for the packages not through yet.
+func InstallPackages(ctx context.Context, pkgs []string, done func(pkg string, skipped bool, err error)) error {
+	ctx = cancellable(ctx)
+	o := OptionsFrom(ctx)
+	report := func(pkg string, skipped bool, err error) {
+		if done != nil {
+			done(pkg, skipped, err)
+		}
+	}
+	var missing []string
+	for _, pkg := range pkgs {
+		if !PackageInstalled(pkg) {
+			missing = append(missing, pkg)
+			continue
+		}
+		if o.Preview == nil {
+			recordPresent(ctx, pkg)
+		}
+		report(pkg, true, nil)
+	}
+	var installed []string
+	succeeded := func(pkg string) {
+		if o.Preview == nil {
+			recordInstalled(ctx, pkg)
+		}
+		installed = append(installed, pkg)
+		report(pkg, false, nil)
+	}
+	// a bad name fails in installPackage, without going into the batch
+	batch := toBatch(ctx, slices.DeleteFunc(slices.Clone(missing), func(pkg string) bool { return checkPackageName(pkg) != nil }))
+	var denied *PackageError
+	if len(batch) > 0 {
+		if o.Installing != nil {
+			o.Installing(batch)
+		}
+		err := installBatch(ctx, batch, succeeded)
+		var perr *PackageError
+		switch {
+		case errors.Is(err, ErrCancelled):
+			return ErrCancelled
+		case errors.As(err, &perr) && perr.Denied:
+			denied = perr
+		default:
+			// a dry run prints nothing, and a pkg that fails may have
+			// installed more than it said
+			for _, pkg := range batch {
+				if !slices.Contains(installed, pkg) && (o.Preview != nil || PackageInstalled(pkg)) {
+					succeeded(pkg)
+				}
+			}
+			if perr != nil && o.Output != nil {
+				reason, _, _ := strings.Cut(perr.Reason(), "\n")
+				o.Output(fmt.Sprintf("Installing them together failed (%s), so each is installed on its own", reason))
+			}
+		}
+	}
+	var failed []*PackageError
+	for _, pkg := range missing {
+		if slices.Contains(installed, pkg) {
+			continue
+		}
+		var err error
+		if denied != nil {
+			err = &PackageError{Package: pkg, Output: denied.Output, Stderr: denied.Stderr, Denied: true}
+		} else {
+			if o.Installing != nil {
+				o.Installing([]string{pkg})
+			}
+			err = installPackage(ctx, pkg)
+		}
+		if err == nil {
+			succeeded(pkg)
+			continue
+		}
+		if cancelled(ctx) != nil {
+			return ErrCancelled
+		}
+		var perr *PackageError
+		if !errors.As(err, &perr) {
+			perr = &PackageError{Package: pkg, Output: err.Error()}
+		}
+		failed = append(failed, perr)
+		report(pkg, false, err)
+		if perr.Denied {
+			break
+		}
+	}
+	if len(failed) > 0 {
+		return &InstallError{Failed: failed}
+	}
+	return nil
+}
+
+// MissingPackages returns the packages in pkgs that the configured
+// repositories do not have, so a typo or a package missing from the branch
+// is caught before an install starts.
+func MissingPackages(pkgs []string) ([]string, error) {
+	var missing []string
+	for _, pkg := range pkgs {
+		if checkPackageName(pkg) != nil {
+			missing = append(missing, pkg) // no repository has it
+			continue
+		}
+		out, err := Command("pkg", "rquery", "%n", pkg).Output()
+		var exit *exec.ExitError
+		if err != nil && !errors.As(err, &exit) {
+			return nil, fmt.Errorf("failed to query the repositories: %w", err)
+		}
+		// pkg rquery exits 1 without output when nothing matches
+		if strings.TrimSpace(string(out)) == "" {
+			missing = append(missing, pkg)
+		}
+	}
+	return missing, nil
+}
+
+// InstallScript returns a shell script running exactly the commands
+// InstallPackages would run for pkgs, so an admin can review the install and
+// run it later.
+func InstallScript(ctx context.Context, pkgs []string) string {
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&script, "# Generated by NiriSetup on %s.\n", time.Now().Format(time.RFC1123))
+	script.WriteString("# Installs the packages for a niri desktop, stopping at the first failure.\n")
+	script.WriteString("set -e\n\n")
+	line := func(args []string) {
+		var words []string
+		for _, w := range args {
+			words = append(words, shellQuote(w))
+		}
+		script.WriteString(strings.Join(words, " ") + "\n")
+	}
+	batch := toBatch(ctx, pkgs)
+	if len(batch) > 0 {
+		line(batchCommand(batch))
+	}
+	for _, pkg := range pkgs {
+		if !slices.Contains(batch, pkg) {
+			line(installCommand(ctx, pkg))
+		}
+	}
+	return script.String()
+}
+
+// WriteInstallScript saves InstallScript(pkgs) to path as an executable.
+func WriteInstallScript(ctx context.Context, path string, pkgs []string) error {
+	if err := writeFile(ctx, path, []byte(InstallScript(ctx, pkgs)), 0755); err != nil {
+		return fmt.Errorf("failed to write install script: %w", err)
+	}
+	return nil
+}
+
+// shellQuote quotes s for sh when it contains anything but plain word
+// characters.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.+/=:") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// InstalledPackage is a managed package pkg reports as installed.
+type InstalledPackage struct {
+	Name      string
+	Version   string
+	Installed time.Time
+}
+
+// InstalledPackages lists the installed packages out of ManagedPackages, in
+// the order they appear there.
+func InstalledPackages() ([]InstalledPackage, error) {
+	out, err := Command("pkg", "query", "%n\t%v\t%t").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages: %w", err)
+	}
+	found := map[string]InstalledPackage{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		p := InstalledPackage{Name: fields[0], Version: fields[1]}
+		if ts, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			p.Installed = time.Unix(ts, 0)
+		}
+		found[p.Name] = p
+	}
+	var pkgs []InstalledPackage
+	for _, name := range ManagedPackages() {
+		if p, ok := found[name]; ok {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs, nil
+}
+
+// RemovePackage deletes a single package with pkg.
+func RemovePackage(ctx context.Context, pkg string) error {
+	if err := checkPackageName(pkg); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", pkg, err)
+	}
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would remove " + pkg)
+		return nil
+	}
+	out, err := Run(ctx, rootCommand("pkg", "delete", "-y", pkg))
+	if err != nil {
+		return fmt.Errorf("failed to remove %s: %s", pkg, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// VerifyPackages runs pkg check on the installed managed packages, checking
+// their files against the recorded checksums and that their dependencies are
+// installed. It returns a check for each and the packages found damaged.
+func VerifyPackages() ([]Check, []string, error) {
+	installed, err := InstalledPackages()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(installed) == 0 {
+		return nil, nil, fmt.Errorf("none of the packages NiriSetup manages are installed")
+	}
+	var names []string
+	for _, p := range installed {
+		names = append(names, p.Name)
+	}
+
+	var checks []Check
+	var damaged []string
+	for _, c := range []struct{ name, flag string }{
+		{"Package checksums match", "-s"},
+		{"Package dependencies installed", "-d"},
+	} {
+		// -n keeps the dependency check from offering to fix anything
+		args := append([]string{"check", c.flag, "-n", "-q"}, names...)
+		out, err := Command("pkg", args...).CombinedOutput()
+		bad := damagedPackages(names, string(out))
+		check := Check{Name: c.name, OK: err == nil && len(bad) == 0}
+		switch {
+		case len(bad) > 0:
+			check.Detail = strings.Join(bad, ", ")
+		case err != nil:
+			check.Detail = strings.TrimSpace(string(out))
+		}
+		checks = append(checks, check)
+		for _, pkg := range bad {
+			if !slices.Contains(damaged, pkg) {
+				damaged = append(damaged, pkg)
+			}
+		}
+	}
+	return checks, damaged, nil
+}
+
+// damagedPackages picks the packages out of pkg check output. Each problem
+// line starts with the package, optionally followed by its version.
+func damagedPackages(names []string, out string) []string {
+	var bad []string
+	for _, line := range strings.Split(out, "\n") {
+		for _, name := range names {
+			rest, ok := strings.CutPrefix(line, name)
+			if ok && (strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, " ")) && !slices.Contains(bad, name) {
+				bad = append(bad, name)
+			}
+		}
+	}
+	return bad
+}
+
+// ReinstallPackages forces pkg to install pkgs again, replacing damaged
+// files.
+func ReinstallPackages(ctx context.Context, pkgs []string) ([]string, error) {
+	var report []string
+	for _, pkg := range pkgs {
+		if err := checkPackageName(pkg); err != nil {
+			return report, &PackageError{Package: pkg, Stderr: err.Error()}
+		}
+		if OptionsFrom(ctx).Preview != nil {
+			OptionsFrom(ctx).Preview("Would reinstall " + pkg)
+			continue
+		}
+		out, stderr, err := RunSplit(ctx, rootCommand("pkg", "install", "-f", "-y", pkg))
+		if err != nil {
+			return report, &PackageError{Package: pkg, Output: out, Stderr: stderr}
+		}
+		report = append(report, "Reinstalled "+pkg)
+	}
+	return report, nil
+}
+
+// DepNode is a package in a dependency tree.
+type DepNode struct {
+	Name      string
+	Installed bool
+	Deps      []*DepNode
+	Seen      bool // already shown earlier in the tree, so Deps is left empty
+}
+
+// DependencyTree asks the repository what pkg depends on, recursively, and
+// marks which packages are already installed. Each package's dependencies
+// are only expanded the first time it appears.
+func DependencyTree(pkg string) (*DepNode, error) {
+	if err := checkPackageName(pkg); err != nil {
+		return nil, err
+	}
+	out, err := Command("pkg", "query", "%n").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages: %w", err)
+	}
+	installed := map[string]bool{}
+	for _, name := range strings.Fields(string(out)) {
+		installed[name] = true
+	}
+
+	seen := map[string]bool{}
+	var walk func(name string) (*DepNode, error)
+	walk = func(name string) (*DepNode, error) {
+		n := &DepNode{Name: name, Installed: installed[name], Seen: seen[name]}
+		if n.Seen {
+			return n, nil
+		}
+		seen[name] = true
+		out, err := Command("pkg", "rquery", "%dn", name).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up the dependencies of %s: %w", name, err)
+		}
+		for _, dep := range strings.Fields(string(out)) {
+			child, err := walk(dep)
+			if err != nil {
+				return nil, err
+			}
+			n.Deps = append(n.Deps, child)
+		}
+		return n, nil
+	}
+	return walk(pkg)
+}
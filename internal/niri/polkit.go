@@ -0,0 +1,96 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// PolkitAgent is a polkit authentication agent: the program that shows the
+// password dialog when an application asks for more privileges.
+type PolkitAgent struct {
+	Name    string
+	Package string
+	Path    string // the agent binary the package installs
+}
+
+// PolkitAgents are the agents NiriSetup can set up, lightest first.
+var PolkitAgents = []PolkitAgent{
+	{Name: "LXQt", Package: "lxqt-policykit", Path: "/usr/local/bin/lxqt-policykit-agent"},
+	{Name: "GNOME", Package: "polkit-gnome", Path: "/usr/local/libexec/polkit-gnome-authentication-agent-1"},
+	{Name: "MATE", Package: "mate-polkit", Path: "/usr/local/libexec/polkit-mate-authentication-agent-1"},
+}
+
+// Installed reports whether pkg has the agent installed.
+func (a PolkitAgent) Installed() bool {
+	return PackageInstalled(a.Package)
+}
+
+// PolkitAgentChoices returns the agents that are installed or can be
+// installed from the repositories, and which of them are installed.
+func PolkitAgentChoices() (agents []PolkitAgent, installed []bool, err error) {
+	var names []string
+	for _, a := range PolkitAgents {
+		names = append(names, a.Package)
+	}
+	missing, err := MissingPackages(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, a := range PolkitAgents {
+		has := a.Installed()
+		if has || !slices.Contains(missing, a.Package) {
+			agents = append(agents, a)
+			installed = append(installed, has)
+		}
+	}
+	return agents, installed, nil
+}
+
+// ConfigurePolkitAgent installs agent if needed and starts it with niri,
+// in place of any other agent from PolkitAgents the config starts.
+func ConfigurePolkitAgent(ctx context.Context, agent PolkitAgent) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	if agent.Installed() {
+		report = append(report, agent.Package+" is already installed")
+	} else {
+		if err := InstallPackage(ctx, agent.Package); err != nil {
+			return report, err
+		}
+		report = append(report, "Installed "+agent.Package)
+	}
+
+	if SpawnAtStartup(cfg, agent.Path) != nil {
+		report = append(report, "niri already starts the "+agent.Name+" polkit agent")
+	} else {
+		for _, other := range PolkitAgents {
+			if n := SpawnAtStartup(cfg, other.Path); n != nil {
+				if err := cfg.Remove(n); err != nil {
+					return report, fmt.Errorf("failed to update niri config: %w", err)
+				}
+				report = append(report, "Removed spawn-at-startup: "+other.Path)
+			}
+		}
+		if err := cfg.AddNode(FormatNode("spawn-at-startup", agent.Path)); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, "Added spawn-at-startup: "+agent.Path)
+	}
+
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return append(report,
+		fmt.Sprintf("The %s agent shows polkit password prompts from the next niri session", agent.Name),
+		"Updated "+ConfigPath(),
+	), nil
+}
@@ -0,0 +1,97 @@
+package niri
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PortalPackages are xdg-desktop-portal and its wlroots backend, which is
+// what browsers and video call apps go through to share the screen.
+var PortalPackages = []string{"xdg-desktop-portal", "xdg-desktop-portal-wlr"}
+
+// portalEnv are the variables spawn-at-startup hands to D-Bus, so the
+// portal it starts knows the Wayland display and picks portalsConf.
+var portalEnv = []string{"WAYLAND_DISPLAY", "XDG_CURRENT_DESKTOP"}
+
+// portalsConf selects the wlr backend for screen casts and screenshots.
+// wlr implements nothing else, so the other portals are left to whichever
+// backend is installed.
+const portalsConf = `[preferred]
+default=*
+org.freedesktop.impl.portal.ScreenCast=wlr
+org.freedesktop.impl.portal.Screenshot=wlr
+`
+
+// PortalsConfPath is where xdg-desktop-portal reads the user's choice of
+// backends.
+func PortalsConfPath() string {
+	return filepath.Join(configHome(), "xdg-desktop-portal", "portals.conf")
+}
+
+// ConfigurePortal installs PortalPackages, writes PortalsConfPath to use
+// the wlr backend and has niri pass its environment to D-Bus at startup,
+// so the portal can be started when an app asks to share the screen. An
+// existing portals.conf that says something else is kept next to the new
+// one with a .bak suffix.
+func ConfigurePortal(ctx context.Context) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	for _, pkg := range PortalPackages {
+		if err := InstallPackage(ctx, pkg); err != nil {
+			return report, err
+		}
+		report = append(report, "Installed "+pkg)
+	}
+
+	path := PortalsConfPath()
+	old, err := os.ReadFile(path)
+	switch {
+	case err == nil && bytes.Equal(old, []byte(portalsConf)):
+		report = append(report, path+" already selects the wlr backend")
+	case err != nil && !os.IsNotExist(err):
+		return report, fmt.Errorf("failed to read %s: %w", path, err)
+	case OptionsFrom(ctx).Preview != nil:
+		OptionsFrom(ctx).Preview("Would write " + path)
+	default:
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return report, fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+		}
+		if old != nil {
+			if err := writeFile(ctx, path+".bak", old, 0644); err != nil {
+				return report, fmt.Errorf("failed to back up %s: %w", path, err)
+			}
+			report = append(report, "Saved the old "+path+" as "+path+".bak")
+		}
+		if err := writeFile(ctx, path, []byte(portalsConf), 0644); err != nil {
+			return report, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		report = append(report, "Wrote "+path+" selecting the wlr backend for screen sharing")
+	}
+
+	args := slices.Concat([]string{"dbus-update-activation-environment"}, portalEnv)
+	if HasSpawnAtStartup(cfg, args...) {
+		report = append(report, "niri already passes its environment to D-Bus")
+	} else {
+		if err := cfg.AddNode(FormatNode("spawn-at-startup", args...)); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		if err := WriteConfig(ctx, cfg); err != nil {
+			return report, fmt.Errorf("failed to write niri config: %w", err)
+		}
+		report = append(report, "Added spawn-at-startup: "+strings.Join(args, " "), "Updated "+ConfigPath())
+	}
+	return append(report, "Log out and start niri again for screen sharing to work"), nil
+}
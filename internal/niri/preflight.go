@@ -0,0 +1,80 @@
+package niri
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// InstallChecks probes what installing packages needs before anything is
+// tried: pkg itself, the privilege tool (doas or sudo), and that tool
+// letting the user run commands without a password prompt NiriSetup could
+// not answer. Each failure says how to fix it. As root, with no
+// privilege tool to use, only pkg is checked.
+func InstallChecks() []Check {
+	if privilegeTool() == "" {
+		return []Check{pkgCheck()}
+	}
+	checks := []Check{pkgCheck(), privilegeCheck()}
+	if checks[1].OK {
+		checks = append(checks, privilegeAccessCheck())
+	}
+	return checks
+}
+
+// InstallBlocked reports whether any of checks failed, which would make
+// every package install fail.
+func InstallBlocked(checks []Check) bool {
+	return LaunchBlocked(checks)
+}
+
+// pkgCheck checks that pkg(8) is there.
+func pkgCheck() Check {
+	c := Check{Name: "pkg available"}
+	if _, err := exec.LookPath("pkg"); err != nil {
+		c.Detail = "pkg not found; NiriSetup installs with FreeBSD's pkg, so it needs FreeBSD or GhostBSD"
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// privilegeCheck checks that the privilege tool is installed.
+func privilegeCheck() Check {
+	tool := privilegeTool()
+	c := Check{Name: tool + " available"}
+	if _, err := exec.LookPath(tool); err != nil {
+		c.Detail = tool + " not found; as root, install doas (permit :wheel in /usr/local/etc/doas.conf) or sudo (allow the wheel group with visudo)"
+		if PrivilegeTool == "" {
+			c.Detail = "neither doas nor sudo found; as root, install doas (permit :wheel in /usr/local/etc/doas.conf) or sudo (allow the wheel group with visudo)"
+		}
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// privilegeAccessCheck runs the privilege tool with -n true, which fails
+// instead of asking for a password.
+func privilegeAccessCheck() Check {
+	tool := privilegeTool()
+	c := Check{Name: tool + " usable"}
+	out, err := Command(tool, "-n", "true").CombinedOutput()
+	text := strings.TrimSpace(string(out))
+	switch {
+	case err == nil:
+		c.OK = true
+	case strings.Contains(text, "password is required"):
+		c.Detail = "sudo wants your password, which NiriSetup cannot ask for; run sudo -v in this terminal first, then try again"
+	case strings.Contains(text, "Authorization required"):
+		c.Detail = "doas wants your password, which NiriSetup cannot ask for; add persist to your rule in /usr/local/etc/doas.conf and run doas true in this terminal first, or use nopass"
+	case strings.Contains(text, "not in the sudoers") || strings.Contains(text, "not allowed"):
+		c.Detail = "you may not use sudo; as root, join the wheel group with pw groupmod wheel -m $USER and allow it with visudo"
+	case strings.Contains(text, "Operation not permitted"):
+		c.Detail = "you may not use doas; as root, join the wheel group with pw groupmod wheel -m $USER and add permit :wheel to /usr/local/etc/doas.conf"
+	case text != "":
+		c.Detail = text
+	default:
+		c.Detail = err.Error()
+	}
+	return c
+}
@@ -0,0 +1,144 @@
+package niri
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// SeatdSocket is where seatd listens; niri gets its seat, the right to use
+// the GPU and input devices, through it.
+const SeatdSocket = "/var/run/seatd.sock"
+
+// LaunchChecks probes what niri needs to start: a private XDG_RUNTIME_DIR
+// for its Wayland socket, a running seatd and the right to talk to it.
+// Each failure says how to fix it. Only a failure marked Warn lets niri
+// start anyway.
+func LaunchChecks() []Check {
+	return []Check{runtimeDirCheck(), seatdCheck(), seatAccessCheck()}
+}
+
+// SystemChecks checks whether the system is ready to run niri: niri and
+// wlroots installed, seatd enabled at boot, LaunchChecks and the user in
+// SeatGroup.
+func SystemChecks() []Check {
+	return slices.Concat([]Check{installedCheck("niri"), installedCheck("wlroots"), seatdEnabledCheck()}, LaunchChecks(), []Check{seatGroupCheck()})
+}
+
+// installedCheck checks that pkg is installed.
+func installedCheck(pkg string) Check {
+	c := Check{Name: pkg + " installed"}
+	if !PackageInstalled(pkg) {
+		c.Detail = "not installed; Install Niri installs it"
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// seatdEnabledCheck checks that rc.conf starts seatd at boot.
+func seatdEnabledCheck() Check {
+	c := Check{Name: "seatd enabled"}
+	out, err := Command("sysrc", "-n", "seatd_enable").Output()
+	if err != nil || !strings.EqualFold(strings.TrimSpace(string(out)), "YES") {
+		c.Detail = "not started at boot; enable it with sudo sysrc seatd_enable=YES"
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// seatGroupCheck checks that the user is in SeatGroup, which seatd only
+// lets in.
+func seatGroupCheck() Check {
+	c := Check{Name: "In the " + SeatGroup + " group"}
+	u, err := user.Current()
+	switch {
+	case err != nil:
+		c.Detail = err.Error()
+	case !inGroup(u, SeatGroup):
+		c.Detail = fmt.Sprintf("%s is not; join it with sudo pw groupmod %s -m %s, then log in again", u.Username, SeatGroup, u.Username)
+	default:
+		c.OK = true
+	}
+	return c
+}
+
+// runtimeDirCheck checks that XDG_RUNTIME_DIR is a directory of the user's
+// own that nobody else can get into.
+func runtimeDirCheck() Check {
+	dir := RuntimeDir()
+	c := Check{Name: "XDG_RUNTIME_DIR " + dir}
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		c.Detail = fmt.Sprintf("missing; create it with mkdir -m 0700 %s", dir)
+		return c
+	case err != nil:
+		c.Detail = err.Error()
+		return c
+	case !info.IsDir():
+		c.Detail = "not a directory; remove it and run NiriSetup again"
+		return c
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+		c.Detail = fmt.Sprintf("owned by uid %d, not you (uid %d); remove it and run NiriSetup again", st.Uid, os.Geteuid())
+		return c
+	}
+	if perm := info.Mode().Perm(); perm&0700 != 0700 {
+		c.Detail = fmt.Sprintf("mode %04o keeps even you out; fix it with chmod 0700 %s", perm, dir)
+		return c
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		c.Warn = true
+		c.Detail = fmt.Sprintf("mode %04o lets other users in; fix it with chmod 0700 %s", perm, dir)
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// seatdCheck checks that seatd is running, by its socket.
+func seatdCheck() Check {
+	c := Check{Name: "seatd running"}
+	if _, err := os.Stat(SeatdSocket); err != nil {
+		c.Detail = fmt.Sprintf("no %s; start it with sudo sysrc seatd_enable=YES && sudo service seatd start", SeatdSocket)
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// seatAccessCheck connects to seatd, which only lets in the members of
+// the group it was started for, video by default.
+func seatAccessCheck() Check {
+	c := Check{Name: "Seat accessible"}
+	conn, err := net.DialTimeout("unix", SeatdSocket, time.Second)
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		c.Detail = "seatd refused you; join the video group with sudo pw groupmod video -m $USER, then log in again"
+	case err != nil:
+		c.Detail = fmt.Sprintf("could not connect to %s: %v", SeatdSocket, err)
+	default:
+		conn.Close()
+		c.OK = true
+	}
+	return c
+}
+
+// LaunchBlocked reports whether any of checks failed in a way that keeps
+// niri from starting.
+func LaunchBlocked(checks []Check) bool {
+	for _, c := range checks {
+		if !c.OK && !c.Warn {
+			return true
+		}
+	}
+	return false
+}
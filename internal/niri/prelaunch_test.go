@@ -0,0 +1,279 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeDirCheck(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "runtime")
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	for _, tt := range []struct {
+		name         string
+		prepare      func() error
+		wantOK, warn bool
+	}{
+		{name: "missing", prepare: func() error { return nil }},
+		{name: "open to others", prepare: func() error { return os.Mkdir(dir, 0755) }, warn: true},
+		{name: "private", prepare: func() error { return os.Chmod(dir, 0700) }, wantOK: true},
+	} {
+		if err := tt.prepare(); err != nil {
+			t.Fatal(err)
+		}
+		c := runtimeDirCheck()
+		if c.OK != tt.wantOK || c.Warn != tt.warn {
+			t.Errorf("%s: got %s (warn %v), want ok %v warn %v", tt.name, c, c.Warn, tt.wantOK, tt.warn)
+		}
+	}
+}
+
+// stubEUID makes NiriSetup take uid for its own.
+func stubEUID(t *testing.T, uid int) {
+	geteuid = func() int { return uid }
+	t.Cleanup(func() { geteuid = os.Geteuid })
+}
+
+func TestSetUpRuntimeDir(t *testing.T) {
+	stubEUID(t, 1001)
+	userDirs := t.TempDir()
+	old := userRuntimeDirs
+	userRuntimeDirs = userDirs
+	t.Cleanup(func() { userRuntimeDirs = old })
+	standard := filepath.Join(userDirs, "1001")
+	if err := os.Mkdir(standard, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	own := filepath.Join(t.TempDir(), "runtime")
+	if err := os.Mkdir(own, 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", own)
+	if chosen, report, err := SetUpRuntimeDir(context.Background()); err != nil || len(report) > 0 || os.Getenv("XDG_RUNTIME_DIR") != own || !strings.HasSuffix(chosen, "as the session set it") {
+		t.Errorf("a valid XDG_RUNTIME_DIR gave %q, %q, %v and %s", chosen, report, err, os.Getenv("XDG_RUNTIME_DIR"))
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", filepath.Join(own, "missing"))
+	chosen, report, err := SetUpRuntimeDir(context.Background())
+	if err != nil || os.Getenv("XDG_RUNTIME_DIR") != standard || chosen != "XDG_RUNTIME_DIR is "+standard+", the one the login created" {
+		t.Fatalf("a missing XDG_RUNTIME_DIR gave %q, %v and %s, want %s", chosen, err, os.Getenv("XDG_RUNTIME_DIR"), standard)
+	}
+	if want := []string{"Not using XDG_RUNTIME_DIR", "Changed the mode of XDG_RUNTIME_DIR " + standard + " from 0755 to 0700"}; len(report) != 2 || !strings.HasPrefix(report[0], want[0]) || report[1] != want[1] {
+		t.Errorf("got %q, want %q", report, want)
+	}
+	if info, err := os.Stat(standard); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("the runtime dir is not private: %v %v", info.Mode(), err)
+	}
+
+	if err := os.Remove(standard); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if dir := RuntimeDir(); dir != fmt.Sprintf(runtimeDirFormat, "1001") {
+		t.Errorf("without one under %s, RuntimeDir is %s", userDirs, dir)
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", own)
+	RuntimeDirOverride = filepath.Join(t.TempDir(), "custom")
+	t.Cleanup(func() { RuntimeDirOverride = "" })
+	chosen, report, err = SetUpRuntimeDir(context.Background())
+	if err != nil || len(report) > 0 || os.Getenv("XDG_RUNTIME_DIR") != RuntimeDirOverride || !strings.HasSuffix(chosen, "as --runtime-dir asks") {
+		t.Errorf("--runtime-dir gave %q, %q, %v and %s", chosen, report, err, os.Getenv("XDG_RUNTIME_DIR"))
+	}
+	if info, err := os.Stat(RuntimeDirOverride); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("--runtime-dir was not created private: %v", err)
+	}
+}
+
+func TestRunningAsRoot(t *testing.T) {
+	stubEUID(t, 0)
+	userDirs := t.TempDir()
+	old := userRuntimeDirs
+	userRuntimeDirs = userDirs
+	t.Cleanup(func() { userRuntimeDirs = old })
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	chosen, _, err := SetUpRuntimeDir(context.Background())
+	if dir := filepath.Join(userDirs, "0"); err != nil || chosen != "XDG_RUNTIME_DIR is "+dir+", root's own, since the session set none" {
+		t.Errorf("as root SetUpRuntimeDir gave %q, %v, want %s", chosen, err, dir)
+	}
+	userRuntimeDirs = filepath.Join(userDirs, "missing")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if dir := RuntimeDir(); dir != fmt.Sprintf(runtimeDirFormat, "0") {
+		t.Errorf("as root without %s, RuntimeDir is %s", userRuntimeDirs, dir)
+	}
+
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "pkg"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin) // no sudo or doas, which root does not need
+	if checks := InstallChecks(); InstallBlocked(checks) || len(checks) != 1 {
+		t.Errorf("as root InstallChecks gave %v, want pkg alone", checks)
+	}
+	if got := installCommand(context.Background(), "niri"); !slices.Equal(got, []string{"pkg", "install", "-y", "niri"}) {
+		t.Errorf("as root niri installs with %q", got)
+	}
+	PrivilegeTool = "doas"
+	t.Cleanup(func() { PrivilegeTool = "" })
+	if got := installCommand(context.Background(), "niri"); got[0] != "doas" {
+		t.Errorf("as root with --privilege-tool doas niri installs with %q", got)
+	}
+}
+
+func TestLaunchBlocked(t *testing.T) {
+	if LaunchBlocked([]Check{{OK: true}, {Warn: true}}) {
+		t.Error("a warning blocked the launch")
+	}
+	if !LaunchBlocked([]Check{{OK: true}, {}}) {
+		t.Error("a failed check did not block the launch")
+	}
+}
+
+func TestInstallChecks(t *testing.T) {
+	stubEUID(t, 1001)
+	for _, tt := range []struct {
+		name    string
+		sudo    string // the fake sudo, "" for none
+		doas    string // the fake doas, "" for none
+		blocked bool
+		detail  string
+	}{
+		{name: "neither doas nor sudo", blocked: true, detail: "neither doas nor sudo found"},
+		{name: "password needed", sudo: "echo 'sudo: a password is required' >&2; exit 1", blocked: true, detail: "run sudo -v"},
+		{name: "not allowed", sudo: "echo 'me is not in the sudoers file.' >&2; exit 1", blocked: true, detail: "wheel group"},
+		{name: "usable", sudo: "exit 0"},
+		{name: "doas", doas: "echo 'doas: Authorization required' >&2; exit 1", sudo: "exit 0", blocked: true, detail: "doas wants your password"},
+	} {
+		bin := t.TempDir()
+		if err := os.WriteFile(filepath.Join(bin, "pkg"), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, script := range map[string]string{"sudo": tt.sudo, "doas": tt.doas} {
+			if script == "" {
+				continue
+			}
+			if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Setenv("PATH", bin)
+		checks := InstallChecks()
+		if InstallBlocked(checks) != tt.blocked || !strings.Contains(fmt.Sprint(checks), tt.detail) {
+			t.Errorf("%s: got %v", tt.name, checks)
+		}
+	}
+}
+
+func TestSetUpSeatd(t *testing.T) {
+	bin := t.TempDir()
+	log := filepath.Join(bin, "sudo.log")
+	fake := "#!/bin/sh\necho \"$*\" >> " + log + "\n[ \"$1\" = service ] && { echo 'seatd: cannot open /dev/tty0' >&2; exit 1; }\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(bin, "sudo"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	PrivilegeTool = "sudo" // even where doas is installed
+	t.Cleanup(func() { PrivilegeTool = "" })
+	if _, err := os.Stat(SeatdSocket); err == nil {
+		t.Skip("seatd is running here, so it would not be started")
+	}
+
+	report, err := SetUpSeatd(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "could not start seatd") {
+		t.Errorf("SetUpSeatd with a failing service returned %v", err)
+	}
+	if !slices.Contains(report, "Enabled the seatd service, it starts at every boot") || !slices.Contains(report, "Could not start seatd: seatd: cannot open /dev/tty0") {
+		t.Errorf("SetUpSeatd reported %q", report)
+	}
+	data, _ := os.ReadFile(log)
+	if ran := string(data); !strings.HasPrefix(ran, "sysrc seatd_enable=YES\nservice seatd start\n") {
+		t.Errorf("SetUpSeatd ran %q", ran)
+	}
+	if last := report[len(report)-1]; !strings.Contains(last, " the video group") {
+		t.Errorf("SetUpSeatd did not get to the video group after the failure: %q", report)
+	}
+}
+
+func TestSystemChecks(t *testing.T) {
+	bin := t.TempDir()
+	for name, script := range map[string]string{
+		"pkg":   "#!/bin/sh\n[ \"$3\" = niri ]\n",
+		"sysrc": "#!/bin/sh\necho YES\n",
+	} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+	checks := map[string]Check{}
+	for _, c := range SystemChecks() {
+		checks[c.Name] = c
+	}
+	if c := checks["niri installed"]; !c.OK {
+		t.Errorf("niri counts as missing: %s", c)
+	}
+	if c := checks["wlroots installed"]; c.OK || !strings.Contains(c.Detail, "Install Niri") {
+		t.Errorf("a missing wlroots gives %s", c)
+	}
+	if c := checks["seatd enabled"]; !c.OK {
+		t.Errorf("seatd_enable=YES gives %s", c)
+	}
+	for _, name := range []string{"seatd running", "In the video group"} {
+		if _, ok := checks[name]; !ok {
+			t.Errorf("SystemChecks has no %q check: %q", name, SystemChecks())
+		}
+	}
+}
+
+func TestDownloadChecks(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // no pkg to ask for PKG_CACHEDIR
+	old := MinFreeSpace
+	t.Cleanup(func() { MinFreeSpace = old })
+	MinFreeSpace = 0
+	if c := spaceCheck(); !c.OK || !strings.HasSuffix(c.Detail, " free") {
+		t.Errorf("space with no minimum gives %s", c)
+	}
+	MinFreeSpace = 1 << 62
+	if c := spaceCheck(); c.OK || !c.Warn || !strings.Contains(c.Detail, "pkg clean -a") {
+		t.Errorf("too little space gives %s", c)
+	}
+
+	slept := stubSleep(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conf := filepath.Join(t.TempDir(), "FreeBSD.conf")
+	oldConfigs := pkgRepoConfigs
+	t.Cleanup(func() { pkgRepoConfigs = oldConfigs })
+	pkgRepoConfigs = []string{conf}
+	for _, tt := range []struct {
+		url    string
+		wantOK bool
+	}{
+		{url: "pkg+http://" + l.Addr().String() + "/${ABI}/quarterly", wantOK: true},
+		{url: "file:///usr/local/poudriere/data/packages", wantOK: true},
+		{url: "http://127.0.0.1:1/${ABI}/latest"},
+	} {
+		if err := os.WriteFile(conf, []byte("FreeBSD: {\n  url: \""+tt.url+"\"\n}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if c := repoCheck(); c.OK != tt.wantOK {
+			t.Errorf("%s gives %s", tt.url, c)
+		}
+	}
+	// an unreachable server is tried again before it is reported
+	if len(*slept) != probeAttempts-1 {
+		t.Errorf("the probes slept %v, want one wait for the unreachable server", *slept)
+	}
+	if DownloadBlocked([]Check{{OK: true, Warn: true}}) || !DownloadBlocked([]Check{{Warn: true}}) {
+		t.Error("DownloadBlocked does not go by the failed checks")
+	}
+}
@@ -0,0 +1,96 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Process is one of the user's processes running a program NiriSetup
+// installs.
+type Process struct {
+	PID     int
+	Name    string
+	Command string // the command line ps reports, used to start it again
+}
+
+// stopTimeout is how long RestartProcess waits for a process to exit after
+// SIGTERM before giving up.
+const stopTimeout = 3 * time.Second
+
+// SessionProcesses lists the user's running processes whose program is one
+// of ManagedPackages, such as waybar, mako or swaybg. niri itself is left
+// out, since killing it ends the session.
+func SessionProcesses() ([]Process, error) {
+	return processesOf(slices.DeleteFunc(ManagedPackages(), func(pkg string) bool { return pkg == "niri" }))
+}
+
+// processesOf lists the user's running processes of programs.
+func processesOf(programs []string) ([]Process, error) {
+	out, err := Command("ps", "-x", "-o", "pid=", "-o", "comm=", "-o", "args=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return parseProcesses(string(out), programs), nil
+}
+
+// parseProcesses picks the processes running one of programs out of ps
+// output with pid, comm and args columns.
+func parseProcesses(out string, programs []string) []Process {
+	var procs []Process
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !slices.Contains(programs, fields[1]) {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		procs = append(procs, Process{PID: pid, Name: fields[1], Command: strings.Join(fields[2:], " ")})
+	}
+	return procs
+}
+
+// KillProcess sends p SIGTERM.
+func KillProcess(ctx context.Context, p Process) ([]string, error) {
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview(fmt.Sprintf("Would send SIGTERM to %s (pid %d)", p.Name, p.PID))
+		return nil, nil
+	}
+	if err := syscall.Kill(p.PID, syscall.SIGTERM); err != nil {
+		return nil, fmt.Errorf("failed to kill %s (pid %d): %w", p.Name, p.PID, err)
+	}
+	return []string{fmt.Sprintf("Sent SIGTERM to %s (pid %d)", p.Name, p.PID)}, nil
+}
+
+// RestartProcess stops p and starts its command line again in its own
+// session, so it outlives NiriSetup. It gets NiriSetup's environment, so
+// restarting a Wayland client only works from inside niri.
+func RestartProcess(ctx context.Context, p Process) ([]string, error) {
+	args := strings.Fields(p.Command)
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview(fmt.Sprintf("Would restart %s (pid %d) as %s", p.Name, p.PID, p.Command))
+		return nil, nil
+	}
+	report, err := KillProcess(ctx, p)
+	if err != nil {
+		return report, err
+	}
+	for deadline := time.Now().Add(stopTimeout); syscall.Kill(p.PID, 0) == nil; time.Sleep(100 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			return report, fmt.Errorf("%s (pid %d) did not exit within %s; kill it instead", p.Name, p.PID, stopTimeout)
+		}
+	}
+	cmd := commandIn(context.Background(), args[0], args[1:]...) // CancelCommands must not stop it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}         // outlive NiriSetup
+	if err := cmd.Start(); err != nil {
+		return report, fmt.Errorf("failed to start %s again: %w", p.Name, err)
+	}
+	go cmd.Wait() // reap it if it exits while NiriSetup runs
+	return append(report, fmt.Sprintf("Started %s again (pid %d)", p.Name, cmd.Process.Pid)), nil
+}
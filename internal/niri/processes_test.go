@@ -0,0 +1,21 @@
+package niri
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseProcesses(t *testing.T) {
+	out := "  812 niri             niri --session\n" +
+		"  840 waybar           waybar -c /home/me/.config/waybar/config\n" +
+		"  841 mako             mako\n" +
+		"  900 sh               sh -c waybar\n" +
+		"bogus line\n"
+	want := []Process{
+		{PID: 840, Name: "waybar", Command: "waybar -c /home/me/.config/waybar/config"},
+		{PID: 841, Name: "mako", Command: "mako"},
+	}
+	if got := parseProcesses(out, []string{"waybar", "mako"}); !slices.Equal(got, want) {
+		t.Errorf("parseProcesses found %+v, want %+v", got, want)
+	}
+}
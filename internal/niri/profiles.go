@@ -0,0 +1,129 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ProfilesDir holds the user's config profiles, one directory each with
+// its own config.kdl.
+func ProfilesDir() string {
+	return filepath.Join(configHome(), "nirisetup", "profiles")
+}
+
+// profileName matches the profile names SaveProfile accepts.
+var profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func profileConfig(name string) string {
+	return filepath.Join(ProfilesDir(), name, "config.kdl")
+}
+
+// Profiles lists the profiles that have a config.kdl, sorted by name.
+func Profiles() ([]string, error) {
+	configs, err := filepath.Glob(profileConfig("*"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, c := range configs {
+		names = append(names, filepath.Base(filepath.Dir(c)))
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// ActiveProfile returns the profile the niri config links to, or "" when
+// the config is an ordinary file.
+func ActiveProfile() string {
+	target, err := os.Readlink(ConfigPath())
+	if err != nil {
+		return ""
+	}
+	if dir := filepath.Dir(target); filepath.Dir(dir) == ProfilesDir() {
+		return filepath.Base(dir)
+	}
+	return ""
+}
+
+// SwitchProfile makes the niri config a symlink to the config of profile
+// name and asks a running niri to load it. A config that is not a profile
+// link yet is backed up first.
+func SwitchProfile(ctx context.Context, name string) ([]string, error) {
+	target := profileConfig(name)
+	if _, err := os.Stat(target); err != nil {
+		return nil, fmt.Errorf("profile %s has no config: %w", name, err)
+	}
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	report := []string{checked}
+
+	if info, err := os.Lstat(ConfigPath()); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		backup, err := Backup(ctx)
+		if err != nil {
+			return report, err
+		}
+		report = append(report, "Backed up the current config to "+backup)
+	}
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview(fmt.Sprintf("Would link %s to %s", ConfigPath(), target))
+		return report, nil
+	}
+	// link beside the config and rename over it, so niri never sees it missing
+	tmp := ConfigPath() + ".nirisetup-link"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return report, fmt.Errorf("failed to link profile %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, ConfigPath()); err != nil {
+		os.Remove(tmp)
+		return report, fmt.Errorf("failed to link profile %s: %w", name, err)
+	}
+	report = append(report, fmt.Sprintf("Linked %s to %s", ConfigPath(), target))
+	report = append(report, reloadNiri())
+	return append(report, "Active profile: "+name), nil
+}
+
+// reloadNiri asks a running niri to load its config again.
+func reloadNiri() string {
+	if os.Getenv("NIRI_SOCKET") == "" {
+		return "niri is not running here; it will use the profile the next time it starts"
+	}
+	if out, err := Command("niri", "msg", "action", "load-config-file").CombinedOutput(); err != nil {
+		return fmt.Sprintf("niri did not reload (%s); it picks up config changes by itself", strings.TrimSpace(string(out)))
+	}
+	return "Reloaded niri"
+}
+
+// SaveProfile copies the current niri config into a new profile called
+// name and switches to it.
+func SaveProfile(ctx context.Context, name string) ([]string, error) {
+	if !profileName.MatchString(name) {
+		return nil, fmt.Errorf("%q is not a valid profile name; use letters, digits, '.', '_' and '-'", name)
+	}
+	if _, err := os.Stat(profileConfig(name)); err == nil {
+		return nil, fmt.Errorf("profile %s already exists", name)
+	}
+	data, err := os.ReadFile(ConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview(fmt.Sprintf("Would save %s as profile %s and link it", ConfigPath(), name))
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(profileConfig(name)), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create profile %s: %w", name, err)
+	}
+	if err := writeFile(ctx, profileConfig(name), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write profile %s: %w", name, err)
+	}
+	report, err := SwitchProfile(ctx, name)
+	return append([]string{"Saved the current config as profile " + name}, report...), err
+}
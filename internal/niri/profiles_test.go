@@ -0,0 +1,133 @@
+package niri
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndSwitchProfiles(t *testing.T) {
+	writeTestConfig(t, "layout {\n    gaps 16\n}\n")
+	t.Setenv("NIRI_SOCKET", "") // no niri to reload
+	ctx := context.Background()
+	if got := ActiveProfile(); got != "" {
+		t.Errorf("an ordinary config is profile %q", got)
+	}
+
+	report, err := SaveProfile(ctx, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.Join(report, "\n")
+	for _, want := range []string{"Saved the current config as profile work", "Backed up the current config to ", "Active profile: work"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("saving the profile reported\n%s\nwithout %q", text, want)
+		}
+	}
+	if data, _ := os.ReadFile(profileConfig("work")); string(data) != "layout {\n    gaps 16\n}\n" {
+		t.Errorf("the saved profile holds %q", data)
+	}
+	if target, err := os.Readlink(ConfigPath()); err != nil || target != profileConfig("work") || ActiveProfile() != "work" {
+		t.Errorf("the config links to %q (%v), active profile %q, want work", target, err, ActiveProfile())
+	}
+
+	// a second profile starts as a copy of the first, which is edited apart
+	if _, err := SaveProfile(ctx, "home"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(profileConfig("home"), []byte("layout {\n    gaps 4\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := Profiles(); err != nil || !slices.Equal(names, []string{"home", "work"}) {
+		t.Errorf("Profiles = %q, %v, want home and work", names, err)
+	}
+
+	report, err = SwitchProfile(ctx, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the config was a profile link, which needs no backup
+	if text := strings.Join(report, "\n"); strings.Contains(text, "Backed up") || !strings.Contains(text, "niri is not running here") {
+		t.Errorf("switching between profiles reported\n%s", text)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); ActiveProfile() != "work" || !strings.Contains(string(data), "gaps 16") {
+		t.Errorf("after switching to work the profile is %q and the config %q", ActiveProfile(), data)
+	}
+	if _, err := SwitchProfile(ctx, "home"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); ActiveProfile() != "home" || !strings.Contains(string(data), "gaps 4") {
+		t.Errorf("after switching to home the profile is %q and the config %q", ActiveProfile(), data)
+	}
+}
+
+func TestSwitchToMissingProfile(t *testing.T) {
+	src := "layout {\n    gaps 16\n}\n"
+	writeTestConfig(t, src)
+	if _, err := SwitchProfile(context.Background(), "nope"); err == nil || !strings.Contains(err.Error(), "profile nope has no config") {
+		t.Errorf("switching to a missing profile returned %v", err)
+	}
+	// a profile directory without a config is no profile either
+	if err := os.MkdirAll(filepath.Join(ProfilesDir(), "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SwitchProfile(context.Background(), "empty"); err == nil {
+		t.Error("switching to a profile without a config succeeded")
+	}
+	if names, err := Profiles(); err != nil || len(names) != 0 {
+		t.Errorf("Profiles = %q, %v, want none", names, err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != src || ActiveProfile() != "" {
+		t.Errorf("a failed switch left the config %q, profile %q", data, ActiveProfile())
+	}
+	if backups, _ := Backups(); len(backups) > 0 {
+		t.Errorf("a failed switch backed the config up to %q", backups)
+	}
+}
+
+func TestSaveProfileRefuses(t *testing.T) {
+	writeTestConfig(t, "layout {\n    gaps 16\n}\n")
+	t.Setenv("NIRI_SOCKET", "")
+	for _, name := range []string{"", "../escape", "-f", "two words", ".hidden"} {
+		if _, err := SaveProfile(context.Background(), name); err == nil || !strings.Contains(err.Error(), "not a valid profile name") {
+			t.Errorf("saving profile %q returned %v", name, err)
+		}
+	}
+	if _, err := SaveProfile(context.Background(), "work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveProfile(context.Background(), "work"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("saving work twice returned %v", err)
+	}
+	if names, _ := Profiles(); !slices.Equal(names, []string{"work"}) {
+		t.Errorf("Profiles = %q, want work alone", names)
+	}
+}
+
+func TestProfilesUnderDryRun(t *testing.T) {
+	src := "layout {\n    gaps 16\n}\n"
+	writeTestConfig(t, src)
+	var previewed []string
+	ctx := WithOptions(context.Background(), Options{Preview: func(change string) { previewed = append(previewed, change) }})
+	if _, err := SaveProfile(ctx, "work"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := Profiles(); len(names) != 0 || len(previewed) != 1 {
+		t.Errorf("a dry run saved the profiles %q, previewing %q", names, previewed)
+	}
+	if err := os.MkdirAll(filepath.Dir(profileConfig("home")), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(profileConfig("home"), []byte("layout {\n    gaps 4\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SwitchProfile(ctx, "home"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != src || ActiveProfile() != "" {
+		t.Errorf("a dry run switch left the config %q, profile %q", data, ActiveProfile())
+	}
+}
@@ -0,0 +1,96 @@
+package niri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Repair brings a niri desktop back to what Install Niri and Configure
+// Niri leave behind, fixing only what is missing or broken: it installs
+// the packages of pkgs that are missing, reinstalls the ones pkg check
+// finds damaged, writes the starter niri config when there is none and
+// replaces one niri rejects with its newest backup that validates, or
+// DefaultConfig without one. The starter configs of DefaultConfigureTools
+// are written only where they are missing and the tool is in pkgs, so a
+// user's own are kept. It reports what it fixed and goes on past a
+// failure; the error names the steps that failed.
+func Repair(ctx context.Context, pkgs []string) ([]string, error) {
+	var report, failed []string
+	fail := func(step string, err error) {
+		report = append(report, fmt.Sprintf("Could not %s: %s", step, err))
+		failed = append(failed, step)
+	}
+
+	err := InstallPackages(ctx, pkgs, func(pkg string, skipped bool, err error) {
+		if !skipped && err == nil && OptionsFrom(ctx).Preview == nil {
+			report = append(report, "Installed "+pkg)
+		}
+	})
+	if errors.Is(err, ErrCancelled) {
+		return report, err
+	}
+	if err != nil {
+		fail("install the missing packages", err)
+	}
+	// after sudo refused the install, a reinstall would be refused too
+	if !errors.Is(err, ErrPrivilege) {
+		if _, damaged, err := VerifyPackages(); err != nil {
+			fail("check the installed packages", err)
+		} else if len(damaged) > 0 {
+			reinstalled, err := ReinstallPackages(ctx, damaged)
+			report = append(report, reinstalled...)
+			if err != nil {
+				fail("reinstall the damaged packages", err)
+			}
+		}
+	}
+
+	if _, err := os.Stat(ConfigPath()); os.IsNotExist(err) {
+		written, err := Configure(ctx)
+		report = append(report, written...)
+		if err != nil {
+			fail("write the niri config", err)
+		}
+	} else if d, err := Diagnose(); err != nil {
+		fail("validate the niri config", err)
+	} else if !d.Valid {
+		report = append(report, "niri rejected "+ConfigPath()+": "+strings.TrimSpace(d.Problem))
+		replace := RestoreDefaults
+		if d.Backup != "" {
+			replace = func(ctx context.Context) ([]string, error) { return RestoreBackup(ctx, d.Backup) }
+		}
+		fixed, err := replace(ctx)
+		report = append(report, fixed...)
+		if err != nil {
+			fail("repair the niri config", err)
+		}
+	}
+
+	for _, t := range ToolConfigs {
+		path := filepath.Join(configHome(), t.Path)
+		if !slices.Contains(DefaultConfigureTools, t.Tool) || !slices.Contains(pkgs, t.Tool) || fileExists(path) {
+			continue
+		}
+		line, err := writeToolConfig(ctx, t)
+		if err != nil {
+			fail("write the "+t.Tool+" config", err)
+			continue
+		}
+		if line != "" {
+			report = append(report, line)
+		}
+	}
+
+	if len(failed) > 0 {
+		return report, fmt.Errorf("the setup is not fully repaired, could not %s", strings.Join(failed, " or "))
+	}
+	if len(report) == 0 {
+		report = append(report, "Nothing to repair: every package is installed and intact, and the configs are in place")
+	}
+	return report, nil
+}
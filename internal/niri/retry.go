@@ -0,0 +1,54 @@
+package niri
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// sleep is time.Sleep, swapped out by tests.
+var sleep = time.Sleep
+
+// permanentError marks an error retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// permanent wraps err so retry gives up on it straight away.
+func permanent(err error) error { return permanentError{err} }
+
+// probeAttempts and probeBackoff bound how hard a quick network probe, of
+// a mirror or the repository, is retried. They are lower than an
+// install's, since an unreachable server keeps each attempt waiting for
+// its whole timeout.
+const (
+	probeAttempts = 2
+	probeBackoff  = 500 * time.Millisecond
+)
+
+// retry calls fn up to attempts times until it succeeds, returning the last
+// error if it never does. An error wrapped with permanent is returned, as
+// the error it wraps, without further attempts. After the nth failure it
+// waits backoff*2^(n-1) plus up to half that again at random, so several
+// clients retrying the same mirror do not hit it in lockstep.
+func retry(attempts int, backoff time.Duration, fn func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		var stop permanentError
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if i == attempts-1 {
+			break
+		}
+		delay := backoff << i
+		if half := int64(delay / 2); half > 0 {
+			delay += time.Duration(rand.Int63n(half))
+		}
+		sleep(delay)
+	}
+	return err
+}
@@ -0,0 +1,507 @@
+package niri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"NiriSetup/internal/niri/niritest"
+)
+
+// stubSleep records the delays retry asks for instead of waiting.
+func stubSleep(t *testing.T) *[]time.Duration {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	t.Cleanup(func() { sleep = time.Sleep })
+	return &slept
+}
+
+// fakeCommands has Runner answer every command with answer until the test
+// ends, with sudo as the privilege tool.
+func fakeCommands(t *testing.T, answer func(line string) niritest.Reply) *niritest.Runner {
+	r := &niritest.Runner{Answer: answer}
+	Runner, PrivilegeTool = r, "sudo"
+	t.Cleanup(func() { Runner, PrivilegeTool = execRunner{}, "" })
+	return r
+}
+
+func TestRetrySucceedsAfterFailures(t *testing.T) {
+	slept := stubSleep(t)
+	calls := 0
+	err := retry(5, time.Second, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("mirror unreachable")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retry returned %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3", calls)
+	}
+	if len(*slept) != 2 {
+		t.Errorf("slept %d times, want 2", len(*slept))
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	slept := stubSleep(t)
+	fail := errors.New("mirror unreachable")
+	calls := 0
+	err := retry(3, time.Second, func() error {
+		calls++
+		return fail
+	})
+	if err != fail {
+		t.Errorf("retry returned %v, want %v", err, fail)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3", calls)
+	}
+	// no wait after the last attempt
+	if len(*slept) != 2 {
+		t.Errorf("slept %d times, want 2", len(*slept))
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	slept := stubSleep(t)
+	retry(5, 100*time.Millisecond, func() error { return errors.New("fail") })
+
+	base := 100 * time.Millisecond
+	for i, d := range *slept {
+		min := base << i
+		max := min + min/2
+		if d < min || d >= max {
+			t.Errorf("delay %d = %v, want in [%v, %v)", i+1, d, min, max)
+		}
+	}
+	if len(*slept) != 4 {
+		t.Errorf("slept %d times, want 4", len(*slept))
+	}
+}
+
+func TestRetryStopsOnPermanentErrors(t *testing.T) {
+	slept := stubSleep(t)
+	denied := errors.New("sudo: a password is required")
+	calls := 0
+	err := retry(3, time.Second, func() error {
+		calls++
+		return permanent(denied)
+	})
+	if err != denied {
+		t.Errorf("retry returned %v, want %v", err, denied)
+	}
+	if calls != 1 || len(*slept) != 0 {
+		t.Errorf("fn called %d times and slept %d times, want 1 and 0", calls, len(*slept))
+	}
+}
+
+func TestInstallPackageRetries(t *testing.T) {
+	slept := stubSleep(t)
+	bin := t.TempDir()
+	fake := "#!/bin/sh\necho 'pkg: mirror unreachable'\nexit 1\n"
+	for _, name := range []string{"sudo", "pkg"} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(fake), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+	var lines []string
+	ctx := WithOptions(context.Background(), Options{Output: func(line string) { lines = append(lines, line) }})
+
+	var perr *PackageError
+	if err := InstallPackage(ctx, "niri"); !errors.As(err, &perr) || perr.Package != "niri" {
+		t.Fatalf("InstallPackage returned %v, want a *PackageError for niri", err)
+	}
+	var retries int
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Retrying niri") {
+			retries++
+		}
+	}
+	if retries != 3 || len(*slept) != 3 {
+		t.Fatalf("InstallPackage logged %d retries and waited %v, want 3 of each", retries, *slept)
+	}
+	for i, base := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if d := (*slept)[i]; d < base || d >= base*3/2 {
+			t.Errorf("wait %d was %v, want %v plus up to half that", i+1, d, base)
+		}
+	}
+}
+
+func TestPackageErrorShowsStderr(t *testing.T) {
+	stubSleep(t)
+	bin := t.TempDir()
+	for name, script := range map[string]string{
+		"sudo": "#!/bin/sh\nexec \"$@\"\n",
+		"pkg":  "#!/bin/sh\necho 'Updating FreeBSD repository catalogue... 100%'\necho \"pkg: No packages available to install matching 'nirri'\" >&2\nexit 1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+
+	var perr *PackageError
+	if err := InstallPackage(context.Background(), "nirri"); !errors.As(err, &perr) {
+		t.Fatalf("InstallPackage returned %v, want a *PackageError", err)
+	}
+	if want := "pkg: No packages available to install matching 'nirri'"; perr.Stderr != want || !strings.Contains(perr.Output, "catalogue") {
+		t.Errorf("stderr is %q and the output %q, want %q apart from the rest", perr.Stderr, perr.Output, want)
+	}
+	if got, want := perr.Error(), "failed to install nirri: pkg: No packages available to install matching 'nirri'"; got != want {
+		t.Errorf("the error reads %q, want %q", got, want)
+	}
+}
+
+func TestPackageErrorKeepsPercentSigns(t *testing.T) {
+	stubSleep(t)
+	bin := t.TempDir()
+	out := "Fetching niri: 100% of %s, 12%d left"
+	fake := "#!/bin/sh\necho '" + out + "' >&2\nexit 1\n"
+	for _, name := range []string{"sudo", "pkg"} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(fake), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+
+	err := InstallPackage(context.Background(), "niri")
+	if want := "failed to install niri: " + out; err == nil || err.Error() != want {
+		t.Errorf("InstallPackage returned %v, want %q", err, want)
+	}
+}
+
+func TestInstallWaitsForThePkgLock(t *testing.T) {
+	slept := stubSleep(t)
+	bin := t.TempDir()
+	first, second := filepath.Join(bin, "first"), filepath.Join(bin, "second")
+	// the first two runs find the database locked by another pkg
+	pkg := "#!/bin/sh\n[ -e " + second + " ] && exit 0\nif [ -e " + first + " ]; then : > " + second + "; else : > " + first + "; fi\necho 'pkg: Cannot get an exclusive lock on a database, it is locked by another process' >&2\nexit 3\n"
+	for name, script := range map[string]string{"sudo": "#!/bin/sh\nexec \"$@\"\n", "pkg": pkg} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+	var output []string
+	ctx := WithOptions(context.Background(), Options{Output: func(line string) { output = append(output, line) }})
+
+	if err := InstallPackage(ctx, "niri"); err != nil {
+		t.Fatalf("InstallPackage returned %v once the lock was free", err)
+	}
+	if want := []time.Duration{pkgLockPoll, pkgLockPoll}; !slices.Equal(*slept, want) {
+		t.Errorf("slept %v, want %v", *slept, want)
+	}
+	if !slices.ContainsFunc(output, func(l string) bool { return strings.HasPrefix(l, "Waiting for pkg lock") }) || slices.ContainsFunc(output, func(l string) bool { return strings.HasPrefix(l, "Retrying") }) {
+		t.Errorf("the output was %q, want one wait and no retry", output)
+	}
+
+	old := pkgLockWait
+	pkgLockWait = pkgLockPoll
+	t.Cleanup(func() { pkgLockWait = old })
+	for _, f := range []string{first, second} {
+		if err := os.Remove(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var perr *PackageError
+	if err := InstallPackage(context.Background(), "niri"); !errors.As(err, &perr) || !strings.Contains(perr.Reason(), "kept the package database locked") {
+		t.Errorf("a lock held too long gave %v", err)
+	}
+}
+
+func TestInstallTimesOut(t *testing.T) {
+	stubSleep(t)
+	bin := t.TempDir()
+	for name, script := range map[string]string{
+		"sudo": "#!/bin/sh\nexec \"$@\"\n",
+		"pkg":  "#!/bin/sh\nexec sleep 30\n",
+	} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("no sleep to hang pkg with")
+	}
+	if err := os.Symlink(sleep, filepath.Join(bin, "sleep")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	PrivilegeTool = "sudo" // even where doas is installed
+	t.Cleanup(func() { PrivilegeTool = "" })
+	old := InstallTimeout
+	InstallTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { InstallTimeout = old })
+	var output []string
+	ctx := WithOptions(context.Background(), Options{Output: func(line string) { output = append(output, line) }})
+
+	var perr *PackageError
+	if err := InstallPackage(ctx, "niri"); !errors.As(err, &perr) || !strings.Contains(perr.Reason(), "timed out after 100ms") {
+		t.Fatalf("a hung pkg gave %v", err)
+	}
+	if slices.ContainsFunc(output, func(l string) bool { return strings.HasPrefix(l, "Retrying") }) {
+		t.Errorf("a timed out package was retried: %q", output)
+	}
+}
+
+func TestInstallPackagesCommands(t *testing.T) {
+	stubSleep(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tries := 0
+	r := fakeCommands(t, func(line string) niritest.Reply {
+		switch {
+		case line == "pkg info -e niri":
+			return niritest.Reply{} // already installed
+		case strings.HasPrefix(line, "pkg info -e "):
+			return niritest.Fail
+		case line == "sudo pkg install -y mako fuzzel":
+			return niritest.Reply{Stderr: "pkg: fuzzel is broken\n", Exit: 1}
+		case line == "sudo pkg install -y mako":
+			if tries++; tries == 1 {
+				return niritest.Reply{Stdout: "pkg: mirror unreachable\n", Exit: 1}
+			}
+		case line == "sudo pkg install -y fuzzel":
+			return niritest.Reply{Stderr: "pkg: fuzzel is broken\n", Exit: 1}
+		}
+		return niritest.Reply{}
+	})
+
+	var started []string
+	ctx := WithOptions(context.Background(), Options{Installing: func(pkgs []string) { started = append(started, strings.Join(pkgs, " ")) }})
+
+	var skipped, failed []string
+	err := InstallPackages(ctx, []string{"niri", "mako", "fuzzel"}, func(pkg string, skip bool, err error) {
+		if skip {
+			skipped = append(skipped, pkg)
+		}
+		if err != nil {
+			failed = append(failed, pkg)
+		}
+	})
+	var ierr *InstallError
+	if !errors.As(err, &ierr) || len(ierr.Failed) != 1 || ierr.Failed[0].Package != "fuzzel" {
+		t.Fatalf("InstallPackages returned %v, want an *InstallError for fuzzel alone", err)
+	}
+	if !slices.Equal(skipped, []string{"niri"}) || !slices.Equal(failed, []string{"fuzzel"}) {
+		t.Errorf("skipped %q and failed %q, want niri and fuzzel", skipped, failed)
+	}
+	want := []string{
+		"pkg info -e niri", "pkg info -e mako", "pkg info -e fuzzel",
+		"sudo pkg install -y mako fuzzel", // fails, so each goes on its own
+		"pkg info -e mako", "pkg info -e fuzzel",
+		"sudo pkg install -y mako", "sudo pkg install -y mako", // retried once
+	}
+	for range installAttempts {
+		want = append(want, "sudo pkg install -y fuzzel")
+	}
+	if got := r.Lines(); !slices.Equal(got, want) {
+		t.Errorf("the install ran\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if want := []string{"mako fuzzel", "mako", "fuzzel"}; !slices.Equal(started, want) {
+		t.Errorf("Installing was told of %q, want %q", started, want)
+	}
+	if pkgs, err := SetupPackages(); err != nil || !slices.Equal(pkgs, []string{"mako"}) {
+		t.Errorf("the manifest records %q (%v) as installed, want mako", pkgs, err)
+	}
+}
+
+func TestInstallPackagesInOneBatch(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	batched := false
+	r := fakeCommands(t, func(line string) niritest.Reply {
+		switch {
+		case line == "sudo pkg install -y niri mako fuzzel":
+			batched = true
+		case strings.HasPrefix(line, "pkg info -e ") && !batched:
+			return niritest.Fail
+		}
+		return niritest.Reply{}
+	})
+
+	var installed []string
+	err := InstallPackages(context.Background(), []string{"niri", "mako", "fuzzel"}, func(pkg string, skip bool, err error) {
+		if !skip && err == nil {
+			installed = append(installed, pkg)
+		}
+	})
+	if err != nil || !slices.Equal(installed, []string{"niri", "mako", "fuzzel"}) {
+		t.Fatalf("InstallPackages installed %q, %v, want all three", installed, err)
+	}
+	want := []string{
+		"pkg info -e niri", "pkg info -e mako", "pkg info -e fuzzel",
+		"sudo pkg install -y niri mako fuzzel",
+		"pkg info -e niri", "pkg info -e mako", "pkg info -e fuzzel",
+	}
+	if got := r.Lines(); !slices.Equal(got, want) {
+		t.Errorf("the install ran\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if pkgs, err := SetupPackages(); err != nil || !slices.Equal(pkgs, []string{"niri", "mako", "fuzzel"}) {
+		t.Errorf("the manifest records %q (%v) as installed, want all three", pkgs, err)
+	}
+}
+
+func TestInstallPackagesReportsEachAsPkgGetsThere(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	out := []string{
+		"Updating FreeBSD repository catalogue...",
+		"[1/4] Installing wlroots-0.18.2...",
+		"[1/4] Extracting wlroots-0.18.2: .......... done",
+		"[2/4] Installing xdg-desktop-portal-gtk-1.15.1_2...",
+		"[2/4] Extracting xdg-desktop-portal-gtk-1.15.1_2: .......... done",
+		"[3/4] Upgrading jq from 1.6 to 1.7.1...",
+		"[4/4] Installing mako-1.9.0...",
+		"[4/4] Extracting mako-1.9.0: .......... done",
+	}
+	r := fakeCommands(t, func(line string) niritest.Reply {
+		switch {
+		case line == "sudo pkg install -y xdg-desktop-portal-gtk mako":
+			return niritest.Reply{Stdout: strings.Join(out, "\n") + "\n"}
+		case strings.HasPrefix(line, "pkg info -e "):
+			return niritest.Fail
+		}
+		return niritest.Reply{}
+	})
+
+	var events []string
+	ctx := WithOptions(context.Background(), Options{
+		Output:  func(line string) { events = append(events, line) },
+		Current: func(pkg string) { events = append(events, "at "+pkg) },
+	})
+	err := InstallPackages(ctx, []string{"xdg-desktop-portal-gtk", "mako"}, func(pkg string, skip bool, err error) {
+		events = append(events, fmt.Sprintf("done %s %v %v", pkg, skip, err))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Installing 2 packages and their dependencies...",
+		out[0], out[1], out[2],
+		out[3], "at xdg-desktop-portal-gtk", out[4],
+		out[5], "done xdg-desktop-portal-gtk false <nil>", // pkg moved on
+		out[6], "at mako", out[7],
+		"done mako false <nil>", // the run ended
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("the install went\n%s\nwant\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+	// pkg said what it installed, so nothing is looked up afterwards
+	if got := r.Lines(); len(got) != 3 {
+		t.Errorf("the install ran %q, want the two lookups and the batch", got)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	bin := t.TempDir() // Diagnose looks for niri before validating
+	if err := os.WriteFile(filepath.Join(bin, "niri"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	for path, content := range map[string]string{ConfigPath(): "broken\n", filepath.Join(configHome(), "mako/config"): "font=serif 12\n"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	installed := map[string]bool{"niri": true, "waybar": true}
+	damaged := true
+	fakeCommands(t, func(line string) niritest.Reply {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "pkg info -e ") && !installed[fields[3]]:
+			return niritest.Fail
+		case line == "sudo pkg install -y mako":
+			installed["mako"] = true
+		case strings.HasPrefix(line, "pkg query "):
+			return niritest.Reply{Stdout: "niri\t25.08\t0\nwaybar\t0.12\t0\nmako\t1.9\t0\n"}
+		case strings.HasPrefix(line, "pkg check -s ") && damaged:
+			return niritest.Reply{Stdout: "waybar-0.12: checksum mismatch for /usr/local/bin/waybar\n", Exit: 1}
+		case line == "sudo pkg install -f -y waybar":
+			damaged = false
+		case strings.HasPrefix(line, "niri validate -c "):
+			if data, _ := os.ReadFile(fields[len(fields)-1]); string(data) == "broken\n" {
+				return niritest.Reply{Stdout: "error: unknown node broken\n", Exit: 1}
+			}
+		}
+		return niritest.Reply{}
+	})
+
+	report, err := Repair(context.Background(), []string{"niri", "waybar", "mako"})
+	if err != nil {
+		t.Fatalf("Repair failed: %v\n%s", err, strings.Join(report, "\n"))
+	}
+	for _, want := range []string{"Installed mako", "Reinstalled waybar", "niri rejected " + ConfigPath() + ": error: unknown node broken", "Wrote the default niri config", "Wrote the waybar config"} {
+		if !slices.ContainsFunc(report, func(line string) bool { return strings.HasPrefix(line, want) }) {
+			t.Errorf("Repair reported\n%s\nwithout %q", strings.Join(report, "\n"), want)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(configHome(), "mako/config")); string(data) != "font=serif 12\n" {
+		t.Errorf("Repair replaced the user's mako config with %q", data)
+	}
+
+	report, err = Repair(context.Background(), []string{"niri", "waybar", "mako"})
+	if err != nil || len(report) != 1 || !strings.HasPrefix(report[0], "Nothing to repair") {
+		t.Errorf("a second Repair reported %q, %v, want nothing to repair", report, err)
+	}
+}
+
+func TestUpgradePackagesRetries(t *testing.T) {
+	slept := stubSleep(t)
+	version, upgrades := "1.0", 0
+	fakeCommands(t, func(line string) niritest.Reply {
+		switch line {
+		case "pkg query %n\t%v\t%t":
+			return niritest.Reply{Stdout: "niri\t" + version + "\t0\n"}
+		case "pkg query %n\t%v":
+			return niritest.Reply{Stdout: "niri\t" + version + "\n"}
+		case "sudo pkg upgrade -y niri":
+			if upgrades++; upgrades < 3 {
+				return niritest.Reply{Stdout: "pkg: http://pkg.FreeBSD.org/meta.conf: Connection reset by peer\n", Exit: 1}
+			}
+			version = "1.1"
+			return niritest.Reply{}
+		}
+		return niritest.Fail
+	})
+	var output []string
+	ctx := WithOptions(context.Background(), Options{Output: func(line string) { output = append(output, line) }})
+	changes, err := UpgradePackages(ctx)
+	if want := []VersionChange{{Package: "niri", Old: "1.0", New: "1.1"}}; err != nil || !slices.Equal(changes, want) {
+		t.Fatalf("UpgradePackages = %v, %v, want %v", changes, err, want)
+	}
+	if upgrades != 3 || len(*slept) != 2 || !slices.Contains(output, "Retrying the upgrade (attempt 3 of 4)...") {
+		t.Errorf("upgraded %d times, slept %v, output %q", upgrades, *slept, output)
+	}
+
+	// sudo refusing is not retried
+	upgrades = 0
+	fakeCommands(t, func(line string) niritest.Reply {
+		if strings.HasPrefix(line, "sudo ") {
+			upgrades++
+			return niritest.Reply{Stdout: "sudo: a password is required\n", Exit: 1}
+		}
+		return niritest.Reply{Stdout: "niri\t1.0\t0\n"}
+	})
+	if _, err := UpgradePackages(context.Background()); !errors.Is(err, ErrPrivilege) || upgrades != 1 {
+		t.Errorf("a refused upgrade returned %v after %d runs, want ErrPrivilege after one", err, upgrades)
+	}
+}
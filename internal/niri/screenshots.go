@@ -0,0 +1,96 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ScreenshotPackages are installed by ConfigureScreenshots, besides grim
+// and wl-clipboard.
+var ScreenshotPackages = []string{"slurp"}
+
+// Screenshot keys ConfigureScreenshots binds.
+const (
+	ScreenshotKey       = "Print"       // the whole screen
+	RegionScreenshotKey = "Shift+Print" // a region picked with slurp
+)
+
+// screenshotsDir is where screenshots are saved, relative to the home
+// directory.
+const screenshotsDir = "Pictures"
+
+// screenshotCommand saves a timestamped PNG in ~/Pictures with grim, passing
+// grimArgs first, and copies it to the clipboard.
+func screenshotCommand(grimArgs string) string {
+	return `f="$HOME/` + screenshotsDir + `/screenshot-$(date +%Y%m%d-%H%M%S).png"; grim ` + grimArgs + `"$f" && wl-copy < "$f"`
+}
+
+// takesScreenshot matches the binds ConfigureScreenshots writes.
+func takesScreenshot(b *Bind) bool {
+	return b.Action == "spawn" && strings.Contains(strings.Join(b.Args, " "), "grim")
+}
+
+// ConfigureScreenshots installs slurp and wl-clipboard, makes sure
+// ~/Pictures exists and binds ScreenshotKey and RegionScreenshotKey to
+// save a screenshot there and copy it to the clipboard. Other binds on
+// those keys, such as niri's own screenshot UI, are replaced.
+func ConfigureScreenshots(ctx context.Context) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	for _, pkg := range slices.Concat([]string{"grim", "wl-clipboard"}, ScreenshotPackages) {
+		if err := InstallPackage(ctx, pkg); err != nil {
+			return report, err
+		}
+		report = append(report, "Installed "+pkg)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return report, err
+	}
+	dir := filepath.Join(home, screenshotsDir)
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would create " + dir)
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return report, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	report = append(report, "Screenshots are saved in "+dir)
+
+	for _, s := range []struct{ key, grimArgs, what string }{
+		{ScreenshotKey, "", "the whole screen"},
+		{RegionScreenshotKey, `-g "$(slurp)" `, "a region you select"},
+	} {
+		bind := fmt.Sprintf("%s { %s; }", s.key, FormatNode("spawn", "sh", "-c", screenshotCommand(s.grimArgs)))
+		switch b := cfg.Bind(s.key); {
+		case b == nil:
+			err = cfg.AddChild("binds", bind)
+		case takesScreenshot(b):
+			report = append(report, s.key+" already takes a screenshot, left it unchanged")
+			continue
+		default:
+			report = append(report, fmt.Sprintf("Replaced the %s bind to %s", s.key, b.Action))
+			err = cfg.Replace(b.Node, bind)
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, fmt.Sprintf("Bound %s to a screenshot of %s, copied to the clipboard", s.key, s.what))
+	}
+
+	if err := WriteConfig(ctx, cfg); err != nil {
+		return report, fmt.Errorf("failed to write niri config: %w", err)
+	}
+	return append(report, "Updated "+ConfigPath()), nil
+}
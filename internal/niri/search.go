@@ -0,0 +1,76 @@
+package niri
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigLocation is a place niri looks for its config.
+type ConfigLocation struct {
+	Path    string
+	From    string // where the path comes from
+	Exists  bool
+	Skipped string // why niri does not look here, if it does not
+}
+
+// ConfigSearchPath lists where niri looks for config.kdl when it is not
+// given -c, in the order it looks: NIRI_CONFIG, the user's config
+// directory (under XDG_CONFIG_HOME when that is set, ~/.config when not)
+// and finally /etc/niri. niri uses the first one that exists.
+func ConfigSearchPath() []ConfigLocation {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	locations := []ConfigLocation{{Path: os.Getenv("NIRI_CONFIG"), From: "NIRI_CONFIG"}}
+	if locations[0].Path == "" {
+		locations[0].Path, locations[0].Skipped = "$NIRI_CONFIG", "not set"
+	}
+	user := ConfigLocation{Path: filepath.Join(xdg, "niri", "config.kdl"), From: "XDG_CONFIG_HOME"}
+	if xdg == "" {
+		user.Path, user.Skipped = "$XDG_CONFIG_HOME/niri/config.kdl", "not set"
+	}
+	fallback := ConfigLocation{Path: filepath.Join(home, ".config", "niri", "config.kdl"), From: "home directory"}
+	if xdg != "" {
+		fallback.Skipped = "only used when XDG_CONFIG_HOME is not set"
+	}
+	locations = append(locations, user, fallback, ConfigLocation{Path: "/etc/niri/config.kdl", From: "system-wide"})
+	for i := range locations {
+		if l := &locations[i]; l.Skipped == "" {
+			_, err := os.Stat(l.Path)
+			l.Exists = err == nil
+		}
+	}
+	return locations
+}
+
+// ConfigSearchReport describes ConfigSearchPath, numbered in precedence
+// order, marking which files exist and which one niri uses.
+func ConfigSearchReport() string {
+	var b strings.Builder
+	b.WriteString("niri looks for its config in this order and uses the first that exists:\n\n")
+	active := ""
+	for i, l := range ConfigSearchPath() {
+		fmt.Fprintf(&b, "%d. %s\n   from %s: ", i+1, l.Path, l.From)
+		switch {
+		case l.Skipped != "":
+			b.WriteString("skipped, " + l.Skipped + "\n")
+		case l.Exists && active == "":
+			active = l.Path
+			b.WriteString("exists, this is the config niri uses\n")
+		case l.Exists:
+			b.WriteString("exists, but an earlier one wins\n")
+		default:
+			b.WriteString("does not exist\n")
+		}
+	}
+	switch active {
+	case "":
+		b.WriteString("\nNone exists yet; niri writes its default config to the user config directory the first time it starts.\n")
+	case ConfigPath():
+	default:
+		b.WriteString("\nNiriSetup edits " + ConfigPath() + ", which niri does not use while " + active + " exists.\n")
+	}
+	b.WriteString("\nniri -c <path> skips the search and uses the given file.\n")
+	return b.String()
+}
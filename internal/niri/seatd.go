@@ -0,0 +1,89 @@
+package niri
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+)
+
+// SeatGroup is the group whose members seatd lets use the seat, the one
+// FreeBSD's seatd service starts it for.
+const SeatGroup = "video"
+
+// inGroup reports whether u is a member of group.
+func inGroup(u *user.User, group string) bool {
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return false
+	}
+	ids, err := u.GroupIds()
+	return err == nil && slices.Contains(ids, g.Gid)
+}
+
+// SetUpSeatd does what niri needs once seatd is installed to get a seat:
+// it enables and starts the seatd service and adds the user to SeatGroup.
+// Each step is reported, a step already done is skipped and one that fails
+// does not stop the others; the error names the ones that failed.
+func SetUpSeatd(ctx context.Context) ([]string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("cannot tell who you are to add you to the %s group: %w", SeatGroup, err)
+	}
+	steps := []struct {
+		what    string
+		done    string // reported instead when the step is not needed
+		skip    bool
+		args    []string
+		success string
+	}{
+		{
+			what:    "enable seatd",
+			args:    asRoot("sysrc", "seatd_enable=YES"),
+			success: "Enabled the seatd service, it starts at every boot",
+		},
+		{
+			what:    "start seatd",
+			done:    "seatd is already running",
+			skip:    fileExists(SeatdSocket),
+			args:    asRoot("service", "seatd", "start"),
+			success: "Started seatd",
+		},
+		{
+			what:    "add " + u.Username + " to the " + SeatGroup + " group",
+			done:    u.Username + " is already in the " + SeatGroup + " group",
+			skip:    inGroup(u, SeatGroup),
+			args:    asRoot("pw", "groupmod", SeatGroup, "-m", u.Username),
+			success: "Added " + u.Username + " to the " + SeatGroup + " group; log out and in again for niri to get the seat",
+		},
+	}
+	var report, failed []string
+	for _, s := range steps {
+		switch {
+		case s.skip:
+			report = append(report, s.done)
+		case OptionsFrom(ctx).Preview != nil:
+			OptionsFrom(ctx).Preview("Would run " + strings.Join(s.args, " "))
+		default:
+			if out, err := Command(s.args[0], s.args[1:]...).CombinedOutput(); err != nil {
+				report = append(report, fmt.Sprintf("Could not %s: %s", s.what, strings.TrimSpace(cmp.Or(string(out), err.Error()))))
+				failed = append(failed, s.what)
+				continue
+			}
+			report = append(report, s.success)
+		}
+	}
+	if len(failed) > 0 {
+		return report, fmt.Errorf("seatd is not fully set up, could not %s", strings.Join(failed, " or "))
+	}
+	return report, nil
+}
+
+// fileExists reports whether something is at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
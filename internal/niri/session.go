@@ -0,0 +1,326 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// userRuntimeDirs is where pam_xdg, on FreeBSD 14.1 and later, creates each
+// user's XDG_RUNTIME_DIR at login, by user ID.
+var userRuntimeDirs = "/var/run/user"
+
+// runtimeDirFormat is where NiriSetup keeps XDG_RUNTIME_DIR, by user ID,
+// when the login did not create one: without pam_xdg FreeBSD has nothing
+// to, and only root can create it under userRuntimeDirs.
+const runtimeDirFormat = "/tmp/%s-runtime-dir"
+
+// RuntimeDirOverride is the --runtime-dir: when set, it is the
+// XDG_RUNTIME_DIR whatever the environment says.
+var RuntimeDirOverride string
+
+// RuntimeDir is the XDG_RUNTIME_DIR for the current user: RuntimeDirOverride,
+// else the one the environment sets, else the one under userRuntimeDirs if
+// the login created it, else NiriSetup's own.
+func RuntimeDir() string {
+	if RuntimeDirOverride != "" {
+		return RuntimeDirOverride
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return defaultRuntimeDir()
+}
+
+// defaultRuntimeDir is RuntimeDir leaving the environment aside. Root can
+// create its own under userRuntimeDirs, where pam_xdg would, so it does
+// not need one in /tmp.
+func defaultRuntimeDir() string {
+	uid := fmt.Sprint(geteuid())
+	if info, err := os.Stat(filepath.Join(userRuntimeDirs, uid)); err == nil && info.IsDir() {
+		return filepath.Join(userRuntimeDirs, uid)
+	}
+	if info, err := os.Stat(userRuntimeDirs); err == nil && info.IsDir() && RunningAsRoot() {
+		return filepath.Join(userRuntimeDirs, uid)
+	}
+	return fmt.Sprintf(runtimeDirFormat, uid)
+}
+
+// SetUpRuntimeDir makes RuntimeDir ready for niri and sets XDG_RUNTIME_DIR
+// to it: it is created with mode 0700 if missing, and an existing one must
+// be a directory the user owns, whose mode is set back to 0700 if it lets
+// anyone else in. An XDG_RUNTIME_DIR from the environment that fails that
+// is replaced by the default one; a valid one is left as the session set
+// it. chosen says which directory it took and why, and report what it
+// fixed or replaced.
+func SetUpRuntimeDir(ctx context.Context) (chosen string, report []string, err error) {
+	dir, why := RuntimeDir(), ""
+	switch env := os.Getenv("XDG_RUNTIME_DIR"); {
+	case RuntimeDirOverride != "":
+		why = "as --runtime-dir asks"
+	case env == "":
+	case checkRuntimeDir(env) == nil:
+		why = "as the session set it"
+	default:
+		dir = defaultRuntimeDir()
+		report = append(report, fmt.Sprintf("Not using XDG_RUNTIME_DIR %s: %s; using %s", env, checkRuntimeDir(env), dir))
+	}
+	if why == "" {
+		why = "NiriSetup's own, since the session set none"
+		switch {
+		case filepath.Dir(dir) == userRuntimeDirs && fileExists(dir):
+			why = "the one the login created"
+		case filepath.Dir(dir) == userRuntimeDirs:
+			why = "root's own, since the session set none"
+		}
+	}
+	chosen = fmt.Sprintf("XDG_RUNTIME_DIR is %s, %s", dir, why)
+	os.Setenv("XDG_RUNTIME_DIR", dir)
+	if _, err := os.Lstat(dir); os.IsNotExist(err) {
+		if err := os.Mkdir(dir, 0700); err != nil {
+			return chosen, report, fmt.Errorf("failed to create XDG_RUNTIME_DIR %s: %w; check that you can write to %s (ls -ld %s)", dir, err, filepath.Dir(dir), filepath.Dir(dir))
+		}
+		return chosen, report, nil
+	}
+	if err := checkRuntimeDir(dir); err != nil {
+		return chosen, report, fmt.Errorf("XDG_RUNTIME_DIR %s is unusable, %w; remove it and start NiriSetup again", dir, err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return chosen, report, fmt.Errorf("failed to check XDG_RUNTIME_DIR: %w", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 && OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview(fmt.Sprintf("Would change the mode of XDG_RUNTIME_DIR %s from %04o to 0700", dir, perm))
+	} else if perm != 0700 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return chosen, report, fmt.Errorf("XDG_RUNTIME_DIR %s has mode %04o and could not be made private: %w; fix it with chmod 0700 %s", dir, perm, err, dir)
+		}
+		report = append(report, fmt.Sprintf("Changed the mode of XDG_RUNTIME_DIR %s from %04o to 0700", dir, perm))
+	}
+	return chosen, report, nil
+}
+
+// checkRuntimeDir returns why dir cannot be an XDG_RUNTIME_DIR: that it is
+// missing, not a directory or someone else's. Its mode can be fixed, so it
+// is not checked.
+func checkRuntimeDir(dir string) error {
+	info, err := os.Lstat(dir)
+	switch {
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return fmt.Errorf("not a directory")
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Geteuid() {
+		return fmt.Errorf("owned by uid %d, not you (uid %d)", st.Uid, os.Geteuid())
+	}
+	return nil
+}
+
+// StartScriptPath is where WriteStartScript puts the session start script.
+func StartScriptPath() string {
+	return filepath.Join(filepath.Dir(ConfigPath()), "start.sh")
+}
+
+// StartScript is a script that starts a niri session from a console login,
+// preparing XDG_RUNTIME_DIR the same way NiriSetup does.
+func StartScript() string {
+	return `#!/bin/sh
+# Generated by NiriSetup. Starts a niri session from a console login.
+
+if [ -z "$XDG_RUNTIME_DIR" ]; then
+	XDG_RUNTIME_DIR="` + userRuntimeDirs + `/$(id -u)"
+	[ -d "$XDG_RUNTIME_DIR" ] || { [ "$(id -u)" = 0 ] && [ -d "` + userRuntimeDirs + `" ]; } || XDG_RUNTIME_DIR="` + fmt.Sprintf(runtimeDirFormat, "$(id -u)") + `"
+fi
+export XDG_RUNTIME_DIR
+
+if [ ! -d "$XDG_RUNTIME_DIR" ]; then
+	mkdir -m 0700 "$XDG_RUNTIME_DIR" || exit 1
+fi
+if [ "$(stat -f %u "$XDG_RUNTIME_DIR")" != "$(id -u)" ]; then
+	echo "XDG_RUNTIME_DIR '$XDG_RUNTIME_DIR' is not owned by $(id -un)" >&2
+	exit 1
+fi
+if [ "$(stat -f %Lp "$XDG_RUNTIME_DIR")" != 700 ]; then
+	chmod 0700 "$XDG_RUNTIME_DIR" || exit 1
+fi
+
+exec niri --session
+`
+}
+
+// WriteStartScript saves StartScript as an executable next to the niri
+// config, along with an env file for the user's login shell that exports
+// the same XDG_RUNTIME_DIR.
+func WriteStartScript(ctx context.Context) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	report := []string{checked}
+	if err := writeFile(ctx, StartScriptPath(), []byte(StartScript()), 0755); err != nil {
+		return report, fmt.Errorf("failed to write start script: %w", err)
+	}
+	report = append(report, "Wrote "+StartScriptPath())
+
+	shell := UserShell()
+	report = append(report, "Detected login shell "+shell)
+	env, source, profile := loginFiles(shell)
+	exports := EnvExports(shell, [][2]string{{"XDG_RUNTIME_DIR", RuntimeDir()}})
+	if err := writeFile(ctx, env, []byte(exports), 0644); err != nil {
+		return report, fmt.Errorf("failed to write env file: %w", err)
+	}
+	return append(report, "Wrote "+env, fmt.Sprintf("Add '%s %s' to %s to set it at login", source, env, profile)), nil
+}
+
+// loginFiles returns, for shell, the env file WriteStartScript writes, the
+// command that reads it and the login file the shell runs at login, in ~
+// form.
+func loginFiles(shell string) (env, source, profile string) {
+	env, source, profile = filepath.Join(filepath.Dir(ConfigPath()), "env.sh"), ".", "~/.profile"
+	if isCsh(shell) {
+		env, source, profile = strings.TrimSuffix(env, ".sh")+".csh", "source", "~/.login"
+	}
+	return env, source, profile
+}
+
+// firstConsole is the tty of the first virtual terminal on FreeBSD.
+const firstConsole = "/dev/ttyv0"
+
+// autostartMarker starts the block SetUpAutostart adds to the login file,
+// so running it again can tell the block is already there.
+const autostartMarker = "# nirisetup autostart: start niri on the first console"
+
+// AutostartBlock is what SetUpAutostart adds to the login file of shell:
+// on the first console, and only when no Wayland session is running, it
+// reads the env file and replaces the login shell with the start script.
+func AutostartBlock(shell string) string {
+	env, source, _ := loginFiles(shell)
+	if isCsh(shell) {
+		return autostartMarker + "\n" +
+			"if ( ! $?WAYLAND_DISPLAY && \"`tty`\" == \"" + firstConsole + "\" ) then\n" +
+			"\t" + source + " " + shellQuote(env) + "\n" +
+			"\texec " + shellQuote(StartScriptPath()) + "\n" +
+			"endif\n" +
+			"# end nirisetup autostart\n"
+	}
+	return autostartMarker + "\n" +
+		"if [ -z \"$WAYLAND_DISPLAY\" ] && [ \"$(tty)\" = \"" + firstConsole + "\" ]; then\n" +
+		"\t" + source + " " + shellQuote(env) + "\n" +
+		"\texec " + shellQuote(StartScriptPath()) + "\n" +
+		"fi\n" +
+		"# end nirisetup autostart\n"
+}
+
+// SetUpAutostart makes a console login on the first virtual terminal start
+// niri: it writes the start script and env file, then appends
+// AutostartBlock to the login shell's ~/.profile or ~/.login. A block added
+// before is left alone.
+func SetUpAutostart(ctx context.Context) ([]string, error) {
+	report, err := WriteStartScript(ctx)
+	if err != nil {
+		return report, err
+	}
+	report = report[:len(report)-1] // the block sources the env file itself
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return report, err
+	}
+	shell := UserShell()
+	_, _, profile := loginFiles(shell)
+	path := filepath.Join(home, strings.TrimPrefix(profile, "~/"))
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return report, fmt.Errorf("failed to read %s: %w", profile, err)
+	}
+	if strings.Contains(string(existing), autostartMarker) {
+		return append(report, profile+" already starts niri on "+firstConsole+"; left it unchanged"), nil
+	}
+
+	block := AutostartBlock(shell)
+	sep := "\n" // a blank line before the block
+	switch {
+	case len(existing) == 0:
+		sep = ""
+	case !strings.HasSuffix(string(existing), "\n"):
+		sep = "\n\n"
+	}
+	// Rewritten whole rather than appended to, so an interruption cannot
+	// leave a login file with half a block that breaks every login
+	if err := writeFile(ctx, path, []byte(string(existing)+sep+block), 0644); err != nil {
+		return report, fmt.Errorf("failed to update %s: %w", profile, err)
+	}
+	report = append(report, "Added to "+profile+":")
+	for _, line := range strings.Split(strings.TrimRight(block, "\n"), "\n") {
+		report = append(report, "  "+line)
+	}
+	return report, nil
+}
+
+// UserShell returns the current user's login shell from the passwd
+// database, falling back to $SHELL and then /bin/sh.
+func UserShell() string {
+	if data, err := os.ReadFile("/etc/passwd"); err == nil {
+		uid := strconv.Itoa(os.Getuid())
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Split(line, ":")
+			if len(fields) >= 7 && fields[2] == uid && fields[6] != "" {
+				return fields[6]
+			}
+		}
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+// isCsh reports whether shell uses csh syntax, like tcsh, FreeBSD's default
+// shell for root.
+func isCsh(shell string) bool {
+	base := filepath.Base(shell)
+	return base == "csh" || base == "tcsh"
+}
+
+// EnvExports renders name/value pairs as environment settings in the syntax
+// of shell: setenv for csh and tcsh, export for everything else.
+func EnvExports(shell string, vars [][2]string) string {
+	var b strings.Builder
+	for _, v := range vars {
+		if isCsh(shell) {
+			fmt.Fprintf(&b, "setenv %s %s\n", v[0], shellQuote(v[1]))
+		} else {
+			fmt.Fprintf(&b, "export %s=%s\n", v[0], shellQuote(v[1]))
+		}
+	}
+	return b.String()
+}
+
+// InsideNiri reports whether NiriSetup runs inside a live niri session:
+// niri sets WAYLAND_DISPLAY and NIRI_SOCKET for its clients, and answers
+// niri msg on that socket.
+func InsideNiri() bool {
+	if os.Getenv("WAYLAND_DISPLAY") == "" || os.Getenv("NIRI_SOCKET") == "" {
+		return false
+	}
+	return Command("niri", "msg", "version").Run() == nil
+}
+
+// ReloadConfig asks the niri NiriSetup runs in to load its config again. An
+// invalid config is refused by niri, which keeps the one it has.
+func ReloadConfig(ctx context.Context) ([]string, error) {
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would run niri msg action load-config-file")
+		return nil, nil
+	}
+	if out, err := Command("niri", "msg", "action", "load-config-file").CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("niri did not reload its config: %s", strings.TrimSpace(string(out)))
+	}
+	return []string{"Reloaded " + ConfigPath()}, nil
+}
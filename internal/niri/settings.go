@@ -0,0 +1,62 @@
+package niri
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SettingsPath is where NiriSetup keeps its own preferences, one
+// "name = value" line each, with # starting a comment.
+func SettingsPath() string {
+	return filepath.Join(configHome(), "nirisetup", "settings")
+}
+
+// LoadSettings reads SettingsPath. There are no settings when the file
+// does not exist.
+func LoadSettings() (map[string]string, error) {
+	data, err := os.ReadFile(SettingsPath())
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSettings(string(data))
+}
+
+func parseSettings(src string) (map[string]string, error) {
+	settings := map[string]string{}
+	for i, line := range strings.Split(src, "\n") {
+		entry, _, _ := strings.Cut(line, "#")
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%s line %d: want name = value, got %q", SettingsPath(), i+1, strings.TrimSpace(line))
+		}
+		settings[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return settings, nil
+}
+
+// SaveSettings replaces SettingsPath with settings, sorted by name. It
+// writes in dry-run mode too, which they can turn off.
+func SaveSettings(settings map[string]string) error {
+	var b strings.Builder
+	b.WriteString("# NiriSetup preferences, set from its Settings screen.\n# Command-line flags override them.\n")
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		fmt.Fprintf(&b, "%s = %s\n", name, settings[name])
+	}
+	if err := os.MkdirAll(filepath.Dir(SettingsPath()), 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", filepath.Dir(SettingsPath()), err)
+	}
+	if err := replaceFile(SettingsPath(), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", SettingsPath(), err)
+	}
+	return nil
+}
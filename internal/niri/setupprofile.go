@@ -0,0 +1,248 @@
+package niri
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SetupProfileVersion is the version of the setup profile format
+// LoadSetupProfile reads.
+const SetupProfileVersion = 1
+
+// SetupProfile is a whole setup in one file, to share or to provision
+// machines with: the packages Install Niri installs, the starter niri
+// config Configure Niri writes, the starter tool configs it writes and the
+// services to enable after the install. What it leaves out stays as
+// NiriSetup would do it without one.
+type SetupProfile struct {
+	Path     string   // the file it was loaded from
+	Packages []string // instead of PackageList's
+	Config   string   // the starter config instead of DefaultConfig, "" for that
+	Toolset  *Toolset // the programs the built-in starter config starts, if set
+	Tools    []string // instead of DefaultConfigureTools, if set
+	Services []string // rc.d services to enable and start after the install
+}
+
+// setupProfileFile is a setup profile as it is written, e.g.
+//
+//	{
+//	    "version": 1,
+//	    "packages": ["niri", "foot", "waybar", "mako", "seatd"],
+//	    "toolset": {"terminal": "foot", "launcher": "fuzzel", "bar": "waybar"},
+//	    "tools": ["waybar", "mako"],
+//	    "services": ["dbus"]
+//	}
+//
+// with "config": "niri.kdl" naming a starter config, relative to the
+// profile, instead of a toolset.
+type setupProfileFile struct {
+	Version  int       `json:"version"`
+	Packages *[]string `json:"packages"`
+	Config   string    `json:"config"`
+	Toolset  *struct {
+		Terminal *string `json:"terminal"`
+		Launcher *string `json:"launcher"`
+		Bar      *string `json:"bar"`
+	} `json:"toolset"`
+	Tools    *[]string `json:"tools"`
+	Services []string  `json:"services"`
+}
+
+// serviceName matches the rc.d services a setup profile may enable, the
+// names their NAME_enable variables are made of.
+var serviceName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// SetupProfileError lists every problem found in a setup profile.
+type SetupProfileError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *SetupProfileError) Error() string {
+	return fmt.Sprintf("%s is not a valid setup profile:\n%s", e.Path, strings.Join(e.Problems, "\n"))
+}
+
+// LoadSetupProfile reads the setup profile at path, a JSON object, and
+// checks all of it: an unknown key, a package, tool or program that is not
+// one, a config niri's syntax rejects or a missing version makes it return
+// a *SetupProfileError naming every problem, so they can be fixed at once.
+func LoadSetupProfile(path string) (*SetupProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read setup profile: %w", err)
+	}
+	var f setupProfileFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, &SetupProfileError{Path: path, Problems: []string{"not a JSON object of the setup profile keys: " + err.Error()}}
+	}
+	var problems []string
+	switch f.Version {
+	case SetupProfileVersion:
+	case 0:
+		problems = append(problems, fmt.Sprintf("version: missing, want %d", SetupProfileVersion))
+	default:
+		problems = append(problems, fmt.Sprintf("version: is %d, this NiriSetup reads %d", f.Version, SetupProfileVersion))
+	}
+	p := &SetupProfile{Path: path, Services: f.Services}
+
+	names := func(key string, list []string, valid func(string) bool, what string) {
+		for i, name := range list {
+			switch {
+			case !valid(name):
+				problems = append(problems, fmt.Sprintf("%s entry %d: %q is not %s", key, i+1, name, what))
+			case slices.Index(list, name) < i:
+				problems = append(problems, fmt.Sprintf("%s entry %d: %s is listed twice", key, i+1, name))
+			}
+		}
+	}
+	if f.Packages != nil {
+		if p.Packages = *f.Packages; len(p.Packages) == 0 {
+			problems = append(problems, "packages: lists no packages; leave it out for the built-in list")
+		}
+		names("packages", p.Packages, packageName.MatchString, "a valid package name")
+	}
+	if f.Tools != nil {
+		p.Tools = *f.Tools
+		names("tools", p.Tools, func(t string) bool { return slices.Contains(ToolNames(), t) }, "one of "+strings.Join(ToolNames(), ", "))
+	}
+	names("services", p.Services, serviceName.MatchString, "a valid rc.d service name")
+
+	if f.Config != "" {
+		file := f.Config
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		src, err := os.ReadFile(file)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("config: %s", err))
+		case strings.TrimSpace(string(src)) == "":
+			problems = append(problems, fmt.Sprintf("config: %s is empty", file))
+		default:
+			if _, err := ParseConfig(string(src)); err != nil {
+				problems = append(problems, fmt.Sprintf("config: %s: %s", file, err))
+			}
+			p.Config = string(src)
+		}
+	}
+	if f.Toolset != nil {
+		if f.Config != "" {
+			problems = append(problems, "toolset: only shapes the built-in starter config, which config replaces")
+		}
+		t := DefaultToolset
+		for _, part := range []struct {
+			key      string
+			name     *string
+			programs []Program
+			set      func(Program)
+		}{
+			{"terminal", f.Toolset.Terminal, StarterTerminals, func(p Program) { t.Terminal = p }},
+			{"launcher", f.Toolset.Launcher, StarterLaunchers, func(p Program) { t.Launcher = p }},
+			{"bar", f.Toolset.Bar, append(slices.Clone(StarterBars), Program{}), func(p Program) { t.Bar = p }},
+		} {
+			if part.name == nil {
+				continue
+			}
+			i := slices.IndexFunc(part.programs, func(p Program) bool { return p.Package == *part.name })
+			if i < 0 {
+				var offered []string
+				for _, p := range part.programs {
+					offered = append(offered, cmp.Or(p.Package, `""`))
+				}
+				problems = append(problems, fmt.Sprintf("toolset: %s %q is not one of %s", part.key, *part.name, strings.Join(offered, ", ")))
+				continue
+			}
+			part.set(part.programs[i])
+		}
+		p.Toolset = &t
+	}
+	if len(problems) > 0 {
+		return nil, &SetupProfileError{Path: path, Problems: problems}
+	}
+	return p, nil
+}
+
+// setupProfile is the setup profile in use, nil without one.
+var setupProfile *SetupProfile
+
+// UseSetupProfile makes p drive the install and the configuration in
+// place of the built-in defaults: PackageList returns its packages,
+// Configure writes its starter config and DefaultConfigureTools become its
+// tools, where it sets them. nil goes back to the defaults, except for
+// DefaultConfigureTools.
+func UseSetupProfile(p *SetupProfile) {
+	setupProfile = p
+	if p != nil && p.Tools != nil {
+		DefaultConfigureTools = p.Tools
+	}
+}
+
+// CurrentSetupProfile returns the setup profile in use, nil without one.
+func CurrentSetupProfile() *SetupProfile {
+	return setupProfile
+}
+
+// StarterToolset is the Toolset Configure starts without being asked:
+// the setup profile's, or DefaultToolset.
+func StarterToolset() Toolset {
+	if setupProfile != nil && setupProfile.Toolset != nil {
+		return *setupProfile.Toolset
+	}
+	return DefaultToolset
+}
+
+// starterConfig is the starter config ConfigureWith writes for t: the
+// setup profile's if it has one, else DefaultConfig starting t.
+func starterConfig(t Toolset) string {
+	if setupProfile != nil && setupProfile.Config != "" {
+		return setupProfile.Config
+	}
+	return t.Config()
+}
+
+// EnableProfileServices enables each service of the setup profile in
+// rc.conf and starts the ones not running yet, reporting each step. A
+// service that fails does not stop the others; the error names them all.
+func EnableProfileServices(ctx context.Context) ([]string, error) {
+	if setupProfile == nil {
+		return nil, nil
+	}
+	var report, failed []string
+	for _, svc := range setupProfile.Services {
+		steps := [][]string{asRoot("sysrc", svc+"_enable=YES")}
+		line := "Enabled the " + svc + " service, which was already running"
+		if OptionsFrom(ctx).Preview != nil || Command("service", svc, "status").Run() != nil {
+			steps = append(steps, asRoot("service", svc, "start"))
+			line = "Enabled and started the " + svc + " service"
+		}
+		for _, args := range steps {
+			if OptionsFrom(ctx).Preview != nil {
+				OptionsFrom(ctx).Preview("Would run " + strings.Join(args, " "))
+				line = ""
+				continue
+			}
+			if out, err := Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+				line = fmt.Sprintf("Could not %s: %s", strings.Join(args, " "), strings.TrimSpace(cmp.Or(string(out), err.Error())))
+				failed = append(failed, svc)
+				break
+			}
+		}
+		if line != "" {
+			report = append(report, line)
+		}
+	}
+	if len(failed) > 0 {
+		return report, fmt.Errorf("could not enable the services %s", strings.Join(failed, ", "))
+	}
+	return report, nil
+}
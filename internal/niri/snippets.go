@@ -0,0 +1,101 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SnippetsDir holds the user's own config fragments, which Configure merges
+// into the niri config.
+func SnippetsDir() string {
+	return filepath.Join(configHome(), "nirisetup", "snippets")
+}
+
+// Snippet is a config fragment from SnippetsDir.
+type Snippet struct {
+	Name string // file name, e.g. "10-outputs.kdl"
+	Text string
+}
+
+// Snippets reads the .kdl files in SnippetsDir in file name order. A missing
+// directory just means there are none.
+func Snippets() ([]Snippet, error) {
+	paths, err := filepath.Glob(filepath.Join(SnippetsDir(), "*.kdl"))
+	if err != nil {
+		return nil, err
+	}
+	var snippets []Snippet
+	for _, path := range paths { // Glob sorts its matches
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snippet: %w", err)
+		}
+		snippets = append(snippets, Snippet{Name: filepath.Base(path), Text: string(data)})
+	}
+	return snippets, nil
+}
+
+// snippetStart and snippetEnd fence a snippet in the config, so configuring
+// again replaces it instead of appending a second copy.
+func snippetStart(name string) string { return "// nirisetup snippet: " + name + "\n" }
+func snippetEnd(name string) string   { return "// end nirisetup snippet: " + name + "\n" }
+
+// applySnippets appends each snippet to src, replacing the copy an earlier
+// run left there.
+func applySnippets(src string, snippets []Snippet) (string, error) {
+	for _, s := range snippets {
+		if _, err := ParseConfig(s.Text); err != nil {
+			return "", fmt.Errorf("snippet %s: %w", s.Name, err)
+		}
+		start, end := snippetStart(s.Name), snippetEnd(s.Name)
+		if i := strings.Index(src, start); i >= 0 {
+			if j := strings.Index(src[i:], end); j >= 0 {
+				src = src[:i] + src[i+j+len(end):]
+			}
+		}
+		if src != "" && !strings.HasSuffix(src, "\n") {
+			src += "\n"
+		}
+		text := s.Text
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		src += start + text + end
+	}
+	return src, nil
+}
+
+// configureSnippets merges the user's snippets into the niri config, or into
+// DefaultConfig when there is none yet, and writes the result if niri accepts
+// it. It returns a line per snippet applied.
+func configureSnippets(ctx context.Context) ([]string, error) {
+	snippets, err := Snippets()
+	if err != nil || len(snippets) == 0 {
+		return nil, err
+	}
+	src := DefaultConfig
+	data, err := os.ReadFile(ConfigPath())
+	if err == nil {
+		src = string(data)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	src, err = applySnippets(src, snippets)
+	if err != nil {
+		return nil, err
+	}
+	if problem, err := SaveSource(ctx, src); err != nil {
+		if problem != "" {
+			return nil, fmt.Errorf("%w with snippets from %s: %s", err, SnippetsDir(), strings.TrimSpace(problem))
+		}
+		return nil, err
+	}
+	var report []string
+	for _, s := range snippets {
+		report = append(report, "Applied snippet "+s.Name)
+	}
+	return report, nil
+}
@@ -0,0 +1,89 @@
+package niri
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SetupStatus is how far NiriSetup has set the machine up, for
+// provisioning tools that want to know whether another run would change
+// anything.
+type SetupStatus struct {
+	Complete bool    `json:"complete"` // no check failed, warnings aside
+	Checks   []Check `json:"checks"`
+}
+
+// Status checks that the default packages are installed, that the niri
+// config exists and validates and that something starts niri, then runs
+// the Doctor checks that concern the machine. The session checks are left
+// out: a compositor running right now says nothing about the setup.
+func Status() SetupStatus {
+	checks := slices.Concat([]Check{packagesCheck(), ConfigCheck(), startCheck()}, []Check{VersionCheck(), MemoryCheck()}, LaunchChecks())
+	return SetupStatus{
+		Complete: !slices.ContainsFunc(checks, func(c Check) bool { return !c.OK && !c.Warn }),
+		Checks:   checks,
+	}
+}
+
+// packagesCheck checks that every one of DefaultPackages is installed.
+func packagesCheck() Check {
+	c := Check{Name: "Packages installed"}
+	installed, err := InstalledPackages()
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+	missing := slices.Clone(DefaultPackages)
+	for _, p := range installed {
+		missing = slices.DeleteFunc(missing, func(name string) bool { return name == p.Name })
+	}
+	if len(missing) > 0 {
+		c.Detail = "missing " + strings.Join(missing, ", ") + "; run Install Niri"
+		return c
+	}
+	c.OK, c.Detail = true, fmt.Sprintf("all %d default packages", len(DefaultPackages))
+	return c
+}
+
+// ConfigCheck checks that the niri config exists and niri accepts it.
+func ConfigCheck() Check {
+	c := Check{Name: "Config valid", Detail: ConfigPath()}
+	if _, err := os.Stat(ConfigPath()); err != nil {
+		c.Detail = ConfigPath() + " does not exist; run Configure Niri"
+		return c
+	}
+	if out, err := ValidateFile(ConfigPath()); err != nil {
+		c.Detail = strings.TrimSpace(out + " " + err.Error())
+		return c
+	}
+	c.OK = true
+	return c
+}
+
+// startCheck checks that niri starts without being launched by hand: from
+// the console login SetUpAutostart sets up, or from a login manager
+// offering a niri session.
+func startCheck() Check {
+	c := Check{Name: "niri starts at login"}
+	home, _ := os.UserHomeDir()
+	_, _, profile := loginFiles(UserShell())
+	if data, err := os.ReadFile(filepath.Join(home, strings.TrimPrefix(profile, "~/"))); err == nil && strings.Contains(string(data), autostartMarker) {
+		c.OK, c.Detail = true, profile+" starts niri on "+firstConsole
+		return c
+	}
+	session := filepath.Join(WaylandSessionsDir, "niri.desktop")
+	if _, err := os.Stat(session); err == nil {
+		for _, dm := range DisplayManagers {
+			out, _ := Command("sysrc", "-n", "-i", dm.Service+"_enable").Output()
+			if strings.EqualFold(strings.TrimSpace(string(out)), "YES") {
+				c.OK, c.Detail = true, dm.Name+" is enabled and offers "+session
+				return c
+			}
+		}
+	}
+	c.Detail = "neither a console login nor a login manager starts it; run Start on Console Login or Set Up Login Manager"
+	return c
+}
@@ -0,0 +1,318 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SwayConfigs returns the sway and i3 configs found in the places those
+// compositors look, most likely first.
+func SwayConfigs() []string {
+	home, _ := os.UserHomeDir()
+	var found []string
+	for _, path := range []string{
+		filepath.Join(configHome(), "sway", "config"),
+		filepath.Join(home, ".sway", "config"),
+		filepath.Join(configHome(), "i3", "config"),
+		filepath.Join(home, ".i3", "config"),
+	} {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			found = append(found, path)
+		}
+	}
+	return found
+}
+
+// swayModifiers maps sway modifier names onto niri's. Mod4 becomes Mod,
+// which niri maps to Super, as the usual $mod of a sway config.
+var swayModifiers = map[string]string{
+	"Mod4":    "Mod",
+	"Mod1":    "Alt",
+	"Shift":   "Shift",
+	"Control": "Ctrl",
+	"Ctrl":    "Ctrl",
+}
+
+// swayActions maps sway commands without arguments onto niri actions.
+var swayActions = map[string]string{
+	"kill":              "close-window",
+	"exit":              "quit",
+	"fullscreen":        "fullscreen-window",
+	"fullscreen toggle": "fullscreen-window",
+	"floating toggle":   "toggle-window-floating",
+	"focus left":        "focus-column-left",
+	"focus right":       "focus-column-right",
+	"focus up":          "focus-window-up",
+	"focus down":        "focus-window-down",
+	"move left":         "move-column-left",
+	"move right":        "move-column-right",
+	"move up":           "move-window-up",
+	"move down":         "move-window-down",
+}
+
+// shellChars mark an exec command that needs a shell to run.
+const shellChars = "|&;<>()$`\\\"'*?[]~"
+
+// swayTranslation collects the niri nodes a sway config turns into.
+type swayTranslation struct {
+	outputs []string
+	startup []string
+	binds   []string
+	skipped []string
+}
+
+// TranslateSway turns the keybinds, outputs and autostart commands of a
+// sway or i3 config into a niri config. Everything it does not understand
+// is returned in skipped, one entry per line, as "line N: text".
+func TranslateSway(src, from string) (kdl string, skipped []string) {
+	var t swayTranslation
+	vars := map[string]string{}
+	depth := 0
+	lines := strings.Split(strings.ReplaceAll(src, "\\\n", " "), "\n")
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		skip := func() { t.skipped = append(t.skipped, fmt.Sprintf("line %d: %s", i+1, line)) }
+		// Blocks (bar, mode, input, ...) are reported by their first line.
+		if depth > 0 {
+			depth += strings.Count(line, "{") - strings.Count(line, "}")
+			continue
+		}
+		if strings.HasSuffix(line, "{") {
+			depth = 1
+			skip()
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if fields[0] == "set" && len(fields) >= 3 && strings.HasPrefix(fields[1], "$") {
+			vars[fields[1]] = strings.Join(fields[2:], " ")
+			continue
+		}
+		fields = strings.Fields(expandSwayVars(line, vars))
+
+		switch fields[0] {
+		case "bindsym":
+			if !t.bind(fields[1:]) {
+				skip()
+			}
+		case "exec", "exec_always":
+			args := swayExecArgs(fields[1:])
+			if len(args) == 0 {
+				skip()
+				continue
+			}
+			t.startup = append(t.startup, FormatNode("spawn-at-startup", args...))
+		case "output":
+			if !t.output(fields[1:]) {
+				skip()
+			}
+		default:
+			skip()
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Imported from %s by NiriSetup.\n", from)
+	for _, o := range t.outputs {
+		b.WriteString("\n" + o)
+	}
+	if len(t.startup) > 0 {
+		b.WriteString("\n" + strings.Join(t.startup, "\n") + "\n")
+	}
+	if len(t.binds) > 0 {
+		b.WriteString("\nbinds {\n")
+		for _, bind := range t.binds {
+			b.WriteString("    " + bind + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String(), t.skipped
+}
+
+// expandSwayVars substitutes the config's $variables into line, longest
+// name first so $mod does not eat the start of $modifier.
+func expandSwayVars(line string, vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int { return len(b) - len(a) })
+	for _, name := range names {
+		line = strings.ReplaceAll(line, name, vars[name])
+	}
+	return line
+}
+
+// swayExecArgs turns the rest of an exec line into spawn arguments, going
+// through sh -c when the command needs a shell.
+func swayExecArgs(fields []string) []string {
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "--") { // --no-startup-id
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	cmd := strings.Join(fields, " ")
+	if strings.ContainsAny(cmd, shellChars) {
+		return []string{"sh", "-c", cmd}
+	}
+	return fields
+}
+
+// bind translates the arguments of a bindsym line, reporting whether it
+// could.
+func (t *swayTranslation) bind(fields []string) bool {
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return false
+	}
+	var keys []string
+	for _, part := range strings.Split(fields[0], "+") {
+		if mod, ok := swayModifiers[part]; ok {
+			part = mod
+		}
+		keys = append(keys, part)
+	}
+	key := strings.Join(keys, "+")
+
+	cmd := fields[1:]
+	if slices.ContainsFunc(cmd, func(f string) bool { return strings.ContainsAny(f, ";,") }) && cmd[0] != "exec" {
+		return false // several commands chained together
+	}
+	var action string
+	switch {
+	case cmd[0] == "exec":
+		args := swayExecArgs(cmd[1:])
+		if len(args) == 0 {
+			return false
+		}
+		action = FormatNode("spawn", args...)
+	case swayActions[strings.Join(cmd, " ")] != "":
+		action = swayActions[strings.Join(cmd, " ")]
+	default:
+		n, ok := swayWorkspace(cmd)
+		if !ok {
+			return false
+		}
+		action = "focus-workspace " + n
+		if cmd[0] == "move" {
+			action = "move-column-to-workspace " + n
+		}
+	}
+	t.binds = append(t.binds, key+" { "+action+"; }")
+	return true
+}
+
+// swayWorkspace returns the number in "workspace [number] N" or
+// "move [container|window] [to] workspace [number] N". niri only has
+// numbered workspaces without extra config, so named ones are not
+// translated.
+func swayWorkspace(cmd []string) (string, bool) {
+	if cmd[0] == "move" {
+		cmd = slices.DeleteFunc(slices.Clone(cmd[1:]), func(f string) bool {
+			return f == "container" || f == "window" || f == "to"
+		})
+	}
+	if len(cmd) == 0 || cmd[0] != "workspace" {
+		return "", false
+	}
+	cmd = cmd[1:]
+	if len(cmd) > 0 && cmd[0] == "number" {
+		cmd = cmd[1:]
+	}
+	if len(cmd) != 1 {
+		return "", false
+	}
+	if _, err := strconv.Atoi(cmd[0]); err != nil {
+		return "", false
+	}
+	return cmd[0], true
+}
+
+// output translates the arguments of an output line: its mode, position,
+// scale and transform, or disable. Wallpapers and everything else are left
+// out, which counts as not understood.
+func (t *swayTranslation) output(fields []string) bool {
+	if len(fields) < 2 || fields[0] == "*" {
+		return false
+	}
+	name := strings.Trim(fields[0], `"`)
+	var children []string
+	rest := fields[1:]
+	for len(rest) > 0 {
+		switch {
+		case (rest[0] == "mode" || rest[0] == "resolution" || rest[0] == "res") && len(rest) >= 2:
+			mode := strings.TrimSuffix(strings.TrimSuffix(rest[1], "Hz"), "hz")
+			children = append(children, FormatNode("mode", mode))
+			rest = rest[2:]
+		case (rest[0] == "pos" || rest[0] == "position") && len(rest) >= 3:
+			children = append(children, fmt.Sprintf("position x=%s y=%s", rest[1], rest[2]))
+			rest = rest[3:]
+		case rest[0] == "scale" && len(rest) >= 2:
+			children = append(children, "scale "+rest[1])
+			rest = rest[2:]
+		case rest[0] == "transform" && len(rest) >= 2:
+			children = append(children, FormatNode("transform", rest[1]))
+			rest = rest[2:]
+		case rest[0] == "disable":
+			children = append(children, "off")
+			rest = rest[1:]
+		default:
+			return false
+		}
+	}
+	var b strings.Builder
+	b.WriteString(FormatNode("output", name) + " {\n")
+	for _, c := range children {
+		b.WriteString("    " + c + "\n")
+	}
+	b.WriteString("}\n")
+	t.outputs = append(t.outputs, b.String())
+	return true
+}
+
+// ImportSwayConfig replaces the niri config with a translation of the sway
+// or i3 config at path, after niri has validated it. The old config is
+// backed up first. What could not be translated is listed in the report.
+func ImportSwayConfig(ctx context.Context, path string) ([]string, error) {
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	report := []string{checked}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	_, statErr := os.Stat(ConfigPath())
+	kdl, skipped := TranslateSway(string(src), path)
+	if out, err := SaveSource(ctx, kdl); err != nil {
+		if out != "" {
+			report = append(report, out)
+		}
+		return report, err
+	}
+	if OptionsFrom(ctx).Preview == nil && statErr == nil {
+		if backups, err := Backups(); err == nil && len(backups) > 0 {
+			report = append(report, "Backed up the previous config to "+backups[0])
+		}
+	}
+	report = append(report, "Imported "+path+" into "+ConfigPath())
+	if len(skipped) > 0 {
+		report = append(report, fmt.Sprintf("Could not translate %d lines:", len(skipped)))
+		for _, s := range skipped {
+			report = append(report, "  "+s)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,243 @@
+package niri
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTranslateSway(t *testing.T) {
+	src := `# sway config
+set $mod Mod4
+set $term foot
+set $menu wofi --show drun
+
+output eDP-1 mode 1920x1080@60Hz pos 0 0 scale 1.5
+output HDMI-A-1 resolution 2560x1440 position 1920 0 transform 90
+output DP-2 disable
+output * bg ~/wall.png fill
+
+exec mako
+exec_always --no-startup-id waybar -c /home/me/.config/waybar/config
+exec swayidle -w timeout 300 'swaylock -f'
+
+bindsym $mod+Return exec $term
+bindsym $mod+d exec $menu
+bindsym $mod+Shift+q kill
+bindsym $mod+1 workspace number 1
+bindsym $mod+x splith; layout tabbed
+mode "resize" {
+    bindsym Left resize shrink width 10px
+}
+bar {
+    swaybar_command waybar
+}
+input type:keyboard xkb_layout us
+`
+	kdl, skipped := TranslateSway(src, "/home/me/.config/sway/config")
+	want := `// Imported from /home/me/.config/sway/config by NiriSetup.
+
+output "eDP-1" {
+    mode "1920x1080@60"
+    position x=0 y=0
+    scale 1.5
+}
+
+output "HDMI-A-1" {
+    mode "2560x1440"
+    position x=1920 y=0
+    transform "90"
+}
+
+output "DP-2" {
+    off
+}
+
+spawn-at-startup "mako"
+spawn-at-startup "waybar" "-c" "/home/me/.config/waybar/config"
+spawn-at-startup "sh" "-c" "swayidle -w timeout 300 'swaylock -f'"
+
+binds {
+    Mod+Return { spawn "foot"; }
+    Mod+d { spawn "wofi" "--show" "drun"; }
+    Mod+Shift+q { close-window; }
+    Mod+1 { focus-workspace 1; }
+}
+`
+	if kdl != want {
+		t.Errorf("the translation is\n%s\nwant\n%s", kdl, want)
+	}
+	if _, err := ParseConfig(kdl); err != nil {
+		t.Errorf("the translation does not parse: %v", err)
+	}
+	// what it left out is reported as written, a block by its first line
+	wantSkipped := []string{
+		"line 9: output * bg ~/wall.png fill",
+		"line 19: bindsym $mod+x splith; layout tabbed",
+		`line 20: mode "resize" {`,
+		"line 23: bar {",
+		"line 26: input type:keyboard xkb_layout us",
+	}
+	if !slices.Equal(skipped, wantSkipped) {
+		t.Errorf("skipped %q, want %q", skipped, wantSkipped)
+	}
+}
+
+func TestTranslateSwayBinds(t *testing.T) {
+	for _, tt := range []struct {
+		line, want string // want is "" when the line cannot be translated
+	}{
+		{line: "bindsym Mod4+Shift+c kill", want: "Mod+Shift+c { close-window; }"},
+		{line: "bindsym Mod1+Control+l exec swaylock -f", want: `Alt+Ctrl+l { spawn "swaylock" "-f"; }`},
+		{line: "bindsym --release --to-code Ctrl+Print exec grim", want: `Ctrl+Print { spawn "grim"; }`},
+		{line: "bindsym Mod4+p exec --no-startup-id grim -g \"$(slurp)\"", want: `Mod+p { spawn "sh" "-c" "grim -g \"$(slurp)\""; }`},
+		{line: "bindsym Mod4+Return exec foot; exec mako", want: `Mod+Return { spawn "sh" "-c" "foot; exec mako"; }`},
+		{line: "bindsym Mod4+f fullscreen toggle", want: "Mod+f { fullscreen-window; }"},
+		{line: "bindsym Mod4+space floating toggle", want: "Mod+space { toggle-window-floating; }"},
+		{line: "bindsym Mod4+Shift+e exit", want: "Mod+Shift+e { quit; }"},
+		{line: "bindsym Mod4+h focus left", want: "Mod+h { focus-column-left; }"},
+		{line: "bindsym Mod4+k focus up", want: "Mod+k { focus-window-up; }"},
+		{line: "bindsym Mod4+Shift+l move right", want: "Mod+Shift+l { move-column-right; }"},
+		{line: "bindsym Mod4+Shift+j move down", want: "Mod+Shift+j { move-window-down; }"},
+		{line: "bindsym Mod4+3 workspace 3", want: "Mod+3 { focus-workspace 3; }"},
+		{line: "bindsym Mod4+Shift+3 move window to workspace 3", want: "Mod+Shift+3 { move-column-to-workspace 3; }"},
+		{line: "bindsym Mod4+Shift+4 move container to workspace number 4", want: "Mod+Shift+4 { move-column-to-workspace 4; }"},
+		{line: "bindsym Mod4+w workspace web"},
+		{line: "bindsym Mod4+Shift+w move container to workspace web"},
+		{line: "bindsym Mod4+x splith; layout tabbed"},
+		{line: "bindsym Mod4+r mode resize"},
+		{line: "bindsym Mod4+Tab focus next"},
+		{line: "bindsym Mod4+e exec"},
+		{line: "bindsym Mod4+e"},
+	} {
+		kdl, skipped := TranslateSway(tt.line+"\n", "sway")
+		cfg, err := ParseConfig(kdl)
+		if err != nil {
+			t.Errorf("%s: the translation does not parse: %v", tt.line, err)
+			continue
+		}
+		var got string
+		if binds := cfg.Section("binds"); binds != nil {
+			got = strings.TrimSpace(kdl[binds.Children[0].start : binds.Children[0].close+1])
+		}
+		if got != tt.want {
+			t.Errorf("%s: translated to %q, want %q", tt.line, got, tt.want)
+		}
+		if wantSkipped := tt.want == ""; (len(skipped) == 1) != wantSkipped {
+			t.Errorf("%s: skipped %q", tt.line, skipped)
+		}
+	}
+}
+
+func TestTranslateSwayOutputs(t *testing.T) {
+	for _, tt := range []struct {
+		line, want string // want is "" when the line cannot be translated
+	}{
+		{line: "output DP-1 mode 3840x2160@144Hz", want: "output \"DP-1\" {\n    mode \"3840x2160@144\"\n}\n"},
+		{line: "output DP-1 res 1280x1024", want: "output \"DP-1\" {\n    mode \"1280x1024\"\n}\n"},
+		{line: `output "HDMI-A-1" pos -1920 0`, want: "output \"HDMI-A-1\" {\n    position x=-1920 y=0\n}\n"},
+		{line: "output DP-1 transform flipped-90", want: "output \"DP-1\" {\n    transform \"flipped-90\"\n}\n"},
+		{line: "output DP-1 bg ~/wall.png fill"},
+		{line: "output * scale 2"},
+		{line: "output DP-1 mode"},
+		{line: "output DP-1"},
+	} {
+		kdl, skipped := TranslateSway(tt.line+"\n", "sway")
+		got, _ := strings.CutPrefix(kdl, "// Imported from sway by NiriSetup.\n\n")
+		if tt.want == "" {
+			if len(skipped) != 1 || strings.Contains(kdl, "output") {
+				t.Errorf("%s: translated to %q, skipped %q, want it skipped", tt.line, kdl, skipped)
+			}
+			continue
+		}
+		if got != tt.want || len(skipped) > 0 {
+			t.Errorf("%s: translated to %q, skipped %q, want %q", tt.line, got, skipped, tt.want)
+		}
+	}
+}
+
+func TestTranslateSwayVariablesAndContinuations(t *testing.T) {
+	src := "set $mod Mod4\nset $modifier Mod1\nbindsym $modifier+t exec foot\nbindsym $mod+b \\\n    exec firefox\n"
+	kdl, skipped := TranslateSway(src, "sway")
+	// $modifier is substituted whole instead of as $mod and "ifier"
+	for _, want := range []string{`Alt+t { spawn "foot"; }`, `Mod+b { spawn "firefox"; }`} {
+		if !strings.Contains(kdl, want) {
+			t.Errorf("the translation lacks %q:\n%s", want, kdl)
+		}
+	}
+	if len(skipped) > 0 {
+		t.Errorf("skipped %q", skipped)
+	}
+}
+
+func TestSwayConfigs(t *testing.T) {
+	home, config := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", config)
+	if got := SwayConfigs(); len(got) != 0 {
+		t.Errorf("SwayConfigs found %q without any", got)
+	}
+	i3, sway := filepath.Join(home, ".i3", "config"), filepath.Join(config, "sway", "config")
+	for _, path := range []string{i3, sway} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("exec foot\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a directory where a config would be is not one
+	if err := os.MkdirAll(filepath.Join(config, "i3", "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := SwayConfigs(); !slices.Equal(got, []string{sway, i3}) {
+		t.Errorf("SwayConfigs = %q, want sway's before i3's", got)
+	}
+}
+
+func TestImportSwayConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir()) // no niri to validate with
+	sway := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(sway, []byte("bindsym Mod4+Return exec foot\nbar {\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigPath(), []byte("prefer-no-csd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var previewed []string
+	ctx := WithOptions(context.Background(), Options{Preview: func(change string) { previewed = append(previewed, change) }})
+	if _, err := ImportSwayConfig(ctx, sway); err != nil || len(previewed) == 0 {
+		t.Fatalf("a dry run import returned %v, previewing %q", err, previewed)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != "prefer-no-csd\n" {
+		t.Errorf("a dry run import wrote\n%s", data)
+	}
+
+	report, err := ImportSwayConfig(context.Background(), sway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(ConfigPath())
+	if !strings.Contains(string(data), `Mod+Return { spawn "foot"; }`) || strings.Contains(string(data), "prefer-no-csd") {
+		t.Errorf("the import wrote\n%s", data)
+	}
+	text := strings.Join(report, "\n")
+	for _, want := range []string{"Backed up the previous config to ", "Imported " + sway, "Could not translate 1 lines:\n  line 2: bar {"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the report lacks %q:\n%s", want, text)
+		}
+	}
+
+	if _, err := ImportSwayConfig(context.Background(), filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("importing a missing sway config succeeded")
+	}
+}
@@ -0,0 +1,63 @@
+package niri
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Theme is the palette of NiriSetup's own screens. Each color is a hex
+// color such as "#ffa07a" or an ANSI color number from 0 to 255; "" keeps
+// the default, which adapts to light and dark terminals.
+type Theme struct {
+	Accent  string `json:"accent"`  // the title and the highlighted entry
+	Text    string `json:"text"`    // results, logs and questions
+	Dim     string `json:"dim"`     // the other entries and key hints
+	Success string `json:"success"` // what is running, progress and what worked
+	Warning string `json:"warning"` // what may go wrong
+	Error   string `json:"error"`   // what failed
+	Border  string `json:"border"`  // the frame around help and notices
+}
+
+// ThemePath is where NiriSetup reads its Theme from.
+func ThemePath() string {
+	return filepath.Join(configHome(), "nirisetup", "theme.json")
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// LoadTheme reads ThemePath. Without the file every color is the default;
+// a color it cannot use is an error naming it, and the others still apply.
+func LoadTheme() (Theme, error) {
+	var t Theme
+	data, err := os.ReadFile(ThemePath())
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return t, fmt.Errorf("failed to read %s: %w", ThemePath(), err)
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Theme{}, fmt.Errorf("%s is not a theme: %w", ThemePath(), err)
+	}
+	for _, c := range []struct {
+		name  string
+		color *string
+	}{
+		{"accent", &t.Accent}, {"text", &t.Text}, {"dim", &t.Dim},
+		{"success", &t.Success}, {"warning", &t.Warning}, {"error", &t.Error}, {"border", &t.Border},
+	} {
+		if *c.color == "" || hexColor.MatchString(*c.color) {
+			continue
+		}
+		if n, err := strconv.Atoi(*c.color); err == nil && n >= 0 && n <= 255 {
+			continue
+		}
+		err = fmt.Errorf("%s: %s is %q, want a color like #ffa07a or a number from 0 to 255", ThemePath(), c.name, *c.color)
+		*c.color = ""
+	}
+	return t, err
+}
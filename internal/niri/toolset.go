@@ -0,0 +1,82 @@
+package niri
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Program is a terminal, launcher or bar the starter config can start:
+// the package it comes in and the command niri runs for it.
+type Program struct {
+	Package string
+	Spawn   []string
+}
+
+// Installed reports whether the command of p is on the PATH.
+func (p Program) Installed() bool {
+	_, err := exec.LookPath(p.Spawn[0])
+	return err == nil
+}
+
+// StarterTerminals, StarterLaunchers and StarterBars are the programs
+// Configure Niri offers for each part of the Toolset, the one
+// DefaultConfig uses first. The launchers are those of Launchers, which
+// Configure Clipboard can also pick from.
+var (
+	StarterTerminals = []Program{
+		{"alacritty", []string{"alacritty"}},
+		{"foot", []string{"foot"}},
+		{"kitty", []string{"kitty"}},
+	}
+	StarterLaunchers = []Program{
+		{"fuzzel", []string{"fuzzel"}},
+		{"wofi", []string{"wofi", "--show", "drun"}},
+	}
+	StarterBars = []Program{
+		{"waybar", []string{"waybar"}},
+		{"yambar", []string{"yambar"}},
+	}
+)
+
+// Toolset is the terminal Mod+T opens, the launcher Mod+D opens and the bar
+// niri starts in the config Configure writes. A Bar with no Package starts
+// no bar.
+type Toolset struct {
+	Terminal, Launcher, Bar Program
+}
+
+// DefaultToolset is the Toolset of DefaultConfig.
+var DefaultToolset = Toolset{Terminal: StarterTerminals[0], Launcher: StarterLaunchers[0], Bar: StarterBars[0]}
+
+// Config returns DefaultConfig starting the programs of t instead of its
+// own.
+func (t Toolset) Config() string {
+	d := DefaultToolset
+	cfg := strings.Replace(DefaultConfig, "Mod+T { "+FormatNode("spawn", d.Terminal.Spawn...)+"; }", "Mod+T { "+FormatNode("spawn", t.Terminal.Spawn...)+"; }", 1)
+	cfg = strings.Replace(cfg, "Mod+D { "+FormatNode("spawn", d.Launcher.Spawn...)+"; }", "Mod+D { "+FormatNode("spawn", t.Launcher.Spawn...)+"; }", 1)
+	bar := FormatNode("spawn-at-startup", d.Bar.Spawn...) + "\n"
+	if t.Bar.Package == "" {
+		return strings.Replace(cfg, bar, "", 1)
+	}
+	return strings.Replace(cfg, bar, FormatNode("spawn-at-startup", t.Bar.Spawn...)+"\n", 1)
+}
+
+// missing reports the programs of t that are not installed, with what
+// that means for the session.
+func (t Toolset) missing() []string {
+	var report []string
+	for _, p := range []struct {
+		program Program
+		effect  string
+	}{
+		{t.Terminal, "Mod+T opens nothing"},
+		{t.Launcher, "Mod+D opens nothing"},
+		{t.Bar, "niri starts without a bar"},
+	} {
+		if p.program.Package != "" && !p.program.Installed() {
+			report = append(report, fmt.Sprintf("Warning: %s is not installed, so %s until it is (pkg install %s)", p.program.Spawn[0], p.effect, p.program.Package))
+		}
+	}
+	return report
+}
@@ -0,0 +1,192 @@
+package niri
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Manifest is what NiriSetup's installs found: the packages they
+// installed, which Uninstall may remove, and the ones that were already
+// there, which it never does.
+type Manifest struct {
+	Version   string            `json:"version"` // of the NiriSetup that last changed it
+	Updated   string            `json:"updated"` // when, in RFC 3339
+	Installed []ManifestPackage `json:"installed"`
+	Present   []string          `json:"present"`
+}
+
+// ManifestPackage is a package NiriSetup installed, with when and which
+// version of NiriSetup installed it. Packages recorded before the manifest
+// had times have neither.
+type ManifestPackage struct {
+	Name    string `json:"name"`
+	Time    string `json:"time,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// ManifestPath is where the Manifest is kept.
+func ManifestPath() string {
+	return filepath.Join(configHome(), "nirisetup", "installed.json")
+}
+
+// legacyManifestPath is where NiriSetup recorded the packages it installed
+// before the Manifest, one per line in install order.
+func legacyManifestPath() string {
+	return filepath.Join(stateHome(), "nirisetup", "installed")
+}
+
+// LoadManifest reads the Manifest, or the list of packages that came
+// before it. Without either nothing has been installed.
+func LoadManifest() (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(ManifestPath())
+	if err == nil {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return Manifest{}, fmt.Errorf("%s is not a manifest: %w", ManifestPath(), err)
+		}
+		return m, nil
+	}
+	if !os.IsNotExist(err) {
+		return m, fmt.Errorf("failed to read %s: %w", ManifestPath(), err)
+	}
+	data, err = os.ReadFile(legacyManifestPath())
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("failed to read %s: %w", legacyManifestPath(), err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if pkg := strings.TrimSpace(line); pkg != "" && !m.installed(pkg) {
+			m.Installed = append(m.Installed, ManifestPackage{Name: pkg})
+		}
+	}
+	return m, nil
+}
+
+// installed reports whether m records NiriSetup installing pkg.
+func (m Manifest) installed(pkg string) bool {
+	return slices.ContainsFunc(m.Installed, func(p ManifestPackage) bool { return p.Name == pkg })
+}
+
+// saveManifest writes m, stamped with this version and the time, in place
+// of the list that came before it.
+func saveManifest(ctx context.Context, m Manifest) error {
+	m.Version, m.Updated = Version, time.Now().UTC().Format(time.RFC3339)
+	path := ManifestPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(ctx, path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	os.Remove(legacyManifestPath()) // its packages are in the manifest now
+	return nil
+}
+
+// SetupPackages returns the packages NiriSetup installed that were not
+// installed before, oldest first.
+func SetupPackages() ([]string, error) {
+	m, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []string
+	for _, p := range m.Installed {
+		pkgs = append(pkgs, p.Name)
+	}
+	return pkgs, nil
+}
+
+// recordInstalled adds pkg to the packages the Manifest says NiriSetup
+// installed. Losing the record only means Uninstall leaves pkg alone, so a
+// failure is ignored.
+func recordInstalled(ctx context.Context, pkg string) {
+	m, err := LoadManifest()
+	if err != nil || m.installed(pkg) {
+		return
+	}
+	m.Installed = append(m.Installed, ManifestPackage{Name: pkg, Time: time.Now().UTC().Format(time.RFC3339), Version: Version})
+	m.Present = slices.DeleteFunc(m.Present, func(p string) bool { return p == pkg })
+	saveManifest(ctx, m)
+}
+
+// recordPresent adds pkg, which an install found already there, to the
+// Manifest, unless NiriSetup installed it in the first place. Like
+// recordInstalled it ignores a failure.
+func recordPresent(ctx context.Context, pkg string) {
+	m, err := LoadManifest()
+	if err != nil || m.installed(pkg) || slices.Contains(m.Present, pkg) {
+		return
+	}
+	m.Present = append(m.Present, pkg)
+	saveManifest(ctx, m)
+}
+
+// dependents returns the installed packages that depend on pkg.
+func dependents(pkg string) ([]string, error) {
+	out, err := Command("pkg", "query", "%rn", pkg).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up what depends on %s: %w", pkg, err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// Uninstall removes pkgs, which come from SetupPackages, newest first.
+// pkg delete takes whatever depends on a package with it, so a package
+// that something NiriSetup did not install still needs is kept. It
+// reports each package and carries on past a failure; the ones gone are
+// dropped from the Manifest.
+func Uninstall(ctx context.Context, pkgs []string) ([]string, error) {
+	var report, gone, failed []string
+	for _, pkg := range slices.Backward(pkgs) {
+		if !PackageInstalled(pkg) {
+			report = append(report, pkg+" is no longer installed")
+			gone = append(gone, pkg)
+			continue
+		}
+		needed, err := dependents(pkg)
+		if err != nil {
+			report = append(report, err.Error())
+			failed = append(failed, pkg)
+			continue
+		}
+		if others := slices.DeleteFunc(needed, func(d string) bool { return slices.Contains(pkgs, d) }); len(others) > 0 {
+			report = append(report, fmt.Sprintf("Kept %s: %s needs it", pkg, strings.Join(others, ", ")))
+			continue
+		}
+		if err := RemovePackage(ctx, pkg); err != nil {
+			report = append(report, err.Error())
+			failed = append(failed, pkg)
+			continue
+		}
+		if OptionsFrom(ctx).Preview == nil {
+			report = append(report, "Removed "+pkg) // else Preview said it would
+		}
+		gone = append(gone, pkg)
+	}
+	if OptionsFrom(ctx).Preview == nil {
+		m, err := LoadManifest()
+		if err == nil {
+			m.Installed = slices.DeleteFunc(m.Installed, func(p ManifestPackage) bool { return slices.Contains(gone, p.Name) })
+			err = saveManifest(ctx, m)
+		}
+		if err != nil {
+			report = append(report, "Could not update the list of installed packages: "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return report, fmt.Errorf("failed to remove %s", strings.Join(failed, ", "))
+	}
+	return report, nil
+}
@@ -0,0 +1,110 @@
+package niri
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// VersionChange is a package an upgrade changed. Old is empty for a package
+// it pulled in and New for one it removed.
+type VersionChange struct {
+	Package string
+	Old     string
+	New     string
+}
+
+// installedVersions maps every installed package to its version.
+func installedVersions() (map[string]string, error) {
+	out, err := Command("pkg", "query", "%n\t%v").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages: %w", err)
+	}
+	versions := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if name, version, ok := strings.Cut(line, "\t"); ok {
+			versions[name] = version
+		}
+	}
+	return versions, nil
+}
+
+// versionChanges compares the installed versions before and after, sorted
+// by package.
+func versionChanges(before, after map[string]string) []VersionChange {
+	var changes []VersionChange
+	for name, old := range before {
+		if after[name] != old {
+			changes = append(changes, VersionChange{Package: name, Old: old, New: after[name]})
+		}
+	}
+	for name, version := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, VersionChange{Package: name, New: version})
+		}
+	}
+	slices.SortFunc(changes, func(a, b VersionChange) int { return strings.Compare(a.Package, b.Package) })
+	return changes
+}
+
+// UpgradePackages upgrades the installed packages NiriSetup manages, and
+// whatever pkg brings along with them. It returns every package whose
+// version changed, found by comparing pkg's records before and after, so a
+// failed upgrade still reports what it did get done.
+func UpgradePackages(ctx context.Context) ([]VersionChange, error) {
+	installed, err := InstalledPackages()
+	if err != nil {
+		return nil, err
+	}
+	if len(installed) == 0 {
+		return nil, fmt.Errorf("none of the packages NiriSetup manages are installed")
+	}
+	var names []string
+	for _, p := range installed {
+		names = append(names, p.Name)
+	}
+	if OptionsFrom(ctx).Preview != nil {
+		OptionsFrom(ctx).Preview("Would upgrade " + strings.Join(names, ", "))
+		return nil, nil
+	}
+
+	before, err := installedVersions()
+	if err != nil {
+		return nil, err
+	}
+	// a failed upgrade is most often the mirror, like a failed install;
+	// running it again picks up where it stopped
+	var out string
+	attempt := 0
+	upgradeErr := retry(installAttempts, installBackoff, func() error {
+		if err := cancelled(ctx); err != nil {
+			return permanent(err)
+		}
+		attempt++
+		if attempt > 1 && OptionsFrom(ctx).Output != nil {
+			OptionsFrom(ctx).Output(fmt.Sprintf("Retrying the upgrade (attempt %d of %d)...", attempt, installAttempts))
+		}
+		var err error
+		out, err = Run(ctx, rootCommand(append([]string{"pkg", "upgrade", "-y"}, names...)...))
+		if err != nil && privilegeFailed(out) {
+			return permanent(err) // asking sudo again will not help
+		}
+		return err
+	})
+	after, err := installedVersions()
+	if err != nil {
+		return nil, err
+	}
+	changes := versionChanges(before, after)
+	switch {
+	case upgradeErr == nil:
+		return changes, nil
+	case errors.Is(upgradeErr, ErrCancelled):
+		return changes, fmt.Errorf("failed to upgrade: %w", ErrCancelled)
+	case privilegeFailed(out):
+		return changes, fmt.Errorf("failed to upgrade: %w", ErrPrivilege)
+	}
+	return changes, fmt.Errorf("failed to upgrade: %s", strings.TrimSpace(out))
+}
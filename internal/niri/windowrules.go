@@ -0,0 +1,127 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WindowRuleProperties are the properties offered for a new window rule.
+var WindowRuleProperties = []string{
+	"open-floating true",
+	"open-maximized true",
+	"open-fullscreen true",
+	"opacity 0.9",
+	"opacity 0.8",
+}
+
+// WindowRuleFields are the window properties a rule can match on.
+var WindowRuleFields = []string{"app-id", "title"}
+
+// WindowRule is a window-rule block from the config.
+type WindowRule struct {
+	Matches    []string // "app-id=..." and "title=..." per match line
+	Properties []string // everything else, as "name args"
+	Node       *Node
+}
+
+func (r WindowRule) String() string {
+	matches := "every window"
+	if len(r.Matches) > 0 {
+		matches = strings.Join(r.Matches, " or ")
+	}
+	return matches + " → " + strings.Join(r.Properties, "; ")
+}
+
+// windowRules lists the enabled window-rule blocks in cfg.
+func windowRules(cfg *Config) []WindowRule {
+	var rules []WindowRule
+	for _, n := range cfg.All("window-rule") {
+		r := WindowRule{Node: n}
+		for _, c := range n.Children {
+			if c.Disabled {
+				continue
+			}
+			if c.Name == "match" || c.Name == "exclude" {
+				var parts []string
+				for _, field := range WindowRuleFields {
+					if v, ok := c.Props[field]; ok {
+						parts = append(parts, field+"="+v)
+					}
+				}
+				if c.Name == "exclude" {
+					r.Properties = append(r.Properties, "exclude "+strings.Join(parts, " "))
+				} else {
+					r.Matches = append(r.Matches, strings.Join(parts, " "))
+				}
+				continue
+			}
+			r.Properties = append(r.Properties, strings.TrimSpace(c.Name+" "+strings.Join(c.Args, " ")))
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// WindowRules returns the window rules in the niri config, in order.
+func WindowRules() ([]WindowRule, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	return windowRules(cfg), nil
+}
+
+// AddWindowRule adds a window rule giving windows whose field (app-id or
+// title) matches the regular expression pattern the property, and saves
+// it once niri has validated the result.
+func AddWindowRule(ctx context.Context, field, pattern, property string) ([]string, error) {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("%q is not a valid regular expression: %w", pattern, err)
+	}
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	rule := fmt.Sprintf("window-rule {\n    match %s=%s\n    %s\n}", field, strconv.Quote(pattern), property)
+	if err := cfg.AddNode(rule); err != nil {
+		return []string{checked}, fmt.Errorf("failed to update niri config: %w", err)
+	}
+	if out, err := SaveSource(ctx, cfg.String()); err != nil {
+		return []string{checked, out}, err
+	}
+	return []string{checked, fmt.Sprintf("Added a window rule: %s=%s → %s", field, pattern, property), "Updated " + ConfigPath()}, nil
+}
+
+// RemoveWindowRule deletes rule, which must come from WindowRules, and
+// saves the config once niri has validated it.
+func RemoveWindowRule(ctx context.Context, rule WindowRule) ([]string, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	// find the rule again in case the config changed since it was listed
+	var found *Node
+	for _, r := range windowRules(cfg) {
+		if r.String() == rule.String() {
+			found = r.Node
+			break
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("the window rule for %s is no longer in %s", rule, ConfigPath())
+	}
+	if err := cfg.Remove(found); err != nil {
+		return nil, fmt.Errorf("failed to update niri config: %w", err)
+	}
+	if out, err := SaveSource(ctx, cfg.String()); err != nil {
+		return []string{out}, err
+	}
+	return []string{"Removed the window rule: " + rule.String(), "Updated " + ConfigPath()}, nil
+}
@@ -0,0 +1,149 @@
+package niri
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeTestConfig makes src the niri config of a fresh XDG_CONFIG_HOME,
+// with no niri to validate it.
+func writeTestConfig(t *testing.T, src string) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir()) // no niri to validate with
+	if err := os.MkdirAll(filepath.Dir(ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigPath(), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWindowRules(t *testing.T) {
+	writeTestConfig(t, `window-rule {
+    match app-id="^firefox$"
+    match title="Picture-in-Picture"
+    exclude title="Private"
+    open-floating true
+    /-opacity 0.5
+}
+
+/-window-rule {
+    match app-id="foot"
+    opacity 0.9
+}
+
+window-rule {
+    geometry-corner-radius 8
+}
+`)
+	rules, err := WindowRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range rules {
+		got = append(got, r.String())
+	}
+	want := []string{
+		`app-id=^firefox$ or title=Picture-in-Picture → exclude title=Private; open-floating true`,
+		"every window → geometry-corner-radius 8",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("WindowRules = %q, want %q", got, want)
+	}
+}
+
+func TestAddAndRemoveWindowRule(t *testing.T) {
+	src := `// my config
+layout {
+    gaps 16 // wide
+}
+
+window-rule {
+    match app-id="mpv"
+    open-fullscreen true
+}
+`
+	writeTestConfig(t, src)
+	report, err := AddWindowRule(context.Background(), "app-id", "^org.gnome.Nautilus$", "open-floating true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Added a window rule: app-id=^org.gnome.Nautilus$ → open-floating true"; !slices.Contains(report, want) {
+		t.Errorf("adding the rule reported %q, want %q", report, want)
+	}
+	data, _ := os.ReadFile(ConfigPath())
+	added := "window-rule {\n    match app-id=\"^org.gnome.Nautilus$\"\n    open-floating true\n}"
+	// the rest of the config, comments and all, stays as it was
+	if !strings.HasPrefix(string(data), src) || !strings.Contains(string(data), added) {
+		t.Errorf("the config with the rule is\n%s", data)
+	}
+
+	rules, err := WindowRules()
+	if err != nil || len(rules) != 2 {
+		t.Fatalf("WindowRules = %v, %v, want the two rules", rules, err)
+	}
+	if report, err := RemoveWindowRule(context.Background(), rules[1]); err != nil || !slices.Contains(report, "Removed the window rule: "+rules[1].String()) {
+		t.Fatalf("removing the rule returned %q, %v", report, err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); strings.TrimSpace(string(data)) != strings.TrimSpace(src) {
+		t.Errorf("removing the added rule left\n%s\nwant\n%s", data, src)
+	}
+	// a rule that is gone cannot be removed again
+	if _, err := RemoveWindowRule(context.Background(), rules[1]); err == nil || !strings.Contains(err.Error(), "no longer in") {
+		t.Errorf("removing the rule again returned %v", err)
+	}
+
+	if _, err := RemoveWindowRule(context.Background(), rules[0]); err != nil {
+		t.Fatal(err)
+	}
+	if rules, err := WindowRules(); err != nil || len(rules) != 0 {
+		t.Errorf("WindowRules after removing both = %v, %v", rules, err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); !strings.Contains(string(data), "gaps 16 // wide") {
+		t.Errorf("removing the rules lost the layout:\n%s", data)
+	}
+}
+
+func TestAddWindowRuleEscapesThePattern(t *testing.T) {
+	for _, pattern := range []string{
+		`^org\.gnome\.Nautilus$`,
+		`"quoted" title`,
+		`C:\\path`,
+		"tab\tand é",
+	} {
+		writeTestConfig(t, "")
+		if _, err := AddWindowRule(context.Background(), "title", pattern, "opacity 0.8"); err != nil {
+			t.Errorf("%s: %v", pattern, err)
+			continue
+		}
+		data, _ := os.ReadFile(ConfigPath())
+		cfg, err := ParseConfig(string(data))
+		if err != nil {
+			t.Errorf("%s: the config does not parse: %v\n%s", pattern, err, data)
+			continue
+		}
+		// niri reads back the very pattern that was given
+		rule := cfg.Section("window-rule")
+		if match := rule.Child("match"); match == nil || match.Props["title"] != pattern || rule.Child("opacity") == nil {
+			t.Errorf("%s: the rule is written as\n%s", pattern, data)
+		}
+	}
+}
+
+func TestAddWindowRuleRejectsBadPatterns(t *testing.T) {
+	src := "layout {\n    gaps 16\n}\n"
+	writeTestConfig(t, src)
+	if _, err := AddWindowRule(context.Background(), "app-id", "fire(fox", "open-floating true"); err == nil || !strings.Contains(err.Error(), "not a valid regular expression") {
+		t.Errorf("an unbalanced parenthesis returned %v", err)
+	}
+	if data, _ := os.ReadFile(ConfigPath()); string(data) != src {
+		t.Errorf("a rejected rule changed the config to\n%s", data)
+	}
+}
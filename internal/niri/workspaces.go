@@ -0,0 +1,269 @@
+package niri
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// WorkspaceApp is an app niri starts at startup and opens on a given
+// workspace: a spawn-at-startup line for Command and a window rule sending
+// windows with AppID to a workspace named after its index.
+type WorkspaceApp struct {
+	Workspace int
+	Command   string // "" when nothing in the config starts the app
+	AppID     string
+}
+
+func (a WorkspaceApp) String() string {
+	command := a.Command
+	if command == "" {
+		command = a.AppID + " (not started by niri)"
+	}
+	return fmt.Sprintf("workspace %d: %s", a.Workspace, command)
+}
+
+// DefaultAppID guesses the app ID of the windows command opens: the name
+// of its program, which is what most apps use.
+func DefaultAppID(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// appIDPattern is the app-id match of the rule for appID.
+func appIDPattern(appID string) string {
+	return "^" + regexp.QuoteMeta(appID) + "$"
+}
+
+// workspaceRules returns the window rules that send one app ID to a
+// workspace named after its index, keyed by app ID.
+func workspaceRules(cfg *Config) map[string]*Node {
+	rules := map[string]*Node{}
+	for _, n := range cfg.All("window-rule") {
+		var appID string
+		matches := 0
+		for _, c := range n.Children {
+			if c.Disabled || c.Name != "match" {
+				continue
+			}
+			matches++
+			if p := c.Props["app-id"]; strings.HasPrefix(p, "^") && strings.HasSuffix(p, "$") && len(c.Props) == 1 {
+				appID = p
+			}
+		}
+		target := n.Child("open-on-workspace")
+		if matches != 1 || appID == "" || target == nil {
+			continue
+		}
+		if _, ok := numbered(target); !ok {
+			continue
+		}
+		if unquoted, err := unquoteMeta(appID); err == nil {
+			rules[unquoted] = n
+		}
+	}
+	return rules
+}
+
+// unquoteMeta turns an appIDPattern back into the app ID.
+func unquoteMeta(pattern string) (string, error) {
+	var b strings.Builder
+	escaped := false
+	for _, r := range strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$") {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	if appIDPattern(b.String()) != pattern {
+		return "", fmt.Errorf("%s is not a plain app ID", pattern)
+	}
+	return b.String(), nil
+}
+
+// startupOf returns the spawn-at-startup line that starts appID: the one
+// whose program is named like it.
+func startupOf(cfg *Config, appID string) *Node {
+	for _, n := range cfg.All("spawn-at-startup") {
+		if len(n.Args) > 0 && strings.EqualFold(DefaultAppID(n.Args[0]), appID) {
+			return n
+		}
+	}
+	return nil
+}
+
+// workspaceApps lists the apps in cfg that open on a workspace, by
+// workspace.
+func workspaceApps(cfg *Config) []WorkspaceApp {
+	var apps []WorkspaceApp
+	for appID, rule := range workspaceRules(cfg) {
+		n, _ := numbered(rule.Child("open-on-workspace"))
+		app := WorkspaceApp{Workspace: n, AppID: appID}
+		if spawn := startupOf(cfg, appID); spawn != nil {
+			app.Command = strings.Join(spawn.Args, " ")
+		}
+		apps = append(apps, app)
+	}
+	slices.SortFunc(apps, func(a, b WorkspaceApp) int {
+		if a.Workspace != b.Workspace {
+			return a.Workspace - b.Workspace
+		}
+		return strings.Compare(a.AppID, b.AppID)
+	})
+	return apps
+}
+
+// WorkspaceApps returns the apps the niri config opens on a workspace.
+func WorkspaceApps() ([]WorkspaceApp, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	return workspaceApps(cfg), nil
+}
+
+// numbered returns the index a workspace node is named after, if it is.
+func numbered(n *Node) (int, bool) {
+	if len(n.Args) != 1 {
+		return 0, false
+	}
+	i, err := strconv.Atoi(n.Args[0])
+	return i, err == nil
+}
+
+// declareWorkspaces makes sure cfg names workspaces "1" up to last, in
+// order. niri puts named workspaces first, in the order they are declared,
+// so workspace "n" is the nth one as long as no other named workspace
+// comes before it. It returns the names of those that do.
+func declareWorkspaces(cfg *Config, last int) ([]string, error) {
+	var named []int
+	var others []string
+	for _, n := range cfg.All("workspace") {
+		if i, ok := numbered(n); ok {
+			named = append(named, i)
+		} else if len(n.Args) > 0 {
+			others = append(others, n.Args[0])
+		}
+	}
+	want := make([]int, last)
+	for i := range want {
+		want[i] = i + 1
+	}
+	if len(named) >= last && slices.Equal(named[:last], want) {
+		return others, nil
+	}
+	// declared out of order or with gaps: declare them all again
+	for {
+		i := slices.IndexFunc(cfg.All("workspace"), func(n *Node) bool { _, ok := numbered(n); return ok })
+		if i < 0 {
+			break
+		}
+		if err := cfg.Remove(cfg.All("workspace")[i]); err != nil {
+			return others, err
+		}
+	}
+	slices.Sort(named)
+	for _, i := range slices.Concat(want, slices.Compact(slices.DeleteFunc(named, func(i int) bool { return i <= last }))) {
+		if err := cfg.AddNode(FormatNode("workspace", strconv.Itoa(i))); err != nil {
+			return others, err
+		}
+	}
+	return others, nil
+}
+
+// SetWorkspaceApp makes niri start command at startup and open the windows
+// with appID on workspace, which counts from 1. An app already mapped is
+// moved to workspace; a command already started is not started twice. The
+// config is saved once niri has validated it.
+func SetWorkspaceApp(ctx context.Context, command, appID string, workspace int) ([]string, error) {
+	if workspace < 1 {
+		return nil, fmt.Errorf("workspace %d does not exist, they count from 1", workspace)
+	}
+	args := strings.Fields(command)
+	if appID == "" {
+		appID = DefaultAppID(command)
+	}
+	if appID == "" {
+		return nil, fmt.Errorf("an app needs a command or an app ID")
+	}
+	checked, err := prepareConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+
+	report := []string{checked}
+	last := workspace
+	for _, a := range workspaceApps(cfg) {
+		if a.AppID != appID {
+			last = max(last, a.Workspace)
+		}
+	}
+	others, err := declareWorkspaces(cfg, last)
+	if err != nil {
+		return report, fmt.Errorf("failed to update niri config: %w", err)
+	}
+	if len(others) > 0 {
+		report = append(report, "The named workspaces "+strings.Join(others, ", ")+" may come first and shift the numbered ones")
+	}
+	rule := fmt.Sprintf("window-rule {\n    match app-id=%s\n    open-on-workspace %s\n}", strconv.Quote(appIDPattern(appID)), strconv.Quote(strconv.Itoa(workspace)))
+	if old := workspaceRules(cfg)[appID]; old != nil {
+		err = cfg.Replace(old, rule)
+	} else {
+		err = cfg.AddNode(rule)
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to update niri config: %w", err)
+	}
+	report = append(report, fmt.Sprintf("Windows with app ID %s open on workspace %d", appID, workspace))
+	if len(args) > 0 && !HasSpawnAtStartup(cfg, args...) && startupOf(cfg, appID) == nil {
+		if err := cfg.AddNode(FormatNode("spawn-at-startup", args...)); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, "Added spawn-at-startup: "+command)
+	}
+	if out, err := SaveSource(ctx, cfg.String()); err != nil {
+		return append(report, out), err
+	}
+	return append(report, "Updated "+ConfigPath()), nil
+}
+
+// RemoveWorkspaceApp deletes the window rule of app, which must come from
+// WorkspaceApps, and the spawn-at-startup line starting it, then saves the
+// config once niri has validated it.
+func RemoveWorkspaceApp(ctx context.Context, app WorkspaceApp) ([]string, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read niri config: %w", err)
+	}
+	rule := workspaceRules(cfg)[app.AppID]
+	if rule == nil {
+		return nil, fmt.Errorf("%s no longer opens on a workspace in %s", app.AppID, ConfigPath())
+	}
+	if err := cfg.Remove(rule); err != nil {
+		return nil, fmt.Errorf("failed to update niri config: %w", err)
+	}
+	report := []string{fmt.Sprintf("Windows with app ID %s no longer open on workspace %d", app.AppID, app.Workspace)}
+	if spawn := startupOf(cfg, app.AppID); spawn != nil {
+		if err := cfg.Remove(spawn); err != nil {
+			return report, fmt.Errorf("failed to update niri config: %w", err)
+		}
+		report = append(report, "Removed spawn-at-startup: "+strings.Join(spawn.Args, " "))
+	}
+	if out, err := SaveSource(ctx, cfg.String()); err != nil {
+		return append(report, out), err
+	}
+	return append(report, "Updated "+ConfigPath()), nil
+}
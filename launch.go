@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"NiriSetup/internal/niri"
+)
+
+// How long a launched niri gets to start answering, and how much of its log
+// is shown if it doesn't
+const launchTimeout = 5 * time.Second
+const launchLogLines = 15
+
+// displayManagersMsg lists the known login managers and which of them are
+// installed.
+type displayManagersMsg struct {
+	managers  []niri.DisplayManager
+	installed []bool
+}
+
+// launchReadyMsg carries the checks Launch Niri passed, before the user
+// picks how to run niri.
+type launchReadyMsg struct {
+	report []string
+}
+
+// sessionEndedMsg is a niri session run on this terminal exiting.
+type sessionEndedMsg struct {
+	err error
+}
+
+// launchMsg reports how launching niri went. When err is set the user is
+// offered niri's log.
+type launchMsg struct {
+	report []string
+	err    error
+}
+
+// updateLaunch handles the messages of starting niri, now or at login.
+func (m model) updateLaunch(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case displayManagersMsg:
+		m.state = choiceView
+		m.choice = choice{question: tr("Which login manager should offer niri?")}
+		for i, dm := range msg.managers {
+			label := trf("Install %s", dm.Name)
+			if msg.installed[i] {
+				label = trf("%s (installed)", dm.Name)
+			}
+			m.choice.options = append(m.choice.options, option{
+				label:   label,
+				working: trf("Setting up %s...", dm.Name),
+				run:     m.cmds.login(m.ctx, dm),
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case launchReadyMsg:
+		m.log(msg.report...)
+		m.state = choiceView
+		m.choice = choice{
+			question: trf("%s\n\nHow do you want to run niri?", strings.Join(msg.report, "\n")),
+			options: []option{
+				{label: tr("Run it here, back to NiriSetup when it exits"), working: tr("Running niri..."), run: m.cmds.session(m.ctx)},
+				{label: tr("Start it in the background"), working: tr("Launching niri..."), run: m.cmds.launch(m.ctx)},
+				{label: tr("Back to the menu")},
+			},
+		}
+		return m, nil
+	case sessionEndedMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, errors.New(trf("niri exited: %s", msg.err))))
+		}
+		return m.Update(statusMsg{status: tr("niri exited; back in NiriSetup.")})
+	case launchMsg:
+		if msg.err == nil {
+			return m.Update(reportMsg(msg.report, nil))
+		}
+		failure := reportMsg(msg.report, msg.err).status
+		m.logAt(levelError, failure)
+		m.state = choiceView
+		m.choice = choice{
+			question: trf("%s\n\nShow the niri log?", failure),
+			options: []option{
+				{label: tr("Show the niri log"), working: tr("Reading the niri log..."), run: m.cmds.launchLog()},
+				{label: tr("Back to the menu")},
+			},
+		}
+		return m, nil
+	}
+	return m, nil
+}
+
+// checkLaunch runs the config check and niri.LaunchChecks, which have to
+// pass before niri is run either way.
+func checkLaunch() tea.Cmd {
+	return func() tea.Msg {
+		var report []string
+		checks := append([]niri.Check{niri.ConfigCheck()}, niri.LaunchChecks()...)
+		for _, c := range checks {
+			report = append(report, c.String())
+		}
+		if niri.LaunchBlocked(checks) {
+			return reportMsg(report, errors.New(tr("niri was not started, fix the ✗ above first")))
+		}
+		return launchReadyMsg{report: report}
+	}
+}
+
+// launchNiri starts niri in the background and checks that it comes up.
+func launchNiri(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		launched, err := niri.Launch(ctx, launchTimeout)
+		return launchMsg{report: launched, err: err}
+	}
+}
+
+// runSession hands the terminal to niri --session, suspending the TUI
+// until niri exits.
+func runSession(ctx context.Context) tea.Cmd {
+	if preview := niri.OptionsFrom(ctx).Preview; preview != nil {
+		return func() tea.Msg {
+			preview("Would run niri --session on this terminal")
+			return statusMsg{}
+		}
+	}
+	return tea.ExecProcess(niri.SessionCommand(), func(err error) tea.Msg { return sessionEndedMsg{err: err} })
+}
+
+func showLaunchLog() tea.Cmd {
+	return func() tea.Msg {
+		tail, err := niri.LaunchLogTail(launchLogLines)
+		if err != nil {
+			return reportMsg(nil, fmt.Errorf("failed to read the niri log: %w", err))
+		}
+		return statusMsg{status: trf("Last lines of %s:\n%s", niri.LaunchLog(), tail)}
+	}
+}
+
+func setUpAutostart(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.SetUpAutostart(ctx))
+	}
+}
+
+func writeStartScript(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		report, err := niri.WriteStartScript(ctx)
+		if err == nil {
+			report = append(report, trf("Log in on a console and run %s to start niri.", niri.StartScriptPath()))
+		}
+		return reportMsg(report, err)
+	}
+}
+
+func detectDisplayManagers() tea.Cmd {
+	return func() tea.Msg {
+		msg := displayManagersMsg{managers: niri.DisplayManagers}
+		for _, dm := range msg.managers {
+			msg.installed = append(msg.installed, dm.Installed())
+		}
+		return msg
+	}
+}
+
+func setUpDisplayManager(ctx context.Context, dm niri.DisplayManager) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.SetUpDisplayManager(ctx, dm))
+	}
+}
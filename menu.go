@@ -0,0 +1,701 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"NiriSetup/internal/niri"
+)
+
+// menuEntry is one entry of the main menu, with what the ? key says
+// about it.
+type menuEntry struct {
+	name   string
+	help   string      // what it does and what it changes
+	sudo   bool        // it runs pkg or another command through sudo
+	undo   string      // how to reverse it, "" when it changes nothing
+	safe   bool        // it only looks at the system, see safeActions
+	inNiri bool        // menu only offers it inside a niri session
+	failed bool        // menu only offers it after an install failed
+	when   func() bool // it is only offered when this holds, if set
+}
+
+// menuEntries are the main menu, in order.
+var menuEntries = []menuEntry{
+	{name: "Install Niri", sudo: true,
+		help: "Installs niri and the packages a desktop needs with pkg, or the ones listed in ~/.config/nirisetup/packages.",
+		undo: "Uninstall Niri removes what it installed."},
+	{name: "Retry Failed Packages", sudo: true, failed: true,
+		help: "Installs again only the packages the last install did not get installed.",
+		undo: "Uninstall Niri removes what it installed."},
+	{name: "Install from Cache", sudo: true,
+		help: "Installs the same packages from a directory of downloaded .pkg files, without the network.",
+		undo: "Uninstall Niri removes what it installed."},
+	{name: "Uninstall Niri", sudo: true,
+		help: "Removes the packages Install Niri installed, keeping ones you had before and ones other packages need.",
+		undo: "Install Niri installs them again."},
+	{name: "Show Dependencies", safe: true,
+		help: "Shows every package niri depends on, marking the ones already installed."},
+	{name: "Write Install Script",
+		help: "Writes install.sh in the current directory with the pkg commands Install Niri would run, for review.",
+		undo: "Delete install.sh."},
+	{name: "Configure Niri",
+		help: "Writes a starter config.kdl to ~/.config/niri if there is none, then merges your snippets from ~/.config/nirisetup/snippets into it. It can also write starter configs for waybar, mako, fuzzel and swaylock; you pick which.",
+		undo: "The old config.kdl is backed up; put it back with Restore Config or compare with Compare Backups. Replaced waybar, mako, fuzzel and swaylock configs are kept next to themselves with a .bak.<time> suffix."},
+	{name: "Generate Default Configs",
+		help: "Writes starter configs for waybar, mako, fuzzel and swaylock, then the default niri config, and validates it.",
+		undo: "Every file replaced is kept next to itself with a .bak.<time> suffix."},
+	{name: "Import Sway Config",
+		help: "Translates the keybinds, outputs and autostart commands of a sway or i3 config into a new niri config.",
+		undo: "The old config.kdl is backed up first."},
+	{name: "Configure Clipboard", sudo: true, when: launcherConfigured,
+		help: "Installs wl-clipboard and cliphist, starts the clipboard history with niri and binds a key to pick from it.",
+		undo: "Remove the bind and spawn-at-startup line with Edit Config; config.kdl is backed up first."},
+	{name: "Toggle XWayland",
+		help: "Starts xwayland-satellite with niri so X11 apps run, or removes it for a pure-Wayland session.",
+		undo: "Run it again to switch back."},
+	{name: "Configure Outputs",
+		help: "Sets the scale of one of your outputs, for HiDPI screens.",
+		undo: "Pick another scale; config.kdl is backed up first."},
+	{name: "Configure Cursor", sudo: true,
+		help: "Sets the cursor theme and size in config.kdl and the environment, installing a theme if there is none.",
+		undo: "Pick another theme; config.kdl is backed up first."},
+	{name: "Configure Appearance",
+		help: "Sets the gaps between windows, the focus ring and the animation speed.",
+		undo: "Pick Comfortable for niri's defaults; config.kdl is backed up first."},
+	{name: "Configure Night Light",
+		help: "Sets the day and night colour temperatures wlsunset fades between.",
+		undo: "Pick other temperatures; config.kdl is backed up first."},
+	{name: "Configure Screenshots", sudo: true,
+		help: "Installs slurp and wl-clipboard and binds Print and Shift+Print to screenshots saved in ~/Pictures.",
+		undo: "Remove the binds with Edit Config; config.kdl is backed up first."},
+	{name: "Configure Brightness Keys",
+		help: "Binds the brightness keys to backlight(8) on the backlight device the GPU driver provides.",
+		undo: "Remove the binds with Edit Config; config.kdl is backed up first."},
+	{name: "Configure Media Keys", sudo: true,
+		help: "Installs wireplumber and playerctl and binds the volume, mute and play/pause/next/previous keys to them.",
+		undo: "Remove the binds with Edit Config; config.kdl is backed up first."},
+	{name: "Configure Idle and Lock", sudo: true,
+		help: "Installs swayidle and swaylock and locks the screen when idle, turning the monitors off later.",
+		undo: "Remove the swayidle spawn-at-startup line with Edit Config; config.kdl is backed up first."},
+	{name: "Configure Polkit Agent", sudo: true,
+		help: "Installs a polkit agent if needed and starts it with niri, so password prompts show up.",
+		undo: "Remove its spawn-at-startup line with Edit Config; config.kdl is backed up first."},
+	{name: "Configure Screen Sharing", sudo: true,
+		help: "Installs xdg-desktop-portal with the wlr backend and starts it with niri, for screen sharing in browsers.",
+		undo: "An old portals.conf is kept as portals.conf.bak; config.kdl is backed up first."},
+	{name: "Configure Audio", sudo: true,
+		help: "Checks whether sound works and, if not, installs pipewire and wireplumber and starts them with niri.",
+		undo: "Remove the spawn-at-startup line with Edit Config; config.kdl is backed up first."},
+	{name: "Configure Input Method", sudo: true,
+		help: "Installs fcitx5 with an engine for Chinese, Japanese, Korean or Vietnamese and starts it with niri.",
+		undo: "Remove the fcitx5 lines with Edit Config; config.kdl is backed up first."},
+	{name: "Configure Input Behavior",
+		help: "Turns focus-follows-mouse, warp-mouse-to-focus and workspace-auto-back-and-forth on or off.",
+		undo: "Turn them back; config.kdl is backed up first."},
+	{name: "Configure Window Rules",
+		help: "Adds or removes window rules, e.g. to open an app floating or at a given opacity.",
+		undo: "Remove the rule the same way; config.kdl is backed up first."},
+	{name: "Configure Workspace Apps",
+		help: "Starts apps with niri and opens each on the workspace you pick, with spawn-at-startup and window rules.",
+		undo: "Remove the app here again; config.kdl is backed up first."},
+	{name: "Show Keybinds", safe: true,
+		help: "Lists the binds in config.kdl and the common keys that are still free."},
+	{name: "Read Documentation", safe: true,
+		help: "Shows the niri man page, the annotated default config or where to read more online."},
+	{name: "Edit Config",
+		help: "Edits config.kdl in your $EDITOR, then runs niri validate on it, or inside NiriSetup, where it is only saved if niri validate accepts it.",
+		undo: "The old config.kdl is backed up before the editor opens and on every save."},
+	{name: "Show Config Paths", safe: true,
+		help: "Lists every place niri looks for config.kdl, in order, and which one it uses."},
+	{name: "Validate Config", safe: true,
+		help: "Runs niri validate on config.kdl."},
+	{name: "Reload Config", inNiri: true,
+		help: "Asks the running niri to load config.kdl again; it keeps the current config if the new one is invalid.",
+		undo: "Fix config.kdl and reload again."},
+	{name: "Repair Config",
+		help: "If config.kdl is broken, restores the newest backup that validates or writes the default config.",
+		undo: "The broken config is backed up first."},
+	{name: "Restore Config",
+		help: "Lists the backups taken of config.kdl before each change, newest first, and puts the one you pick back.",
+		undo: "The config it replaces is backed up first; Restore Config brings it back."},
+	{name: "Audit Config", safe: true,
+		help: "Looks for options the installed niri has deprecated and says what to use instead."},
+	{name: "Compare Backups", safe: true,
+		help: "Shows what changed between two config backups."},
+	{name: "Switch Profile",
+		help: "Makes config.kdl a link to one of the profiles in ~/.config/nirisetup/profiles, or saves it as a new one.",
+		undo: "Switch back; a config that is not a profile yet is backed up first."},
+	{name: "System Check", safe: true,
+		help: "Checks that niri can run here: niri and wlroots installed, seatd enabled and running, XDG_RUNTIME_DIR and the video group."},
+	{name: "Doctor", safe: true,
+		help: "Checks the niri version, memory, session and what niri needs to start."},
+	{name: "Launch Niri",
+		help: "Checks the config, the runtime directory and seatd, then runs niri on this terminal or starts it in the background.",
+		undo: "Quit niri with its quit bind."},
+	{name: "Write Start Script",
+		help: "Writes ~/.config/niri/start.sh and an env file for starting niri from a console.",
+		undo: "Delete the files it reports."},
+	{name: "Start on Console Login",
+		help: "Makes logging in on the first console start niri, from your shell's login file.",
+		undo: "Delete the block it adds to ~/.profile or ~/.login."},
+	{name: "Set Up Login Manager", sudo: true,
+		help: "Installs and enables SDDM, ly or GDM and adds a niri session to it.",
+		undo: "Disable the service with sysrc and remove the package."},
+	{name: "Manage Packages", sudo: true,
+		help: "Lists the installed packages NiriSetup manages; pick one to remove it.",
+		undo: "Install it again with Install Niri."},
+	{name: "Upgrade Packages", sudo: true,
+		help: "Runs pkg upgrade on the packages NiriSetup manages and shows which versions changed.",
+		undo: "pkg cannot go back; older packages would have to be added by hand."},
+	{name: "Verify Packages", sudo: true,
+		help: "Checks the checksums and dependencies of the managed packages and offers to reinstall damaged ones.",
+		undo: "Reinstalling only puts back the packaged files."},
+	{name: "Repair", sudo: true,
+		help: "Installs the packages that are missing, reinstalls damaged ones, writes a missing config and replaces one niri rejects; what already works is left alone.",
+		undo: "Uninstall Niri removes what it installed; a replaced config.kdl is backed up first."},
+	{name: "Manage Processes",
+		help: "Lists the running programs NiriSetup installs, such as waybar or mako; pick one to restart or kill it.",
+		undo: "Start a killed program again from a terminal or by restarting niri."},
+	{name: "Benchmark Mirrors", sudo: true,
+		help: "Times the package mirrors and offers to point pkg at the fastest one.",
+		undo: "Delete /usr/local/etc/pkg/repos/FreeBSD.conf to go back to the default repository."},
+	{name: "View Last Logs", safe: true,
+		help: "Shows this session's log, such as the output of the last install, in a scrollable view."},
+	{name: "Save Logs",
+		help: "Appends this session's log to the log file, nirisetup.log in the temporary directory unless --log-file names another.",
+		undo: "Delete the log file."},
+	{name: "Generate Bug Report",
+		help: "Writes a report with versions, checks, the config and logs, with personal details redacted.",
+		undo: "Delete the report file."},
+	{name: "Settings", // writes the settings file, so it takes the instance lock
+		help: "Changes NiriSetup's own preferences, saved in ~/.config/nirisetup/settings.",
+		undo: "Pick the old value again."},
+	{name: "Exit", safe: true, // changes nothing either
+		help: "Quits NiriSetup."},
+}
+
+// menuChoices builds the menu from menuEntries. Entries that depend on an
+// earlier step are only offered once that step has been done; the ones
+// for inside niri or after a failed install are added by model.menu.
+func menuChoices() []string {
+	var choices []string
+	for _, e := range menuEntries {
+		if !e.inNiri && !e.failed && (e.when == nil || e.when()) {
+			choices = append(choices, e.name)
+		}
+	}
+	return choices
+}
+
+// safeActions are the menu entries that only look at the system. They run
+// from the menu without a confirmation or the action screen, and the menu
+// marks every other entry but Exit with mutatingMark.
+var safeActions = func() map[string]bool {
+	safe := map[string]bool{}
+	for _, e := range menuEntries {
+		if e.safe {
+			safe[e.name] = true
+		}
+	}
+	return safe
+}()
+
+// launcherConfigured reports whether config.kdl binds a launcher, which
+// Configure Clipboard needs to show the history.
+func launcherConfigured() bool {
+	cfg, err := niri.LoadConfig()
+	return err == nil && niri.ConfiguredLauncher(cfg) != ""
+}
+
+// menuEntryFor returns the entry called name.
+func menuEntryFor(name string) (menuEntry, bool) {
+	for _, e := range menuEntries {
+		if e.name == name {
+			return e, true
+		}
+	}
+	return menuEntry{}, false
+}
+
+// renderHelp is what the ? key shows for the menu entry called name, in
+// frame.
+func renderHelp(name string, frame lipgloss.Style) string {
+	e, ok := menuEntryFor(name)
+	if !ok {
+		return ""
+	}
+	lines := []string{cursorStyle.Render(tr(e.name)), "", tr(e.help), ""}
+	if e.sudo {
+		lines = append(lines, tr("Needs sudo: yes"))
+	} else {
+		lines = append(lines, tr("Needs sudo: no"))
+	}
+	if e.undo == "" {
+		lines = append(lines, tr("Changes nothing."))
+	} else {
+		lines = append(lines, trf("To undo: %s", tr(e.undo)))
+	}
+	lines = append(lines, "", disabledStyle.Render(tr("Press any key to close.")))
+	return frame.Render(strings.Join(lines, "\n"))
+}
+
+// changesNotice is what is shown at startup about the changes NiriSetup
+// made to its configs since the version that last wrote them, or "" when
+// there are none.
+func changesNotice(changes []niri.Change) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	lines := []string{cursorStyle.Render(trf("NiriSetup was updated to %s. What changed:", niri.Version))}
+	for _, c := range changes {
+		for _, note := range c.Notes {
+			lines = append(lines, "", "- "+tr(note))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runtimeNotice is shown over the menu at startup when err kept
+// niri.SetUpRuntimeDir from preparing XDG_RUNTIME_DIR, or "" if it did not.
+func runtimeNotice(err error) string {
+	if err == nil {
+		return ""
+	}
+	return strings.Join([]string{
+		cursorStyle.Render(tr("XDG_RUNTIME_DIR is not ready, so niri cannot start yet.")),
+		"",
+		err.Error(),
+		"",
+		tr("The other entries still work; Launch Niri and System Check will fail until it is fixed."),
+	}, "\n")
+}
+
+// joinNotices shows the notices that are not "" one after the other.
+func joinNotices(notices ...string) string {
+	return strings.Join(slices.DeleteFunc(notices, func(n string) bool { return n == "" }), "\n\n")
+}
+
+// menu is the menu for where NiriSetup runs: inside niri the config can be
+// reloaded live, and there is no niri to launch. After an install failed,
+// the packages it did not install can be retried. With a filter typed
+// after /, only the entries containing it are left.
+func (m model) menu() []string {
+	var choices []string
+	for _, c := range m.cmds.choices() {
+		switch {
+		case c == "Launch Niri" && m.insideNiri:
+			continue
+		case c == "Validate Config" && m.insideNiri:
+			choices = append(choices, c, "Reload Config")
+			continue
+		case c == "Install Niri" && len(m.failed) > 0:
+			choices = append(choices, c, "Retry Failed Packages")
+			continue
+		}
+		choices = append(choices, c)
+	}
+	if m.filter != "" {
+		choices = slices.DeleteFunc(choices, func(c string) bool { return !matchesFilter(c, m.filter) })
+	}
+	return choices
+}
+
+// matchesFilter reports whether the menu entry name contains filter,
+// ignoring case, in English or as it is shown.
+func matchesFilter(name, filter string) bool {
+	filter = strings.ToLower(filter)
+	return strings.Contains(strings.ToLower(name), filter) || strings.Contains(strings.ToLower(tr(name)), filter)
+}
+
+// setFilter narrows the menu to filter, starting again from its first
+// entry.
+func (m *model) setFilter(filter string) {
+	m.filter = filter
+	m.choices = m.menu()
+	m.cursor = 0
+}
+
+// mutatingMark follows the menu entries that change the system. It is
+// plain ASCII, as the FreeBSD console has no glyph for an emoji.
+const mutatingMark = "[!]"
+
+// menuKey handles a key in menuView: the help, the filter, moving through
+// the entries and starting one.
+func (m model) menuKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.help != "" {
+		m.help = "" // any key closes the help
+		return m, nil
+	}
+	if m.confirmingQuit {
+		m.confirmingQuit = false
+		if msg.String() == "y" || msg.String() == "Y" {
+			m.cmds.cancel() // what runs must not go on without NiriSetup
+			return m, tea.Quit
+		}
+		return m, nil // any other key keeps it running
+	}
+	if m.notice != "" {
+		m.notice = "" // any key dismisses it for good
+		if err := m.cmds.acknowledge(); err != nil {
+			m.logAt(levelWarn, trf("Warning: the changes will be shown again: %s", err))
+		}
+		return m, nil
+	}
+	if m.filtering {
+		switch msg.Type {
+		case tea.KeyRunes, tea.KeySpace:
+			m.setFilter(m.filter + string(msg.Runes))
+			return m, nil
+		case tea.KeyBackspace:
+			if r := []rune(m.filter); len(r) > 0 {
+				m.setFilter(string(r[:len(r)-1]))
+			}
+			return m, nil
+		case tea.KeyEnter:
+			if len(m.choices) > 0 {
+				m.filtering = false // the keys move and select again
+			}
+			return m, nil
+		}
+	}
+	switch msg.String() {
+	case "/":
+		m.filtering = true
+		return m, nil
+	case "esc":
+		if m.filtering || m.filter != "" {
+			m.filtering = false
+			m.setFilter("")
+		}
+		return m, nil
+	}
+	if len(m.choices) == 0 {
+		if msg.String() == "ctrl+c" || msg.String() == "q" {
+			return m.quit()
+		}
+		return m, nil // nothing matches the filter
+	}
+	switch msg.String() {
+	case "?":
+		m.help = m.choices[m.cursor]
+	case "ctrl+c", "q":
+		return m.quit()
+	case "up", "k":
+		// wraps around, so the last entry is one key away
+		m.cursor = (m.cursor + len(m.choices) - 1) % len(m.choices)
+	case "down", "j":
+		m.cursor = (m.cursor + 1) % len(m.choices)
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.choices) - 1
+	case "enter":
+		if m.isProcessing {
+			return m, nil // a safe action is still running
+		}
+		if !safeActions[m.choices[m.cursor]] {
+			var locked *niri.LockedError
+			switch err := m.cmds.lock(); {
+			case errors.As(err, &locked):
+				m.actionMsg = trf("Another NiriSetup (pid %d) is running. Only the entries without %s work until it exits.", locked.PID, mutatingMark)
+				return m, nil
+			case err != nil:
+				m.logAt(levelWarn, trf("Warning: running without the instance lock: %s", err))
+			}
+		}
+		m.selected = m.choices[m.cursor]
+		m.isProcessing = true
+		// the one dry-run gate: whatever an entry not marked safe would
+		// change, niri only reports
+		m.ctx, m.preview = context.Background(), nil
+		if m.dryRun && !safeActions[m.selected] {
+			m.ctx, m.preview = previewing(m.ctx)
+		}
+		return m.startEntry()
+	}
+	return m, nil
+}
+
+// startEntry starts the selected menu entry.
+func (m model) startEntry() (tea.Model, tea.Cmd) {
+	switch m.selected {
+	case "Retry Failed Packages":
+		m.log(trf("Retrying %d packages: %s", len(m.failed), strings.Join(m.failed, ", ")))
+		return m.startInstall(m.failed, m.installFiles)
+	case "Install Niri":
+		m.state = actionView
+		m.actionMsg = tr("Checking the packages are in the repositories...")
+		return m, m.cmds.check()
+	case "Install from Cache":
+		return m.askText(tr("Directory with the downloaded .pkg files:"), niri.DefaultPackageCache, func(dir string) tea.Msg { return cacheDirMsg(dir) })
+	case "Show Dependencies":
+		return m.runSafe(tr("Looking up niri's dependencies..."), m.cmds.deps())
+	case "Write Install Script":
+		m.state = actionView
+		m.actionMsg = tr("Writing install script...")
+		return m, m.cmds.script(m.ctx)
+	case "Configure Niri":
+		m.state = actionView
+		m.actionMsg = tr("Checking the niri config...")
+		return m, m.cmds.toolsets()
+	case "Configure Clipboard":
+		m.state = actionView
+		m.actionMsg = tr("Configuring clipboard manager...")
+		return m, m.cmds.clipboard(m.ctx, "")
+	case "Toggle XWayland":
+		m.state = actionView
+		m.actionMsg = tr("Checking XWayland...")
+		return m, m.cmds.xwaylandState()
+	case "Configure Outputs":
+		m.state = actionView
+		m.actionMsg = tr("Detecting outputs...")
+		return m, m.cmds.outputs()
+	case "Configure Appearance":
+		m.state = choiceView
+		m.choice = choice{question: tr("Which look should niri have?")}
+		for _, look := range niri.AppearancePresets {
+			animations := tr("animations off")
+			if look.Slowdown > 0 {
+				animations = trf("animations at %gx", look.Slowdown)
+			}
+			m.choice.options = append(m.choice.options, option{
+				label:   trf("%s: %dpx gaps, %dpx focus ring, %s", tr(look.Name), look.Gaps, look.FocusRing, animations),
+				working: tr("Updating niri config..."),
+				run:     m.cmds.appearance(m.ctx, look),
+			})
+		}
+		m.choice.options = append(m.choice.options,
+			option{label: tr("Custom..."), run: func() tea.Msg { return appearanceStepMsg{look: niri.DefaultAppearance} }},
+			option{label: tr("Back to the menu")},
+		)
+		return m, nil
+	case "Configure Cursor":
+		m.state = actionView
+		m.actionMsg = tr("Looking for cursor themes...")
+		return m, m.cmds.cursors()
+	case "Configure Night Light":
+		m.state = choiceView
+		m.choice = choice{question: tr("Daytime colour temperature:")}
+		for _, day := range niri.DayTemperatures {
+			m.choice.options = append(m.choice.options, option{
+				label: fmt.Sprintf("%dK", day),
+				run:   func() tea.Msg { return nightLightPickedMsg{day: day} },
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case "Configure Screenshots":
+		return m.ask(confirmation{
+			question: trf("Bind %s to a screenshot of the screen and %s to one of a region?\n\nThey are saved in ~/Pictures and copied to the clipboard. Anything else on those keys is replaced.", niri.ScreenshotKey, niri.RegionScreenshotKey),
+			working:  tr("Setting up screenshots..."),
+			run:      m.cmds.screenshots(m.ctx),
+		})
+	case "Configure Brightness Keys":
+		return m.ask(confirmation{
+			question: trf("Bind %s and %s to backlight(8), so the brightness keys work?\n\nAnything else on those keys is replaced.", niri.BrightnessUpKey, niri.BrightnessDownKey),
+			working:  tr("Setting up the brightness keys..."),
+			run:      m.cmds.brightness(m.ctx),
+		})
+	case "Configure Media Keys":
+		return m.ask(confirmation{
+			question: trf("Install wpctl and playerctl and bind the volume and media keys to them?\n\n%s\n\nAnything else on those keys is replaced.", strings.Join(niri.MediaKeys(), " ")),
+			working:  tr("Setting up the media keys..."),
+			run:      m.cmds.mediaKeys(m.ctx),
+		})
+	case "Configure Idle and Lock":
+		return m.ask(confirmation{
+			question: trf("Lock the screen after %d minutes idle and turn the monitors off after %d?\n\nVideo players that inhibit idling, such as mpv and Firefox, keep the screen on while they play.", niri.LockTimeout/60, niri.MonitorsOffTimeout/60),
+			working:  tr("Setting up idle and lock..."),
+			run:      m.cmds.idle(m.ctx),
+		})
+	case "Configure Polkit Agent":
+		m.state = actionView
+		m.actionMsg = tr("Looking for polkit agents...")
+		return m, m.cmds.polkits()
+	case "Generate Default Configs":
+		return m.ask(confirmation{
+			question:    trf("Write the default configs for niri and %s?\n\nEvery file that is replaced is backed up next to it first, and the niri config is validated at the end.", strings.Join(niri.ToolNames(), ", ")),
+			working:     tr("Writing the default configs..."),
+			run:         m.cmds.allDefaults(m.ctx),
+			destructive: true,
+		})
+	case "Configure Screen Sharing":
+		return m.ask(confirmation{
+			question: trf("Install %s and set up screen sharing for browsers and video calls?\n\nThis writes %s and has niri pass its environment to D-Bus at startup.", strings.Join(niri.PortalPackages, ", "), niri.PortalsConfPath()),
+			working:  tr("Setting up screen sharing..."),
+			run:      m.cmds.portal(m.ctx),
+		})
+	case "Configure Audio":
+		m.state = actionView
+		m.actionMsg = tr("Checking audio...")
+		return m, m.cmds.audioChecks()
+	case "Configure Input Method":
+		m.state = choiceView
+		m.choice = choice{question: tr("Which language should fcitx5 let you type?")}
+		for _, im := range niri.InputMethods {
+			m.choice.options = append(m.choice.options, option{
+				label:   trf("%s (%s)", tr(im.Language), im.Package),
+				working: trf("Setting up fcitx5 with %s...", im.Engine),
+				run:     m.cmds.inputMethod(m.ctx, im),
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case "Configure Window Rules":
+		m.state = actionView
+		m.actionMsg = tr("Reading window rules...")
+		return m, m.cmds.rules()
+	case "Configure Input Behavior":
+		m.state = actionView
+		m.actionMsg = tr("Reading niri config...")
+		return m, m.cmds.behavior()
+	case "Configure Workspace Apps":
+		m.state = actionView
+		m.actionMsg = tr("Reading window rules...")
+		return m, m.cmds.workspaceApps()
+	case "Switch Profile":
+		m.state = actionView
+		m.actionMsg = tr("Looking for profiles...")
+		return m, m.cmds.profiles()
+	case "Import Sway Config":
+		m.state = actionView
+		m.actionMsg = tr("Looking for sway and i3 configs...")
+		return m, m.cmds.swayFiles()
+	case "Show Keybinds":
+		return m.runSafe(tr("Reading keybinds..."), m.cmds.keybinds())
+	case "Show Config Paths":
+		return m.runSafe(tr("Looking for niri configs..."), m.cmds.configPaths())
+	case "Settings":
+		m.state = choiceView
+		m.choice = m.settingsChoice(0)
+		return m, nil
+	case "Read Documentation":
+		m.state = choiceView
+		m.choice = choice{
+			question: tr("What would you like to read?"),
+			options: []option{
+				{label: tr("The niri man page"), working: tr("Formatting the man page..."), run: m.cmds.manPage("niri")},
+				{label: tr("The default config, with a comment on every section"), run: func() tea.Msg {
+					return textMsg{title: tr("Default niri config"), text: niri.DefaultConfig}
+				}},
+				{label: tr("Where to read more online"), run: func() tea.Msg {
+					return textMsg{title: tr("niri documentation"), text: niri.WikiLinks()}
+				}},
+				{label: tr("Back to the menu")},
+			},
+		}
+		return m, nil
+	case "Edit Config":
+		editor := strings.Join(niri.Editor(), " ")
+		m.state = choiceView
+		m.choice = choice{question: trf("Edit %s where?", niri.ConfigPath()), options: []option{
+			{label: trf("In %s, then validate it", editor), working: tr("Backing up niri config..."), run: m.cmds.editorBackup(m.ctx)},
+			{label: tr("Inside NiriSetup, saved only if valid"), working: tr("Loading niri config..."), run: m.cmds.edit()},
+			{label: tr("Back to the menu")},
+		}}
+		return m, nil
+	case "Validate Config":
+		return m.runSafe(tr("Validating Niri config..."), m.cmds.validate())
+	case "Reload Config":
+		m.state = actionView
+		m.actionMsg = tr("Reloading niri's config...")
+		return m, m.cmds.reload(m.ctx)
+	case "Repair Config":
+		m.state = actionView
+		m.actionMsg = tr("Checking niri config...")
+		return m, m.cmds.repair()
+	case "Audit Config":
+		return m.runSafe(tr("Looking for deprecated options..."), m.cmds.audit())
+	case "Compare Backups":
+		return m.runSafe(tr("Looking for backups..."), m.cmds.backups())
+	case "Restore Config":
+		m.state = actionView
+		m.actionMsg = tr("Looking for backups...")
+		return m, toRestore(m.cmds.backups())
+	case "System Check":
+		return m.runSafe(tr("Checking the system..."), m.cmds.systemCheck())
+	case "Doctor":
+		return m.runSafe(tr("Running diagnostics..."), m.cmds.doctor())
+	case "Launch Niri":
+		m.state = actionView
+		m.actionMsg = tr("Checking what niri needs to start...")
+		return m, m.cmds.launchChecks()
+	case "Write Start Script":
+		m.state = actionView
+		m.actionMsg = tr("Writing start script...")
+		return m, m.cmds.start(m.ctx)
+	case "Start on Console Login":
+		return m.ask(confirmation{
+			question: tr("Start niri automatically when you log in on the first console (ttyv0)?\n\nA block is added to your shell's login file; it does nothing on other consoles or inside a running Wayland session."),
+			working:  tr("Setting up console autostart..."),
+			run:      m.cmds.autostart(m.ctx),
+		})
+	case "Set Up Login Manager":
+		m.state = actionView
+		m.actionMsg = tr("Looking for login managers...")
+		return m, m.cmds.loginDMs()
+	case "Uninstall Niri":
+		m.state = actionView
+		m.actionMsg = tr("Looking up the packages NiriSetup installed...")
+		return m, m.cmds.setupPkgs()
+	case "Manage Packages":
+		m.state = actionView
+		m.actionMsg = tr("Listing installed packages...")
+		return m, m.cmds.packages()
+	case "Manage Processes":
+		m.state = actionView
+		m.actionMsg = tr("Looking for running processes...")
+		return m, m.cmds.processes()
+	case "Upgrade Packages":
+		return m.ask(confirmation{
+			question: tr("Upgrade the installed packages NiriSetup manages?\n\npkg upgrades whatever they depend on too. Every version that changes is listed afterwards."),
+			working:  tr("Upgrading packages..."),
+			run:      m.cmds.upgrade(m.ctx),
+		})
+	case "Verify Packages":
+		m.state = actionView
+		m.actionMsg = tr("Verifying installed packages...")
+		return m, m.cmds.verify()
+	case "Repair":
+		return m.ask(confirmation{
+			question: tr("Repair the niri setup?\n\nMissing packages are installed and damaged ones reinstalled. A missing niri config is written, and one niri rejects is replaced by its newest backup that validates, or the default config. Missing waybar and mako configs are written too. Nothing that works is changed."),
+			working:  tr("Repairing the niri setup..."),
+			run:      m.cmds.repairSetup(m.ctx),
+		})
+	case "Benchmark Mirrors":
+		m.state = actionView
+		m.actionMsg = tr("Timing pkg mirrors...")
+		return m, m.cmds.mirrors()
+	case "View Last Logs":
+		if len(m.logs) == 0 {
+			m.actionMsg, m.isProcessing = tr("Nothing has been logged yet."), false
+			return m, nil
+		}
+		text := strings.Join(m.stampedLogs(), "\n")
+		if note := m.spillNote(); note != "" {
+			text = note + "\n" + text
+		}
+		next, cmd := m.Update(textMsg{title: tr("Last logs"), text: lipgloss.NewStyle().Width(editorWidth).Render(text)})
+		m = next.(model)
+		m.text.GotoBottom() // the newest lines are what was just done
+		return m, cmd
+	case "Save Logs":
+		m.state = actionView
+		m.actionMsg = tr("Saving logs...")
+		return m, m.cmds.saveLogs(m)
+	case "Generate Bug Report":
+		m.state = actionView
+		m.actionMsg = tr("Collecting bug report details...")
+		return m, m.cmds.bugReport(m)
+	case "Exit":
+		return m, tea.Quit
+	}
+	return m, nil
+}
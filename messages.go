@@ -0,0 +1,572 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// locale is the language UI strings are shown in. It stays English unless
+// main picks another one from the environment.
+var locale = "en"
+
+// catalogs translates UI strings, keyed by locale and then by the English
+// text. English needs no catalog: missing entries fall back to the key.
+var catalogs = map[string]map[string]string{
+	"es": {
+		// Menu
+		"Niri Setup Assistant for GhostBSD": "Asistente de instalación de Niri para GhostBSD",
+		"Version %s":                        "Versión %s",
+		"Install Niri":                      "Instalar Niri",
+		"Retry Failed Packages":             "Reintentar paquetes fallidos",
+		"Install from Cache":                "Instalar desde la caché",
+		"Uninstall Niri":                    "Desinstalar Niri",
+		"Show Dependencies":                 "Mostrar dependencias",
+		"Write Install Script":              "Escribir script de instalación",
+		"Configure Niri":                    "Configurar Niri",
+		"Generate Default Configs":          "Generar configuraciones predeterminadas",
+		"Import Sway Config":                "Importar configuración de sway",
+		"Configure Clipboard":               "Configurar portapapeles",
+		"Toggle XWayland":                   "Activar o desactivar XWayland",
+		"Configure Outputs":                 "Configurar pantallas",
+		"Configure Cursor":                  "Configurar cursor",
+		"Configure Appearance":              "Configurar apariencia",
+		"Configure Night Light":             "Configurar luz nocturna",
+		"Configure Screenshots":             "Configurar capturas de pantalla",
+		"Configure Brightness Keys":         "Configurar teclas de brillo",
+		"Configure Media Keys":              "Configurar teclas multimedia",
+		"Configure Idle and Lock":           "Configurar inactividad y bloqueo",
+		"Configure Polkit Agent":            "Configurar agente de polkit",
+		"Configure Screen Sharing":          "Configurar compartir pantalla",
+		"Configure Audio":                   "Configurar audio",
+		"Configure Input Method":            "Configurar método de entrada",
+		"Configure Input Behavior":          "Configurar comportamiento de entrada",
+		"Show Keybinds":                     "Mostrar atajos",
+		"Read Documentation":                "Leer la documentación",
+		"Configure Window Rules":            "Configurar reglas de ventana",
+		"Configure Workspace Apps":          "Configurar aplicaciones por espacio de trabajo",
+		"Edit Config":                       "Editar configuración",
+		"Show Config Paths":                 "Mostrar rutas de configuración",
+		"Validate Config":                   "Validar configuración",
+		"Reload Config":                     "Recargar configuración",
+		"Repair Config":                     "Reparar configuración",
+		"Restore Config":                    "Restaurar configuración",
+		"Audit Config":                      "Auditar configuración",
+		"Compare Backups":                   "Comparar copias de seguridad",
+		"Switch Profile":                    "Cambiar de perfil",
+		"Doctor":                            "Diagnóstico",
+		"System Check":                      "Comprobar el sistema",
+		"Launch Niri":                       "Iniciar Niri",
+		"Write Start Script":                "Escribir script de inicio",
+		"Start on Console Login":            "Iniciar al entrar en la consola",
+		"Set Up Login Manager":              "Configurar gestor de inicio de sesión",
+		"Manage Packages":                   "Gestionar paquetes",
+		"Verify Packages":                   "Verificar paquetes",
+		"Repair":                            "Reparar",
+		"Manage Processes":                  "Gestionar procesos",
+		"Upgrade Packages":                  "Actualizar paquetes",
+		"Benchmark Mirrors":                 "Medir réplicas",
+		"Save Logs":                         "Guardar registros",
+		"View Last Logs":                    "Ver últimos registros",
+		"Shows this session's log, such as the output of the last install, in a scrollable view.": "Muestra el registro de esta sesión, como la salida de la última instalación, en una vista desplazable.",
+		"Nothing has been logged yet.": "Todavía no se ha registrado nada.",
+		"Last logs":                    "Últimos registros",
+		"Generate Bug Report":          "Generar informe de errores",
+		"Settings":                     "Ajustes",
+		"Exit":                         "Salir",
+
+		// Progress and prompts
+		"Installing Niri...":                                                               "Instalando Niri...",
+		"The download may fail:":                                                           "La descarga puede fallar:",
+		"%s\n\nInstall anyway?":                                                            "%s\n\n¿Instalar de todos modos?",
+		"Check at least one package, or press esc.":                                        "Marca al menos un paquete o pulsa esc.",
+		"Install these %d packages? Uncheck the ones you do not want.":                     "¿Instalar estos %d paquetes? Desmarca los que no quieras.",
+		"space: check   enter: install   esc: cancel":                                      "espacio: marcar   enter: instalar   esc: cancelar",
+		"Install these %d packages?\n\n%s":                                                 "¿Instalar estos %d paquetes?\n\n%s",
+		"Checking the packages are in the repositories...":                                 "Comprobando que los paquetes están en los repositorios...",
+		"Not in the repositories: %s":                                                      "No están en los repositorios: %s",
+		"%s\n\nCheck the package list for typos, or whether your pkg branch carries them.": "%s\n\nRevisa si hay erratas en la lista de paquetes o si tu rama de pkg los incluye.",
+		"Install the %d available packages":                                                "Instalar los %d paquetes disponibles",
+		"Looking up niri's dependencies...":                                                "Buscando las dependencias de niri...",
+		"Pausing after the current package...  [r] Resume":                                 "Pausando tras el paquete actual...  [r] Reanudar",
+		"Now installing: %s":                                                               "Instalando ahora: %s",
+		"[p] Pause":                                                                        "[p] Pausar",
+		"Paused. %d packages left: %s\n\n[r] Resume":                                       "En pausa. Quedan %d paquetes: %s\n\n[r] Reanudar",
+		"Writing install script...":                                                        "Escribiendo el script de instalación...",
+		"Please wait...":                                                                   "Espere, por favor...",
+		"Configuring Niri...":                                                              "Configurando Niri...",
+		"Checking what Configure Niri would change...":                                     "Comprobando qué cambiaría Configurar Niri...",
+		"What Configure Niri would change":                                                 "Lo que cambiaría Configurar Niri",
+		"Nothing to change: the niri config and the starter configs are already what Configure Niri would write.":                                                         "Nada que cambiar: la configuración de niri y las configuraciones iniciales ya son lo que escribiría Configurar Niri.",
+		"Configure Niri can also write starter configs for the programs niri starts, backing up the files they replace. Pick one to check or uncheck it, then configure.": "Configurar Niri también puede escribir configuraciones iniciales para los programas que inicia niri, guardando copia de los archivos que reemplaza. Elige uno para marcarlo o desmarcarlo y luego configura.",
+		"Starter %s config":                 "Configuración inicial de %s",
+		"Checking the niri config...":       "Comprobando la configuración de niri...",
+		"Which terminal should Mod+T open?": "¿Qué terminal debe abrir Mod+T?",
+		"Which launcher should Mod+D open?": "¿Qué lanzador debe abrir Mod+D?",
+		"Which bar should niri start?":      "¿Qué barra debe iniciar niri?",
+		"There is no niri config yet, so Configure Niri writes the starter one (%d of %d). %s": "Todavía no hay configuración de niri, así que Configurar Niri escribe la inicial (%d de %d). %s",
+		"No bar":                             "Sin barra",
+		"(not installed)":                    "(no instalado)",
+		"Configure":                          "Configurar",
+		"Looking for sway and i3 configs...": "Buscando configuraciones de sway e i3...",
+		"No sway or i3 config found in ~/.config/sway, ~/.sway, ~/.config/i3 or ~/.i3.":  "No se encontró ninguna configuración de sway ni de i3 en ~/.config/sway, ~/.sway, ~/.config/i3 ni ~/.i3.",
+		"Import which config?\n\nIt replaces %s; the current config is backed up first.": "¿Qué configuración importar?\n\nSustituye a %s; antes se guarda una copia de la configuración actual.",
+		"Importing the sway config...":              "Importando la configuración de sway...",
+		"Configuring clipboard manager...":          "Configurando el gestor del portapapeles...",
+		"Configuring XWayland...":                   "Configurando XWayland...",
+		"Detecting outputs...":                      "Detectando pantallas...",
+		"Looking for cursor themes...":              "Buscando temas de cursor...",
+		"Installing %s...":                          "Instalando %s...",
+		"Updating niri config...":                   "Actualizando la configuración de niri...",
+		"Which look should niri have?":              "¿Qué aspecto debe tener niri?",
+		"animations off":                            "sin animaciones",
+		"animations at %gx":                         "animaciones a %gx",
+		"%s: %dpx gaps, %dpx focus ring, %s":        "%s: huecos de %dpx, anillo de foco de %dpx, %s",
+		"Compact":                                   "Compacto",
+		"Comfortable":                               "Cómodo",
+		"No animations":                             "Sin animaciones",
+		"Custom...":                                 "Personalizado...",
+		"Gaps around windows, in pixels (0 to %d):": "Huecos alrededor de las ventanas, en píxeles (de 0 a %d):",
+		"Width of the ring around the focused window, in pixels (0 to %d, 0 turns it off):":   "Ancho del anillo alrededor de la ventana enfocada, en píxeles (de 0 a %d, 0 lo desactiva):",
+		"Colour of the ring, a CSS name or #rrggbb:":                                          "Color del anillo, un nombre CSS o #rrggbb:",
+		"%s is not a colour niri understands.":                                                "%s no es un color que niri entienda.",
+		"Animation slowdown: 1 is normal, less is faster, 0 turns animations off (up to %g):": "Ralentización de las animaciones: 1 es normal, menos es más rápido, 0 las desactiva (hasta %g):",
+		"Enter a number from 0 to %g.":                                                        "Introduce un número de 0 a %g.",
+		"Enter a whole number from 0 to %d.":                                                  "Introduce un número entero de 0 a %d.",
+		"Loading niri config...":                                                              "Cargando la configuración de niri...",
+		"Edit %s where?":                                                                      "¿Dónde editar %s?",
+		"In %s, then validate it":                                                             "En %s, y luego validarlo",
+		"Inside NiriSetup, saved only if valid":                                               "Dentro de NiriSetup, solo se guarda si es válido",
+		"Backing up niri config...":                                                           "Haciendo una copia de la configuración de niri...",
+		"Backed up niri config to %s":                                                         "Copia de la configuración de niri guardada en %s",
+		"Editing %s...":                                                                       "Editando %s...",
+		"%s failed: %s":                                                                       "%s falló: %s",
+		"Validating Niri config...":                                                           "Validando la configuración de Niri...",
+		"Reloading niri's config...":                                                          "Recargando la configuración de niri...",
+		"niri was not started, fix the ✗ above first":                                         "niri no se ha iniciado, corrige antes lo marcado con ✗",
+		"Reading keybinds...":                                                                 "Leyendo los atajos...",
+		"Looking for niri configs...":                                                         "Buscando configuraciones de niri...",
+		"Where niri looks for its config":                                                     "Dónde busca niri su configuración",
+		"What would you like to read?":                                                        "¿Qué quieres leer?",
+		"The niri man page":                                                                   "La página de manual de niri",
+		"Formatting the man page...":                                                          "Formateando la página de manual...",
+		"The default config, with a comment on every section":                                 "La configuración predeterminada, con un comentario en cada sección",
+		"Default niri config":                                                                 "Configuración predeterminada de niri",
+		"Where to read more online":                                                           "Dónde leer más en línea",
+		"niri documentation":                                                                  "Documentación de niri",
+		"Keybinds":                                                                            "Atajos",
+		"Checking niri config...":                                                             "Comprobando la configuración de niri...",
+		"Looking for deprecated options...":                                                   "Buscando opciones obsoletas...",
+		"Looking for backups...":                                                              "Buscando copias de seguridad...",
+		"Comparing backups...":                                                                "Comparando copias de seguridad...",
+		"Running diagnostics...":                                                              "Ejecutando diagnósticos...",
+		"Launching niri...":                                                                   "Iniciando niri...",
+		"Checking what niri needs to start...":                                                "Comprobando lo que niri necesita para arrancar...",
+		"%s\n\nHow do you want to run niri?":                                                  "%s\n\n¿Cómo quiere ejecutar niri?",
+		"Run it here, back to NiriSetup when it exits":                                        "Ejecutarlo aquí y volver a NiriSetup al salir",
+		"Running niri...":                                                                     "Ejecutando niri...",
+		"Start it in the background":                                                          "Iniciarlo en segundo plano",
+		"niri exited: %s":                                                                     "niri terminó: %s",
+		"niri exited; back in NiriSetup.":                                                     "niri terminó; de vuelta en NiriSetup.",
+		"Writing start script...":                                                             "Escribiendo el script de inicio...",
+		"Start niri automatically when you log in on the first console (ttyv0)?\n\nA block is added to your shell's login file; it does nothing on other consoles or inside a running Wayland session.": "¿Iniciar niri automáticamente al entrar en la primera consola (ttyv0)?\n\nSe añade un bloque al archivo de inicio de sesión de tu shell; no hace nada en otras consolas ni dentro de una sesión Wayland en marcha.",
+		"Setting up console autostart...":            "Configurando el inicio automático en la consola...",
+		"Looking for login managers...":              "Buscando gestores de inicio de sesión...",
+		"Setting up %s...":                           "Configurando %s...",
+		"Which language should fcitx5 let you type?": "¿Qué idioma debe permitir escribir fcitx5?",
+		"Setting up fcitx5 with %s...":               "Configurando fcitx5 con %s...",
+		"Chinese (Pinyin)":                           "Chino (Pinyin)",
+		"Japanese (Mozc)":                            "Japonés (Mozc)",
+		"Japanese (Anthy)":                           "Japonés (Anthy)",
+		"Korean (Hangul)":                            "Coreano (Hangul)",
+		"Vietnamese (Unikey)":                        "Vietnamita (Unikey)",
+		"Reading the niri log...":                    "Leyendo el registro de niri...",
+		"Listing installed packages...":              "Listando los paquetes instalados...",
+		"Looking for running processes...":           "Buscando procesos en ejecución...",
+		"NiriSetup settings. Pick one to change it; it is saved right away.": "Ajustes de NiriSetup. Elige uno para cambiarlo; se guarda en el acto.",
+		"Dry run: show changes instead of making them":                       "Simulación: mostrar los cambios en vez de hacerlos",
+		"Answer yes to every confirmation":                                   "Responder sí a cada confirmación",
+		"With yes, accept destructive confirmations too":                     "Con sí, aceptar también las confirmaciones destructivas",
+		"What to log":             "Qué registrar",
+		"Colors":                  "Colores",
+		"Set %s to %s":            "%s cambiado a %s",
+		"Failed to save settings": "No se pudieron guardar los ajustes",
+		"Warning: ignoring the settings file: %s":                                                 "Advertencia: se ignora el archivo de ajustes: %s",
+		"Warning: ignoring %s = %s in the settings file, it takes %v":                             "Advertencia: se ignora %s = %s en el archivo de ajustes, admite %v",
+		"Another NiriSetup (pid %d) is running. Only the entries without %s work until it exits.": "Hay otro NiriSetup en ejecución (pid %d). Solo funcionan las entradas sin %s hasta que termine.",
+		"Warning: running without the instance lock: %s":                                          "Advertencia: se ejecuta sin el bloqueo de instancia: %s",
+		"None of the programs NiriSetup installs is running.":                                     "Ninguno de los programas que instala NiriSetup está en ejecución.",
+		"Running programs installed by NiriSetup.\nPick one to restart or kill it.":               "Programas en ejecución instalados por NiriSetup.\nElige uno para reiniciarlo o terminarlo.",
+		"What should happen to %s (pid %d)?":                                                      "¿Qué debe pasar con %s (pid %d)?",
+		"Restart it":                                                                              "Reiniciarlo",
+		"Kill it":                                                                                 "Terminarlo",
+		"Restarting %s...":                                                                        "Reiniciando %s...",
+		"Killing %s...":                                                                           "Terminando %s...",
+		"Removing %s...":                                                                          "Eliminando %s...",
+		"Verifying installed packages...":                                                         "Verificando los paquetes instalados...",
+		"Reinstalling packages...":                                                                "Reinstalando paquetes...",
+		"Upgrade the installed packages NiriSetup manages?\n\npkg upgrades whatever they depend on too. Every version that changes is listed afterwards.": "¿Actualizar los paquetes instalados que gestiona NiriSetup?\n\npkg también actualiza aquello de lo que dependen. Después se muestran todas las versiones que cambien.",
+		"Upgrading packages...":              "Actualizando paquetes...",
+		"Everything was already up to date.": "Todo estaba ya actualizado.",
+		"What changed: %d packages":          "Qué ha cambiado: %d paquetes",
+		"What changed:":                      "Qué ha cambiado:",
+		"(new)":                              "(nuevo)",
+		"(removed)":                          "(eliminado)",
+		"Timing pkg mirrors...":              "Midiendo las réplicas de pkg...",
+		"Saving logs...":                     "Guardando registros...",
+		"No logs to save.":                   "No hay registros que guardar.",
+		"Collecting bug report details...":   "Recopilando datos para el informe de errores...",
+		"Bug report written to %s":           "Informe de errores escrito en %s",
+		"%s\n\nIt has your config and recent logs, with your home directory, user and host names and secret-looking environment values redacted. Have a look before sharing it.": "%s\n\nIncluye tu configuración y los registros recientes, con tu directorio personal, tus nombres de usuario y de equipo y los valores de entorno que parecen secretos ocultos. Revísalo antes de compartirlo.",
+		"Open it":                   "Abrirlo",
+		"Reading the bug report...": "Leyendo el informe de errores...",
+		"Restoring backup...":       "Restaurando la copia de seguridad...",
+		"Restore which backup?\nThe current config is backed up first.":                           "¿Qué copia de seguridad restaurar?\nAntes se guarda una copia de la configuración actual.",
+		"There are no backups of %s yet. One is taken before every change NiriSetup makes to it.": "Todavía no hay copias de seguridad de %s. Se toma una antes de cada cambio que NiriSetup hace en él.",
+		"Checked the new config: niri validate passed.":                                           "Nueva configuración comprobada: niri validate la acepta.",
+		"The config just written fails niri validate:\n%s":                                        "La configuración recién escrita no pasa niri validate:\n%s",
+		"%s\n\nUndo the change by restoring %s?":                                                  "%s\n\n¿Deshacer el cambio restaurando %s?",
+		"Undo the change":                                                                         "Deshacer el cambio",
+		"Keep it":                                                                                 "Mantenerla",
+		"Writing default config...":                                                               "Escribiendo la configuración predeterminada...",
+		"Updating pkg configuration...":                                                           "Actualizando la configuración de pkg...",
+		"Validating...":                                                                           "Validando...",
+		"Cancelled.":                                                                              "Cancelado.",
+		"[y] Yes   [n] No":                                                                        "[y] Sí   [n] No",
+		"Edit cancelled, config unchanged.":                                                       "Edición cancelada, la configuración no ha cambiado.",
+		"XWayland is off. Enable X11 app support?\n\nThis starts xwayland-satellite with niri and sets DISPLAY in the environment block.":                                                       "XWayland está desactivado. ¿Activar la compatibilidad con aplicaciones X11?\n\nEsto inicia xwayland-satellite con niri y define DISPLAY en el bloque environment.",
+		"XWayland is on. Turn it off for a pure-Wayland session?\n\nThis removes xwayland-satellite from spawn-at-startup and DISPLAY from the environment block, so X11 apps no longer start.": "XWayland está activado. ¿Desactivarlo para una sesión solo Wayland?\n\nEsto quita xwayland-satellite de spawn-at-startup y DISPLAY del bloque environment, así que las aplicaciones X11 dejarán de iniciarse.",
+		"Checking XWayland...":    "Comprobando XWayland...",
+		"Turning XWayland off...": "Desactivando XWayland...",
+		"The niri config is invalid:\n\n%s\n\nHow do you want to repair it?": "La configuración de niri no es válida:\n\n%s\n\n¿Cómo quiere repararla?",
+		"Restore %s":                    "Restaurar %s",
+		"Regenerate the default config": "Regenerar la configuración predeterminada",
+		"Leave it as it is":             "Dejarla como está",
+		"%s\n\nShow the niri log?":      "%s\n\n¿Mostrar el registro de niri?",
+		"Show the niri log":             "Mostrar el registro de niri",
+		"Back to the menu":              "Volver al menú",
+		"%s is already bound to %s.\n\nWhich key should open clipboard history?": "%s ya está asignada a %s.\n\n¿Qué tecla debe abrir el historial del portapapeles?",
+		"Use %s":                                         "Usar %s",
+		"Leave the keybinds as they are":                 "Dejar los atajos como están",
+		"Which output do you want to scale?":             "¿Qué pantalla quiere escalar?",
+		"No cursor themes are installed.\n\nInstall %s?": "No hay temas de cursor instalados.\n\n¿Instalar %s?",
+		"Which cursor theme do you want?":                "¿Qué tema de cursor quiere?",
+		"Daytime colour temperature:":                    "Temperatura de color de día:",
+		"Bind %s to a screenshot of the screen and %s to one of a region?\n\nThey are saved in ~/Pictures and copied to the clipboard. Anything else on those keys is replaced.": "¿Asignar %s a una captura de la pantalla y %s a una de una región?\n\nSe guardan en ~/Pictures y se copian al portapapeles. Se sustituye lo que hubiera en esas teclas.",
+		"Setting up screenshots...": "Configurando las capturas de pantalla...",
+		"Bind %s and %s to backlight(8), so the brightness keys work?\n\nAnything else on those keys is replaced.": "¿Asignar %s y %s a backlight(8) para que funcionen las teclas de brillo?\n\nSe sustituye lo que hubiera en esas teclas.",
+		"Setting up the brightness keys...": "Configurando las teclas de brillo...",
+		"Install wpctl and playerctl and bind the volume and media keys to them?\n\n%s\n\nAnything else on those keys is replaced.": "¿Instalar wpctl y playerctl y asignarles las teclas de volumen y multimedia?\n\n%s\n\nSe sustituye lo que hubiera en esas teclas.",
+		"Setting up the media keys...": "Configurando las teclas multimedia...",
+		"Lock the screen after %d minutes idle and turn the monitors off after %d?\n\nVideo players that inhibit idling, such as mpv and Firefox, keep the screen on while they play.": "¿Bloquear la pantalla tras %d minutos de inactividad y apagar los monitores tras %d?\n\nLos reproductores de vídeo que impiden la inactividad, como mpv y Firefox, mantienen la pantalla encendida mientras reproducen.",
+		"Setting up idle and lock...":  "Configurando inactividad y bloqueo...",
+		"Looking for polkit agents...": "Buscando agentes de polkit...",
+		"Install %s and set up screen sharing for browsers and video calls?\n\nThis writes %s and has niri pass its environment to D-Bus at startup.": "¿Instalar %s y configurar la compartición de pantalla para navegadores y videollamadas?\n\nEsto escribe %s y hace que niri pase su entorno a D-Bus al arrancar.",
+		"Setting up screen sharing...": "Configurando la compartición de pantalla...",
+		"Warning: this machine has %.1f GiB of RAM, less than %d GiB. Expect the desktop to swap, and install binary packages rather than building from ports, which would thrash.": "Advertencia: esta máquina tiene %.1f GiB de RAM, menos de %d GiB. El escritorio usará la memoria de intercambio; instala paquetes binarios en vez de compilar desde los ports, que saturaría el sistema.",
+		"Write the default configs for niri and %s?\n\nEvery file that is replaced is backed up next to it first, and the niri config is validated at the end.":                     "¿Escribir las configuraciones predeterminadas de niri y %s?\n\nCada archivo que se reemplaza se respalda antes a su lado, y la configuración de niri se valida al final.",
+		"Writing the default configs...":             "Escribiendo las configuraciones predeterminadas...",
+		"Checking audio...":                          "Comprobando el audio...",
+		"Audio already works.":                       "El audio ya funciona.",
+		"%s\n\nInstall %s and start them with niri?": "%s\n\n¿Instalar %s e iniciarlos con niri?",
+		"Setting up audio...":                        "Configurando el audio...",
+		"None of the polkit agents NiriSetup knows is in the repositories.": "Ninguno de los agentes de polkit que conoce NiriSetup está en los repositorios.",
+		"Which polkit agent should show authentication prompts?":            "¿Qué agente de polkit debe mostrar las peticiones de autenticación?",
+		"Reading window rules...":                                           "Leyendo las reglas de ventana...",
+		"Looking for profiles...":                                           "Buscando perfiles...",
+		"Which profile should niri use? Profiles live in %s.":               "¿Qué perfil debe usar niri? Los perfiles están en %s.",
+		"%s (active)":        "%s (activo)",
+		"Switching to %s...": "Cambiando a %s...",
+		"Save the current config as a new profile": "Guardar la configuración actual como un perfil nuevo",
+		"Name for the new profile:":                "Nombre del perfil nuevo:",
+		"Saving profile %s...":                     "Guardando el perfil %s...",
+		"Profile: %s":                              "Perfil: %s",
+		"Warning: niri %s is older than %s; some configure actions write options it cannot parse.": "Aviso: niri %s es anterior a %s; algunas acciones de configuración escriben opciones que no entiende.",
+		"%d window rules. Add one, or pick one to remove:":                                         "%d reglas de ventana. Añade una o elige una para quitarla:",
+		"Reading niri config...": "Leyendo la configuración de niri...",
+		"How should focus and the mouse behave? Pick a setting to turn it on or off, then save.": "¿Cómo deben comportarse el foco y el ratón? Elige un ajuste para activarlo o desactivarlo y luego guarda.",
+		"Focus follows the mouse":                      "El foco sigue al ratón",
+		"Move the mouse to the focused window":         "Mover el ratón a la ventana enfocada",
+		"Switching to the current workspace goes back": "Ir al espacio de trabajo actual vuelve al anterior",
+		"Use the defaults":                             "Usar los valores por defecto",
+		"Save":                                         "Guardar",
+		"%d apps open on a workspace at startup. Add one, or pick one to move or remove:": "%d aplicaciones se abren en un espacio de trabajo al iniciar. Añade una o elige una para moverla o quitarla:",
+		"Add an app":                   "Añadir una aplicación",
+		"Command that starts the app:": "Orden que inicia la aplicación:",
+		"App ID of its windows (niri msg windows shows it):":               "App ID de sus ventanas (niri msg windows lo muestra):",
+		"Move it to another workspace":                                     "Moverla a otro espacio de trabajo",
+		"Remove it":                                                        "Quitarla",
+		"Workspace to open %s on (1 is the first):":                        "Espacio de trabajo en el que abrir %s (1 es el primero):",
+		"%s is not a workspace number.":                                    "%s no es un número de espacio de trabajo.",
+		"Add a rule matching the %s":                                       "Añadir una regla según %s",
+		"Remove: %s":                                                       "Quitar: %s",
+		"Remove the window rule for %s?":                                   "¿Quitar la regla de ventana para %s?",
+		"Regular expression the window's %s must match:":                   "Expresión regular que debe cumplir el %s de la ventana:",
+		"What should happen to windows whose %s matches %s?":               "¿Qué hacer con las ventanas cuyo %s cumple %s?",
+		"enter: continue   esc: cancel":                                    "enter: continuar   esc: cancelar",
+		"Night colour temperature (day is %dK):":                           "Temperatura de color de noche (de día %dK):",
+		"Fade between %dK and %dK over:":                                   "Pasar de %dK a %dK durante:",
+		"%d minutes":                                                       "%d minutos",
+		"Cursor size for %s:":                                              "Tamaño del cursor para %s:",
+		"Which login manager should offer niri?":                           "¿Qué gestor de inicio de sesión debe ofrecer niri?",
+		"Install %s":                                                       "Instalar %s",
+		"%s (installed)":                                                   "%s (instalado)",
+		"Compare which backup...":                                          "Comparar la copia de seguridad...",
+		"...with which backup?\n(first: %s)":                               "...¿con cuál?\n(primera: %s)",
+		"Changes between backups":                                          "Cambios entre copias de seguridad",
+		"✓ installed   ↓ will be fetched":                                  "✓ instalado   ↓ se descargará",
+		"(see above)":                                                      "(ver arriba)",
+		"%d packages would be fetched.":                                    "Se descargarían %d paquetes.",
+		"What niri depends on":                                             "De qué depende niri",
+		"↑/↓ pgup/pgdn: scroll   esc: back to the menu":                    "↑/↓ re pág/av pág: desplazarse   esc: volver al menú",
+		"↑/↓ pgup/pgdn: scroll   y: go ahead   n: back to the menu":        "↑/↓ re pág/av pág: desplazarse   y: adelante   n: volver al menú",
+		"↑/↓ pgup/pgdn: scroll the log":                                    "↑/↓ re pág/av pág: desplazar el registro",
+		"Scale for %s:\nHiDPI laptop screens usually want 1.5 or 2.":       "Escala para %s:\nLas pantallas HiDPI de portátiles suelen necesitar 1.5 o 2.",
+		"Installed packages managed by NiriSetup.\nPick one to remove it.": "Paquetes instalados gestionados por NiriSetup.\nElija uno para eliminarlo.",
+		"Remove %s?\n\nOther packages that depend on it may stop working.": "¿Eliminar %s?\n\nOtros paquetes que dependen de él pueden dejar de funcionar.",
+		"%s\n\nReinstall %s?":                                              "%s\n\n¿Reinstalar %s?",
+		"%s\n\nUse %s for pkg?":                                            "%s\n\n¿Usar %s para pkg?",
+		"pkg mirrors, fastest first:":                                      "Réplicas de pkg, de la más rápida a la más lenta:",
+		"No mirror responded.":                                             "Ninguna réplica respondió.",
+		"Editing %s":                                                       "Editando %s",
+		"ctrl+s: validate and save   esc: discard changes":                 "ctrl+s: validar y guardar   esc: descartar cambios",
+
+		// Results
+		"Successfully installed %s": "%s instalado correctamente",
+		" (took %s)":                " (tardó %s)",
+		" (took %s, installing %d packages together)":       " (tardó %s, instalando %d paquetes juntos)",
+		"The install took %s in total.":                     "La instalación tardó %s en total.",
+		"Installed %s from the cache":                       "%s instalado desde la caché",
+		"Installed %d packages, from the cache: %s":         "Se instalaron %d paquetes, desde la caché: %s",
+		"Directory with the downloaded .pkg files:":         "Directorio con los archivos .pkg descargados:",
+		"Looking for the packages in %s...":                 "Buscando los paquetes en %s...",
+		"None of the packages are in %s":                    "Ninguno de los paquetes está en %s",
+		"Not in %s: %s":                                     "No están en %s: %s",
+		"%s\n\nFetching them needs the network.":            "%s\n\nDescargarlos requiere conexión a la red.",
+		"Install %d from the cache and fetch the other %d":  "Instalar %d desde la caché y descargar los otros %d",
+		"%s changes your system":                            "%s modifica el sistema",
+		"? explains the highlighted entry   /: filter":      "? explica la entrada   /: filtrar",
+		"No entry matches; esc clears the filter.":          "Ninguna entrada coincide; esc borra el filtro.",
+		"↑/↓ j/k g/G: move   enter: select   q: quit":       "↑/↓ j/k g/G: mover   enter: elegir   q: salir",
+		"ctrl+c: cancel   other keys wait until it is done": "ctrl+c: cancelar   las demás teclas esperan",
+		"Cancelling the install...":                         "Cancelando la instalación...",
+		"Setting up seatd...":                               "Configurando seatd...",
+		"Enabling the services of %s...":                    "Habilitando los servicios de %s...",
+		"Using the setup profile %s.":                       "Usando el perfil de instalación %s.",
+		"Checking the system...":                            "Comprobando el sistema...",
+		"Ready to run niri.":                                "Listo para ejecutar niri.",
+		"Not ready yet: fix the items marked ✗ first.":      "Todavía no está listo: corrige primero lo marcado con ✗.",
+		"Checks that niri can run here: niri and wlroots installed, seatd enabled and running, XDG_RUNTIME_DIR and the video group.": "Comprueba que niri puede ejecutarse aquí: niri y wlroots instalados, seatd habilitado y en marcha, XDG_RUNTIME_DIR y el grupo video.",
+		"Cancelled: %s": "Cancelado: %s",
+		"Install cancelled. Installed %d of %d packages.": "Instalación cancelada. Se instalaron %d de %d paquetes.",
+		"↑/↓: move   enter: pick   esc: back to the menu": "↑/↓: mover   enter: elegir   esc: volver al menú",
+		"Needs sudo: yes":         "Necesita sudo: sí",
+		"Needs sudo: no":          "Necesita sudo: no",
+		"Changes nothing.":        "No cambia nada.",
+		"To undo: %s":             "Para deshacerlo: %s",
+		"Press any key to close.": "Pulsa cualquier tecla para cerrar.",
+		"Really quit? An action is still running, and quitting stops it.":                         "¿Salir de verdad? Una acción sigue en curso y salir la detiene.",
+		"y: quit   any other key: keep it running":                                                "y: salir   otra tecla: dejarla seguir",
+		"Really cancel? It is still running, and cancelling stops it.":                            "¿Cancelar de verdad? Sigue en curso y cancelar lo detiene.",
+		"y: cancel it   any other key: keep it running":                                           "y: cancelarla   otra tecla: dejarla seguir",
+		"Warning: the changes will be shown again: %s":                                            "Aviso: los cambios se mostrarán de nuevo: %s",
+		"NiriSetup was updated to %s. What changed:":                                              "NiriSetup se actualizó a %s. Qué cambió:",
+		"Enable X11 Apps is now Toggle XWayland, which can also remove xwayland-satellite again.": "Enable X11 Apps ahora es Toggle XWayland, que también puede quitar xwayland-satellite de nuevo.",
+		"Generate Default Configs writes starter configs for waybar, mako, fuzzel and swaylock; run it to get them, your own files are backed up.": "Generate Default Configs escribe configuraciones iniciales para waybar, mako, fuzzel y swaylock; ejecútalo para obtenerlas, tus propios archivos se respaldan.",
+		"Configs written before lack brightness and media key binds; Configure Brightness Keys and Configure Media Keys add them.":                 "Las configuraciones escritas antes no tienen atajos de brillo ni de teclas multimedia; Configure Brightness Keys y Configure Media Keys los añaden.",
+		"Every config NiriSetup writes is now checked with niri validate first, so a change niri would reject no longer reaches config.kdl.":       "Cada configuración que escribe NiriSetup se comprueba ahora primero con niri validate, así que un cambio que niri rechazaría ya no llega a config.kdl.",
+		"Installs niri and the packages a desktop needs with pkg, or the ones listed in ~/.config/nirisetup/packages.":                             "Instala con pkg niri y los paquetes que necesita un escritorio, o los que lista ~/.config/nirisetup/packages.",
+		"Uninstall Niri removes what it installed.":                                                                                   "Desinstalar Niri elimina lo que instaló.",
+		"Removes the packages Install Niri installed, keeping ones you had before and ones other packages need.":                      "Elimina los paquetes que instaló Instalar Niri, conservando los que ya tenías y los que necesitan otros paquetes.",
+		"Install Niri installs them again.":                                                                                           "Instalar Niri los vuelve a instalar.",
+		"Looking up the packages NiriSetup installed...":                                                                              "Buscando los paquetes que instaló NiriSetup...",
+		"NiriSetup has not installed any packages, so there is nothing to uninstall. Packages you had before are left alone.":         "NiriSetup no ha instalado ningún paquete, así que no hay nada que desinstalar. Los paquetes que ya tenías no se tocan.",
+		"Remove the %d packages NiriSetup installed?\n\n%s\n\nPackages you had before, and ones other packages still need, are kept.": "¿Eliminar los %d paquetes que instaló NiriSetup?\n\n%s\n\nSe conservan los paquetes que ya tenías y los que otros paquetes aún necesitan.",
+		"Uninstalling Niri...": "Desinstalando Niri...",
+		"Installs again only the packages the last install did not get installed.":                             "Vuelve a instalar solo los paquetes que la última instalación no llegó a instalar.",
+		"Installs the same packages from a directory of downloaded .pkg files, without the network.":           "Instala los mismos paquetes desde un directorio de archivos .pkg descargados, sin red.",
+		"Shows every package niri depends on, marking the ones already installed.":                             "Muestra todos los paquetes de los que depende niri y marca los ya instalados.",
+		"Writes install.sh in the current directory with the pkg commands Install Niri would run, for review.": "Escribe install.sh en el directorio actual con las órdenes pkg que ejecutaría Instalar Niri, para revisarlas.",
+		"Delete install.sh.": "Borra install.sh.",
+		"Writes a starter config.kdl to ~/.config/niri if there is none, then merges your snippets from ~/.config/nirisetup/snippets into it. It can also write starter configs for waybar, mako, fuzzel and swaylock; you pick which.": "Escribe un config.kdl inicial en ~/.config/niri si no existe y luego integra en él tus fragmentos de ~/.config/nirisetup/snippets. También puede escribir configuraciones iniciales para waybar, mako, fuzzel y swaylock; tú eliges cuáles.",
+		"The old config.kdl is backed up; put it back with Restore Config or compare with Compare Backups. Replaced waybar, mako, fuzzel and swaylock configs are kept next to themselves with a .bak.<time> suffix.":                   "Se guarda una copia del config.kdl anterior; restáurala con Restaurar configuración o compárala con Comparar copias de seguridad. Las configuraciones de waybar, mako, fuzzel y swaylock reemplazadas se guardan junto a ellas con el sufijo .bak.<hora>.",
+		"Writes starter configs for waybar, mako, fuzzel and swaylock, then the default niri config, and validates it.":                                                                                                                 "Escribe configuraciones iniciales para waybar, mako, fuzzel y swaylock y luego la de niri por defecto, y la valida.",
+		"Every file replaced is kept next to itself with a .bak.<time> suffix.":                                                                                                                                                         "Cada archivo reemplazado se conserva a su lado con el sufijo .bak.<hora>.",
+		"Translates the keybinds, outputs and autostart commands of a sway or i3 config into a new niri config.":                                                                                                                        "Traduce los atajos, salidas y órdenes de inicio de una configuración de sway o i3 a una nueva de niri.",
+		"The old config.kdl is backed up first.": "Antes se guarda una copia del config.kdl anterior.",
+		"Installs wl-clipboard and cliphist, starts the clipboard history with niri and binds a key to pick from it.": "Instala wl-clipboard y cliphist, inicia el historial del portapapeles con niri y asigna una tecla para elegir de él.",
+		"Remove the bind and spawn-at-startup line with Edit Config; config.kdl is backed up first.":                  "Quita el atajo y la línea spawn-at-startup con Editar configuración; antes se guarda una copia de config.kdl.",
+		"Starts xwayland-satellite with niri so X11 apps run, or removes it for a pure-Wayland session.":              "Inicia xwayland-satellite con niri para que funcionen las aplicaciones X11, o lo quita para una sesión solo Wayland.",
+		"Run it again to switch back.":                                                                                                                              "Ejecútalo otra vez para volver atrás.",
+		"Sets the scale of one of your outputs, for HiDPI screens.":                                                                                                 "Ajusta la escala de una de tus salidas, para pantallas HiDPI.",
+		"Pick another scale; config.kdl is backed up first.":                                                                                                        "Elige otra escala; antes se guarda una copia de config.kdl.",
+		"Sets the cursor theme and size in config.kdl and the environment, installing a theme if there is none.":                                                    "Ajusta el tema y el tamaño del cursor en config.kdl y el entorno, e instala un tema si no hay ninguno.",
+		"Pick another theme; config.kdl is backed up first.":                                                                                                        "Elige otro tema; antes se guarda una copia de config.kdl.",
+		"Sets the gaps between windows, the focus ring and the animation speed.":                                                                                    "Ajusta el espacio entre ventanas, el anillo de foco y la velocidad de las animaciones.",
+		"Pick Comfortable for niri's defaults; config.kdl is backed up first.":                                                                                      "Elige Cómodo para los valores por defecto de niri; antes se guarda una copia de config.kdl.",
+		"Sets the day and night colour temperatures wlsunset fades between.":                                                                                        "Ajusta las temperaturas de color de día y de noche entre las que cambia wlsunset.",
+		"Pick other temperatures; config.kdl is backed up first.":                                                                                                   "Elige otras temperaturas; antes se guarda una copia de config.kdl.",
+		"Installs slurp and wl-clipboard and binds Print and Shift+Print to screenshots saved in ~/Pictures.":                                                       "Instala slurp y wl-clipboard y asigna Imprimir y Mayús+Imprimir a capturas guardadas en ~/Pictures.",
+		"Remove the binds with Edit Config; config.kdl is backed up first.":                                                                                         "Quita los atajos con Editar configuración; antes se guarda una copia de config.kdl.",
+		"Binds the brightness keys to backlight(8) on the backlight device the GPU driver provides.":                                                                "Asigna las teclas de brillo a backlight(8) sobre el dispositivo de retroiluminación que ofrece el driver de la GPU.",
+		"Installs wireplumber and playerctl and binds the volume, mute and play/pause/next/previous keys to them.":                                                  "Instala wireplumber y playerctl y les asigna las teclas de volumen, silencio y reproducir/pausa/siguiente/anterior.",
+		"Installs swayidle and swaylock and locks the screen when idle, turning the monitors off later.":                                                            "Instala swayidle y swaylock y bloquea la pantalla por inactividad, apagando después los monitores.",
+		"Remove the swayidle spawn-at-startup line with Edit Config; config.kdl is backed up first.":                                                                "Quita la línea spawn-at-startup de swayidle con Editar configuración; antes se guarda una copia de config.kdl.",
+		"Installs a polkit agent if needed and starts it with niri, so password prompts show up.":                                                                   "Instala un agente de polkit si hace falta y lo inicia con niri, para que aparezcan las peticiones de contraseña.",
+		"Remove its spawn-at-startup line with Edit Config; config.kdl is backed up first.":                                                                         "Quita su línea spawn-at-startup con Editar configuración; antes se guarda una copia de config.kdl.",
+		"Installs xdg-desktop-portal with the wlr backend and starts it with niri, for screen sharing in browsers.":                                                 "Instala xdg-desktop-portal con el backend wlr y lo inicia con niri, para compartir pantalla en navegadores.",
+		"An old portals.conf is kept as portals.conf.bak; config.kdl is backed up first.":                                                                           "Un portals.conf anterior se conserva como portals.conf.bak; antes se guarda una copia de config.kdl.",
+		"Checks whether sound works and, if not, installs pipewire and wireplumber and starts them with niri.":                                                      "Comprueba si funciona el sonido y, si no, instala pipewire y wireplumber y los inicia con niri.",
+		"Remove the spawn-at-startup line with Edit Config; config.kdl is backed up first.":                                                                         "Quita la línea spawn-at-startup con Editar configuración; antes se guarda una copia de config.kdl.",
+		"Installs fcitx5 with an engine for Chinese, Japanese, Korean or Vietnamese and starts it with niri.":                                                       "Instala fcitx5 con un motor para chino, japonés, coreano o vietnamita y lo inicia con niri.",
+		"Remove the fcitx5 lines with Edit Config; config.kdl is backed up first.":                                                                                  "Quita las líneas de fcitx5 con Editar configuración; antes se guarda una copia de config.kdl.",
+		"Adds or removes window rules, e.g. to open an app floating or at a given opacity.":                                                                         "Añade o quita reglas de ventana, p. ej. para abrir una aplicación flotante o con cierta opacidad.",
+		"Remove the rule the same way; config.kdl is backed up first.":                                                                                              "Quita la regla de la misma forma; antes se guarda una copia de config.kdl.",
+		"Turns focus-follows-mouse, warp-mouse-to-focus and workspace-auto-back-and-forth on or off.":                                                               "Activa o desactiva focus-follows-mouse, warp-mouse-to-focus y workspace-auto-back-and-forth.",
+		"Turn them back; config.kdl is backed up first.":                                                                                                            "Vuelve a cambiarlos; antes se guarda una copia de config.kdl.",
+		"Starts apps with niri and opens each on the workspace you pick, with spawn-at-startup and window rules.":                                                   "Inicia aplicaciones con niri y abre cada una en el espacio de trabajo que elijas, con spawn-at-startup y reglas de ventana.",
+		"Remove the app here again; config.kdl is backed up first.":                                                                                                 "Quita la aplicación desde aquí; antes se guarda una copia de config.kdl.",
+		"Lists the binds in config.kdl and the common keys that are still free.":                                                                                    "Lista los atajos de config.kdl y las teclas habituales que siguen libres.",
+		"Shows the niri man page, the annotated default config or where to read more online.":                                                                       "Muestra la página de manual de niri, la configuración por defecto comentada o dónde leer más en línea.",
+		"Edits config.kdl in your $EDITOR, then runs niri validate on it, or inside NiriSetup, where it is only saved if niri validate accepts it.":                 "Edita config.kdl en tu $EDITOR y luego ejecuta niri validate, o dentro de NiriSetup, donde solo se guarda si niri validate lo acepta.",
+		"The old config.kdl is backed up before the editor opens and on every save.":                                                                                "Se conserva una copia del config.kdl anterior antes de abrir el editor y cada vez que se guarda.",
+		"Lists every place niri looks for config.kdl, in order, and which one it uses.":                                                                             "Lista en orden todos los sitios donde niri busca config.kdl y cuál usa.",
+		"Runs niri validate on config.kdl.":                                                                                                                         "Ejecuta niri validate sobre config.kdl.",
+		"Asks the running niri to load config.kdl again; it keeps the current config if the new one is invalid.":                                                    "Pide al niri en marcha que vuelva a cargar config.kdl; si el nuevo no es válido, mantiene el actual.",
+		"Fix config.kdl and reload again.":                                                                                                                          "Corrige config.kdl y vuelve a recargar.",
+		"If config.kdl is broken, restores the newest backup that validates or writes the default config.":                                                          "Si config.kdl está roto, restaura la copia más reciente que sea válida o escribe la configuración por defecto.",
+		"The broken config is backed up first.":                                                                                                                     "Antes se guarda una copia de la configuración rota.",
+		"Lists the backups taken of config.kdl before each change, newest first, and puts the one you pick back.":                                                   "Muestra las copias de seguridad de config.kdl tomadas antes de cada cambio, de la más reciente a la más antigua, y vuelve a poner la que elijas.",
+		"The config it replaces is backed up first; Restore Config brings it back.":                                                                                 "Antes se guarda una copia de la configuración que reemplaza; Restaurar configuración la recupera.",
+		"Looks for options the installed niri has deprecated and says what to use instead.":                                                                         "Busca opciones que el niri instalado considera obsoletas y dice qué usar en su lugar.",
+		"Shows what changed between two config backups.":                                                                                                            "Muestra qué cambió entre dos copias de la configuración.",
+		"Makes config.kdl a link to one of the profiles in ~/.config/nirisetup/profiles, or saves it as a new one.":                                                 "Convierte config.kdl en un enlace a uno de los perfiles de ~/.config/nirisetup/profiles, o lo guarda como uno nuevo.",
+		"Switch back; a config that is not a profile yet is backed up first.":                                                                                       "Vuelve a cambiar; antes se guarda una copia de una configuración que aún no sea un perfil.",
+		"Checks the niri version, memory, session and what niri needs to start.":                                                                                    "Comprueba la versión de niri, la memoria, la sesión y lo que niri necesita para arrancar.",
+		"Checks the config, the runtime directory and seatd, then runs niri on this terminal or starts it in the background.":                                       "Comprueba la configuración, el directorio de ejecución y seatd, luego ejecuta niri en este terminal o lo inicia en segundo plano.",
+		"Quit niri with its quit bind.":                                                                                                                             "Sal de niri con su atajo de salida.",
+		"Writes ~/.config/niri/start.sh and an env file for starting niri from a console.":                                                                          "Escribe ~/.config/niri/start.sh y un archivo de entorno para iniciar niri desde una consola.",
+		"Delete the files it reports.":                                                                                                                              "Borra los archivos que indica.",
+		"Makes logging in on the first console start niri, from your shell's login file.":                                                                           "Hace que al iniciar sesión en la primera consola arranque niri, desde el archivo de inicio de tu shell.",
+		"Delete the block it adds to ~/.profile or ~/.login.":                                                                                                       "Borra el bloque que añade a ~/.profile o ~/.login.",
+		"Installs and enables SDDM, ly or GDM and adds a niri session to it.":                                                                                       "Instala y activa SDDM, ly o GDM y le añade una sesión de niri.",
+		"Disable the service with sysrc and remove the package.":                                                                                                    "Desactiva el servicio con sysrc y elimina el paquete.",
+		"Lists the installed packages NiriSetup manages; pick one to remove it.":                                                                                    "Lista los paquetes instalados que gestiona NiriSetup; elige uno para eliminarlo.",
+		"Install it again with Install Niri.":                                                                                                                       "Vuelve a instalarlo con Instalar Niri.",
+		"Runs pkg upgrade on the packages NiriSetup manages and shows which versions changed.":                                                                      "Ejecuta pkg upgrade sobre los paquetes que gestiona NiriSetup y muestra qué versiones cambiaron.",
+		"pkg cannot go back; older packages would have to be added by hand.":                                                                                        "pkg no puede volver atrás; habría que añadir a mano los paquetes anteriores.",
+		"Checks the checksums and dependencies of the managed packages and offers to reinstall damaged ones.":                                                       "Comprueba las sumas de verificación y dependencias de los paquetes gestionados y ofrece reinstalar los dañados.",
+		"Installs the packages that are missing, reinstalls damaged ones, writes a missing config and replaces one niri rejects; what already works is left alone.": "Instala los paquetes que faltan, reinstala los dañados, escribe una configuración que falte y reemplaza la que niri rechace; lo que ya funciona se deja como está.",
+		"Uninstall Niri removes what it installed; a replaced config.kdl is backed up first.":                                                                       "Desinstalar Niri elimina lo que instaló; antes de reemplazar config.kdl se hace una copia de seguridad.",
+		"Repair the niri setup?\n\nMissing packages are installed and damaged ones reinstalled. A missing niri config is written, and one niri rejects is replaced by its newest backup that validates, or the default config. Missing waybar and mako configs are written too. Nothing that works is changed.": "¿Reparar la instalación de niri?\n\nSe instalan los paquetes que faltan y se reinstalan los dañados. Se escribe la configuración de niri si falta, y la que niri rechace se reemplaza por su copia de seguridad válida más reciente o por la configuración predeterminada. También se escriben las configuraciones de waybar y mako que falten. No se cambia nada que funcione.",
+		"Repairing the niri setup...":                     "Reparando la instalación de niri...",
+		"Reinstalling only puts back the packaged files.": "Reinstalar solo repone los archivos del paquete.",
+		"Lists the running programs NiriSetup installs, such as waybar or mako; pick one to restart or kill it.":                "Lista los programas en marcha que instala NiriSetup, como waybar o mako; elige uno para reiniciarlo o terminarlo.",
+		"Start a killed program again from a terminal or by restarting niri.":                                                   "Vuelve a iniciar un programa terminado desde un terminal o reiniciando niri.",
+		"Times the package mirrors and offers to point pkg at the fastest one.":                                                 "Mide los espejos de paquetes y ofrece configurar pkg con el más rápido.",
+		"Delete /usr/local/etc/pkg/repos/FreeBSD.conf to go back to the default repository.":                                    "Borra /usr/local/etc/pkg/repos/FreeBSD.conf para volver al repositorio por defecto.",
+		"Appends this session's log to the log file, nirisetup.log in the temporary directory unless --log-file names another.": "Añade el registro de esta sesión al archivo de registro, nirisetup.log en el directorio temporal salvo que --log-file indique otro.",
+		"Delete the log file.": "Borra el archivo de registro.",
+		"Writes a report with versions, checks, the config and logs, with personal details redacted.": "Escribe un informe con versiones, comprobaciones, la configuración y los registros, ocultando los datos personales.",
+		"Delete the report file.": "Borra el archivo del informe.",
+		"Changes NiriSetup's own preferences, saved in ~/.config/nirisetup/settings.": "Cambia las preferencias de NiriSetup, guardadas en ~/.config/nirisetup/settings.",
+		"Quits NiriSetup.":                                 "Sale de NiriSetup.",
+		"Pick the old value again.":                        "Vuelve a elegir el valor anterior.",
+		"%d of %d packages":                                "%d de %d paquetes",
+		"Could not write install summary: %s":              "No se pudo escribir el resumen de la instalación: %s",
+		"Failed to install %s":                             "No se pudo instalar %s",
+		"Installed %d packages.":                           "%d paquetes instalados.",
+		"Install finished":                                 "Instalación terminada",
+		"%d packages: %d installed, %d skipped, %d failed": "%d paquetes: %d instalados, %d omitidos, %d fallidos",
+		"Took %s.": "Tardó %s.",
+		"Next: Configure Niri writes a config, System Check checks the system, then Launch Niri starts it.": "Siguiente: Configurar Niri escribe una configuración, Comprobar el sistema revisa el sistema y luego Iniciar Niri lo arranca.",
+		"any key: back to the menu":               "cualquier tecla: volver al menú",
+		"Nothing can be installed yet:":           "Todavía no se puede instalar nada:",
+		"Installed %d of %d packages. Failed: %s": "Instalados %d de %d paquetes. Fallaron: %s",
+		"Skipping %s, already installed":          "Se omite %s, ya instalado",
+		"Already installed, skipped: %s":          "Ya instalados, omitidos: %s",
+		"Dry run, nothing was installed. The log shows the commands for the %d packages.":                                                 "Simulación, no se ha instalado nada. El registro muestra las órdenes para los %d paquetes.",
+		"(dry run) Nothing is installed.":                                                                                                 "(simulación) No se instala nada.",
+		"Privilege escalation failed — ensure your user can run sudo/doas (running sudo -v in this terminal first caches your password).": "Falló la elevación de privilegios: comprueba que tu usuario puede usar sudo/doas (ejecutar antes sudo -v en este terminal guarda tu contraseña).",
+		"Paused with %d packages left.":                                                                                                   "En pausa con %d paquetes pendientes.",
+		"Resuming the install...":                                                                                                         "Reanudando la instalación...",
+		"Retrying %d packages: %s":                                                                                                        "Reintentando %d paquetes: %s",
+		"Retried the failed packages: %s":                                                                                                 "Se reintentaron los paquetes fallidos: %s",
+		"%d packages are not installed: %s. Retry Failed Packages tries them again.":                                                      "%d paquetes no están instalados: %s. Reintentar paquetes fallidos vuelve a intentarlo.",
+		"%d packages are not installed: %s\n\n[r] Retry them   [esc] Back to the menu":                                                    "%d paquetes no están instalados: %s\n\n[r] Reintentarlos   [esc] Volver al menú",
+		"Error: %s": "Error: %s",
+		"XDG_RUNTIME_DIR is not ready, so niri cannot start yet.":                                 "XDG_RUNTIME_DIR no está listo, así que niri aún no puede arrancar.",
+		"The other entries still work; Launch Niri and System Check will fail until it is fixed.": "Las demás entradas siguen funcionando; Iniciar Niri y Comprobar el sistema fallarán hasta que se corrija.",
+		"Niri configuration completed successfully.":                                              "La configuración de Niri se completó correctamente.",
+		"Auto-accepted: %s":     "Aceptado automáticamente: %s",
+		"Edit rejected: %s":     "Edición rechazada: %s",
+		"Validation failed: %s": "La validación falló: %s",
+		"niri is not installed, so there is nothing to validate with. Run Install Niri first.":                                                                                                   "niri no está instalado, así que no hay con qué validar. Ejecuta primero Instalar Niri.",
+		"There is no niri config at %s yet. Configure Niri writes one.":                                                                                                                          "Todavía no hay configuración de niri en %s. Configurar Niri escribe una.",
+		"Unknown --log-level %s, want error, warn, info or debug.":                                                                                                                               "Valor de --log-level desconocido: %s; usa error, warn, info o debug.",
+		"NiriSetup only supports FreeBSD, not %s. --force starts it anyway, for experimenting.":                                                                                                  "NiriSetup solo es compatible con FreeBSD, no con %s. --force lo inicia de todos modos, para experimentar.",
+		"Warning: NiriSetup is running as root, so the niri config and session are root's, not %s's. Run it as %s instead; it uses sudo or doas itself where it needs root.":                     "Advertencia: NiriSetup se está ejecutando como root, así que la configuración y la sesión de niri son de root, no de %s. Ejecútelo como %s; usa sudo o doas por su cuenta donde necesita root.",
+		"Warning: NiriSetup is running as root, so the niri config and session are root's. Run it as the user who will log in to niri instead; it uses sudo or doas itself where it needs root.": "Advertencia: NiriSetup se está ejecutando como root, así que la configuración y la sesión de niri son de root. Ejecútelo como el usuario que iniciará sesión en niri; usa sudo o doas por su cuenta donde necesita root.",
+		"Unknown --privilege-tool %s, want doas or sudo.":                                                                                                                                        "--privilege-tool %s desconocido, se espera doas o sudo.",
+		"Unknown --headless %s, want install or configure.":                                                                                                                                      "--headless %s desconocido, se espera install o configure.",
+		"Another NiriSetup (pid %d) is running; try again once it exits.":                                                                                                                        "Otro NiriSetup (pid %d) está en ejecución; inténtalo de nuevo cuando termine.",
+		"Niri configuration is valid.":                                        "La configuración de Niri es válida.",
+		"--json only works with --status.":                                    "--json solo funciona con --status.",
+		"--json-logs writes JSON to stdout; redirect it to a file or a pipe.": "--json-logs escribe JSON en la salida estándar; redirígela a un archivo o a una tubería.",
+		"--json-logs needs a terminal to draw on: %s":                         "--json-logs necesita un terminal en el que dibujar: %s",
+		"The setup is complete.":                                              "La configuración del sistema está completa.",
+		"The setup is incomplete.":                                            "La configuración del sistema está incompleta.",
+		"Niri configuration is valid, nothing to repair.":                     "La configuración de Niri es válida, no hay nada que reparar.",
+		"No deprecated options for niri %s.":                                  "No hay opciones obsoletas para niri %s.",
+		"Options to migrate for niri %s:":                                     "Opciones que migrar para niri %s:",
+		"Saved %s\nNiri configuration is valid.":                              "%s guardado\nLa configuración de Niri es válida.",
+		"pkg now fetches from %s":                                             "pkg ahora descarga desde %s",
+		"Failed to write to log file":                                         "No se pudo escribir en el archivo de registro",
+		"Logs saved to %s":                                                    "Registros guardados en %s",
+		"(%d earlier log lines are in %s)":                                    "(%d líneas anteriores del registro están en %s)",
+		"(%d earlier log lines dropped: %s)":                                  "(%d líneas anteriores del registro descartadas: %s)",
+		"Dry run, nothing was changed.":                                       "Simulación, no se ha cambiado nada.",
+		"None of the packages NiriSetup manages are installed.":               "No está instalado ninguno de los paquetes que gestiona NiriSetup.",
+		"Removed %s": "%s eliminado",
+		"Log in on a console and run %s to start niri.":     "Inicie sesión en una consola y ejecute %s para iniciar niri.",
+		"All managed packages passed.":                      "Todos los paquetes gestionados pasaron la verificación.",
+		"Comparing needs at least two config backups.":      "Para comparar hacen falta al menos dos copias de seguridad.",
+		"The two backups are identical.":                    "Las dos copias de seguridad son idénticas.",
+		"Wrote %s\nReview it, then run it to install Niri.": "%s escrito\nRevíselo y ejecútelo para instalar Niri.",
+		"It would change nothing.":                          "No cambiaría nada.",
+		"Last lines of %s:\n%s":                             "Últimas líneas de %s:\n%s",
+	},
+}
+
+// tr returns the translation of s for the current locale, or s itself if
+// there is none.
+func tr(s string) string {
+	if t, ok := catalogs[locale][s]; ok {
+		return t
+	}
+	return s
+}
+
+// trf translates format and then formats it like fmt.Sprintf.
+func trf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// detectLocale picks the UI language from the standard locale variables,
+// in the order POSIX gives them precedence. Languages without a catalog
+// fall back to English.
+func detectLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		lang, _, _ := strings.Cut(v, "_")
+		lang, _, _ = strings.Cut(lang, ".")
+		if _, ok := catalogs[lang]; ok {
+			return lang
+		}
+		return "en"
+	}
+	return "en"
+}
@@ -0,0 +1,144 @@
+// Package nirisetup installs, configures and validates niri on FreeBSD for
+// other Go programs. It is what the NiriSetup TUI runs for Install Niri,
+// Configure Niri and Validate Config, and at startup, without the TUI:
+// every step returns its result and an error instead of a message for the
+// screen.
+package nirisetup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+
+	"NiriSetup/internal/niri"
+)
+
+// Packages are the packages Install Niri installs by default: niri and the
+// tools of a complete desktop around it.
+func Packages() []string {
+	return slices.Clone(niri.DefaultPackages)
+}
+
+// InstallResult is what Install did with each package it was given.
+type InstallResult struct {
+	Installed []string // installed now
+	Present   []string // already installed, so skipped
+	Failed    []string // failed, or not tried after a cancel or a privilege failure
+}
+
+// Install installs pkgs with pkg, through sudo or doas, and records the
+// ones it installed so an uninstall can remove them again. done, if not
+// nil, is called after each package as it finishes. A package that fails
+// does not stop the others; the error lists every failure.
+func Install(pkgs []string, done func(pkg string, skipped bool, err error)) (InstallResult, error) {
+	return InstallContext(context.Background(), pkgs, done)
+}
+
+// InstallContext is Install with what ctx carries for NiriSetup's own
+// install screen: where pkg's output and progress go while it installs.
+func InstallContext(ctx context.Context, pkgs []string, done func(pkg string, skipped bool, err error)) (InstallResult, error) {
+	var r InstallResult
+	err := niri.InstallPackages(ctx, pkgs, func(pkg string, skipped bool, err error) {
+		switch {
+		case err != nil:
+			r.Failed = append(r.Failed, pkg)
+		case skipped:
+			r.Present = append(r.Present, pkg)
+		default:
+			r.Installed = append(r.Installed, pkg)
+		}
+		if done != nil {
+			done(pkg, skipped, err)
+		}
+	})
+	if err != nil {
+		for _, pkg := range pkgs {
+			if !slices.Contains(r.Installed, pkg) && !slices.Contains(r.Present, pkg) && !slices.Contains(r.Failed, pkg) {
+				r.Failed = append(r.Failed, pkg)
+			}
+		}
+	}
+	return r, err
+}
+
+// Configure writes the starter niri config when there is none, merges in
+// the user's snippets and writes the starter configs of tools, such as
+// "waybar" or "mako". An existing config is kept, and one niri rejects is
+// put back as it was. It reports each step.
+func Configure(tools ...string) ([]string, error) {
+	return niri.Configure(context.Background(), tools...)
+}
+
+// Toolset is the terminal, launcher and bar the starter config starts,
+// each a Program: the package it comes in and the command niri runs.
+type (
+	Toolset = niri.Toolset
+	Program = niri.Program
+)
+
+// Terminals, Launchers and Bars are the programs a Toolset is usually
+// made of; DefaultToolset takes the first of each.
+var (
+	Terminals      = niri.StarterTerminals
+	Launchers      = niri.StarterLaunchers
+	Bars           = niri.StarterBars
+	DefaultToolset = niri.DefaultToolset
+)
+
+// ConfigureWith is Configure with a starter config that starts the
+// programs of t, warning about the ones that are not installed.
+func ConfigureWith(t Toolset, tools ...string) ([]string, error) {
+	return niri.ConfigureWith(context.Background(), t, tools...)
+}
+
+// Repair fixes what is missing or broken of a setup with the packages
+// pkgs: it installs the missing ones, reinstalls damaged ones and writes
+// or replaces a niri config that is missing or rejected, leaving what
+// works alone. It reports each fix; the error names the steps that failed.
+func Repair(pkgs []string) ([]string, error) {
+	return niri.Repair(context.Background(), pkgs)
+}
+
+// ErrNoNiri means niri is not installed, so there is nothing to validate
+// the config with.
+var ErrNoNiri = errors.New("niri is not installed")
+
+// ErrNoConfig means there is no niri config to validate yet.
+var ErrNoConfig = errors.New("there is no niri config")
+
+// Validate runs niri validate on the active config and returns what it
+// printed. The error is ErrNoNiri or ErrNoConfig, wrapped, when there is
+// nothing to validate, and niri's own when it rejects the config.
+func Validate() (string, error) {
+	if _, err := exec.LookPath("niri"); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNoNiri, err)
+	}
+	if _, err := os.Stat(niri.ConfigPath()); os.IsNotExist(err) {
+		return "", fmt.Errorf("%w at %s", ErrNoConfig, niri.ConfigPath())
+	}
+	return niri.ValidateConfig()
+}
+
+// ConfigPath is the niri config Configure writes and Validate checks.
+func ConfigPath() string {
+	return niri.ConfigPath()
+}
+
+// Environment is the XDG_RUNTIME_DIR SetUpEnvironment prepared.
+type Environment struct {
+	RuntimeDir string   // the directory XDG_RUNTIME_DIR is now set to
+	Chosen     string   // which directory that is and why
+	Report     []string // what it fixed or replaced
+}
+
+// SetUpEnvironment prepares XDG_RUNTIME_DIR for niri and sets it in this
+// process's environment, which the niri it starts inherits: it is created
+// with mode 0700 when missing, and one that is not the user's own is
+// replaced.
+func SetUpEnvironment() (Environment, error) {
+	chosen, report, err := niri.SetUpRuntimeDir(context.Background())
+	return Environment{RuntimeDir: os.Getenv("XDG_RUNTIME_DIR"), Chosen: chosen, Report: report}, err
+}
@@ -0,0 +1,43 @@
+package nirisetup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	if _, err := Validate(); !errors.Is(err, ErrNoNiri) {
+		t.Errorf("Validate without niri returned %v, want ErrNoNiri", err)
+	}
+
+	bin := t.TempDir()
+	fake := "#!/bin/sh\nif grep -q broken \"$XDG_CONFIG_HOME/niri/config.kdl\"; then echo 'unknown node broken'; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "niri"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/bin:/usr/bin")
+	if _, err := Validate(); !errors.Is(err, ErrNoConfig) {
+		t.Errorf("Validate without a config returned %v, want ErrNoConfig", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigPath(), []byte("prefer-no-csd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Validate(); err != nil {
+		t.Errorf("Validate of a good config returned %v", err)
+	}
+	if err := os.WriteFile(ConfigPath(), []byte("broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Validate()
+	if err == nil || errors.Is(err, ErrNoNiri) || errors.Is(err, ErrNoConfig) || out != "unknown node broken\n" {
+		t.Errorf("Validate of a rejected config returned %q, %v, want niri's output and error", out, err)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"NiriSetup/internal/niri"
+)
+
+// mirrorsMsg carries the result of timing the pkg mirrors.
+type mirrorsMsg struct {
+	timings []niri.MirrorTiming
+	err     error
+}
+
+// packagesMsg carries the managed packages that are installed.
+type packagesMsg struct {
+	pkgs []niri.InstalledPackage
+	err  error
+}
+
+// removePackageMsg asks to confirm removing a package picked in Manage
+// Packages.
+type removePackageMsg string
+
+// setupPackagesMsg carries the packages NiriSetup installed, for Uninstall
+// Niri to confirm removing.
+type setupPackagesMsg struct {
+	pkgs []string
+	err  error
+}
+
+// processesMsg carries the running processes Manage Processes offers.
+type processesMsg struct {
+	procs []niri.Process
+	err   error
+}
+
+// processPickedMsg asks what to do with a process picked in Manage
+// Processes.
+type processPickedMsg niri.Process
+
+// integrityMsg carries the outcome of checking the managed packages, and
+// the packages found damaged.
+type integrityMsg struct {
+	checks  []niri.Check
+	damaged []string
+	err     error
+}
+
+// upgradeMsg carries the packages an upgrade changed.
+type upgradeMsg struct {
+	changes []niri.VersionChange
+	err     error
+}
+
+// updatePackages handles the messages of managing, verifying and upgrading
+// the installed packages, the processes they run and the pkg mirrors.
+func (m model) updatePackages(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case mirrorsMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		ranking := []string{tr("pkg mirrors, fastest first:")}
+		for _, t := range msg.timings {
+			if t.Err != nil {
+				ranking = append(ranking, fmt.Sprintf("  failed   %s (%v)", t.URL, t.Err))
+			} else {
+				ranking = append(ranking, fmt.Sprintf("  %6dms %s", t.Latency.Milliseconds(), t.URL))
+			}
+		}
+		report := strings.Join(ranking, "\n")
+		m.log(report)
+		fastest := msg.timings[0]
+		if fastest.Err != nil {
+			return m.Update(statusMsg{status: report + "\n" + tr("No mirror responded."), err: fastest.Err})
+		}
+		return m.ask(confirmation{
+			question: trf("%s\n\nUse %s for pkg?", report, fastest.URL),
+			working:  tr("Updating pkg configuration..."),
+			declined: report,
+			run:      m.cmds.useMirror(m.ctx, fastest.URL),
+		})
+	case packagesMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if len(msg.pkgs) == 0 {
+			return m.Update(statusMsg{status: tr("None of the packages NiriSetup manages are installed.")})
+		}
+		m.state = choiceView
+		m.choice = choice{question: tr("Installed packages managed by NiriSetup.\nPick one to remove it.")}
+		for _, p := range msg.pkgs {
+			pkg := p.Name
+			m.choice.options = append(m.choice.options, option{
+				label: fmt.Sprintf("%-20s %-14s %s", p.Name, p.Version, p.Installed.Format(time.DateOnly)),
+				run:   func() tea.Msg { return removePackageMsg(pkg) },
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case removePackageMsg:
+		return m.ask(confirmation{
+			question:    trf("Remove %s?\n\nOther packages that depend on it may stop working.", string(msg)),
+			working:     trf("Removing %s...", string(msg)),
+			run:         m.cmds.remove(m.ctx, string(msg)),
+			destructive: true,
+		})
+	case setupPackagesMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if len(msg.pkgs) == 0 {
+			return m.Update(statusMsg{status: tr("NiriSetup has not installed any packages, so there is nothing to uninstall. Packages you had before are left alone.")})
+		}
+		return m.ask(confirmation{
+			question:    trf("Remove the %d packages NiriSetup installed?\n\n%s\n\nPackages you had before, and ones other packages still need, are kept.", len(msg.pkgs), strings.Join(msg.pkgs, ", ")),
+			working:     tr("Uninstalling Niri..."),
+			run:         m.cmds.uninstall(m.ctx, msg.pkgs),
+			destructive: true,
+		})
+	case processesMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		if len(msg.procs) == 0 {
+			return m.Update(statusMsg{status: tr("None of the programs NiriSetup installs is running.")})
+		}
+		m.state = choiceView
+		m.choice = choice{question: tr("Running programs installed by NiriSetup.\nPick one to restart or kill it.")}
+		for _, p := range msg.procs {
+			m.choice.options = append(m.choice.options, option{
+				label: fmt.Sprintf("%-20s %7d  %s", p.Name, p.PID, p.Command),
+				run:   func() tea.Msg { return processPickedMsg(p) },
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case processPickedMsg:
+		p := niri.Process(msg)
+		m.state = choiceView
+		m.choice = choice{
+			question: trf("What should happen to %s (pid %d)?", p.Name, p.PID),
+			options: []option{
+				{label: tr("Restart it"), working: trf("Restarting %s...", p.Name), run: m.cmds.restart(m.ctx, p)},
+				{label: tr("Kill it"), working: trf("Killing %s...", p.Name), run: m.cmds.kill(m.ctx, p)},
+				{label: tr("Back to the menu")},
+			},
+		}
+		return m, nil
+	case integrityMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		var lines []string
+		for _, c := range msg.checks {
+			lines = append(lines, c.String())
+		}
+		summary := strings.Join(lines, "\n")
+		if len(msg.damaged) == 0 {
+			return m.Update(statusMsg{status: summary + "\n" + tr("All managed packages passed.")})
+		}
+		m.log(summary)
+		return m.ask(confirmation{
+			question: trf("%s\n\nReinstall %s?", summary, strings.Join(msg.damaged, ", ")),
+			working:  tr("Reinstalling packages..."),
+			declined: summary,
+			run:      m.cmds.reinstall(m.ctx, msg.damaged),
+		})
+	case upgradeMsg:
+		m.actionOutput = nil
+		if len(msg.changes) == 0 {
+			if msg.err != nil {
+				return m.Update(reportMsg(nil, msg.err))
+			}
+			return m.Update(statusMsg{status: tr("Everything was already up to date.")})
+		}
+		// Logged so Save Logs keeps what the upgrade did
+		table := versionTable(msg.changes)
+		m.log(table)
+		if msg.err != nil {
+			return m.Update(reportMsg([]string{table}, msg.err))
+		}
+		return m.Update(textMsg{title: trf("What changed: %d packages", len(msg.changes)), text: table})
+	}
+	return m, nil
+}
+
+func listPackages() tea.Cmd {
+	return func() tea.Msg {
+		pkgs, err := niri.InstalledPackages()
+		return packagesMsg{pkgs: pkgs, err: err}
+	}
+}
+
+func listSetupPackages() tea.Cmd {
+	return func() tea.Msg {
+		pkgs, err := niri.SetupPackages()
+		return setupPackagesMsg{pkgs: pkgs, err: err}
+	}
+}
+
+func uninstallNiri(ctx context.Context, pkgs []string) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.Uninstall(ctx, pkgs))
+	}
+}
+
+func listProcesses() tea.Cmd {
+	return func() tea.Msg {
+		procs, err := niri.SessionProcesses()
+		return processesMsg{procs: procs, err: err}
+	}
+}
+
+func killProcess(ctx context.Context, p niri.Process) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.KillProcess(ctx, p))
+	}
+}
+
+func restartProcess(ctx context.Context, p niri.Process) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.RestartProcess(ctx, p))
+	}
+}
+
+func removePackage(ctx context.Context, pkg string) tea.Cmd {
+	return func() tea.Msg {
+		if err := niri.RemovePackage(ctx, pkg); err != nil {
+			return reportMsg(nil, err)
+		}
+		return statusMsg{status: trf("Removed %s", pkg)}
+	}
+}
+
+func verifyPackages() tea.Cmd {
+	return func() tea.Msg {
+		checks, damaged, err := niri.VerifyPackages()
+		return integrityMsg{checks: checks, damaged: damaged, err: err}
+	}
+}
+
+// upgradePackages upgrades the managed packages in the background,
+// streaming what pkg prints as progressMsgs and finishing with an
+// upgradeMsg.
+func upgradePackages(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		updates := make(chan tea.Msg)
+		go func() {
+			o := niri.OptionsFrom(ctx)
+			o.Output = func(line string) { updates <- progressMsg{line: line} }
+			changes, err := niri.UpgradePackages(niri.WithOptions(ctx, o))
+			updates <- upgradeMsg{changes: changes, err: err}
+		}()
+		return listen(updates)()
+	}
+}
+
+// versionTable lines up the old and new version of each changed package.
+func versionTable(changes []niri.VersionChange) string {
+	width := 0
+	for _, c := range changes {
+		width = max(width, len(c.Package))
+	}
+	lines := []string{tr("What changed:")}
+	for _, c := range changes {
+		from, to := c.Old, c.New
+		if from == "" {
+			from = tr("(new)")
+		}
+		if to == "" {
+			to = tr("(removed)")
+		}
+		lines = append(lines, fmt.Sprintf("  %-*s  %s → %s", width, c.Package, from, to))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func reinstallPackages(ctx context.Context, pkgs []string) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.ReinstallPackages(ctx, pkgs))
+	}
+}
+
+// repairSetup runs Repair on the packages Install Niri installs.
+func repairSetup(ctx context.Context) tea.Cmd {
+	return func() tea.Msg {
+		pkgs, _, err := niri.PackageList()
+		if err != nil {
+			return reportMsg(nil, err)
+		}
+		return reportMsg(niri.Repair(ctx, pkgs))
+	}
+}
+
+func benchmarkMirrors() tea.Cmd {
+	return func() tea.Msg {
+		timings, err := niri.BenchmarkMirrors()
+		return mirrorsMsg{timings: timings, err: err}
+	}
+}
+
+func useMirror(ctx context.Context, url string) tea.Cmd {
+	return func() tea.Msg {
+		if err := niri.UseMirror(ctx, url); err != nil {
+			return reportMsg(nil, err)
+		}
+		return statusMsg{status: trf("pkg now fetches from %s", url)}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// preference is one of NiriSetup's own settings. The Settings screen steps
+// through its values, the settings file keeps the one picked there, and
+// the flag of the same name overrides it for a run.
+type preference struct {
+	name   string   // the flag and the key in the settings file
+	label  string   // what the Settings screen calls it
+	values []string // the values it steps through, the default first
+	get    func(m *model) string
+	set    func(m *model, value string)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// preferences are the settings the Settings screen offers, in its order.
+var preferences = []preference{
+	{
+		name: "dry-run", label: "Dry run: show changes instead of making them", values: []string{"off", "on"},
+		get: func(m *model) string { return onOff(m.dryRun) },
+		set: func(m *model, v string) { m.dryRun = v == "on" },
+	},
+	{
+		name: "yes", label: "Answer yes to every confirmation", values: []string{"off", "on"},
+		get: func(m *model) string { return onOff(m.autoYes) },
+		set: func(m *model, v string) { m.autoYes = v == "on" },
+	},
+	{
+		name: "force", label: "With yes, accept destructive confirmations too", values: []string{"off", "on"},
+		get: func(m *model) string { return onOff(m.force) },
+		set: func(m *model, v string) { m.force = v == "on" },
+	},
+	{
+		name: "log-level", label: "What to log", values: []string{"info", "warn", "error", "debug"},
+		get: func(*model) string { return verbosity.String() },
+		set: func(_ *model, v string) {
+			verbosity, _ = parseLogLevel(v)
+			debug = verbosity == levelDebug
+		},
+	},
+	{
+		name: "color", label: "Colors", values: []string{"auto", "off"},
+		get: func(*model) string { return colorMode },
+		set: func(_ *model, v string) { setColorMode(v) },
+	},
+}
+
+// colorMode is the color preference: "auto" uses colors when the terminal
+// has them, "off" never does.
+var colorMode = "auto"
+
+// terminalColors is the color profile lipgloss detected at startup, for
+// turning colors back on.
+var terminalColors termenv.Profile
+
+func setColorMode(mode string) {
+	colorMode = mode
+	if mode == "off" || plainOutput() {
+		// Every style renders through lipgloss, so this covers all views
+		lipgloss.SetColorProfile(termenv.Ascii)
+	} else {
+		lipgloss.SetColorProfile(terminalColors)
+	}
+}
+
+// applySettings sets the preferences in saved on m, except those given as
+// flags, which win. It returns a warning for each value it does not know.
+func applySettings(m *model, saved map[string]string, flags map[string]bool) []string {
+	var warnings []string
+	for _, p := range preferences {
+		v, ok := saved[p.name]
+		switch {
+		case !ok || flags[p.name]:
+		case !slices.Contains(p.values, v):
+			warnings = append(warnings, trf("Warning: ignoring %s = %s in the settings file, it takes %v", p.name, v, p.values))
+		default:
+			p.set(m, v)
+		}
+	}
+	return warnings
+}
+
+// settingPickedMsg steps the preference at index to its next value.
+type settingPickedMsg int
+
+// settingsChoice is the Settings screen, with the cursor on option cursor.
+func (m model) settingsChoice(cursor int) choice {
+	c := choice{question: tr("NiriSetup settings. Pick one to change it; it is saved right away."), cursor: cursor}
+	for i, p := range preferences {
+		c.options = append(c.options, option{
+			label: fmt.Sprintf("%-48s %s", tr(p.label), p.get(&m)),
+			run:   func() tea.Msg { return settingPickedMsg(i) },
+		})
+	}
+	c.options = append(c.options, option{label: tr("Back to the menu")})
+	return c
+}
+
+// stepSetting moves preference i to its next value, saves it and shows the
+// Settings screen again.
+func (m model) stepSetting(i int) (tea.Model, tea.Cmd) {
+	p := preferences[i]
+	next := p.values[(slices.Index(p.values, p.get(&m))+1)%len(p.values)]
+	p.set(&m, next)
+	if m.saved == nil {
+		m.saved = map[string]string{}
+	}
+	m.saved[p.name] = next
+	m.log(trf("Set %s to %s", p.name, next))
+	if err := m.cmds.saveSettings(m.saved); err != nil {
+		return m.Update(reportMsg(nil, fmt.Errorf("%s: %w", tr("Failed to save settings"), err)))
+	}
+	m.state = choiceView
+	m.choice = m.settingsChoice(i)
+	return m, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"NiriSetup/internal/niri"
+)
+
+// profilesMsg carries the config profiles there are to switch to.
+type profilesMsg struct {
+	names []string
+	err   error
+}
+
+// newProfileMsg asks for the name of a profile to save the config as.
+type newProfileMsg struct{}
+
+// saveProfileMsg saves the current config as the named profile.
+type saveProfileMsg string
+
+// profileSwitchedMsg reports switching to the profile name.
+type profileSwitchedMsg struct {
+	name   string
+	report []string
+	err    error
+}
+
+// updateProfiles handles the messages of Switch Profile.
+func (m model) updateProfiles(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case profilesMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		m.state = choiceView
+		m.choice = choice{question: trf("Which profile should niri use? Profiles live in %s.", niri.ProfilesDir())}
+		for _, name := range msg.names {
+			label := name
+			if name == m.profile {
+				label = trf("%s (active)", name)
+			}
+			m.choice.options = append(m.choice.options, option{
+				label:   label,
+				working: trf("Switching to %s...", name),
+				run:     m.cmds.useProfile(m.ctx, name),
+			})
+		}
+		m.choice.options = append(m.choice.options,
+			option{label: tr("Save the current config as a new profile"), run: func() tea.Msg { return newProfileMsg{} }},
+			option{label: tr("Back to the menu")},
+		)
+		return m, nil
+	case newProfileMsg:
+		return m.askText(tr("Name for the new profile:"), "work", func(name string) tea.Msg { return saveProfileMsg(name) })
+	case saveProfileMsg:
+		m.state = actionView
+		m.actionMsg = trf("Saving profile %s...", string(msg))
+		return m, m.cmds.saveProfile(m.ctx, string(msg))
+	case profileSwitchedMsg:
+		if msg.err == nil {
+			m.profile = msg.name
+		}
+		return m.Update(reportMsg(msg.report, msg.err))
+	}
+	return m, nil
+}
+
+func listProfiles() tea.Cmd {
+	return func() tea.Msg {
+		names, err := niri.Profiles()
+		return profilesMsg{names: names, err: err}
+	}
+}
+
+func switchProfile(ctx context.Context, name string) tea.Cmd {
+	return func() tea.Msg {
+		report, err := niri.SwitchProfile(ctx, name)
+		return profileSwitchedMsg{name: name, report: report, err: err}
+	}
+}
+
+func saveProfile(ctx context.Context, name string) tea.Cmd {
+	return func() tea.Msg {
+		report, err := niri.SaveProfile(ctx, name)
+		return profileSwitchedMsg{name: name, report: report, err: err}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"NiriSetup/internal/niri"
+)
+
+// windowRulesMsg carries the window rules in the config.
+type windowRulesMsg struct {
+	rules []niri.WindowRule
+	err   error
+}
+
+// windowRuleFieldMsg is what a new window rule matches on: app-id or title.
+type windowRuleFieldMsg string
+
+// windowRuleMatchMsg is the match of a new window rule, before its
+// property is picked.
+type windowRuleMatchMsg struct {
+	field, pattern string
+}
+
+// workspaceAppsMsg carries the apps the config opens on a workspace.
+type workspaceAppsMsg struct {
+	apps []niri.WorkspaceApp
+	err  error
+}
+
+// workspaceAppCommandMsg asks for the command of an app to open on a
+// workspace.
+type workspaceAppCommandMsg struct{}
+
+// workspaceAppIDMsg asks for the app ID of the windows command opens.
+type workspaceAppIDMsg struct {
+	command string
+}
+
+// workspaceAppMsg is an app to open on a workspace, before the workspace
+// is asked for. problem says what was wrong with the last answer.
+type workspaceAppMsg struct {
+	app     niri.WorkspaceApp
+	problem string
+}
+
+// workspaceAppTargetMsg is an app with the workspace it should open on.
+type workspaceAppTargetMsg struct {
+	app niri.WorkspaceApp
+}
+
+// workspaceAppPickedMsg offers to move or remove an app opened on a
+// workspace.
+type workspaceAppPickedMsg struct {
+	app niri.WorkspaceApp
+}
+
+// removeWindowRuleMsg asks to confirm removing a window rule.
+type removeWindowRuleMsg struct {
+	rule niri.WindowRule
+}
+
+// updateRules handles the messages of Configure Window Rules and Configure
+// Workspace Apps.
+func (m model) updateRules(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case windowRulesMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		m.state = choiceView
+		m.choice = choice{question: trf("%d window rules. Add one, or pick one to remove:", len(msg.rules))}
+		for _, field := range niri.WindowRuleFields {
+			m.choice.options = append(m.choice.options, option{
+				label: trf("Add a rule matching the %s", field),
+				run:   func() tea.Msg { return windowRuleFieldMsg(field) },
+			})
+		}
+		for _, rule := range msg.rules {
+			m.choice.options = append(m.choice.options, option{
+				label: trf("Remove: %s", rule),
+				run:   func() tea.Msg { return removeWindowRuleMsg{rule} },
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case workspaceAppsMsg:
+		if msg.err != nil {
+			return m.Update(reportMsg(nil, msg.err))
+		}
+		m.state = choiceView
+		m.choice = choice{question: trf("%d apps open on a workspace at startup. Add one, or pick one to move or remove:", len(msg.apps)), options: []option{{
+			label: tr("Add an app"),
+			run:   func() tea.Msg { return workspaceAppCommandMsg{} },
+		}}}
+		for _, app := range msg.apps {
+			m.choice.options = append(m.choice.options, option{
+				label: app.String(),
+				run:   func() tea.Msg { return workspaceAppPickedMsg{app} },
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	case workspaceAppCommandMsg:
+		return m.askText(tr("Command that starts the app:"), "firefox", func(command string) tea.Msg {
+			return workspaceAppIDMsg{command: command}
+		})
+	case workspaceAppIDMsg:
+		var cmd tea.Cmd
+		m, cmd = m.askText(tr("App ID of its windows (niri msg windows shows it):"), "", func(appID string) tea.Msg {
+			return workspaceAppMsg{app: niri.WorkspaceApp{Command: msg.command, AppID: appID}}
+		})
+		m.prompt.field.SetValue(niri.DefaultAppID(msg.command))
+		return m, cmd
+	case workspaceAppTargetMsg:
+		m.state = actionView
+		m.actionMsg = tr("Updating niri config...")
+		return m, m.cmds.setWorkspace(m.ctx, msg.app)
+	case workspaceAppPickedMsg:
+		m.state = choiceView
+		m.choice = choice{question: msg.app.String(), options: []option{
+			{label: tr("Move it to another workspace"), run: func() tea.Msg { return workspaceAppMsg{app: msg.app} }},
+			{label: tr("Remove it"), working: tr("Updating niri config..."), run: m.cmds.dropWorkspace(m.ctx, msg.app)},
+			{label: tr("Back to the menu")},
+		}}
+		return m, nil
+	case workspaceAppMsg:
+		question := trf("Workspace to open %s on (1 is the first):", msg.app.AppID)
+		if msg.problem != "" {
+			question = msg.problem + "\n\n" + question
+		}
+		return m.askText(question, "1", func(answer string) tea.Msg {
+			n, err := strconv.Atoi(answer)
+			if err != nil || n < 1 {
+				return workspaceAppMsg{app: msg.app, problem: trf("%s is not a workspace number.", answer)}
+			}
+			app := msg.app
+			app.Workspace = n
+			return workspaceAppTargetMsg{app}
+		})
+	case removeWindowRuleMsg:
+		return m.ask(confirmation{
+			question: trf("Remove the window rule for %s?", msg.rule),
+			working:  tr("Updating niri config..."),
+			run:      m.cmds.removeRule(m.ctx, msg.rule),
+		})
+	case windowRuleFieldMsg:
+		field := string(msg)
+		return m.askText(trf("Regular expression the window's %s must match:", field), "^mpv$", func(pattern string) tea.Msg {
+			return windowRuleMatchMsg{field: field, pattern: pattern}
+		})
+	case windowRuleMatchMsg:
+		m.state = choiceView
+		m.choice = choice{question: trf("What should happen to windows whose %s matches %s?", msg.field, msg.pattern)}
+		for _, property := range niri.WindowRuleProperties {
+			m.choice.options = append(m.choice.options, option{
+				label:   property,
+				working: tr("Updating niri config..."),
+				run:     m.cmds.addRule(m.ctx, msg.field, msg.pattern, property),
+			})
+		}
+		m.choice.options = append(m.choice.options, option{label: tr("Back to the menu")})
+		return m, nil
+	}
+	return m, nil
+}
+
+func listWindowRules() tea.Cmd {
+	return func() tea.Msg {
+		rules, err := niri.WindowRules()
+		return windowRulesMsg{rules: rules, err: err}
+	}
+}
+
+func addWindowRule(ctx context.Context, field, pattern, property string) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.AddWindowRule(ctx, field, pattern, property))
+	}
+}
+
+func removeWindowRule(ctx context.Context, rule niri.WindowRule) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.RemoveWindowRule(ctx, rule))
+	}
+}
+
+func listWorkspaceApps() tea.Cmd {
+	return func() tea.Msg {
+		apps, err := niri.WorkspaceApps()
+		return workspaceAppsMsg{apps: apps, err: err}
+	}
+}
+
+func setWorkspaceApp(ctx context.Context, app niri.WorkspaceApp) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.SetWorkspaceApp(ctx, app.Command, app.AppID, app.Workspace))
+	}
+}
+
+func removeWorkspaceApp(ctx context.Context, app niri.WorkspaceApp) tea.Cmd {
+	return func() tea.Msg {
+		return reportMsg(niri.RemoveWorkspaceApp(ctx, app))
+	}
+}